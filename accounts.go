@@ -0,0 +1,177 @@
+package cowbull
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/Bo0mer/cowbull/protocol"
+	"golang.org/x/crypto/bcrypt"
+)
+
+//go:generate counterfeiter . AccountStore
+
+// Account is a registered player.
+type Account struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	// PasswordHash is the bcrypt hash of the password, if there is one.
+	PasswordHash string `json:"password_hash,omitempty"`
+	// TokenHash is the hex encoded SHA-256 of the login token.
+	TokenHash string    `json:"token_hash"`
+	Created   time.Time `json:"created"`
+}
+
+// ErrAccountNotFound is returned by an AccountStore asked for an account it
+// does not have.
+var ErrAccountNotFound = errors.New("account not found")
+
+// ErrUsernameTaken is returned by an AccountStore asked to create an account
+// with the username of an existing one.
+var ErrUsernameTaken = errors.New("username taken")
+
+// AccountStore keeps accounts.
+type AccountStore interface {
+	// CreateAccount creates an account, or returns ErrUsernameTaken.
+	CreateAccount(Account) error
+	// Account returns the account with a username, or ErrAccountNotFound.
+	Account(username string) (Account, error)
+	// AccountByToken returns the account with a token hash, or
+	// ErrAccountNotFound.
+	AccountByToken(tokenHash string) (Account, error)
+}
+
+// minPasswordLength is the length of the shortest password accepted.
+const minPasswordLength = 8
+
+// maxPasswordLength is the length in bytes of the longest password accepted,
+// as bcrypt hashes no more.
+const maxPasswordLength = 72
+
+// dummyHash is what Login compares passwords with when there is no account
+// hash to compare them with, so that it takes as long whether or not the
+// username exists.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("no password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+var validUsername = regexp.MustCompile(`^[A-Za-z0-9_-]{3,32}$`)
+
+// Accounts registers and authenticates players. Its errors are
+// *protocol.Error describing why an attempt failed.
+type Accounts struct {
+	store AccountStore
+}
+
+// NewAccounts creates Accounts keeping them in store.
+func NewAccounts(store AccountStore) *Accounts {
+	return &Accounts{store: store}
+}
+
+// Register creates an account. The password may be empty, in which case
+// the account can be logged in to with the returned token only.
+func (a *Accounts) Register(username, password string) (Account, string, error) {
+	if !validUsername.MatchString(username) {
+		return Account{}, "", protocol.Errorf(protocol.CodeInvalidAccount,
+			"username should have 3 to 32 letters, digits, underscores or dashes")
+	}
+	if password != "" && len(password) < minPasswordLength {
+		return Account{}, "", protocol.Errorf(protocol.CodeInvalidAccount,
+			"password should have at least %d characters", minPasswordLength)
+	}
+	if err := checkPasswordLength(password); err != nil {
+		return Account{}, "", err
+	}
+
+	acc := Account{
+		ID:       "u-" + randomString(12),
+		Username: username,
+		Created:  time.Now().UTC(),
+	}
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return Account{}, "", err
+		}
+		acc.PasswordHash = string(hash)
+	}
+	token := randomString(32)
+	acc.TokenHash = hashToken(token)
+
+	switch err := a.store.CreateAccount(acc); err {
+	case nil:
+		return acc, token, nil
+	case ErrUsernameTaken:
+		return Account{}, "", protocol.Errorf(protocol.CodeUsernameTaken, "username %q is taken", username)
+	default:
+		return Account{}, "", err
+	}
+}
+
+// Login authenticates a player with username and password.
+func (a *Accounts) Login(username, password string) (Account, error) {
+	if err := checkPasswordLength(password); err != nil {
+		return Account{}, err
+	}
+	acc, err := a.store.Account(username)
+	if err != nil && err != ErrAccountNotFound {
+		return Account{}, err
+	}
+	hash := []byte(acc.PasswordHash)
+	if acc.PasswordHash == "" {
+		hash = dummyHash()
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || acc.PasswordHash == "" {
+		return Account{}, authFailed()
+	}
+	return acc, nil
+}
+
+// LoginWithToken authenticates a player with the token given on
+// registration.
+func (a *Accounts) LoginWithToken(token string) (Account, error) {
+	acc, err := a.store.AccountByToken(hashToken(token))
+	if err == ErrAccountNotFound {
+		return Account{}, authFailed()
+	}
+	return acc, err
+}
+
+// checkPasswordLength fails with a *protocol.Error if password is longer
+// than bcrypt allows.
+func checkPasswordLength(password string) error {
+	if len(password) > maxPasswordLength {
+		return protocol.Errorf(protocol.CodeInvalidAccount,
+			"password should have at most %d bytes", maxPasswordLength)
+	}
+	return nil
+}
+
+// authFailed returns the error of a failed login, which does not tell what
+// was wrong.
+func authFailed() *protocol.Error {
+	return protocol.Errorf(protocol.CodeAuthFailed, "wrong username, password or token")
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomString returns n random bytes, URL-safe base64 encoded.
+func randomString(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("cowbull: no randomness: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,145 @@
+package cowbull_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Accounts", func() {
+	var store *cowbullfakes.FakeAccountStore
+	var accounts *Accounts
+
+	BeforeEach(func() {
+		store = new(cowbullfakes.FakeAccountStore)
+		accounts = NewAccounts(store)
+	})
+
+	Describe("Register", func() {
+		It("should create an account with a hashed password and token", func() {
+			acc, token, err := accounts.Register("alice", "secret-password")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token).NotTo(BeEmpty())
+			Expect(acc.ID).NotTo(BeEmpty())
+			Expect(acc.Username).To(Equal("alice"))
+			Expect(acc.PasswordHash).NotTo(ContainSubstring("secret-password"))
+			Expect(acc.TokenHash).NotTo(ContainSubstring(token))
+
+			Expect(store.CreateAccountCallCount()).To(Equal(1))
+			Expect(store.CreateAccountArgsForCall(0)).To(Equal(acc))
+		})
+
+		It("should create accounts without a password", func() {
+			acc, _, err := accounts.Register("alice", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(acc.PasswordHash).To(BeEmpty())
+		})
+
+		It("should reject invalid usernames and short passwords", func() {
+			_, _, err := accounts.Register("al", "secret-password")
+			Expect(code(err)).To(Equal(protocol.CodeInvalidAccount))
+			_, _, err = accounts.Register("alice smith", "secret-password")
+			Expect(code(err)).To(Equal(protocol.CodeInvalidAccount))
+			_, _, err = accounts.Register("alice", "short")
+			Expect(code(err)).To(Equal(protocol.CodeInvalidAccount))
+			Expect(store.CreateAccountCallCount()).To(BeZero())
+		})
+
+		It("should reject passwords longer than bcrypt hashes", func() {
+			_, _, err := accounts.Register("alice", strings.Repeat("x", 73))
+			Expect(code(err)).To(Equal(protocol.CodeInvalidAccount))
+			Expect(err).To(MatchError(ContainSubstring("at most 72 bytes")))
+			Expect(store.CreateAccountCallCount()).To(BeZero())
+
+			_, _, err = accounts.Register("alice", strings.Repeat("x", 72))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should reject a taken username", func() {
+			store.CreateAccountReturns(ErrUsernameTaken)
+			_, _, err := accounts.Register("alice", "")
+			Expect(code(err)).To(Equal(protocol.CodeUsernameTaken))
+		})
+
+		It("should fail when the store does", func() {
+			store.CreateAccountReturns(errors.New("disk on fire"))
+			_, _, err := accounts.Register("alice", "")
+			Expect(err).To(MatchError("disk on fire"))
+		})
+	})
+
+	Describe("logging in", func() {
+		var registered Account
+		var token string
+
+		BeforeEach(func() {
+			var err error
+			registered, token, err = accounts.Register("alice", "secret-password")
+			Expect(err).NotTo(HaveOccurred())
+			store.AccountStub = func(username string) (Account, error) {
+				if username == registered.Username {
+					return registered, nil
+				}
+				return Account{}, ErrAccountNotFound
+			}
+			store.AccountByTokenStub = func(tokenHash string) (Account, error) {
+				if tokenHash == registered.TokenHash {
+					return registered, nil
+				}
+				return Account{}, ErrAccountNotFound
+			}
+		})
+
+		It("should accept the password", func() {
+			acc, err := accounts.Login("alice", "secret-password")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(acc).To(Equal(registered))
+		})
+
+		It("should accept the token", func() {
+			acc, err := accounts.LoginWithToken(token)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(acc).To(Equal(registered))
+		})
+
+		It("should reject wrong credentials", func() {
+			_, err := accounts.Login("alice", "wrong-password")
+			Expect(code(err)).To(Equal(protocol.CodeAuthFailed))
+			_, err = accounts.Login("bob", "secret-password")
+			Expect(code(err)).To(Equal(protocol.CodeAuthFailed))
+			_, err = accounts.LoginWithToken("wrong-token")
+			Expect(code(err)).To(Equal(protocol.CodeAuthFailed))
+		})
+
+		It("should take as long for unknown usernames as for wrong passwords", func() {
+			start := time.Now()
+			_, err := accounts.Login("alice", "wrong-password")
+			Expect(code(err)).To(Equal(protocol.CodeAuthFailed))
+			wrongPassword := time.Since(start)
+
+			start = time.Now()
+			_, err = accounts.Login("bob", "wrong-password")
+			Expect(code(err)).To(Equal(protocol.CodeAuthFailed))
+			Expect(time.Since(start)).To(BeNumerically(">", wrongPassword/2))
+		})
+
+		It("should reject passwords longer than bcrypt hashes", func() {
+			_, err := accounts.Login("alice", strings.Repeat("x", 73))
+			Expect(code(err)).To(Equal(protocol.CodeInvalidAccount))
+			Expect(store.AccountCallCount()).To(BeZero())
+		})
+
+		It("should reject passwords of accounts without one", func() {
+			registered.PasswordHash = ""
+			_, err := accounts.Login("alice", "")
+			Expect(code(err)).To(Equal(protocol.CodeAuthFailed))
+		})
+	})
+})
@@ -0,0 +1,155 @@
+package cowbull
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+// ErrKicked ends the game of a player kicked by an admin.
+var ErrKicked = errors.New("cowbull: the player was kicked")
+
+// Status is what admins are shown of a server.
+type Status struct {
+	Started time.Time `json:"started"`
+	// Uptime is how many seconds ago the server started.
+	Uptime   float64 `json:"uptime"`
+	Draining bool    `json:"draining"`
+	// Players are the connected players, ordered by ID.
+	Players []PlayerEntry `json:"players"`
+	// Games are the games in progress, the oldest first.
+	Games []LiveGame `json:"games"`
+}
+
+// Status returns the status of the server.
+func (s *Server) Status() Status {
+	s.mu.Lock()
+	players := make([]PlayerEntry, 0, len(s.clients))
+	for _, p := range s.clients {
+		players = append(players, PlayerEntry{ID: p.ID(), Name: p.Name()})
+	}
+	s.mu.Unlock()
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].ID < players[j].ID
+	})
+	st := Status{
+		Started: s.started,
+		Uptime:  time.Since(s.started).Seconds(),
+		Players: players,
+		Games:   []LiveGame{},
+	}
+	if s.hub != nil {
+		st.Draining = s.hub.accepting() != nil
+		st.Games = s.hub.LiveGames()
+	}
+	return st
+}
+
+// Kick disconnects the player with an ID, telling it that it was kicked,
+// and aborts its game, if any, with ErrKicked. It reports whether the
+// player was connected.
+func (s *Server) Kick(id string) bool {
+	s.mu.Lock()
+	kicked := make(map[*Client]*RemotePlayer)
+	for c, p := range s.clients {
+		if p.ID() == id {
+			kicked[c] = p
+		}
+	}
+	s.mu.Unlock()
+	for c, p := range kicked {
+		s.clientLog(c, p).Info("kicking player")
+		if err := c.Kick(protocol.Errorf(protocol.CodeKicked, "kicked by an admin")); err != nil {
+			s.clientLog(c, p).Warn("error closing client", "err", err)
+		}
+		p.Abort(ErrKicked)
+	}
+	return len(kicked) > 0
+}
+
+// admin reports whether req comes from an admin, with the admin token as
+// a bearer token or as the token query parameter, and answers it otherwise.
+// Without an admin token there is no admin page.
+func (s *Server) admin(w http.ResponseWriter, req *http.Request) bool {
+	if s.adminToken == "" {
+		http.NotFound(w, req)
+		return false
+	}
+	token := req.URL.Query().Get("token")
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="cowbull admin"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// serveAdmin serves the status of the server as JSON on GET /admin.
+// Browsers asking for HTML are served the admin page instead, which asks
+// for the JSON in turn.
+func (s *Server) serveAdmin(w http.ResponseWriter, req *http.Request) {
+	if !s.admin(w, req) {
+		return
+	}
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if strings.Contains(req.Header.Get("Accept"), "text/html") {
+		page := req.Clone(req.Context())
+		page.URL.Path = "/admin.html"
+		s.fs.ServeHTTP(w, page)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Status()); err != nil {
+		s.log.Warn("error sending status", "err", err)
+	}
+}
+
+// serveKick kicks the player with the ID given by the player query
+// parameter on POST /admin/kick.
+func (s *Server) serveKick(w http.ResponseWriter, req *http.Request) {
+	s.serveAdminAction(w, req, "player", s.Kick)
+}
+
+// serveAbort aborts the game with the ID given by the game query parameter
+// on POST /admin/abort.
+func (s *Server) serveAbort(w http.ResponseWriter, req *http.Request) {
+	s.serveAdminAction(w, req, "game", s.hub.Abort)
+}
+
+// serveAdminAction acts on the ID given by the param query parameter,
+// answering 204 No Content if there is something with the ID, and 404 Not
+// Found otherwise.
+func (s *Server) serveAdminAction(w http.ResponseWriter, req *http.Request, param string, act func(id string) bool) {
+	if !s.admin(w, req) {
+		return
+	}
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := req.URL.Query().Get(param)
+	if id == "" {
+		http.Error(w, "missing "+param, http.StatusBadRequest)
+		return
+	}
+	if !act(id) {
+		http.NotFound(w, req)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,154 @@
+package cowbull_test
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+	"github.com/gorilla/websocket"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Admin", func() {
+	var hub *Hub
+	var server *Server
+
+	BeforeEach(func() {
+		logger := slog.New(slog.NewTextHandler(GinkgoWriter, nil))
+		gamer := new(cowbullfakes.FakeGamer)
+		gamer.GameStub = func(t game.Thinker, g game.Guesser) (*game.Game, error) {
+			return game.New(t, g), nil
+		}
+		hub = NewHub(gamer, logger)
+		server = NewServer(&ServerConfig{
+			Log:        logger,
+			Hub:        hub,
+			Upgrader:   &websocket.Upgrader{},
+			AdminToken: "s3cret",
+		})
+	})
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	status := func() Status {
+		w := do("GET", "/admin", "s3cret")
+		Expect(w.Code).To(Equal(http.StatusOK))
+		Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+		var st Status
+		Expect(json.Unmarshal(w.Body.Bytes(), &st)).To(Succeed())
+		return st
+	}
+
+	It("should not exist without an admin token", func() {
+		server = NewServer(&ServerConfig{Log: slog.New(slog.NewTextHandler(GinkgoWriter, nil)), Hub: hub})
+		Expect(do("GET", "/admin", "").Code).To(Equal(http.StatusNotFound))
+		Expect(do("GET", "/admin", "s3cret").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("should only let admins in", func() {
+		Expect(do("GET", "/admin", "").Code).To(Equal(http.StatusUnauthorized))
+		Expect(do("GET", "/admin", "guess").Code).To(Equal(http.StatusUnauthorized))
+		Expect(do("POST", "/admin/abort?game=1", "guess").Code).To(Equal(http.StatusUnauthorized))
+		Expect(do("GET", "/admin?token=s3cret", "").Code).To(Equal(http.StatusOK))
+	})
+
+	Describe("GET /admin", func() {
+		It("should serve the uptime and the games in progress", func() {
+			startAbortableGame(hub)
+			st := status()
+			Expect(st.Started).To(BeTemporally("~", time.Now(), time.Second))
+			Expect(st.Uptime).To(BeNumerically(">=", 0))
+			Expect(st.Draining).To(BeFalse())
+			Expect(st.Players).To(BeEmpty())
+			Expect(st.Games).To(HaveLen(1))
+			Expect(st.Games[0].Players).To(HaveLen(2))
+		})
+
+		It("should tell that the server is draining", func() {
+			server.Drain(time.Now().Add(time.Minute))
+			Expect(status().Draining).To(BeTrue())
+		})
+
+		It("should only allow GET", func() {
+			Expect(do("POST", "/admin", "s3cret").Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe("POST /admin/abort", func() {
+		It("should abort the game", func() {
+			finished := make(chan game.Result, 1)
+			hub.OnGameFinished(func(g *game.Game, _ GameSettings, _ time.Time) {
+				finished <- g.Result()
+			})
+			startAbortableGame(hub)
+			id := status().Games[0].ID
+			Expect(do("POST", "/admin/abort?game="+id, "s3cret").Code).To(Equal(http.StatusNoContent))
+			var r game.Result
+			Eventually(finished).Should(Receive(&r))
+			Expect(r.Outcome).To(Equal(game.OutcomeAbort))
+		})
+
+		It("should not find unknown games", func() {
+			Expect(do("POST", "/admin/abort?game=42", "s3cret").Code).To(Equal(http.StatusNotFound))
+			Expect(do("POST", "/admin/abort", "s3cret").Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should only allow POST", func() {
+			Expect(do("GET", "/admin/abort?game=1", "s3cret").Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe("POST /admin/kick", func() {
+		var web *httptest.Server
+		var conn *websocket.Conn
+
+		BeforeEach(func() {
+			web = httptest.NewServer(server)
+			var err error
+			conn, _, err = websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(web.URL, "http")+"/websocket", nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			conn.Close()
+			web.Close()
+		})
+
+		It("should disconnect the player, telling it why", func() {
+			Eventually(func() []PlayerEntry { return status().Players }).Should(HaveLen(1))
+			id := status().Players[0].ID
+			Expect(do("POST", "/admin/kick?player="+id, "s3cret").Code).To(Equal(http.StatusNoContent))
+
+			var msg protocol.Message
+			Expect(conn.ReadJSON(&msg)).To(Succeed())
+			Expect(msg.Name).To(Equal(protocol.KindError))
+			var e protocol.Error
+			Expect(protocol.Decode(msg.Data, &e)).To(Succeed())
+			Expect(e.Code).To(Equal(protocol.CodeKicked))
+			err := conn.ReadJSON(&msg)
+			Expect(websocket.IsCloseError(err, websocket.ClosePolicyViolation)).To(BeTrue())
+			Eventually(func() []PlayerEntry { return status().Players }).Should(BeEmpty())
+		})
+
+		It("should not find unknown players", func() {
+			Expect(do("POST", "/admin/kick?player=nobody", "s3cret").Code).To(Equal(http.StatusNotFound))
+		})
+	})
+})
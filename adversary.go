@@ -0,0 +1,73 @@
+package cowbull
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AdversarialThinker is an artificial intelligence that never commits to a
+// number. It answers each guess with the score that keeps the most numbers
+// possible, which makes every game the worst case for the guesser.
+type AdversarialThinker struct {
+	digits     int
+	candidates []string
+}
+
+// NewAdversarialThinker creates new AdversarialThinker that plays with
+// n-digit numbers. Like AIThinker, it never plays with numbers starting with
+// zero.
+func NewAdversarialThinker(n int) *AdversarialThinker {
+	return &AdversarialThinker{digits: n}
+}
+
+// Think starts a new game.
+func (p *AdversarialThinker) Think() (int, error) {
+	if p.digits < 1 || p.digits > 10 {
+		return 0, fmt.Errorf("invalid digit count %d", p.digits)
+	}
+	p.candidates = p.candidates[:0]
+	for _, number := range Numbers(p.digits) {
+		if number[0] != '0' {
+			p.candidates = append(p.candidates, number)
+		}
+	}
+	return p.digits, nil
+}
+
+// Try returns the score of number that keeps the most numbers possible.
+// Among equally good scores, the one with fewer bulls and then fewer cows is
+// preferred.
+func (p *AdversarialThinker) Try(number string) (int, int, error) {
+	if len(number) != p.digits {
+		return 0, 0, errors.New("adversarial thinker: try number digit count mismatch")
+	}
+	var counts [scoreCount]int
+	for _, c := range p.candidates {
+		counts[scoreKey(computeCowsBulls(c, number))]++
+	}
+	cows, bulls, best := 0, 0, 0
+	for b := 0; b <= p.digits; b++ {
+		for c := 0; c+b <= p.digits; c++ {
+			if n := counts[scoreKey(c, b)]; n > best {
+				cows, bulls, best = c, b, n
+			}
+		}
+	}
+
+	left := p.candidates[:0]
+	for _, c := range p.candidates {
+		if c2, b2 := computeCowsBulls(c, number); c2 == cows && b2 == bulls {
+			left = append(left, c)
+		}
+	}
+	p.candidates = left
+	return cows, bulls, nil
+}
+
+// Secret returns a number consistent with all answers given so far.
+func (p *AdversarialThinker) Secret() string {
+	if len(p.candidates) == 0 {
+		return ""
+	}
+	return p.candidates[0]
+}
@@ -0,0 +1,46 @@
+package cowbull_test
+
+import (
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/game"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AdversarialThinker", func() {
+	It("should reject invalid digit counts", func() {
+		_, err := NewAdversarialThinker(11).Think()
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should answer with the score that keeps the most numbers", func() {
+		thinker := NewAdversarialThinker(2)
+		_, err := thinker.Think()
+		Ω(err).ShouldNot(HaveOccurred())
+		// Of the 81 numbers, 56 share no digit with 12.
+		cows, bulls, err := thinker.Try("12")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(cows).Should(BeZero())
+		Ω(bulls).Should(BeZero())
+		cows, bulls = Score(thinker.Secret(), "12")
+		Ω(cows + bulls).Should(BeZero())
+	})
+
+	It("should reject guesses with wrong digit count", func() {
+		thinker := NewAdversarialThinker(2)
+		_, err := thinker.Think()
+		Ω(err).ShouldNot(HaveOccurred())
+		_, _, err = thinker.Try("123")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should lose to a guesser eventually, revealing the number", func() {
+		thinker := NewAdversarialThinker(3)
+		g := game.New(thinker, NewAIGuesser(3, FirstStrategy))
+		Ω(g.Play()).Should(Succeed())
+		r := g.Result()
+		Ω(r.Secret).Should(Equal(thinker.Secret()))
+		Ω(r.Secret[0]).ShouldNot(Equal(byte('0')))
+	})
+})
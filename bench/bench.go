@@ -0,0 +1,308 @@
+// Package bench measures how well AI guessers play against AI thinkers.
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/game"
+)
+
+// Thinkers the guesser may be benchmarked against.
+const (
+	// ThinkerRandom thinks of a random number each game.
+	ThinkerRandom = "random"
+	// ThinkerAdversarial answers so that the guesser learns the least.
+	ThinkerAdversarial = "adversarial"
+	// ThinkerExhaustive thinks of every number AIThinker may think of,
+	// once each.
+	ThinkerExhaustive = "exhaustive"
+)
+
+// maxWorstSecrets is the count of worst-case secrets reported at most.
+const maxWorstSecrets = 10
+
+// Config configures a benchmark.
+type Config struct {
+	// Digits is the digit count of the numbers.
+	Digits int
+	// Games is the count of games to play. It is ignored when the thinker
+	// is exhaustive.
+	Games int
+	// Guesser is the name of the strategy of the guesser, one of
+	// cowbull.Strategies.
+	Guesser string
+	// Thinker is the kind of thinker.
+	Thinker string
+	// Workers is the count of games played in parallel. Defaults to
+	// GOMAXPROCS.
+	Workers int
+	// Tree, if set, is played from by the guesser instead of searching.
+	// It must be for Digits digits and Guesser is ignored.
+	Tree *cowbull.Tree
+}
+
+// Report is the outcome of a benchmark.
+type Report struct {
+	Digits  int    `json:"digits"`
+	Guesser string `json:"guesser"`
+	Thinker string `json:"thinker"`
+	Games   int    `json:"games"`
+	// Failures is the count of games that were not won.
+	Failures int `json:"failures"`
+
+	MeanTurns float64 `json:"mean_turns"`
+	MaxTurns  int     `json:"max_turns"`
+	// Turns maps turn counts to the count of games won in that many turns.
+	Turns map[int]int `json:"turns"`
+	// WorstSecrets are some of the numbers that took MaxTurns to guess.
+	WorstSecrets []string `json:"worst_secrets"`
+
+	// TimePerMove is the mean time the guesser took to make a guess and
+	// learn its score.
+	TimePerMove time.Duration `json:"time_per_move_ns"`
+	// AllocsPerMove and BytesPerMove are the mean heap allocations per
+	// move, of both players.
+	AllocsPerMove float64 `json:"allocs_per_move"`
+	BytesPerMove  float64 `json:"bytes_per_move"`
+	// Elapsed is the wall time of the benchmark.
+	Elapsed time.Duration `json:"elapsed_ns"`
+}
+
+// outcome is the outcome of a single game.
+type outcome struct {
+	secret  string
+	turns   int
+	won     bool
+	guesser time.Duration
+}
+
+// Run runs a benchmark.
+func Run(c Config) (*Report, error) {
+	if c.Digits < 1 || c.Digits > 10 {
+		return nil, fmt.Errorf("bench: invalid digit count %d", c.Digits)
+	}
+	var newGuesser func() game.Guesser
+	if c.Tree != nil {
+		if c.Tree.Digits != c.Digits {
+			return nil, fmt.Errorf("bench: tree is for %d digits, not %d", c.Tree.Digits, c.Digits)
+		}
+		c.Guesser = "tree of " + c.Tree.Strategy
+		newGuesser = func() game.Guesser { return cowbull.NewTreeGuesser(c.Tree) }
+	} else {
+		strategy, ok := cowbull.Strategies[c.Guesser]
+		if !ok {
+			return nil, fmt.Errorf("bench: unknown guesser strategy %q", c.Guesser)
+		}
+		newGuesser = func() game.Guesser { return cowbull.NewAIGuesser(c.Digits, strategy) }
+	}
+
+	var newThinker func(i int) game.Thinker
+	games := c.Games
+	switch c.Thinker {
+	case ThinkerRandom:
+		newThinker = func(int) game.Thinker { return cowbull.LocalThinker(c.Digits) }
+	case ThinkerAdversarial:
+		newThinker = func(int) game.Thinker { return cowbull.NewAdversarialThinker(c.Digits) }
+	case ThinkerExhaustive:
+		secrets := secrets(c.Digits)
+		games = len(secrets)
+		newThinker = func(i int) game.Thinker { return fixedThinker(secrets[i]) }
+	default:
+		return nil, fmt.Errorf("bench: unknown thinker %q", c.Thinker)
+	}
+	if games < 1 {
+		return nil, fmt.Errorf("bench: invalid game count %d", games)
+	}
+	workers := c.Workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	outcomes := make([]outcome, games)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				outcomes[i] = play(newThinker(i), newGuesser())
+			}
+		}()
+	}
+	for i := 0; i < games; i++ {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+
+	r := &Report{
+		Digits:  c.Digits,
+		Guesser: c.Guesser,
+		Thinker: c.Thinker,
+		Games:   games,
+		Turns:   make(map[int]int),
+		Elapsed: elapsed,
+	}
+	var turns, won int
+	var guesser time.Duration
+	for _, o := range outcomes {
+		turns += o.turns
+		guesser += o.guesser
+		if !o.won {
+			r.Failures++
+			continue
+		}
+		won++
+		r.Turns[o.turns]++
+		if o.turns > r.MaxTurns {
+			r.MaxTurns = o.turns
+			r.WorstSecrets = r.WorstSecrets[:0]
+		}
+		if o.turns == r.MaxTurns && !contains(r.WorstSecrets, o.secret) {
+			r.WorstSecrets = append(r.WorstSecrets, o.secret)
+		}
+	}
+	sort.Strings(r.WorstSecrets)
+	if len(r.WorstSecrets) > maxWorstSecrets {
+		r.WorstSecrets = r.WorstSecrets[:maxWorstSecrets]
+	}
+	if won > 0 {
+		var wonTurns int
+		for t, n := range r.Turns {
+			wonTurns += t * n
+		}
+		r.MeanTurns = float64(wonTurns) / float64(won)
+	}
+	if turns > 0 {
+		r.TimePerMove = guesser / time.Duration(turns)
+		r.AllocsPerMove = float64(after.Mallocs-before.Mallocs) / float64(turns)
+		r.BytesPerMove = float64(after.TotalAlloc-before.TotalAlloc) / float64(turns)
+	}
+	return r, nil
+}
+
+// play plays a game and times the guesser.
+func play(thinker game.Thinker, guesser game.Guesser) outcome {
+	t := &timedGuesser{Guesser: guesser}
+	g := game.New(thinker, t)
+	err := g.Play()
+	result := g.Result()
+	return outcome{
+		secret:  result.Secret,
+		turns:   len(result.Moves),
+		won:     err == nil,
+		guesser: t.elapsed,
+	}
+}
+
+// WriteText writes the report in human readable form.
+func (r *Report) WriteText(w io.Writer) error {
+	ew := &errWriter{w: w}
+	ew.printf("%d-digit games of %s guesser against %s thinker\n", r.Digits, r.Guesser, r.Thinker)
+	ew.printf("games:          %d (%d not won)\n", r.Games, r.Failures)
+	ew.printf("turns:          mean %.3f, max %d\n", r.MeanTurns, r.MaxTurns)
+	ew.printf("worst secrets:  %v\n", r.WorstSecrets)
+	ew.printf("time per move:  %v\n", r.TimePerMove)
+	ew.printf("allocs/move:    %.1f (%.0f B)\n", r.AllocsPerMove, r.BytesPerMove)
+	ew.printf("elapsed:        %v\n", r.Elapsed)
+	ew.printf("distribution:\n")
+	var turns []int
+	for t := range r.Turns {
+		turns = append(turns, t)
+	}
+	sort.Ints(turns)
+	for _, t := range turns {
+		n := r.Turns[t]
+		ew.printf("  %3d turns %8d games %6.2f%%\n", t, n, 100*float64(n)/float64(r.Games))
+	}
+	return ew.err
+}
+
+// WriteJSON writes the report as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// secrets returns every n-digit number AIThinker may think of.
+func secrets(n int) []string {
+	var secrets []string
+	for _, number := range cowbull.Numbers(n) {
+		if number[0] != '0' {
+			secrets = append(secrets, number)
+		}
+	}
+	return secrets
+}
+
+// fixedThinker always thinks of the same number.
+type fixedThinker string
+
+func (t fixedThinker) Think() (int, error) {
+	return len(t), nil
+}
+
+func (t fixedThinker) Try(number string) (int, int, error) {
+	if len(number) != len(t) {
+		return 0, 0, fmt.Errorf("bench: try number %q digit count mismatch", number)
+	}
+	cows, bulls := cowbull.Score(string(t), number)
+	return cows, bulls, nil
+}
+
+func (t fixedThinker) Secret() string {
+	return string(t)
+}
+
+// timedGuesser measures the time spent by a guesser.
+type timedGuesser struct {
+	game.Guesser
+	elapsed time.Duration
+}
+
+func (g *timedGuesser) Guess(n int) (string, error) {
+	start := time.Now()
+	defer func() { g.elapsed += time.Since(start) }()
+	return g.Guesser.Guess(n)
+}
+
+func (g *timedGuesser) Tell(number string, cows, bulls int) error {
+	start := time.Now()
+	defer func() { g.elapsed += time.Since(start) }()
+	return g.Guesser.Tell(number, cows, bulls)
+}
+
+func contains(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
@@ -0,0 +1,99 @@
+package bench_test
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/Bo0mer/cowbull"
+	. "github.com/Bo0mer/cowbull/bench"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Run", func() {
+	It("should play every secret when the thinker is exhaustive", func() {
+		r, err := Run(Config{Digits: 2, Guesser: "first", Thinker: ThinkerExhaustive, Workers: 3})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(r.Games).Should(Equal(81))
+		Ω(r.Failures).Should(BeZero())
+
+		var games int
+		for _, n := range r.Turns {
+			games += n
+		}
+		Ω(games).Should(Equal(81))
+		Ω(r.MeanTurns).Should(BeNumerically(">", 1))
+		Ω(r.MeanTurns).Should(BeNumerically("<=", r.MaxTurns))
+		Ω(r.WorstSecrets).ShouldNot(BeEmpty())
+		Ω(len(r.WorstSecrets)).Should(BeNumerically("<=", 10))
+		Ω(r.Turns[r.MaxTurns]).Should(BeNumerically(">=", len(r.WorstSecrets)))
+	})
+
+	It("should play the configured count of games", func() {
+		r, err := Run(Config{Digits: 3, Games: 20, Guesser: "random", Thinker: ThinkerRandom})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(r.Games).Should(Equal(20))
+		Ω(r.Failures).Should(BeZero())
+	})
+
+	It("should make the adversarial thinker the worst case", func() {
+		exhaustive, err := Run(Config{Digits: 3, Guesser: "first", Thinker: ThinkerExhaustive})
+		Ω(err).ShouldNot(HaveOccurred())
+		adversarial, err := Run(Config{Digits: 3, Games: 1, Guesser: "first", Thinker: ThinkerAdversarial})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(adversarial.MaxTurns).Should(BeNumerically(">=", exhaustive.MeanTurns))
+		Ω(adversarial.MaxTurns).Should(BeNumerically("<=", exhaustive.MaxTurns))
+	})
+
+	It("should play from a tree", func() {
+		tree, err := cowbull.BuildTree(3, "minmax")
+		Ω(err).ShouldNot(HaveOccurred())
+		fromTree, err := Run(Config{Digits: 3, Thinker: ThinkerExhaustive, Tree: tree})
+		Ω(err).ShouldNot(HaveOccurred())
+		searching, err := Run(Config{Digits: 3, Guesser: "minmax", Thinker: ThinkerExhaustive})
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(fromTree.Guesser).Should(Equal("tree of minmax"))
+		Ω(fromTree.Turns).Should(Equal(searching.Turns))
+
+		_, err = Run(Config{Digits: 4, Games: 1, Thinker: ThinkerRandom, Tree: tree})
+		Ω(err).Should(HaveOccurred())
+	})
+
+	It("should reject unknown strategies and thinkers", func() {
+		_, err := Run(Config{Digits: 4, Games: 1, Guesser: "psychic", Thinker: ThinkerRandom})
+		Ω(err).Should(MatchError(ContainSubstring("psychic")))
+		_, err = Run(Config{Digits: 4, Games: 1, Guesser: "random", Thinker: "lazy"})
+		Ω(err).Should(MatchError(ContainSubstring("lazy")))
+		_, err = Run(Config{Digits: 4, Guesser: "random", Thinker: ThinkerRandom})
+		Ω(err).Should(HaveOccurred())
+	})
+})
+
+var _ = Describe("Report", func() {
+	var r *Report
+
+	BeforeEach(func() {
+		var err error
+		r, err = Run(Config{Digits: 2, Guesser: "minmax", Thinker: ThinkerExhaustive})
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should be written as text", func() {
+		var buf bytes.Buffer
+		Ω(r.WriteText(&buf)).Should(Succeed())
+		Ω(buf.String()).Should(ContainSubstring("minmax guesser against exhaustive thinker"))
+		Ω(buf.String()).Should(ContainSubstring("games:          81 (0 not won)"))
+		Ω(buf.String()).Should(ContainSubstring("distribution:"))
+	})
+
+	It("should be written as JSON", func() {
+		var buf bytes.Buffer
+		Ω(r.WriteJSON(&buf)).Should(Succeed())
+		var decoded Report
+		Ω(json.Unmarshal(buf.Bytes(), &decoded)).Should(Succeed())
+		Ω(decoded.Games).Should(Equal(81))
+		Ω(decoded.Turns).Should(Equal(r.Turns))
+		Ω(decoded.WorstSecrets).Should(Equal(r.WorstSecrets))
+	})
+})
@@ -0,0 +1,261 @@
+package cowbull
+
+import (
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+const (
+	// maxChatLength is how many characters a chat message may have.
+	maxChatLength = 500
+	// chatWindow is the period over which chat messages are rate limited.
+	chatWindow = 10 * time.Second
+)
+
+var validRoom = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// chatObserver may be implemented by a Player that wants to chat.
+type chatObserver interface {
+	AnnounceChat(protocol.Chat) error
+	AnnounceRoom(protocol.Room) error
+}
+
+// leaks reports whether text gives away the secret of a thinker, as far as
+// it is known, even with other characters between its digits.
+func (g *liveGame) leaks(text string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, text)
+	for _, t := range g.thinkers {
+		for _, p := range playersOf(t) {
+			if r, ok := p.(game.Revealer); ok && r.Secret() != "" && strings.Contains(digits, r.Secret()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LimitChat lets players send up to rate chat messages every 10 seconds,
+// and keeps the last history messages of the lobby and of every room for
+// players joining them. It should be called before the hub is in use.
+func (h *Hub) LimitChat(rate, history int) {
+	h.chatRate, h.chatHistory = rate, history
+}
+
+// Chat relays a chat message from a player to everyone in its scope. The
+// text is trimmed and HTML-escaped. If the message is rejected, the
+// returned error is a *protocol.Error describing why.
+func (h *Hub) Chat(from Player, msg protocol.Chat) error {
+	text := strings.TrimSpace(msg.Text)
+	if text == "" {
+		return protocol.Errorf(protocol.CodeBadRequest, "empty message")
+	}
+	if utf8.RuneCountInString(text) > maxChatLength {
+		return protocol.Errorf(protocol.CodeBadRequest, "message longer than %d characters", maxChatLength)
+	}
+	if msg.Scope == "" {
+		msg.Scope = protocol.ScopeLobby
+	}
+	if msg.Scope != protocol.ScopeRoom {
+		msg.Room = ""
+	}
+	msg = protocol.Chat{
+		Scope: msg.Scope,
+		Room:  msg.Room,
+		From:  PlayerEntry{ID: from.ID(), Name: html.EscapeString(from.Name())},
+		Text:  html.EscapeString(text),
+		Time:  time.Now().UnixNano() / int64(time.Millisecond),
+	}
+
+	type delivery struct {
+		to  []Player
+		err error
+	}
+	deliveries := make(chan delivery, 1)
+	h.do(func(players map[string]Player) {
+		to, err := h.chatRecipients(players, from, msg, text)
+		if err == nil {
+			err = h.rateChat(from.ID())
+		}
+		if err == nil && msg.Scope != protocol.ScopeGame {
+			h.record(chatKey(msg.Scope, msg.Room), msg)
+		}
+		deliveries <- delivery{to, err}
+	})
+	d := <-deliveries
+	if d.err != nil {
+		return d.err
+	}
+	for _, p := range d.to {
+		if o, ok := p.(chatObserver); ok {
+			if err := o.AnnounceChat(msg); err != nil {
+				h.log.Warn("error relaying chat", "player_id", p.ID(), "err", err)
+			}
+		}
+	}
+	return nil
+}
+
+// chatRecipients returns who msg, with the unescaped text, is for. It must
+// be called by a hub op.
+func (h *Hub) chatRecipients(players map[string]Player, from Player, msg protocol.Chat, text string) ([]Player, error) {
+	var to []Player
+	switch msg.Scope {
+	case protocol.ScopeLobby:
+		for _, p := range players {
+			to = append(to, p)
+		}
+	case protocol.ScopeRoom:
+		members := h.rooms[msg.Room]
+		if !members[from.ID()] {
+			return nil, protocol.Errorf(protocol.CodeNotAllowed, "not a member of room %q", msg.Room)
+		}
+		for id := range members {
+			if p, ok := players[id]; ok {
+				to = append(to, p)
+			}
+		}
+	case protocol.ScopeGame:
+		for g := range h.live {
+			members := g.players()
+			if !containsPlayer(members, from.ID()) {
+				continue
+			}
+			if g.leaks(text) {
+				return nil, protocol.Errorf(protocol.CodeNotAllowed, "the message gives the secret away")
+			}
+			return members, nil
+		}
+		return nil, protocol.Errorf(protocol.CodeNotAllowed, "not in a game")
+	default:
+		return nil, protocol.Errorf(protocol.CodeBadRequest, "unknown scope %q", msg.Scope)
+	}
+	return to, nil
+}
+
+// rateChat counts a chat message of the player with id, unless it sent too
+// many lately. It must be called by a hub op.
+func (h *Hub) rateChat(id string) error {
+	now := time.Now()
+	var recent []time.Time
+	for _, t := range h.chatSent[id] {
+		if now.Sub(t) < chatWindow {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= h.chatRate {
+		h.chatSent[id] = recent
+		return protocol.Errorf(protocol.CodeRateLimited, "more than %d messages in %v", h.chatRate, chatWindow)
+	}
+	h.chatSent[id] = append(recent, now)
+	return nil
+}
+
+// chatKey returns the key of the history of the lobby, or of a room.
+func chatKey(scope, room string) string {
+	return scope + ":" + room
+}
+
+// record keeps msg in the history of the lobby or room with key. It must be
+// called by a hub op.
+func (h *Hub) record(key string, msg protocol.Chat) {
+	if h.chatHistory <= 0 {
+		return
+	}
+	msg.History = true
+	history := append(h.history[key], msg)
+	if len(history) > h.chatHistory {
+		history = history[len(history)-h.chatHistory:]
+	}
+	h.history[key] = history
+}
+
+// replay sends p the history of the lobby or room with key. It must be
+// called by a hub op.
+func (h *Hub) replay(p Player, key string) {
+	o, ok := p.(chatObserver)
+	if !ok {
+		return
+	}
+	for _, msg := range h.history[key] {
+		if err := o.AnnounceChat(msg); err != nil {
+			h.log.Warn("error sending chat history", "player_id", p.ID(), "err", err)
+			return
+		}
+	}
+}
+
+// JoinRoom makes a player a member of a chat room, creating it if needed,
+// and sends it the members and the last messages of the room. If the name
+// is invalid, the returned error is a *protocol.Error.
+func (h *Hub) JoinRoom(p Player, room string) error {
+	if !validRoom.MatchString(room) {
+		return protocol.Errorf(protocol.CodeBadRequest, "invalid room name %q", room)
+	}
+	done := make(chan struct{})
+	h.do(func(players map[string]Player) {
+		defer close(done)
+		if h.rooms[room] == nil {
+			h.rooms[room] = make(map[string]bool)
+		}
+		h.rooms[room][p.ID()] = true
+
+		o, ok := p.(chatObserver)
+		if !ok {
+			return
+		}
+		r := protocol.Room{Room: room}
+		for id := range h.rooms[room] {
+			if member, ok := players[id]; ok {
+				r.Members = append(r.Members, PlayerEntry{ID: id, Name: member.Name()})
+			}
+		}
+		sort.Slice(r.Members, func(i, j int) bool { return r.Members[i].ID < r.Members[j].ID })
+		if err := o.AnnounceRoom(r); err != nil {
+			h.log.Warn("error announcing room", "player_id", p.ID(), "err", err)
+			return
+		}
+		h.replay(p, chatKey(protocol.ScopeRoom, room))
+	})
+	<-done
+	return nil
+}
+
+// LeaveRoom removes the player with pid from a chat room. Rooms are gone
+// along with their history once their last member leaves.
+func (h *Hub) LeaveRoom(pid, room string) {
+	h.do(func(map[string]Player) {
+		h.leaveRoom(pid, room)
+	})
+}
+
+// leaveRoom removes the player with pid from room. It must be called by a
+// hub op.
+func (h *Hub) leaveRoom(pid, room string) {
+	delete(h.rooms[room], pid)
+	if len(h.rooms[room]) == 0 {
+		delete(h.rooms, room)
+		delete(h.history, chatKey(protocol.ScopeRoom, room))
+	}
+}
+
+// containsPlayer reports whether one of players has the id.
+func containsPlayer(players []Player, id string) bool {
+	for _, p := range players {
+		if p.ID() == id {
+			return true
+		}
+	}
+	return false
+}
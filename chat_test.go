@@ -0,0 +1,229 @@
+package cowbull_test
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chat", func() {
+	var hub *Hub
+	var gamer *cowbullfakes.FakeGamer
+	var alice, bob, carol *chattingPlayer
+
+	BeforeEach(func() {
+		gamer = new(cowbullfakes.FakeGamer)
+		gamer.GameStub = func(t game.Thinker, g game.Guesser) (*game.Game, error) {
+			return game.New(t, g), nil
+		}
+		hub = NewHub(gamer, slog.New(slog.NewTextHandler(GinkgoWriter, nil)))
+		alice = newChattingPlayer("alice")
+		bob = newChattingPlayer("bob")
+		carol = newChattingPlayer("carol")
+		hub.Add(alice)
+		hub.Add(bob)
+		hub.Add(carol)
+	})
+
+	Describe("in the lobby", func() {
+		It("should relay messages to everyone", func() {
+			Expect(hub.Chat(alice, protocol.Chat{Text: " hi all "})).To(Succeed())
+			for _, p := range []*chattingPlayer{alice, bob, carol} {
+				Expect(p.texts()).To(Equal([]string{"hi all"}))
+			}
+			msg := bob.chat()[0]
+			Expect(msg.Scope).To(Equal(protocol.ScopeLobby))
+			Expect(msg.From).To(Equal(PlayerEntry{ID: "alice", Name: "alice"}))
+			Expect(msg.Time).To(BeNumerically(">", 0))
+			Expect(msg.History).To(BeFalse())
+		})
+
+		It("should escape HTML", func() {
+			evil := newChattingPlayer("<b>eve</b>")
+			hub.Add(evil)
+			Expect(hub.Chat(evil, protocol.Chat{Text: `<script>alert("hi")</script>`})).To(Succeed())
+			msg := bob.chat()[0]
+			Expect(msg.Text).To(Equal("&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt;"))
+			Expect(msg.From.Name).To(Equal("&lt;b&gt;eve&lt;/b&gt;"))
+		})
+
+		It("should reject empty and overlong messages", func() {
+			Expect(code(hub.Chat(alice, protocol.Chat{Text: " "}))).To(Equal(protocol.CodeBadRequest))
+			Expect(code(hub.Chat(alice, protocol.Chat{Text: strings.Repeat("x", 501)}))).To(Equal(protocol.CodeBadRequest))
+			Expect(hub.Chat(alice, protocol.Chat{Text: strings.Repeat("ж", 500)})).To(Succeed())
+		})
+
+		It("should reject unknown scopes", func() {
+			Expect(code(hub.Chat(alice, protocol.Chat{Scope: "galaxy", Text: "hi"}))).To(Equal(protocol.CodeBadRequest))
+		})
+
+		It("should limit how often players may send", func() {
+			for i := 0; i < 5; i++ {
+				Expect(hub.Chat(alice, protocol.Chat{Text: "spam"})).To(Succeed())
+			}
+			Expect(code(hub.Chat(alice, protocol.Chat{Text: "spam"}))).To(Equal(protocol.CodeRateLimited))
+			Expect(bob.texts()).To(HaveLen(5))
+			Expect(hub.Chat(bob, protocol.Chat{Text: "stop it"})).To(Succeed())
+		})
+
+		It("should send the last messages to players joining the hub", func() {
+			hub.LimitChat(10, 2)
+			for _, text := range []string{"one", "two", "three"} {
+				Expect(hub.Chat(alice, protocol.Chat{Text: text})).To(Succeed())
+			}
+			dave := newChattingPlayer("dave")
+			hub.Add(dave)
+			Eventually(dave.texts).Should(Equal([]string{"two", "three"}))
+			Expect(dave.chat()[0].History).To(BeTrue())
+		})
+	})
+
+	Describe("in a room", func() {
+		BeforeEach(func() {
+			Expect(hub.JoinRoom(alice, "den")).To(Succeed())
+			Expect(hub.JoinRoom(bob, "den")).To(Succeed())
+		})
+
+		It("should tell joining players who is in the room", func() {
+			Expect(bob.rooms()).To(Equal([]protocol.Room{{Room: "den", Members: []PlayerEntry{
+				{ID: "alice", Name: "alice"}, {ID: "bob", Name: "bob"},
+			}}}))
+		})
+
+		It("should relay messages to the members only", func() {
+			Expect(hub.Chat(alice, protocol.Chat{Scope: protocol.ScopeRoom, Room: "den", Text: "psst"})).To(Succeed())
+			Expect(bob.texts()).To(Equal([]string{"psst"}))
+			Expect(bob.chat()[0].Room).To(Equal("den"))
+			Expect(carol.texts()).To(BeEmpty())
+		})
+
+		It("should reject messages from others", func() {
+			err := hub.Chat(carol, protocol.Chat{Scope: protocol.ScopeRoom, Room: "den", Text: "hey"})
+			Expect(code(err)).To(Equal(protocol.CodeNotAllowed))
+		})
+
+		It("should send the last messages to players joining it", func() {
+			Expect(hub.Chat(alice, protocol.Chat{Scope: protocol.ScopeRoom, Room: "den", Text: "psst"})).To(Succeed())
+			Expect(hub.JoinRoom(carol, "den")).To(Succeed())
+			Expect(carol.texts()).To(Equal([]string{"psst"}))
+		})
+
+		It("should forget rooms once everyone left", func() {
+			Expect(hub.Chat(alice, protocol.Chat{Scope: protocol.ScopeRoom, Room: "den", Text: "psst"})).To(Succeed())
+			hub.LeaveRoom("alice", "den")
+			hub.Remove("bob")
+			Expect(hub.JoinRoom(carol, "den")).To(Succeed())
+			Expect(carol.texts()).To(BeEmpty())
+			Expect(carol.rooms()[0].Members).To(Equal([]PlayerEntry{{ID: "carol", Name: "carol"}}))
+		})
+
+		It("should reject invalid room names", func() {
+			Expect(code(hub.JoinRoom(carol, "no spaces"))).To(Equal(protocol.CodeBadRequest))
+		})
+	})
+
+	Describe("in a game", func() {
+		var over chan struct{}
+
+		BeforeEach(func() {
+			over = make(chan struct{})
+			alice.secret = "1234"
+			alice.ThinkStub = func() (int, error) {
+				return 4, nil
+			}
+			alice.TryStub = func(string) (int, int, error) {
+				<-over
+				return 0, 0, game.ErrForfeit
+			}
+			bob.GuessReturns("5678", nil)
+			Expect(hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 4})).To(Succeed())
+			Expect(hub.Enqueue(bob, Queue{Role: RoleGuesser, Digits: 4})).To(Succeed())
+			Eventually(alice.TryCallCount).Should(Equal(1))
+		})
+
+		AfterEach(func() {
+			close(over)
+		})
+
+		It("should relay messages to the players of the game", func() {
+			Expect(hub.Chat(bob, protocol.Chat{Scope: protocol.ScopeGame, Text: "good luck"})).To(Succeed())
+			Expect(alice.texts()).To(Equal([]string{"good luck"}))
+			Expect(carol.texts()).To(BeEmpty())
+		})
+
+		It("should keep thinkers from giving the secret away", func() {
+			err := hub.Chat(alice, protocol.Chat{Scope: protocol.ScopeGame, Text: "it is 1-2-3-4"})
+			Expect(code(err)).To(Equal(protocol.CodeNotAllowed))
+			Expect(bob.texts()).To(BeEmpty())
+			Expect(hub.Chat(alice, protocol.Chat{Scope: protocol.ScopeGame, Text: "not 4321"})).To(Succeed())
+		})
+
+		It("should reject messages from players in no game", func() {
+			err := hub.Chat(carol, protocol.Chat{Scope: protocol.ScopeGame, Text: "hi"})
+			Expect(code(err)).To(Equal(protocol.CodeNotAllowed))
+		})
+	})
+})
+
+// chattingPlayer records the chat messages and rooms it is sent.
+type chattingPlayer struct {
+	*cowbullfakes.FakePlayer
+	secret string
+
+	mu    sync.Mutex
+	msgs  []protocol.Chat
+	joins []protocol.Room
+}
+
+func newChattingPlayer(id string) *chattingPlayer {
+	p := &chattingPlayer{FakePlayer: new(cowbullfakes.FakePlayer)}
+	p.IDReturns(id)
+	p.NameReturns(id)
+	return p
+}
+
+func (p *chattingPlayer) Secret() string {
+	return p.secret
+}
+
+func (p *chattingPlayer) AnnounceChat(c protocol.Chat) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.msgs = append(p.msgs, c)
+	return nil
+}
+
+func (p *chattingPlayer) AnnounceRoom(r protocol.Room) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.joins = append(p.joins, r)
+	return nil
+}
+
+func (p *chattingPlayer) chat() []protocol.Chat {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]protocol.Chat(nil), p.msgs...)
+}
+
+func (p *chattingPlayer) texts() []string {
+	var texts []string
+	for _, c := range p.chat() {
+		texts = append(texts, c.Text)
+	}
+	return texts
+}
+
+func (p *chattingPlayer) rooms() []protocol.Room {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]protocol.Room(nil), p.joins...)
+}
@@ -5,21 +5,17 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net"
 	"sync"
 	"time"
+
+	"github.com/Bo0mer/cowbull/protocol"
+	"github.com/gorilla/websocket"
 )
 
 //go:generate counterfeiter . Conn
 
-type message struct {
-	// Name identifies a message family.
-	Name string `json:"name"`
-	// Data is the actual data of the message.
-	Data string `json:"data"`
-}
-
 // Conn represents a client connection.
 type Conn interface {
 	// SetReadDeadline sets the read deadline on the underlying
@@ -45,12 +41,23 @@ type Client struct {
 	retryCount    int
 	retryInterval time.Duration
 
+	// Limits on incoming messages. The buckets are only touched by the
+	// read loop.
+	maxSize int
+	limits  map[string]Limit
+	buckets map[string]*tokenBucket
+
+	metrics *Metrics
+
 	mu      sync.Mutex // guards actions
 	actions map[string]func(data string)
 
-	log *log.Logger
+	wmu sync.Mutex // serializes writes to conn
+
+	log *slog.Logger
 
 	closeOnce sync.Once
+	closed    chan struct{}
 }
 
 // ClientOption configures a client.
@@ -81,8 +88,35 @@ func ReadTimeout(d time.Duration) ClientOption {
 	}
 }
 
-// LogTo configures the logging destination for a client.
-func LogTo(log *log.Logger) ClientOption {
+// MaxMessageSize makes the client refuse messages carrying more than n bytes
+// of data. The reader of conns with a SetReadLimit method, like WebSocket
+// connections, is limited to twice as many bytes, and beyond that the
+// connection simply breaks.
+func MaxMessageSize(n int) ClientOption {
+	return func(c *Client) {
+		c.maxSize = n
+	}
+}
+
+// RateLimits makes the client refuse messages of a kind sent more often
+// than its limit allows, or the limit of AnyKind for kinds not listed.
+func RateLimits(limits map[string]Limit) ClientOption {
+	return func(c *Client) {
+		c.limits = limits
+	}
+}
+
+// Instrument makes the client count the messages it receives and sends,
+// and its read retries, in m.
+func Instrument(m *Metrics) ClientOption {
+	return func(c *Client) {
+		c.metrics = m
+	}
+}
+
+// LogTo configures the logging destination for a client. Every line it logs
+// carries its ID as client_id.
+func LogTo(log *slog.Logger) ClientOption {
 	return func(c *Client) {
 		c.log = log
 	}
@@ -101,13 +135,19 @@ func NewClient(conn Conn, opts ...ClientOption) *Client {
 		retryCount:    3,
 		retryInterval: 1 * time.Second,
 		actions:       make(map[string]func(string)),
+		buckets:       make(map[string]*tokenBucket),
+		closed:        make(chan struct{}),
 	}
 	for _, op := range opts {
 		op(c)
 	}
+	if l, ok := conn.(interface{ SetReadLimit(int64) }); ok && c.maxSize > 0 {
+		l.SetReadLimit(2*int64(c.maxSize) + 1024)
+	}
 	if c.log == nil {
-		c.log = log.New(ioutil.Discard, "", 0)
+		c.log = slog.New(slog.NewTextHandler(ioutil.Discard, nil))
 	}
+	c.log = c.log.With("client_id", id)
 	go c.readLoop()
 	return c
 }
@@ -125,8 +165,12 @@ func (c *Client) OnMessage(name string, action func(data string)) {
 }
 
 // SendMessage sends message to the client.
+// It is safe to call it from multiple goroutines.
 func (c *Client) SendMessage(name, data string) error {
-	return c.conn.WriteJSON(&message{
+	c.metrics.inc(metricSent, name)
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.conn.WriteJSON(&protocol.Message{
 		Name: name,
 		Data: data,
 	})
@@ -138,46 +182,139 @@ func (c *Client) SendMessage(name, data string) error {
 func (c *Client) Close() error {
 	var err error
 	c.closeOnce.Do(func() {
+		close(c.closed)
 		err = c.conn.Close()
-		c.invoke("disconnect", "")
+		c.invoke(protocol.KindDisconnect, "")
 	})
 	return err
 }
 
+// GoAway closes the client like Close, first telling WebSocket connections
+// that the server is going away.
+func (c *Client) GoAway() error {
+	return c.closeWith(websocket.CloseGoingAway, "server shutting down")
+}
+
+// Kick tells the client why it is disconnected with e, then closes it like
+// Close, telling WebSocket connections that it broke the rules.
+func (c *Client) Kick(e *protocol.Error) error {
+	c.refuse(e)
+	return c.closeWith(websocket.ClosePolicyViolation, e.Message)
+}
+
+// closeWith closes the client like Close, first sending a close frame with
+// code and text to WebSocket connections.
+func (c *Client) closeWith(code int, text string) error {
+	if w, ok := c.conn.(interface {
+		WriteControl(messageType int, data []byte, deadline time.Time) error
+	}); ok {
+		msg := websocket.FormatCloseMessage(code, text)
+		if err := w.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second)); err != nil {
+			c.log.Warn("error sending close frame", "err", err)
+		}
+	}
+	return c.Close()
+}
+
 func (c *Client) readLoop() {
 	defer func() {
 		if err := c.Close(); err != nil {
-			c.log.Printf("error closing self: %v\n", err)
+			c.log.Warn("error closing client", "err", err)
 		}
 	}()
 
 	var retries = 0
 	for {
+		select {
+		case <-c.closed:
+			return
+		default:
+		}
 		if err := c.conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
 			return
 		}
-		var msg message
+		var msg protocol.Message
 		if err := c.conn.ReadJSON(&msg); err != nil {
 			if retries == c.retryCount {
 				return
 			}
-			c.log.Printf("error reading JSON from client: %v, retrying...\n", err)
+			c.log.Warn("error reading JSON from client, retrying", "err", err, "retries", retries)
+			c.metrics.inc(metricReadRetries)
 			time.Sleep(c.retryInterval)
 			retries++
 			continue
 		}
 		retries = 0
+		c.metrics.inc(metricReceived, c.kindLabel(msg.Name))
 
+		if err := c.admit(msg); err != nil {
+			c.log.Warn("refusing client", "kind", msg.Name, "code", err.Code, "err", err)
+			c.refuse(err)
+			return
+		}
 		c.invoke(msg.Name, msg.Data)
 	}
 }
 
+// kindLabel labels the metrics of messages of a kind, by the kind if the
+// client acts on it. Made-up kinds share one label.
+func (c *Client) kindLabel(kind string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.actions[kind]; !ok {
+		return "unknown"
+	}
+	return kind
+}
+
+// admit checks msg against the limits of the client.
+func (c *Client) admit(msg protocol.Message) *protocol.Error {
+	if c.maxSize > 0 && len(msg.Data) > c.maxSize {
+		e := protocol.Errorf(protocol.CodeTooLarge, "message larger than %d bytes", c.maxSize)
+		e.Kind = msg.Name
+		return e
+	}
+	key := msg.Name
+	l, ok := c.limits[key]
+	if !ok {
+		key = AnyKind
+		if l, ok = c.limits[key]; !ok {
+			return nil
+		}
+	}
+	now := time.Now()
+	b, ok := c.buckets[key]
+	if !ok {
+		b = newTokenBucket(l, now)
+		c.buckets[key] = b
+	}
+	if !b.allow(now) {
+		e := protocol.Errorf(protocol.CodeRateLimited, "too many %s messages", msg.Name)
+		e.Kind = msg.Name
+		return e
+	}
+	return nil
+}
+
+// refuse tells the client why it is disconnected. The read loop, or Kick,
+// closes the connection afterwards.
+func (c *Client) refuse(e *protocol.Error) {
+	data, err := protocol.Encode(e)
+	if err != nil {
+		c.log.Error("error encoding refusal", "err", err)
+		return
+	}
+	if err := c.SendMessage(protocol.KindError, data); err != nil {
+		c.log.Warn("error sending refusal", "err", err)
+	}
+}
+
 func (c *Client) invoke(name, data string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	if action, ok := c.actions[name]; ok {
-		c.log.Printf("invoking action for %s\n", name)
+		c.log.Debug("invoking action", "kind", name)
 		action(data)
-		c.log.Printf("invoking action for %s is DONE\n", name)
+		c.log.Debug("action done", "kind", name)
 	}
 }
@@ -3,14 +3,20 @@ package cowbull_test
 import (
 	"encoding/json"
 	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	. "github.com/Bo0mer/cowbull"
 	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/protocol"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 )
 
 type fakeNetAddr struct{}
@@ -132,6 +138,101 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Describe("limits", func() {
+		var closed chan struct{}
+		var sent chan protocol.Error
+
+		// feed makes the connection yield the messages, and then block.
+		feed := func(msgs ...string) {
+			var mu sync.Mutex
+			conn.ReadJSONStub = func(val interface{}) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if len(msgs) == 0 {
+					<-closed
+					return errors.New("closed")
+				}
+				msg := msgs[0]
+				msgs = msgs[1:]
+				return json.Unmarshal([]byte(msg), val)
+			}
+		}
+
+		BeforeEach(func() {
+			closed = make(chan struct{})
+			var once sync.Once
+			conn.CloseStub = func() error {
+				once.Do(func() { close(closed) })
+				return nil
+			}
+			sent = make(chan protocol.Error, 10)
+			conn.WriteJSONStub = func(val interface{}) error {
+				msg := val.(*protocol.Message)
+				var e protocol.Error
+				Expect(protocol.Decode(msg.Data, &e)).To(Succeed())
+				sent <- e
+				return nil
+			}
+		})
+
+		It("should refuse too large messages and disconnect", func() {
+			feed(`{"name":"chat","data":"{\"text\":\"` + strings.Repeat("x", 50) + `\"}"}`)
+			c = NewClient(conn, MaxMessageSize(20), RetryCount(0))
+			var e protocol.Error
+			Eventually(sent).Should(Receive(&e))
+			Expect(e.Code).To(Equal(protocol.CodeTooLarge))
+			Expect(e.Kind).To(Equal("chat"))
+			Eventually(closed).Should(BeClosed())
+		})
+
+		It("should refuse messages beyond their rate and disconnect", func() {
+			feed(`{"name":"ping"}`, `{"name":"ping"}`, `{"name":"other"}`, `{"name":"ping"}`)
+			c = NewClient(conn, RetryCount(0), RateLimits(map[string]Limit{
+				"ping":  {Rate: 0.001, Burst: 2},
+				AnyKind: {Rate: 0.001, Burst: 1},
+			}))
+			var pings int32
+			c.OnMessage("ping", func(string) { atomic.AddInt32(&pings, 1) })
+			var e protocol.Error
+			Eventually(sent).Should(Receive(&e))
+			Expect(e.Code).To(Equal(protocol.CodeRateLimited))
+			Expect(e.Kind).To(Equal("ping"))
+			Eventually(closed).Should(BeClosed())
+			Expect(atomic.LoadInt32(&pings)).To(BeEquivalentTo(2))
+		})
+	})
+
+	Describe("Kick", func() {
+		It("should tell the client why before disconnecting it", func() {
+			closed := make(chan struct{})
+			conn.ReadJSONStub = func(interface{}) error {
+				<-closed
+				return errors.New("closed")
+			}
+			var once sync.Once
+			conn.CloseStub = func() error {
+				once.Do(func() { close(closed) })
+				return nil
+			}
+			c = NewClient(conn)
+			Expect(c.Kick(protocol.Errorf(protocol.CodeKicked, "kicked by an admin"))).To(Succeed())
+			Expect(conn.WriteJSONCallCount()).To(Equal(1))
+			msg := conn.WriteJSONArgsForCall(0).(*protocol.Message)
+			Expect(msg.Name).To(Equal(protocol.KindError))
+			Expect(msg.Data).To(MatchJSON(`{"code": "kicked", "message": "kicked by an admin"}`))
+			Expect(closed).To(BeClosed())
+		})
+	})
+
+	Describe("LogTo", func() {
+		It("should log with the ID of the client", func() {
+			logs := gbytes.NewBuffer()
+			conn.ReadJSONReturns(errors.New("doh!"))
+			c = NewClient(conn, LogTo(slog.New(slog.NewTextHandler(logs, nil))), RetryCount(1), RetryInterval(time.Millisecond))
+			Eventually(logs).Should(gbytes.Say(`level=WARN msg="error reading JSON from client, retrying" client_id="` + regexp.QuoteMeta(c.ID()) + `" err=doh!`))
+		})
+	})
+
 	Describe("RetryCount", func() {
 		var n int
 		var tries int
@@ -219,4 +320,11 @@ var _ = Describe("Close", func() {
 			Ω(conn.CloseCallCount()).Should(Equal(1))
 		})
 	})
+
+	It("should stop reading from the connection", func() {
+		c = NewClient(conn)
+		Expect(c.Close()).To(Succeed())
+		reads := conn.ReadJSONCallCount()
+		Consistently(conn.ReadJSONCallCount).Should(BeNumerically("<=", reads+1))
+	})
 })
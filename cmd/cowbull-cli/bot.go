@@ -0,0 +1,48 @@
+package main
+
+import "github.com/Bo0mer/cowbull"
+
+// bot answers the requests of the server with AI players.
+type bot struct {
+	thinker *cowbull.AIThinker
+	guesser *cowbull.AIGuesser
+	digits  int
+}
+
+// newBot creates a bot that thinks of n-digit numbers.
+func newBot(n int) *bot {
+	return &bot{digits: n}
+}
+
+// Think thinks of a new number and returns it.
+func (b *bot) Think() (string, error) {
+	b.thinker = cowbull.LocalThinker(b.digits)
+	if _, err := b.thinker.Think(); err != nil {
+		return "", err
+	}
+	return b.thinker.Secret(), nil
+}
+
+// Guess returns a guess for an n-digit number. The first guess of a game
+// starts a new guesser.
+func (b *bot) Guess(n int) (string, error) {
+	if b.guesser == nil {
+		b.guesser = cowbull.LocalGuesser(n)
+	}
+	return b.guesser.Guess(n)
+}
+
+// Tell tells the guesser the score of a guess. Guesses of other players
+// in the same game are taken into account as well.
+func (b *bot) Tell(number string, cows, bulls int) error {
+	if b.guesser == nil {
+		b.guesser = cowbull.LocalGuesser(len(number))
+	}
+	return b.guesser.Tell(number, cows, bulls)
+}
+
+// Reset forgets everything about the last game.
+func (b *bot) Reset() {
+	b.thinker = nil
+	b.guesser = nil
+}
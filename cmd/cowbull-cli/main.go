@@ -0,0 +1,124 @@
+// Command cowbull-cli is a terminal client for a cowbull game server.
+//
+// By default it is interactive: type help once connected to list the
+// commands. With -bot, the requests of the server are answered by the
+// computer. With -play, a single game is requested right after connecting
+// and the client exits once it is over, with status 0 only if the number
+// was guessed.
+//
+// With -user or -token, the client logs in to an account, so that the player
+// keeps its ID across connections. Add -register to create the account.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/Bo0mer/cowbull/protocol"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	server    string
+	name      string
+	useBot    bool
+	digits    int
+	mode      string
+	opponents string
+	rank      string
+	decision  string
+	username  string
+	password  string
+	token     string
+	register  bool
+)
+
+const (
+	serverUsage    = "WebSocket URL of the server."
+	nameUsage      = "In-game name."
+	botUsage       = "Answer the requests of the server with the computer."
+	digitsUsage    = "Digit count of the numbers thought of by the bot, or of the number to guess with -play ai-thinker, ai-duel, duel, ai-race and ai-team."
+	playUsage      = "Play a single game of this mode and exit: ai-thinker, ai-guesser, thinker, guesser, ai-duel, duel, ai-race, race, ai-team or team."
+	opponentsUsage = "Comma separated names or IDs of the opponents for -play thinker, guesser, ai-race, race, ai-team and team, or the one for -play duel."
+	rankUsage      = "How races are won with -play ai-race and race: turns or time."
+	decisionUsage  = "How teams decide on their guess with -play ai-team and team: vote or captain."
+	userUsage      = "Log in to the account with this username, instead of playing under -name."
+	passwordUsage  = "Password of the account given by -user."
+	tokenUsage     = "Log in with the token given when the account was registered."
+	registerUsage  = "Register the account given by -user, with -password if set, before logging in."
+)
+
+func init() {
+	flag.StringVar(&server, "server", "ws://127.0.0.1:8080/websocket", serverUsage)
+	flag.StringVar(&name, "name", "", nameUsage)
+	flag.BoolVar(&useBot, "bot", false, botUsage)
+	flag.IntVar(&digits, "digits", 4, digitsUsage)
+	flag.StringVar(&mode, "play", "", playUsage)
+	flag.StringVar(&opponents, "opponents", "", opponentsUsage)
+	flag.StringVar(&rank, "rank", "", rankUsage)
+	flag.StringVar(&decision, "decision", "", decisionUsage)
+	flag.StringVar(&username, "user", "", userUsage)
+	flag.StringVar(&password, "password", "", passwordUsage)
+	flag.StringVar(&token, "token", "", tokenUsage)
+	flag.BoolVar(&register, "register", false, registerUsage)
+}
+
+func main() {
+	flag.Parse()
+
+	conn, _, err := websocket.DefaultDialer.Dial(server, nil)
+	if err != nil {
+		log.Fatalf("error connecting to %s: %v\n", server, err)
+	}
+	defer conn.Close()
+
+	s := &session{
+		conn: conn,
+		in:   readLines(os.Stdin),
+		out:  os.Stdout,
+		name: name,
+	}
+	if username != "" || token != "" {
+		s.login = &protocol.Credentials{Username: username, Password: password, Token: token}
+		s.register = register
+	}
+	if useBot {
+		s.bot = newBot(digits)
+	}
+	if mode != "" {
+		p := play{mode: mode, digits: digits, rank: rank, decision: decision}
+		if opponents != "" {
+			p.opponents = strings.Split(opponents, ",")
+		}
+		s.auto = &p
+	}
+
+	result, err := s.run()
+	if err != nil {
+		log.Fatalf("error: %v\n", err)
+	}
+	if s.auto != nil && (result == nil || result.Outcome != protocol.OutcomeWin) {
+		os.Exit(1)
+	}
+}
+
+// readLines returns a channel of the lines read from f.
+// The channel is closed on EOF.
+func readLines(f *os.File) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		if err := scanner.Err(); err != nil {
+			fmt.Fprintf(os.Stderr, "error reading input: %v\n", err)
+		}
+	}()
+	return lines
+}
@@ -0,0 +1,962 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// Game modes, named after the role of the opponent.
+const (
+	modeAIThinker = "ai-thinker"
+	modeAIGuesser = "ai-guesser"
+	modeThinker   = "thinker"
+	modeGuesser   = "guesser"
+	modeAIDuel    = "ai-duel"
+	modeDuel      = "duel"
+	modeAIRace    = "ai-race"
+	modeRace      = "race"
+	modeAITeam    = "ai-team"
+	modeTeam      = "team"
+)
+
+const help = `Commands:
+  name NAME                  set your in-game name
+  players                    list connected players
+  stats [PLAYER]             show your ratings, or those of a player
+  play ai-thinker DIGITS     guess the number of the computer
+  play ai-guesser            let the computer guess your number
+  play thinker PLAYER        guess the number of a player
+  play guesser PLAYER[,...]  let players guess your number
+  play ai-duel DIGITS        duel the computer: both think and guess at once
+  play duel PLAYER DIGITS    duel a player
+  play ai-race DIGITS PLAYER[,...] [RANK]
+                             race players on the number of the computer
+  play race PLAYER[,...] [RANK]
+                             let players race on your number, by turns or time
+  play ai-team DIGITS PLAYER[,...] [DECISION]
+                             team up with players, as captain, against the computer
+  play team PLAYER[,...] [DECISION]
+                             let a team guess your number, deciding by vote or captain
+  say TEXT                   talk to your team
+  chat [game|#ROOM] TEXT     talk to the lobby, your game or a room
+  room ROOM                  join a chat room
+  leave ROOM                 leave a chat room
+  queue ROLE DIGITS [BAND]   wait for a random opponent, to play as thinker or guesser
+  dequeue                    stop waiting for an opponent
+  forfeit                    give up the current game
+  tournament FORMAT DIGITS   organise a round-robin, swiss or single-elimination tournament
+  join TOURNAMENT            play in a tournament
+  start TOURNAMENT           start a tournament you organise
+  help                       show this help
+  quit                       leave
+Players may be referred to by name or ID.`
+
+// play describes a game to be requested.
+type play struct {
+	mode      string
+	digits    int
+	opponents []string
+	rank      string
+	decision  string
+}
+
+// session is a connection to a cowbull server.
+// Requests of the server are answered either by the bot, if there is one,
+// or by the user, one line at a time.
+type session struct {
+	conn *websocket.Conn
+	in   <-chan string // lines typed by the user, closed on EOF
+	out  io.Writer
+	name string
+	bot  *bot
+
+	// login, if set, logs the session in to an account instead of naming
+	// it. With register, the account is registered first.
+	login    *protocol.Credentials
+	register bool
+
+	// auto is played right after connecting, when all its opponents are
+	// in the hub. The session ends once it is over.
+	auto          *play
+	autoRequested bool
+
+	id      string
+	players []protocol.PlayerEntry
+
+	inGame    bool
+	dueling   bool
+	racing    bool
+	voting    bool   // whether the next guess is a vote of the team
+	raceDone  int    // guessers of the current race who are done
+	secret    string // the number thought of in the current game
+	pending   string // kind of the request awaiting an answer by the user
+	digits    int    // digit count of the number to be guessed
+	lastGuess string
+	moves     int
+	result    *protocol.GameOver
+}
+
+// run runs the session until the user quits, the connection breaks or,
+// with auto play, the game is over. It returns the result of the last game
+// played, if any.
+func (s *session) run() (*protocol.GameOver, error) {
+	msgs := make(chan protocol.Message)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			var msg protocol.Message
+			if err := s.conn.ReadJSON(&msg); err != nil {
+				errs <- err
+				return
+			}
+			msgs <- msg
+		}
+	}()
+
+	switch {
+	case s.login != nil && s.register:
+		if err := s.send(protocol.KindRegister, s.login); err != nil {
+			return nil, err
+		}
+	case s.login != nil:
+		if err := s.send(protocol.KindLogin, s.login); err != nil {
+			return nil, err
+		}
+	case s.name != "":
+		if err := s.send(protocol.KindName, protocol.Name{Name: s.name}); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.send(protocol.KindConnect, protocol.Connect{Version: protocol.Version}); err != nil {
+		return nil, err
+	}
+
+	in := s.in
+	for {
+		select {
+		case msg := <-msgs:
+			done, err := s.handle(msg)
+			if err != nil || done {
+				return s.result, err
+			}
+		case line, ok := <-in:
+			if !ok {
+				if s.bot != nil || s.auto != nil {
+					// Nobody needs to type anything, so keep playing.
+					in = nil
+					continue
+				}
+				return s.result, nil
+			}
+			quit, err := s.input(strings.TrimSpace(line))
+			if err != nil || quit {
+				return s.result, err
+			}
+		case err := <-errs:
+			return s.result, fmt.Errorf("connection lost: %v", err)
+		}
+	}
+}
+
+// handle handles a message of the server. It reports whether the session
+// is over.
+func (s *session) handle(msg protocol.Message) (bool, error) {
+	switch msg.Name {
+	case protocol.KindConnect:
+		var c protocol.Connect
+		if err := protocol.Decode(msg.Data, &c); err != nil {
+			return false, err
+		}
+		s.id = c.ID
+		s.printf("Connected as %s (protocol v%d).\n", c.ID, c.Version)
+		if s.auto == nil && s.bot == nil {
+			s.printf("Type help to list the commands.\n")
+		}
+	case protocol.KindRegister, protocol.KindLogin:
+		var a protocol.Account
+		if err := protocol.Decode(msg.Data, &a); err != nil {
+			return false, err
+		}
+		s.name = a.Username
+		s.printf("Logged in as %s.\n", a.Username)
+		if a.Token != "" {
+			s.printf("Your login token is %s. Keep it secret.\n", a.Token)
+		}
+	case protocol.KindStats:
+		var st protocol.Stats
+		if err := protocol.Decode(msg.Data, &st); err != nil {
+			return false, err
+		}
+		s.printStats(st)
+	case protocol.KindTournament:
+		var t protocol.Tournament
+		if err := protocol.Decode(msg.Data, &t); err != nil {
+			return false, err
+		}
+		s.printTournament(t)
+	case protocol.KindQueue:
+		var q protocol.Queue
+		if err := protocol.Decode(msg.Data, &q); err != nil {
+			return false, err
+		}
+		s.printf("Waiting for an opponent, to play as %s of a %d-digit number.\n", q.Role, q.Digits)
+	case protocol.KindMatched:
+		var m protocol.Matched
+		if err := protocol.Decode(msg.Data, &m); err != nil {
+			return false, err
+		}
+		s.printMatched(m)
+		if s.bot != nil && m.Role == protocol.RoleThinker {
+			s.bot.digits = m.Digits
+		}
+	case protocol.KindDuel:
+		var d protocol.Duel
+		if err := protocol.Decode(msg.Data, &d); err != nil {
+			return false, err
+		}
+		s.dueling = true
+		s.printf("Duel against %s: both of you think of a %d-digit number and guess the other.\n",
+			entryName(d.Opponent), d.Digits)
+		if s.bot != nil {
+			s.bot.digits = d.Digits
+		}
+	case protocol.KindDuelOver:
+		var d protocol.DuelOver
+		if err := protocol.Decode(msg.Data, &d); err != nil {
+			return false, err
+		}
+		s.duelOver(d)
+		return s.auto != nil, nil
+	case protocol.KindPlayers:
+		if err := protocol.Decode(msg.Data, &s.players); err != nil {
+			return false, err
+		}
+		if s.auto != nil && !s.autoRequested {
+			if _, err := s.resolve(s.auto.opponents); err == nil {
+				s.autoRequested = true
+				return false, s.play(*s.auto)
+			}
+		}
+	case protocol.KindThink:
+		s.startGame()
+		if s.bot != nil {
+			secret, err := s.bot.Think()
+			if err != nil {
+				return false, err
+			}
+			s.printf("You are the thinker. Thinking of %s.\n", secret)
+			return false, s.think(secret)
+		}
+		s.printf("You have been challenged. Pick your number: ")
+		s.pending = protocol.KindThink
+	case protocol.KindGuess:
+		s.startGame()
+		var d protocol.Digits
+		if err := protocol.Decode(msg.Data, &d); err != nil {
+			return false, err
+		}
+		s.digits = d.Digits
+		if s.voting {
+			s.voting = false
+			if s.bot != nil {
+				// The bot stands by its proposal.
+				return false, s.guess(s.lastGuess)
+			}
+			s.printf("Your vote (%d digits): ", d.Digits)
+			s.pending = protocol.KindGuess
+			return false, nil
+		}
+		if s.bot != nil {
+			guess, err := s.bot.Guess(d.Digits)
+			if err != nil {
+				return false, err
+			}
+			return false, s.guess(guess)
+		}
+		s.printf("Your guess (%d digits): ", d.Digits)
+		s.pending = protocol.KindGuess
+	case protocol.KindTry:
+		var n protocol.Number
+		if err := protocol.Decode(msg.Data, &n); err != nil {
+			return false, err
+		}
+		cows, bulls := cowbull.Score(s.secret, n.Number)
+		if s.dueling {
+			s.printf("     %s  %d %s %d %s  guessed by your opponent\n", n.Number,
+				cows, plural(cows, "cow", "cows"), bulls, plural(bulls, "bull", "bulls"))
+		} else {
+			s.logMove(n.Number, cows, bulls)
+		}
+		return false, s.send(protocol.KindTry, protocol.CowsBulls{Cows: cows, Bulls: bulls})
+	case protocol.KindTell:
+		var cb protocol.CowsBulls
+		if err := protocol.Decode(msg.Data, &cb); err != nil {
+			return false, err
+		}
+		s.logMove(cb.Number, cb.Cows, cb.Bulls)
+		if s.bot != nil {
+			return false, s.bot.Tell(cb.Number, cb.Cows, cb.Bulls)
+		}
+	case protocol.KindGameOver:
+		var r protocol.GameOver
+		if err := protocol.Decode(msg.Data, &r); err != nil {
+			return false, err
+		}
+		s.gameOver(r)
+		// Races are over once every guesser is done.
+		return s.auto != nil && !s.racing, nil
+	case protocol.KindStrike:
+		var st protocol.Strike
+		if err := protocol.Decode(msg.Data, &st); err != nil {
+			return false, err
+		}
+		return s.strike(st), nil
+	case protocol.KindRejoin:
+		var p protocol.PlayerEntry
+		if err := protocol.Decode(msg.Data, &p); err != nil {
+			return false, err
+		}
+		if p.ID == s.id {
+			s.printf("You are back in the game.\n")
+		} else {
+			s.printf("%s rejoined the game.\n", entryName(p))
+		}
+	case protocol.KindProposals:
+		var ps protocol.Proposals
+		if err := protocol.Decode(msg.Data, &ps); err != nil {
+			return false, err
+		}
+		s.proposals(ps)
+	case protocol.KindTeamChat:
+		var m protocol.TeamChat
+		if err := protocol.Decode(msg.Data, &m); err != nil {
+			return false, err
+		}
+		s.printf("[team] %s: %s\n", entryName(m.From), m.Text)
+	case protocol.KindChat:
+		var c protocol.Chat
+		if err := protocol.Decode(msg.Data, &c); err != nil {
+			return false, err
+		}
+		s.chat(c)
+	case protocol.KindRoom:
+		var r protocol.Room
+		if err := protocol.Decode(msg.Data, &r); err != nil {
+			return false, err
+		}
+		names := make([]string, len(r.Members))
+		for i, p := range r.Members {
+			names[i] = entryName(p)
+		}
+		s.printf("Joined #%s with %s.\n", r.Room, strings.Join(names, ", "))
+	case protocol.KindShutdown:
+		var sd protocol.Shutdown
+		if err := protocol.Decode(msg.Data, &sd); err != nil {
+			return false, err
+		}
+		deadline := time.Unix(0, sd.Deadline*int64(time.Millisecond))
+		s.printf("The server is shutting down. Games still on are cancelled at %s.\n", deadline.Format("15:04:05"))
+	case protocol.KindRace:
+		var r protocol.Race
+		if err := protocol.Decode(msg.Data, &r); err != nil {
+			return false, err
+		}
+		s.race(r)
+		return r.Over && s.auto != nil, nil
+	case protocol.KindError:
+		var e protocol.Error
+		if err := protocol.Decode(msg.Data, &e); err != nil {
+			return false, err
+		}
+		s.printf("Error (%s): %s\n", e.Code, e.Message)
+		switch e.Code {
+		case protocol.CodeInvalidDigits:
+			s.printf("Pick your number: ")
+			s.pending = protocol.KindThink
+		case protocol.CodeInvalidGuess:
+			s.printf("Your guess (%d digits): ", s.digits)
+			s.pending = protocol.KindGuess
+		case protocol.CodeInvalidRole, protocol.CodeInvalidSettings, protocol.CodeUnknownOpponent, protocol.CodeTooManyGames, protocol.CodeShuttingDown:
+			if s.auto != nil {
+				return true, &e
+			}
+		case protocol.CodeInvalidAccount, protocol.CodeUsernameTaken, protocol.CodeAuthFailed:
+			// Playing under another identity than asked for is no good.
+			return true, &e
+		case protocol.CodeKicked:
+			return true, &e
+		}
+	}
+	return false, nil
+}
+
+// input handles a line typed by the user. It reports whether the user
+// quits.
+func (s *session) input(line string) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+
+	switch fields[0] {
+	case "quit", "exit":
+		return true, s.conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	case "help":
+		s.printf("%s\n", help)
+		return false, nil
+	case "players":
+		s.printPlayers()
+		return false, nil
+	case "forfeit":
+		return false, s.send(protocol.KindForfeit, nil)
+	case "say":
+		if len(fields) < 2 {
+			s.printf("Usage: say TEXT\n")
+			return false, nil
+		}
+		return false, s.send(protocol.KindTeamChat, protocol.TeamChat{Text: strings.Join(fields[1:], " ")})
+	case "chat":
+		msg, err := parseChat(fields[1:])
+		if err != nil {
+			s.printf("%v\n", err)
+			return false, nil
+		}
+		return false, s.send(protocol.KindChat, msg)
+	case "room", "leave":
+		if len(fields) != 2 {
+			s.printf("Usage: %s ROOM\n", fields[0])
+			return false, nil
+		}
+		return false, s.send(protocol.KindRoom, protocol.Room{Room: strings.TrimPrefix(fields[1], "#"), Leave: fields[0] == "leave"})
+	case "stats":
+		var req protocol.Stats
+		if len(fields) > 1 {
+			ids, err := s.resolve(fields[1:2])
+			if err != nil {
+				s.printf("%v\n", err)
+				return false, nil
+			}
+			req.ID = ids[0]
+		}
+		return false, s.send(protocol.KindStats, req)
+	}
+
+	switch s.pending {
+	case protocol.KindThink:
+		s.pending = ""
+		return false, s.think(fields[0])
+	case protocol.KindGuess:
+		s.pending = ""
+		return false, s.guess(fields[0])
+	}
+
+	switch fields[0] {
+	case "name":
+		if len(fields) < 2 {
+			s.printf("Usage: name NAME\n")
+			return false, nil
+		}
+		s.name = strings.Join(fields[1:], " ")
+		return false, s.send(protocol.KindName, protocol.Name{Name: s.name})
+	case "tournament":
+		if len(fields) != 3 {
+			s.printf("Usage: tournament FORMAT DIGITS\n")
+			return false, nil
+		}
+		n, err := strconv.Atoi(fields[2])
+		if err != nil {
+			s.printf("invalid digit count %q\n", fields[2])
+			return false, nil
+		}
+		return false, s.send(protocol.KindTournament, protocol.TournamentSettings{Format: fields[1], Digits: n})
+	case "queue":
+		q, err := parseQueue(fields[1:])
+		if err != nil {
+			s.printf("%v\n", err)
+			return false, nil
+		}
+		return false, s.send(protocol.KindQueue, q)
+	case "dequeue":
+		return false, s.send(protocol.KindDequeue, nil)
+	case "join", "start":
+		if len(fields) != 2 {
+			s.printf("Usage: %s TOURNAMENT\n", fields[0])
+			return false, nil
+		}
+		return false, s.send(fields[0], protocol.TournamentID{ID: fields[1]})
+	case "play":
+		p, err := parsePlay(fields[1:])
+		if err != nil {
+			s.printf("%v\n", err)
+			return false, nil
+		}
+		if err := s.play(p); err != nil {
+			s.printf("%v\n", err)
+		}
+		return false, nil
+	}
+	s.printf("Unknown command %q. Type help to list the commands.\n", fields[0])
+	return false, nil
+}
+
+// parseChat parses the arguments of a chat command.
+func parseChat(args []string) (protocol.Chat, error) {
+	msg := protocol.Chat{Scope: protocol.ScopeLobby}
+	switch {
+	case len(args) > 0 && args[0] == protocol.ScopeGame:
+		msg.Scope, args = protocol.ScopeGame, args[1:]
+	case len(args) > 0 && strings.HasPrefix(args[0], "#"):
+		msg.Scope, msg.Room, args = protocol.ScopeRoom, args[0][1:], args[1:]
+	}
+	if len(args) == 0 {
+		return protocol.Chat{}, errors.New("Usage: chat [game|#ROOM] TEXT")
+	}
+	msg.Text = strings.Join(args, " ")
+	return msg, nil
+}
+
+// parseQueue parses the arguments of a queue command.
+func parseQueue(args []string) (protocol.Queue, error) {
+	if len(args) < 2 || len(args) > 3 {
+		return protocol.Queue{}, errors.New("Usage: queue ROLE DIGITS [BAND]")
+	}
+	q := protocol.Queue{Role: args[0]}
+	var err error
+	if q.Digits, err = strconv.Atoi(args[1]); err != nil {
+		return protocol.Queue{}, fmt.Errorf("invalid digit count %q", args[1])
+	}
+	if len(args) == 3 {
+		if q.Band, err = strconv.ParseFloat(args[2], 64); err != nil {
+			return protocol.Queue{}, fmt.Errorf("invalid rating band %q", args[2])
+		}
+	}
+	return q, nil
+}
+
+// parsePlay parses the arguments of a play command.
+func parsePlay(args []string) (play, error) {
+	if len(args) == 0 {
+		return play{}, errors.New("Usage: play MODE [DIGITS|PLAYERS]")
+	}
+	p := play{mode: args[0]}
+	switch p.mode {
+	case modeAIThinker:
+		if len(args) != 2 {
+			return play{}, errors.New("Usage: play ai-thinker DIGITS")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return play{}, fmt.Errorf("invalid digit count %q", args[1])
+		}
+		p.digits = n
+	case modeAIGuesser:
+	case modeAIDuel:
+		if len(args) != 2 {
+			return play{}, errors.New("Usage: play ai-duel DIGITS")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return play{}, fmt.Errorf("invalid digit count %q", args[1])
+		}
+		p.digits = n
+	case modeDuel:
+		if len(args) != 3 {
+			return play{}, errors.New("Usage: play duel PLAYER DIGITS")
+		}
+		n, err := strconv.Atoi(args[2])
+		if err != nil {
+			return play{}, fmt.Errorf("invalid digit count %q", args[2])
+		}
+		p.opponents, p.digits = args[1:2], n
+	case modeAIRace:
+		if len(args) != 3 && len(args) != 4 {
+			return play{}, errors.New("Usage: play ai-race DIGITS PLAYER[,...] [RANK]")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return play{}, fmt.Errorf("invalid digit count %q", args[1])
+		}
+		p.digits, p.opponents = n, strings.Split(args[2], ",")
+		if len(args) == 4 {
+			p.rank = args[3]
+		}
+	case modeRace:
+		if len(args) != 2 && len(args) != 3 {
+			return play{}, errors.New("Usage: play race PLAYER[,...] [RANK]")
+		}
+		p.opponents = strings.Split(args[1], ",")
+		if len(args) == 3 {
+			p.rank = args[2]
+		}
+	case modeAITeam:
+		if len(args) != 3 && len(args) != 4 {
+			return play{}, errors.New("Usage: play ai-team DIGITS PLAYER[,...] [DECISION]")
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			return play{}, fmt.Errorf("invalid digit count %q", args[1])
+		}
+		p.digits, p.opponents = n, strings.Split(args[2], ",")
+		if len(args) == 4 {
+			p.decision = args[3]
+		}
+	case modeTeam:
+		if len(args) != 2 && len(args) != 3 {
+			return play{}, errors.New("Usage: play team PLAYER[,...] [DECISION]")
+		}
+		p.opponents = strings.Split(args[1], ",")
+		if len(args) == 3 {
+			p.decision = args[2]
+		}
+	case modeThinker, modeGuesser:
+		if len(args) != 2 {
+			return play{}, fmt.Errorf("Usage: play %s PLAYER", p.mode)
+		}
+		p.opponents = strings.Split(args[1], ",")
+	default:
+		return play{}, fmt.Errorf("unknown mode %q", p.mode)
+	}
+	return p, nil
+}
+
+// play requests a game.
+func (s *session) play(p play) error {
+	opponents, err := s.resolve(p.opponents)
+	if err != nil {
+		return err
+	}
+	settings := protocol.GameSettings{
+		Digits:    p.digits,
+		Opponents: opponents,
+		Rank:      p.rank,
+		Decision:  p.decision,
+	}
+	switch p.mode {
+	case modeAIThinker:
+		settings.Role, settings.AI = protocol.RoleGuesser, true
+	case modeAIGuesser:
+		settings.Role, settings.AI = protocol.RoleThinker, true
+	case modeThinker:
+		settings.Role = protocol.RoleGuesser
+	case modeGuesser:
+		settings.Role = protocol.RoleThinker
+	case modeAIDuel:
+		settings.Mode, settings.AI = protocol.ModeDuel, true
+	case modeDuel:
+		settings.Mode = protocol.ModeDuel
+	case modeAIRace:
+		settings.Mode, settings.Role, settings.AI = protocol.ModeRace, protocol.RoleGuesser, true
+	case modeRace:
+		settings.Mode, settings.Role = protocol.ModeRace, protocol.RoleThinker
+	case modeAITeam:
+		settings.Mode, settings.Role, settings.AI = protocol.ModeTeam, protocol.RoleGuesser, true
+	case modeTeam:
+		settings.Mode, settings.Role = protocol.ModeTeam, protocol.RoleThinker
+	}
+	s.result = nil
+	return s.send(protocol.KindPlay, settings)
+}
+
+// resolve returns the IDs of the players referred to by names or IDs.
+func (s *session) resolve(names []string) ([]string, error) {
+	var ids []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		id := ""
+		for _, p := range s.players {
+			if p.ID == name || p.Name == name {
+				id = p.ID
+				break
+			}
+		}
+		if id == "" {
+			return nil, fmt.Errorf("no player %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (s *session) think(secret string) error {
+	s.secret = secret
+	return s.send(protocol.KindThink, protocol.Digits{Digits: len(secret), Secret: secret})
+}
+
+func (s *session) guess(number string) error {
+	s.lastGuess = number
+	return s.send(protocol.KindGuess, protocol.Number{Number: number})
+}
+
+// chat prints a chat message, which the server sends HTML-escaped.
+func (s *session) chat(c protocol.Chat) {
+	where := c.Scope
+	if c.Scope == protocol.ScopeRoom {
+		where = "#" + c.Room
+	}
+	when := ""
+	if c.History {
+		when = time.Unix(0, c.Time*int64(time.Millisecond)).Format("15:04 ")
+	}
+	s.printf("[%s] %s%s: %s\n", where, when, html.UnescapeString(entryName(c.From)), html.UnescapeString(c.Text))
+}
+
+// proposals prints what the team proposed, and whether the player is to
+// vote or pick next.
+func (s *session) proposals(ps protocol.Proposals) {
+	s.printf("Proposals of the team:\n")
+	for _, p := range ps.Proposals {
+		s.printf("  %-20s %s\n", entryName(p.Player), p.Number)
+	}
+	switch {
+	case ps.Decision == protocol.DecideByVote:
+		s.voting = true
+	case ps.Captain.ID == s.id:
+		s.printf("You are the captain, pick the guess of the team.\n")
+		s.voting = true
+	default:
+		s.printf("%s picks the guess of the team.\n", entryName(ps.Captain))
+	}
+}
+
+func (s *session) startGame() {
+	if s.inGame {
+		return
+	}
+	s.inGame = true
+	s.moves = 0
+	s.lastGuess = ""
+	s.printf("\nGame started.\n")
+}
+
+func (s *session) gameOver(r protocol.GameOver) {
+	s.inGame = false
+	s.pending = ""
+	s.voting = false
+	s.result = &r
+	if s.bot != nil {
+		s.bot.Reset()
+	}
+
+	switch {
+	case r.Outcome != protocol.OutcomeWin:
+		s.printf("Game over (%s): %s\n", r.Outcome, r.Reason)
+	case s.lastGuess == r.Secret:
+		s.printf("You guessed it!\n")
+	default:
+		s.printf("The number was guessed.\n")
+	}
+	if r.Secret != "" {
+		s.printf("The number was %s, %d guesses were made.\n", r.Secret, len(r.Moves))
+	}
+	if r.ID != "" {
+		s.printf("The game was recorded as %s.\n", r.ID)
+	}
+}
+
+// duelOver prints how a duel ended.
+func (s *session) duelOver(d protocol.DuelOver) {
+	s.inGame = false
+	s.dueling = false
+	s.pending = ""
+	s.result = &d.Guessed
+	if s.bot != nil {
+		s.bot.Reset()
+	}
+
+	switch d.Result {
+	case protocol.DuelWon:
+		s.printf("You won the duel!\n")
+	case protocol.DuelLost:
+		s.printf("You lost the duel.\n")
+	case protocol.DuelDraw:
+		s.printf("The duel is drawn.\n")
+	}
+	if d.Outcome != protocol.OutcomeWin {
+		s.printf("Duel over (%s): %s\n", d.Outcome, d.Reason)
+	}
+	if d.Guessed.Secret != "" {
+		s.printf("The number of your opponent was %s.\n", d.Guessed.Secret)
+	}
+	if d.ID != "" {
+		s.printf("The duel was recorded as %s.\n", d.ID)
+	}
+}
+
+// logMove prints a guess and its score.
+func (s *session) logMove(number string, cows, bulls int) {
+	s.moves++
+	s.printf("%3d. %s  %d %s %d %s\n", s.moves, number,
+		cows, plural(cows, "cow", "cows"), bulls, plural(bulls, "bull", "bulls"))
+}
+
+func (s *session) printPlayers() {
+	s.printf("%d players:\n", len(s.players))
+	for _, p := range s.players {
+		me := ""
+		if p.ID == s.id {
+			me = " (you)"
+		}
+		s.printf("  %-20s %s%s\n", p.Name, p.ID, me)
+	}
+}
+
+// race prints the standings of a race as it starts, whenever a guesser is
+// done and once it is over.
+func (s *session) race(r protocol.Race) {
+	done := 0
+	for _, st := range r.Standings {
+		if st.Done {
+			done++
+		}
+	}
+	switch {
+	case !s.racing && !r.Over:
+		s.racing, s.raceDone = true, done
+		s.printf("Race by %s on the %d-digit number of %s, against %d %s.\n", r.Rank, r.Digits,
+			entryName(r.Thinker), len(r.Standings), plural(len(r.Standings), "guesser", "guessers"))
+		return
+	case r.Over:
+		s.racing = false
+		s.pending = ""
+		if !racer(r, s.id) {
+			// Guessers have the result of their own game instead.
+			s.result = &protocol.GameOver{ID: r.ID, Outcome: r.Outcome, Reason: r.Reason, Secret: r.Secret, Digits: r.Digits}
+		}
+		if s.bot != nil {
+			s.bot.Reset()
+		}
+		if r.Outcome != protocol.OutcomeWin {
+			s.printf("Race over (%s): %s\n", r.Outcome, r.Reason)
+		} else {
+			s.printf("Race over, the number was %s:\n", r.Secret)
+		}
+	case done > s.raceDone:
+		s.raceDone = done
+		s.printf("Standings:\n")
+	default:
+		return
+	}
+	for _, st := range r.Standings {
+		place := "-"
+		if st.Place > 0 {
+			place = fmt.Sprintf("%d.", st.Place)
+		}
+		status := "guessing"
+		switch {
+		case st.Solved:
+			status = "guessed"
+		case st.Done:
+			status = "out"
+		}
+		s.printf("  %3s %-16s %2d %-5s %6.1fs  %s\n", place, entryName(st.Player),
+			st.Turns, plural(st.Turns, "turn", "turns"), float64(st.Millis)/1000, status)
+	}
+}
+
+// strike prints that a guesser failed. It reports whether the session is
+// over, which it is with auto play once the player is dropped.
+func (s *session) strike(st protocol.Strike) bool {
+	if st.Player.ID != s.id {
+		if st.Dropped {
+			s.printf("%s was dropped from the game: %s\n", entryName(st.Player), st.Reason)
+		} else {
+			s.printf("%s was skipped (strike %d): %s\n", entryName(st.Player), st.Strikes, st.Reason)
+		}
+		return false
+	}
+	s.pending = ""
+	if !st.Dropped {
+		s.printf("You were skipped (strike %d): %s\n", st.Strikes, st.Reason)
+		return false
+	}
+	s.printf("You were dropped from the game: %s\n", st.Reason)
+	s.inGame = false
+	if s.bot != nil {
+		s.bot.Reset()
+	}
+	return s.auto != nil
+}
+
+// racer reports whether the player with an ID guesses in a race.
+func racer(r protocol.Race, id string) bool {
+	for _, st := range r.Standings {
+		if st.Player.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *session) printMatched(m protocol.Matched) {
+	opponent := entryName(m.Opponent)
+	if m.AI {
+		opponent = "the computer"
+	}
+	s.printf("Matched with %s. You are the %s of a %d-digit number.\n", opponent, m.Role, m.Digits)
+}
+
+func (s *session) printTournament(t protocol.Tournament) {
+	s.printf("Tournament %s (%s, %d digits): %s", t.ID, t.Format, t.Digits, t.State)
+	if t.State == protocol.StateRegistering {
+		s.printf(", %d %s registered.\n", len(t.Players), plural(len(t.Players), "player", "players"))
+		return
+	}
+	s.printf(", round %d of %d.\n", t.Round, t.Rounds)
+	for _, st := range t.Standings {
+		s.printf("  %2d. %-20s %4.1f points  %d-%d-%d\n",
+			st.Rank, st.Name, st.Points, st.Wins, st.Draws, st.Losses)
+	}
+}
+
+func (s *session) printStats(st protocol.Stats) {
+	if len(st.Ratings) == 0 {
+		s.printf("No games rated for %s.\n", st.ID)
+		return
+	}
+	s.printf("Ratings of %s:\n", st.ID)
+	for _, r := range st.Ratings {
+		s.printf("  %-7s %2d digits  %4.0f  %d %s, %d solved",
+			r.Role, r.Digits, r.Rating, r.Games, plural(r.Games, "game", "games"), r.Solved)
+		if r.Solved > 0 {
+			s.printf(" in %.2f turns on average, best %d in %s", r.AverageTurns, r.BestTurns, r.BestGame)
+		}
+		s.printf("\n")
+	}
+}
+
+func (s *session) send(kind string, payload interface{}) error {
+	data, err := protocol.Encode(payload)
+	if err != nil {
+		return err
+	}
+	return s.conn.WriteJSON(&protocol.Message{Name: kind, Data: data})
+}
+
+func (s *session) printf(format string, args ...interface{}) {
+	fmt.Fprintf(s.out, format, args...)
+}
+
+// entryName returns the name of a player, or its ID if it has none.
+func entryName(p protocol.PlayerEntry) string {
+	if p.Name == "" {
+		return p.ID
+	}
+	return p.Name
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+	"github.com/gorilla/websocket"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+type gamer struct{}
+
+func (gamer) Game(t game.Thinker, g game.Guesser) (*game.Game, error) {
+	return game.New(t, g), nil
+}
+
+var _ = Describe("session", func() {
+	var server *httptest.Server
+	var store *cowbullfakes.FakeStore
+	var accountStore *cowbullfakes.FakeAccountStore
+	var conns []*websocket.Conn
+
+	BeforeEach(func() {
+		conns = nil
+		store = new(cowbullfakes.FakeStore)
+		accountStore = new(cowbullfakes.FakeAccountStore)
+		logger := slog.New(slog.NewTextHandler(GinkgoWriter, nil))
+		server = httptest.NewServer(cowbull.NewServer(&cowbull.ServerConfig{
+			Log:      logger,
+			Hub:      cowbull.NewHub(gamer{}, logger),
+			Upgrader: &websocket.Upgrader{},
+			Store:    store,
+			Accounts: cowbull.NewAccounts(accountStore),
+		}))
+	})
+
+	AfterEach(func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		server.Close()
+	})
+
+	// connectBot connects a bot to the server.
+	connectBot := func(name string, auto *play) *session {
+		url := "ws" + strings.TrimPrefix(server.URL, "http") + "/websocket"
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		Ω(err).ShouldNot(HaveOccurred())
+		conns = append(conns, conn)
+		return &session{
+			conn: conn,
+			out:  GinkgoWriter,
+			name: name,
+			bot:  newBot(4),
+			auto: auto,
+		}
+	}
+
+	// start runs a session of a bot, reading lines from the returned
+	// channel.
+	start := func(s *session) (chan<- string, *gbytes.Buffer) {
+		in, out := make(chan string), gbytes.NewBuffer()
+		s.in, s.out = in, out
+		go s.run()
+		Eventually(out).Should(gbytes.Say("Connected as"))
+		return in, out
+	}
+
+	DescribeTable("a bot playing against the computer",
+		func(mode string) {
+			s := connectBot("alice", &play{mode: mode, digits: 4})
+			result, err := s.run()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(result.Outcome).Should(Equal(protocol.OutcomeWin))
+			Ω(result.Secret).Should(HaveLen(4))
+			Ω(result.Moves).ShouldNot(BeEmpty())
+		},
+		Entry("as a guesser", modeAIThinker),
+		Entry("as a thinker", modeAIGuesser))
+
+	It("should let two bots play against each other", func() {
+		alice := connectBot("alice", nil)
+		go alice.run()
+
+		bob := connectBot("bob", &play{mode: modeGuesser, opponents: []string{"alice"}})
+		result, err := bob.run()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(result.Outcome).Should(Equal(protocol.OutcomeWin))
+		Ω(result.Secret).Should(Equal(bob.secret))
+
+		Eventually(store.RecordCallCount).Should(Equal(1))
+		record := store.RecordArgsForCall(0)
+		Ω(record.Thinker.Name).Should(Equal("bob"))
+		Ω(record.Guessers).Should(HaveLen(1))
+		Ω(record.Guessers[0].Name).Should(Equal("alice"))
+		Ω(record.Result.Moves).Should(HaveLen(len(result.Moves)))
+	})
+
+	It("should show the ratings of a player", func() {
+		alice := connectBot("alice", &play{mode: modeAIThinker, digits: 3})
+		_, err := alice.run()
+		Ω(err).ShouldNot(HaveOccurred())
+		Eventually(store.RecordCallCount).Should(Equal(1))
+
+		in := make(chan string)
+		out := gbytes.NewBuffer()
+		bob := connectBot("bob", nil)
+		bob.bot, bob.in, bob.out = nil, in, out
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			bob.run()
+		}()
+		Eventually(func() *gbytes.Buffer {
+			in <- "stats alice"
+			return out
+		}).Should(gbytes.Say(`Ratings of \S+:\n  guesser  3 digits`))
+		Ω(out).Should(gbytes.Say(`1 game, 1 solved`))
+		close(in)
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("should play a tournament", func() {
+		orgIn, orgOut := start(connectBot("organiser", nil))
+		aliceIn, _ := start(connectBot("alice", nil))
+		bobIn, _ := start(connectBot("bob", nil))
+
+		orgIn <- "tournament round-robin 4"
+		Eventually(orgOut).Should(gbytes.Say(`Tournament (\S+) \(round-robin, 4 digits\): registering`))
+		id := regexp.MustCompile(`Tournament (\S+) `).FindStringSubmatch(string(orgOut.Contents()))[1]
+		aliceIn <- "join " + id
+		bobIn <- "join " + id
+		Eventually(orgOut).Should(gbytes.Say("2 players registered"))
+
+		orgIn <- "start " + id
+		Eventually(orgOut, 5*time.Second).Should(gbytes.Say(`finished, round 1 of 1.\n   1. `))
+		Eventually(store.RecordCallCount).Should(Equal(2))
+	})
+
+	It("should pair two bots waiting in the queue", func() {
+		aliceIn, aliceOut := start(connectBot("alice", nil))
+		bobIn, bobOut := start(connectBot("bob", nil))
+
+		aliceIn <- "queue thinker 3"
+		Eventually(aliceOut).Should(gbytes.Say(`Waiting for an opponent, to play as thinker of a 3-digit number.`))
+		bobIn <- "queue guesser 3 100"
+		Eventually(bobOut).Should(gbytes.Say(`Matched with alice. You are the guesser of a 3-digit number.`))
+		Eventually(aliceOut).Should(gbytes.Say(`Matched with bob. You are the thinker of a 3-digit number.`))
+		Eventually(bobOut, 5*time.Second).Should(gbytes.Say("You guessed it!"))
+
+		Eventually(store.RecordCallCount).Should(Equal(1))
+		record := store.RecordArgsForCall(0)
+		Ω(record.Thinker.Name).Should(Equal("alice"))
+		Ω(record.Result.Digits).Should(Equal(3))
+	})
+
+	It("should let two bots duel", func() {
+		alice := connectBot("alice", nil)
+		go alice.run()
+
+		out := gbytes.NewBuffer()
+		bob := connectBot("bob", &play{mode: modeDuel, opponents: []string{"alice"}, digits: 3})
+		bob.out = out
+		_, err := bob.run()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(out).Should(gbytes.Say(`Duel against alice: both of you think of a 3-digit number`))
+		Ω(out).Should(gbytes.Say(`You (won|lost) the duel|The duel is drawn`))
+
+		Eventually(store.RecordCallCount).Should(Equal(2))
+		Ω(store.RecordArgsForCall(0).Result.Digits).Should(Equal(3))
+	})
+
+	It("should duel the computer", func() {
+		out := gbytes.NewBuffer()
+		s := connectBot("alice", &play{mode: modeAIDuel, digits: 4})
+		s.out = out
+		result, err := s.run()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(result.Secret).Should(HaveLen(4))
+		Ω(out).Should(gbytes.Say(`The number of your opponent was \d{4}.`))
+	})
+
+	It("should let bots race on the number of a bot", func() {
+		go connectBot("alice", nil).run()
+		go connectBot("bob", nil).run()
+
+		out := gbytes.NewBuffer()
+		carol := connectBot("carol", &play{mode: modeRace, opponents: []string{"alice", "bob"}, rank: protocol.RankTime})
+		carol.out = out
+		result, err := carol.run()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(result.Outcome).Should(Equal(protocol.OutcomeWin))
+		Ω(result.Secret).Should(Equal(carol.secret))
+		Ω(out).Should(gbytes.Say(`Race by time on the 4-digit number of carol, against 2 guessers.`))
+		Ω(out).Should(gbytes.Say(`Race over, the number was \d{4}:\n   1. (alice|bob) `))
+
+		Eventually(store.RecordCallCount).Should(Equal(2))
+		Ω(store.RecordArgsForCall(0).Thinker.Name).Should(Equal("carol"))
+	})
+
+	It("should race the computer's number against another bot", func() {
+		go connectBot("alice", nil).run()
+
+		out := gbytes.NewBuffer()
+		bob := connectBot("bob", &play{mode: modeAIRace, opponents: []string{"alice"}, digits: 3})
+		bob.out = out
+		result, err := bob.run()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(result.Digits).Should(Equal(3))
+		Ω(out).Should(gbytes.Say(`Race by turns on the 3-digit number of computer, against 2 guessers.`))
+		Ω(out).Should(gbytes.Say(`Race over`))
+	})
+
+	It("should team up with another bot against the computer", func() {
+		go connectBot("alice", nil).run()
+
+		out := gbytes.NewBuffer()
+		bob := connectBot("bob", &play{mode: modeAITeam, opponents: []string{"alice"}, digits: 3, decision: protocol.DecideByCaptain})
+		bob.out = out
+		result, err := bob.run()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(result.Outcome).Should(Equal(protocol.OutcomeWin))
+		Ω(out).Should(gbytes.Say(`Proposals of the team:\n  bob +\d{3}\n  alice +\d{3}\nYou are the captain`))
+
+		Eventually(store.RecordCallCount).Should(Equal(1))
+		Ω(store.RecordArgsForCall(0).Guessers).Should(HaveLen(2))
+	})
+
+	It("should chat in the lobby and in rooms", func() {
+		aliceIn, aliceOut := start(connectBot("alice", nil))
+		bobIn, bobOut := start(connectBot("<bob>", nil))
+
+		bobIn <- "chat hi <all>"
+		Eventually(aliceOut).Should(gbytes.Say(`\[lobby\] <bob>: hi <all>`))
+
+		aliceIn <- "room den"
+		Eventually(aliceOut).Should(gbytes.Say(`Joined #den with alice.`))
+		aliceIn <- "chat #den anyone?"
+		Eventually(aliceOut).Should(gbytes.Say(`\[#den\] alice: anyone\?`))
+		bobIn <- "room #den"
+		Eventually(bobOut).Should(gbytes.Say(`Joined #den with (alice, <bob>|<bob>, alice).\n\[#den\] \d\d:\d\d alice: anyone\?`))
+
+		bobIn <- "chat game hi"
+		Eventually(bobOut).Should(gbytes.Say(`Error \(not_allowed\): not in a game`))
+	})
+
+	It("should fail on invalid settings", func() {
+		s := connectBot("alice", &play{mode: modeAIThinker, digits: 11})
+		_, err := s.run()
+		Ω(err).Should(HaveOccurred())
+		Ω(err.(*protocol.Error).Code).Should(Equal(protocol.CodeInvalidSettings))
+	})
+
+	Describe("with limits", func() {
+		BeforeEach(func() {
+			server.Close()
+			logger := slog.New(slog.NewTextHandler(GinkgoWriter, nil))
+			server = httptest.NewServer(cowbull.NewServer(&cowbull.ServerConfig{
+				Log:      logger,
+				Hub:      cowbull.NewHub(gamer{}, logger),
+				Upgrader: &websocket.Upgrader{},
+				Limits: &cowbull.Limits{
+					MessageSize: 100,
+					Messages:    map[string]cowbull.Limit{protocol.KindName: {Rate: 0.01, Burst: 2}},
+					Games:       1,
+				},
+			}))
+		})
+
+		It("should refuse games beyond the cap", func() {
+			bob := connectBot("bob", nil)
+			bob.bot = nil
+			bobIn, bobOut := start(bob)
+			bobIn <- "play ai-thinker 4"
+			Eventually(bobOut).Should(gbytes.Say(`Your guess`))
+
+			s := connectBot("alice", &play{mode: modeAIThinker, digits: 4})
+			_, err := s.run()
+			Ω(err).Should(HaveOccurred())
+			Ω(err.(*protocol.Error).Code).Should(Equal(protocol.CodeTooManyGames))
+		})
+
+		It("should disconnect clients sending too large messages", func() {
+			s := connectBot("alice", nil)
+			in, out := make(chan string, 1), gbytes.NewBuffer()
+			s.in, s.out = in, out
+			in <- "chat " + strings.Repeat("x", 200)
+			_, err := s.run()
+			Ω(err).Should(MatchError(ContainSubstring("connection lost")))
+			Ω(out).Should(gbytes.Say(`Error \(too_large\): message larger than 100 bytes`))
+		})
+
+		It("should disconnect clients sending too many messages", func() {
+			in, out := start(connectBot("alice", nil))
+			for _, name := range []string{"a", "b", "c"} {
+				in <- "name " + name
+			}
+			Eventually(out).Should(gbytes.Say(`Error \(rate_limited\): too many name messages`))
+		})
+	})
+
+	Describe("shutting down", func() {
+		var srv *cowbull.Server
+
+		BeforeEach(func() {
+			server.Close()
+			logger := slog.New(slog.NewTextHandler(GinkgoWriter, nil))
+			srv = cowbull.NewServer(&cowbull.ServerConfig{
+				Log:      logger,
+				Hub:      cowbull.NewHub(gamer{}, logger),
+				Upgrader: &websocket.Upgrader{},
+			})
+			server = httptest.NewServer(srv)
+		})
+
+		It("should refuse new games and cancel the games on", func() {
+			bob := connectBot("bob", nil)
+			bob.bot = nil
+			bobIn, bobOut := start(bob)
+			bobIn <- "play ai-thinker 4"
+			Eventually(bobOut).Should(gbytes.Say(`Your guess`))
+
+			srv.Drain(time.Now().Add(time.Minute))
+			Eventually(bobOut).Should(gbytes.Say(`The server is shutting down. Games still on are cancelled at \d\d:\d\d:\d\d.`))
+			s := connectBot("alice", &play{mode: modeAIThinker, digits: 4})
+			_, err := s.run()
+			Ω(err).Should(HaveOccurred())
+			Ω(err.(*protocol.Error).Code).Should(Equal(protocol.CodeShuttingDown))
+
+			Ω(srv.Close(context.Background())).Should(Succeed())
+			Eventually(bobOut).Should(gbytes.Say(`the server is shutting down`))
+		})
+	})
+
+	Describe("with an account", func() {
+		var registered cowbull.Account
+
+		BeforeEach(func() {
+			accountStore.CreateAccountStub = func(a cowbull.Account) error {
+				registered = a
+				return nil
+			}
+			accountStore.AccountStub = func(username string) (cowbull.Account, error) {
+				if username == registered.Username {
+					return registered, nil
+				}
+				return cowbull.Account{}, cowbull.ErrAccountNotFound
+			}
+		})
+
+		It("should play under the ID of the account", func() {
+			s := connectBot("", &play{mode: modeAIThinker, digits: 4})
+			s.login = &protocol.Credentials{Username: "alice", Password: "secret-password"}
+			s.register = true
+			result, err := s.run()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(result.Outcome).Should(Equal(protocol.OutcomeWin))
+			Ω(s.id).Should(Equal(registered.ID))
+
+			Eventually(store.RecordCallCount).Should(Equal(1))
+			guessers := store.RecordArgsForCall(0).Guessers
+			Ω(guessers).Should(Equal([]cowbull.PlayerEntry{{ID: registered.ID, Name: "alice"}}))
+		})
+
+		It("should not let an account connect twice", func() {
+			first := connectBot("", nil)
+			first.login = &protocol.Credentials{Username: "alice", Password: "secret-password"}
+			first.register = true
+			out := gbytes.NewBuffer()
+			first.out = out
+			go first.run()
+			Eventually(out, 5*time.Second).Should(gbytes.Say("Connected as"))
+
+			second := connectBot("", nil)
+			second.login = &protocol.Credentials{Username: "alice", Password: "secret-password"}
+			_, err := second.run()
+			Ω(err).Should(HaveOccurred())
+			Ω(err.(*protocol.Error).Code).Should(Equal(protocol.CodeAuthFailed))
+		})
+
+		It("should fail on wrong credentials", func() {
+			s := connectBot("", nil)
+			s.login = &protocol.Credentials{Username: "bob", Password: "secret-password"}
+			_, err := s.run()
+			Ω(err).Should(HaveOccurred())
+			Ω(err.(*protocol.Error).Code).Should(Equal(protocol.CodeAuthFailed))
+		})
+	})
+})
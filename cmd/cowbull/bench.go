@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/bench"
+)
+
+// benchmark plays AI guessers against AI thinkers and reports how well
+// they did. It returns the exit status.
+func benchmark(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	digits := fs.Int("digits", 4, "Digit count of the numbers.")
+	games := fs.Int("games", 1000, "Count of games to play, unless the thinker is exhaustive.")
+	guesser := fs.String("guesser", "random", "Strategy of the guesser: random, first or minmax.")
+	thinker := fs.String("thinker", bench.ThinkerRandom, "Thinker: random, adversarial or exhaustive.")
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "Count of games played in parallel.")
+	asJSON := fs.Bool("json", false, "Write the report as JSON.")
+	treePath := fs.String("tree", "", "Decision tree file, built by solve, for the guesser to play from.")
+	fs.Parse(args)
+
+	var tree *cowbull.Tree
+	if *treePath != "" {
+		var err error
+		if tree, err = loadTree(*treePath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	r, err := bench.Run(bench.Config{
+		Digits:  *digits,
+		Games:   *games,
+		Guesser: *guesser,
+		Thinker: *thinker,
+		Workers: *workers,
+		Tree:    tree,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if *asJSON {
+		err = r.WriteJSON(os.Stdout)
+	} else {
+		err = r.WriteText(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+// envPrefix prefixes the environment variables overriding the settings.
+const envPrefix = "COWBULL_"
+
+// config holds the settings of the server. They are read from a JSON config
+// file, whose keys are the names of the flags, then overridden by environment
+// variables, named after the flags, and at last by the flags themselves.
+type config struct {
+	Address          string
+	Static           string
+	TLSCert          string
+	TLSKey           string
+	SkipOriginCheck  bool
+	AllowedOrigins   list
+	HandshakeTimeout time.Duration
+	PlayerTimeout    time.Duration
+	ReadTimeout      time.Duration
+	ReadRetries      int
+	RetryInterval    time.Duration
+	Trees            list
+	Store            string
+	Accounts         string
+	MatchAIAfter     time.Duration
+	Strikes          int
+	Rejoin           bool
+	ChatRate         int
+	ChatHistory      int
+	MaxMessageSize   int
+	RateLimits       rateLimits
+	MaxGames         int
+	MaxPlayerGames   int
+	MaxTournaments   int
+	Drain            time.Duration
+	AdminToken       string
+	LogFormat        string
+	LogLevel         string
+}
+
+// newConfig returns the default settings.
+func newConfig() *config {
+	limits := cowbull.DefaultLimits()
+	return &config{
+		Address:          "127.0.0.1:8080",
+		HandshakeTimeout: 5 * time.Second,
+		PlayerTimeout:    60 * time.Second,
+		ReadTimeout:      120 * time.Second,
+		ReadRetries:      3,
+		RetryInterval:    time.Second,
+		Strikes:          1,
+		ChatRate:         5,
+		ChatHistory:      50,
+		MaxMessageSize:   limits.MessageSize,
+		RateLimits:       rateLimits{},
+		MaxGames:         limits.Games,
+		MaxPlayerGames:   limits.GamesPerPlayer,
+		MaxTournaments:   limits.Tournaments,
+		Drain:            30 * time.Second,
+		LogFormat:        "text",
+		LogLevel:         "info",
+	}
+}
+
+// flags defines a flag of fs for every setting of c, with its current value
+// as the default.
+func (c *config) flags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Address, "address", c.Address, addrUsage)
+	fs.StringVar(&c.Static, "static", c.Static, staticUsage)
+	fs.StringVar(&c.TLSCert, "tls-cert", c.TLSCert, tlsCertUsage)
+	fs.StringVar(&c.TLSKey, "tls-key", c.TLSKey, tlsKeyUsage)
+	fs.BoolVar(&c.SkipOriginCheck, "skip-origin-check", c.SkipOriginCheck, checkOriginUsage)
+	fs.Var(&c.AllowedOrigins, "allowed-origins", allowedOriginsUsage)
+	fs.DurationVar(&c.HandshakeTimeout, "handshake-timeout", c.HandshakeTimeout, handshakeUsage)
+	fs.DurationVar(&c.PlayerTimeout, "player-timeout", c.PlayerTimeout, playerTimeoutUsage)
+	fs.DurationVar(&c.ReadTimeout, "read-timeout", c.ReadTimeout, readTimeoutUsage)
+	fs.IntVar(&c.ReadRetries, "read-retries", c.ReadRetries, readRetriesUsage)
+	fs.DurationVar(&c.RetryInterval, "retry-interval", c.RetryInterval, retryIntervalUsage)
+	fs.Var(&c.Trees, "trees", treesUsage)
+	fs.StringVar(&c.Store, "store", c.Store, storeUsage)
+	fs.StringVar(&c.Accounts, "accounts", c.Accounts, accountsUsage)
+	fs.DurationVar(&c.MatchAIAfter, "match-ai-after", c.MatchAIAfter, matchAIAfterUsage)
+	fs.IntVar(&c.Strikes, "strikes", c.Strikes, strikesUsage)
+	fs.BoolVar(&c.Rejoin, "rejoin", c.Rejoin, rejoinUsage)
+	fs.IntVar(&c.ChatRate, "chat-rate", c.ChatRate, chatRateUsage)
+	fs.IntVar(&c.ChatHistory, "chat-history", c.ChatHistory, chatHistoryUsage)
+	fs.IntVar(&c.MaxMessageSize, "max-message-size", c.MaxMessageSize, maxMessageUsage)
+	fs.Var(&c.RateLimits, "rate-limits", rateLimitsUsage)
+	fs.IntVar(&c.MaxGames, "max-games", c.MaxGames, maxGamesUsage)
+	fs.IntVar(&c.MaxPlayerGames, "max-games-per-player", c.MaxPlayerGames, maxPlayerUsage)
+	fs.IntVar(&c.MaxTournaments, "max-tournaments", c.MaxTournaments, maxTournamentsUsage)
+	fs.DurationVar(&c.Drain, "drain", c.Drain, drainUsage)
+	fs.StringVar(&c.AdminToken, "admin-token", c.AdminToken, adminTokenUsage)
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, logFormatUsage)
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, logLevelUsage)
+}
+
+// loadConfig loads the settings from the config file at path, or at the
+// path in COWBULL_CONFIG if path is empty, from the environment variables
+// looked up with lookupEnv and from the flags of set that were set.
+func loadConfig(path string, lookupEnv func(string) (string, bool), set *flag.FlagSet) (*config, error) {
+	c := newConfig()
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	c.flags(fs)
+
+	if path == "" {
+		path, _ = lookupEnv(envPrefix + "CONFIG")
+	}
+	if path != "" {
+		if err := readConfig(path, fs); err != nil {
+			return nil, err
+		}
+	}
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		name := envName(f.Name)
+		if v, ok := lookupEnv(name); ok {
+			if err := fs.Set(f.Name, v); err != nil {
+				errs = append(errs, fmt.Errorf("invalid value %q for %s: %v", v, name, err))
+			}
+		}
+	})
+	set.Visit(func(f *flag.Flag) {
+		if fs.Lookup(f.Name) != nil {
+			// The value was parsed once already.
+			fs.Set(f.Name, f.Value.String())
+		}
+	})
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return c, nil
+}
+
+// readConfig sets the flags of fs to the values in the JSON config file at
+// path.
+func readConfig(path string, fs *flag.FlagSet) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var settings map[string]interface{}
+	if err := d.Decode(&settings); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var errs []error
+	for _, name := range names {
+		v := settings[name]
+		if fs.Lookup(name) == nil {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", path, name))
+			continue
+		}
+		s, err := flagValue(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %v", path, name, err))
+		} else if err := fs.Set(name, s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid value %q for %s: %v", path, s, name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// flagValue formats a value of a config file as the value of a flag.
+func flagValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		var l list
+		for _, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return "", fmt.Errorf("list item %v is not a string", e)
+			}
+			l = append(l, s)
+		}
+		return l.String(), nil
+	}
+	return "", fmt.Errorf("%v is not a string, number, boolean or list", v)
+}
+
+// envName returns the name of the environment variable overriding the
+// setting of a flag, e.g. COWBULL_MAX_GAMES for max-games.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// dump writes the settings to w as a JSON config file, with the admin token
+// hidden.
+func (c *config) dump(w io.Writer) error {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	c.flags(fs)
+	settings := make(map[string]interface{})
+	fs.VisitAll(func(f *flag.Flag) {
+		v := f.Value.(flag.Getter).Get()
+		if d, ok := v.(time.Duration); ok {
+			v = d.String()
+		}
+		settings[f.Name] = v
+	})
+	if c.AdminToken != "" {
+		settings["admin-token"] = "<hidden>"
+	}
+	e := json.NewEncoder(w)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "  ")
+	return e.Encode(settings)
+}
+
+// validate reports every invalid setting.
+func (c *config) validate() error {
+	var errs []error
+	invalid := func(name, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", name, fmt.Sprintf(format, args...)))
+	}
+
+	if _, _, err := net.SplitHostPort(c.Address); err != nil {
+		invalid("address", "%v", err)
+	}
+	if c.Static != "" {
+		if fi, err := os.Stat(c.Static); err != nil {
+			invalid("static", "%v", err)
+		} else if !fi.IsDir() {
+			invalid("static", "%s is not a directory", c.Static)
+		}
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		errs = append(errs, errors.New("tls-cert and tls-key must be set together"))
+	}
+	for _, origin := range c.AllowedOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			invalid("allowed-origins", "invalid origin %q, expected SCHEME://HOST[:PORT]", origin)
+		}
+	}
+	if c.SkipOriginCheck && len(c.AllowedOrigins) > 0 {
+		invalid("allowed-origins", "useless with skip-origin-check")
+	}
+	positive := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"handshake-timeout", c.HandshakeTimeout},
+		{"player-timeout", c.PlayerTimeout},
+		{"read-timeout", c.ReadTimeout},
+	}
+	for _, s := range positive {
+		if s.d <= 0 {
+			invalid(s.name, "must be positive, not %v", s.d)
+		}
+	}
+	nonNegative := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"retry-interval", c.RetryInterval},
+		{"match-ai-after", c.MatchAIAfter},
+		{"drain", c.Drain},
+	}
+	for _, s := range nonNegative {
+		if s.d < 0 {
+			invalid(s.name, "must not be negative, not %v", s.d)
+		}
+	}
+	if c.Strikes < 1 {
+		invalid("strikes", "must be at least 1, not %d", c.Strikes)
+	}
+	counts := []struct {
+		name string
+		n    int
+	}{
+		{"read-retries", c.ReadRetries},
+		{"chat-rate", c.ChatRate},
+		{"chat-history", c.ChatHistory},
+		{"max-message-size", c.MaxMessageSize},
+		{"max-games", c.MaxGames},
+		{"max-games-per-player", c.MaxPlayerGames},
+		{"max-tournaments", c.MaxTournaments},
+	}
+	for _, s := range counts {
+		if s.n < 0 {
+			invalid(s.name, "must not be negative, not %d", s.n)
+		}
+	}
+	kinds := make([]string, 0, len(c.RateLimits))
+	for kind := range c.RateLimits {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		l := c.RateLimits[kind]
+		if !clientKind(kind) {
+			invalid("rate-limits", "unknown message kind %q", kind)
+		}
+		if l.Rate <= 0 {
+			invalid("rate-limits", "%s: rate must be positive, not %v", kind, l.Rate)
+		}
+		if l.Burst < 1 {
+			invalid("rate-limits", "%s: burst must be at least 1, not %d", kind, l.Burst)
+		}
+	}
+	if c.Store != "" {
+		if _, _, err := parseSpec(c.Store); err != nil {
+			invalid("store", "%v", err)
+		}
+	}
+	if c.Accounts != "" {
+		if _, _, err := parseSpec(c.Accounts); err != nil {
+			invalid("accounts", "%v", err)
+		}
+	}
+	if _, err := newLogger(io.Discard, c.LogFormat, c.LogLevel); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// checkOrigin returns the check of the Origin header of WebSocket
+// handshakes: nil for the default check, that the origin is the server
+// itself, or a check also letting in the allowed origins.
+func (c *config) checkOrigin() func(*http.Request) bool {
+	if c.SkipOriginCheck {
+		return func(*http.Request) bool {
+			return true
+		}
+	}
+	if len(c.AllowedOrigins) == 0 {
+		return nil
+	}
+	return func(req *http.Request) bool {
+		origin := req.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range c.AllowedOrigins {
+			if strings.EqualFold(origin, allowed) {
+				return true
+			}
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, req.Host)
+	}
+}
+
+// list is a comma separated list of strings, as a flag.
+type list []string
+
+func (l *list) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *list) Set(s string) error {
+	*l = nil
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			*l = append(*l, e)
+		}
+	}
+	return nil
+}
+
+func (l *list) Get() interface{} {
+	return append([]string{}, *l...)
+}
+
+// clientKind reports whether clients send messages of a kind, or whether
+// kind is cowbull.AnyKind.
+func clientKind(kind string) bool {
+	if kind == cowbull.AnyKind {
+		return true
+	}
+	for _, spec := range protocol.Specs {
+		if spec.Kind == kind && spec.Client != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// rateLimits are limits of message kinds, as a flag of comma separated
+// KIND=RATE/BURST items. Setting it adds to the limits set before.
+type rateLimits map[string]cowbull.Limit
+
+func (r *rateLimits) String() string {
+	if r == nil {
+		return ""
+	}
+	return strings.Join(r.items(), ",")
+}
+
+func (r *rateLimits) Set(s string) error {
+	if *r == nil {
+		*r = make(rateLimits)
+	}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		kind, limit, ok := strings.Cut(item, "=")
+		rate, burst, ok2 := strings.Cut(limit, "/")
+		if !ok || !ok2 {
+			return fmt.Errorf("invalid rate limit %q, expected KIND=RATE/BURST", item)
+		}
+		var l cowbull.Limit
+		var err error
+		if l.Rate, err = strconv.ParseFloat(rate, 64); err != nil {
+			return fmt.Errorf("invalid rate in %q", item)
+		}
+		if l.Burst, err = strconv.Atoi(burst); err != nil {
+			return fmt.Errorf("invalid burst in %q", item)
+		}
+		(*r)[strings.TrimSpace(kind)] = l
+	}
+	return nil
+}
+
+func (r *rateLimits) Get() interface{} {
+	return r.items()
+}
+
+// items returns the limits as KIND=RATE/BURST items, ordered by kind.
+func (r *rateLimits) items() []string {
+	items := make([]string, 0, len(*r))
+	for kind, l := range *r {
+		items = append(items, fmt.Sprintf("%s=%s/%d", kind, strconv.FormatFloat(l.Rate, 'g', -1, 64), l.Burst))
+	}
+	sort.Strings(items)
+	return items
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("config", func() {
+	var dir string
+	var env map[string]string
+	var set *flag.FlagSet
+
+	lookupEnv := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	writeConfig := func(content string) string {
+		path := filepath.Join(dir, "cowbull.json")
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "cowbull-config")
+		Expect(err).NotTo(HaveOccurred())
+		env = make(map[string]string)
+		set = flag.NewFlagSet("serve", flag.ContinueOnError)
+		newConfig().flags(set)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("loadConfig", func() {
+		It("should default to the built-in settings", func() {
+			c, err := loadConfig("", lookupEnv, set)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c).To(Equal(newConfig()))
+		})
+
+		It("should let the config file, the environment and the flags override each other in turn", func() {
+			path := writeConfig(`{
+				"address": ":9000",
+				"max-games": 5,
+				"rejoin": true,
+				"drain": "10s",
+				"trees": ["a.tree", "b.tree"]
+			}`)
+			env["COWBULL_MAX_GAMES"] = "7"
+			env["COWBULL_DRAIN"] = "20s"
+			Expect(set.Parse([]string{"-drain", "5s"})).To(Succeed())
+
+			c, err := loadConfig(path, lookupEnv, set)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Address).To(Equal(":9000"))
+			Expect(c.MaxGames).To(Equal(7))
+			Expect(c.Rejoin).To(BeTrue())
+			Expect(c.Drain).To(Equal(5 * time.Second))
+			Expect(c.Trees).To(Equal(list{"a.tree", "b.tree"}))
+			Expect(c.ChatRate).To(Equal(5))
+		})
+
+		It("should read the config file named by COWBULL_CONFIG", func() {
+			env["COWBULL_CONFIG"] = writeConfig(`{"strikes": 3}`)
+			c, err := loadConfig("", lookupEnv, set)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Strikes).To(Equal(3))
+		})
+
+		It("should report unknown and malformed settings", func() {
+			path := writeConfig(`{"adress": ":9000", "strikes": "many", "trees": [1]}`)
+			_, err := loadConfig(path, lookupEnv, set)
+			Expect(err).To(MatchError(ContainSubstring(`unknown setting "adress"`)))
+			Expect(err).To(MatchError(ContainSubstring(`invalid value "many" for strikes`)))
+			Expect(err).To(MatchError(ContainSubstring(`trees: list item 1 is not a string`)))
+
+			env["COWBULL_READ_TIMEOUT"] = "soon"
+			_, err = loadConfig("", lookupEnv, set)
+			Expect(err).To(MatchError(ContainSubstring(`invalid value "soon" for COWBULL_READ_TIMEOUT`)))
+		})
+
+		It("should add the rate limits of each source to the ones before", func() {
+			path := writeConfig(`{"rate-limits": ["chat=2/20", "play=1/5"]}`)
+			env["COWBULL_RATE_LIMITS"] = "play=0.5/3"
+			Expect(set.Parse([]string{"-rate-limits", "*=10/20"})).To(Succeed())
+
+			c, err := loadConfig(path, lookupEnv, set)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.RateLimits).To(Equal(rateLimits{
+				"chat": {Rate: 2, Burst: 20},
+				"play": {Rate: 0.5, Burst: 3},
+				"*":    {Rate: 10, Burst: 20},
+			}))
+
+			env["COWBULL_RATE_LIMITS"] = "play=often"
+			_, err = loadConfig("", lookupEnv, set)
+			Expect(err).To(MatchError(ContainSubstring(`invalid value "play=often" for COWBULL_RATE_LIMITS: invalid rate limit "play=often", expected KIND=RATE/BURST`)))
+		})
+
+		It("should fail on a missing config file", func() {
+			_, err := loadConfig(filepath.Join(dir, "missing.json"), lookupEnv, set)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("validate", func() {
+		var c *config
+
+		BeforeEach(func() {
+			c = newConfig()
+		})
+
+		It("should accept the default settings", func() {
+			Expect(c.validate()).To(Succeed())
+		})
+
+		It("should accept a directory to serve the content from", func() {
+			c.Static = dir
+			Expect(c.validate()).To(Succeed())
+		})
+
+		It("should report every invalid setting", func() {
+			c.Address = "nowhere"
+			c.Static = filepath.Join(dir, "missing")
+			c.TLSKey = "key.pem"
+			c.AllowedOrigins = list{"example.com"}
+			c.PlayerTimeout = 0
+			c.Drain = -time.Second
+			c.Strikes = 0
+			c.MaxGames = -1
+			c.Store = "csv:games.csv"
+			c.LogLevel = "loud"
+
+			err := c.validate()
+			Expect(err).To(HaveOccurred())
+			lines := strings.Split(err.Error(), "\n")
+			Expect(lines).To(HaveLen(10))
+			Expect(lines).To(ContainElement(HavePrefix("address: ")))
+			Expect(lines).To(ContainElement(HavePrefix("static: ")))
+			Expect(lines).To(ContainElement("tls-cert and tls-key must be set together"))
+			Expect(lines).To(ContainElement(`allowed-origins: invalid origin "example.com", expected SCHEME://HOST[:PORT]`))
+			Expect(lines).To(ContainElement("player-timeout: must be positive, not 0s"))
+			Expect(lines).To(ContainElement("drain: must not be negative, not -1s"))
+			Expect(lines).To(ContainElement("strikes: must be at least 1, not 0"))
+			Expect(lines).To(ContainElement("max-games: must not be negative, not -1"))
+			Expect(lines).To(ContainElement(`store: unknown store kind "csv"`))
+			Expect(lines).To(ContainElement(`invalid log level "loud"`))
+		})
+
+		It("should report invalid rate limits", func() {
+			c.RateLimits = rateLimits{
+				"chat":  {Rate: 1, Burst: 10},
+				"*":     {Rate: 20, Burst: 40},
+				"shout": {Rate: 1, Burst: 1},
+				"play":  {Rate: 0, Burst: 0},
+				"pong":  {Rate: 1, Burst: 1},
+			}
+			err := c.validate()
+			Expect(err).To(HaveOccurred())
+			Expect(strings.Split(err.Error(), "\n")).To(Equal([]string{
+				"rate-limits: play: rate must be positive, not 0",
+				"rate-limits: play: burst must be at least 1, not 0",
+				`rate-limits: unknown message kind "pong"`,
+				`rate-limits: unknown message kind "shout"`,
+			}))
+		})
+
+		It("should not allow origins when skipping the origin check", func() {
+			c.SkipOriginCheck = true
+			c.AllowedOrigins = list{"https://example.com"}
+			Expect(c.validate()).To(MatchError("allowed-origins: useless with skip-origin-check"))
+		})
+	})
+
+	Describe("dump", func() {
+		It("should write the settings as a config file, hiding the admin token", func() {
+			c := newConfig()
+			c.AdminToken = "s3cret"
+			c.Trees = list{"a.tree"}
+			c.RateLimits = rateLimits{"chat": {Rate: 0.5, Burst: 10}}
+			var b strings.Builder
+			Expect(c.dump(&b)).To(Succeed())
+			Expect(b.String()).NotTo(ContainSubstring("s3cret"))
+
+			var settings map[string]interface{}
+			Expect(json.Unmarshal([]byte(b.String()), &settings)).To(Succeed())
+			Expect(settings).To(HaveKeyWithValue("admin-token", "<hidden>"))
+			Expect(settings).To(HaveKeyWithValue("drain", "30s"))
+			Expect(settings).To(HaveKeyWithValue("trees", []interface{}{"a.tree"}))
+			Expect(settings).To(HaveKeyWithValue("allowed-origins", []interface{}{}))
+			Expect(settings).To(HaveKeyWithValue("rate-limits", []interface{}{"chat=0.5/10"}))
+
+			c.AdminToken = ""
+			b.Reset()
+			Expect(c.dump(&b)).To(Succeed())
+			loaded, err := loadConfig(writeConfig(b.String()), lookupEnv, set)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded).To(Equal(c))
+		})
+	})
+
+	Describe("checkOrigin", func() {
+		var c *config
+
+		handshake := func(host, origin string) bool {
+			req := httptest.NewRequest("GET", "http://"+host+"/websocket", nil)
+			req.Header.Set("Origin", origin)
+			return c.checkOrigin()(req)
+		}
+
+		BeforeEach(func() {
+			c = newConfig()
+		})
+
+		It("should leave the check to the upgrader by default", func() {
+			Expect(c.checkOrigin()).To(BeNil())
+		})
+
+		It("should let in any origin when skipping the check", func() {
+			c.SkipOriginCheck = true
+			Expect(handshake("cowbull.test", "https://evil.test")).To(BeTrue())
+		})
+
+		It("should let in the server itself and the allowed origins only", func() {
+			c.AllowedOrigins = list{"https://example.com"}
+			Expect(handshake("cowbull.test", "https://cowbull.test")).To(BeTrue())
+			Expect(handshake("cowbull.test", "https://Example.com")).To(BeTrue())
+			Expect(handshake("cowbull.test", "https://evil.test")).To(BeFalse())
+		})
+	})
+})
@@ -1,10 +1,23 @@
+// Command cowbull runs a cowbull game server.
+//
+// Usage:
+//
+//	cowbull [serve] [flags]   run the game server
+//	cowbull play [flags]      play a game against the computer, offline
+//	cowbull bench [flags]     measure how well the computer guesses
+//	cowbull solve [flags]     build the decision tree of a guessing strategy
 package main
 
 import (
+	"context"
 	"flag"
-	"log"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/Bo0mer/cowbull"
@@ -13,47 +26,252 @@ import (
 )
 
 var (
-	addr            string
-	skipOriginCheck bool
+	// cmdline holds the settings given as flags.
+	cmdline    = newConfig()
+	configPath string
+	dumpConfig bool
 )
 
 const (
-	addrUsage        = "Server address."
-	checkOriginUsage = "Skip Origin header check upon WebSocket connection negotiation."
+	configUsage         = "JSON config file, whose keys are the names of the flags. Environment variables named COWBULL_ and the flag name, like COWBULL_MAX_GAMES, override its settings, and flags override both. Defaults to COWBULL_CONFIG."
+	dumpConfigUsage     = "Print the effective settings, with the admin token hidden, and exit."
+	addrUsage           = "Server address."
+	staticUsage         = "Directory to serve the content for browsers from, like ./static/ to try changes to it without rebuilding. By default the content built into the binary is served."
+	tlsCertUsage        = "Certificate file to serve HTTPS with, together with -tls-key. By default the server serves plain HTTP."
+	tlsKeyUsage         = "Private key file of -tls-cert."
+	allowedOriginsUsage = "Comma separated `origins`, like https://example.com, that may open WebSocket connections besides the server itself."
+	handshakeUsage      = "How long WebSocket handshakes may take."
+	playerTimeoutUsage  = "How long players have to answer during a game."
+	readTimeoutUsage    = "How long a connection may stay silent before a read from it fails."
+	readRetriesUsage    = "How many times a failed read from a connection is retried before it is considered broken."
+	retryIntervalUsage  = "How long to wait before retrying a failed read."
+	checkOriginUsage    = "Skip Origin header check upon WebSocket connection negotiation."
+	treesUsage          = "Comma separated decision tree `files`, built by solve, for the AI guesser to play from."
+	storeUsage          = "Where to record finished games: jsonl:PATH or sqlite:PATH. By default they are not recorded."
+	accountsUsage       = "Where to keep player accounts: jsonl:PATH or sqlite:PATH. By default players cannot register."
+	matchAIAfterUsage   = "How long players may wait in the matchmaking queue before they play against the computer. Zero means as long as it takes."
+	strikesUsage        = "How many times a guesser of a game with several guessers may fail before it is dropped."
+	rejoinUsage         = "Let guessers dropped from a game with several guessers back in when they connect again under the same ID."
+	chatRateUsage       = "How many chat messages a player may send every 10 seconds."
+	chatHistoryUsage    = "How many of the last chat messages of the lobby and of each room are sent to players joining them."
+	maxMessageUsage     = "How many bytes of data a message from a client may carry before the client is disconnected. Zero means no limit."
+	rateLimitsUsage     = "Comma separated `limits` of how often a client may send messages of a kind, as KIND=RATE/BURST, like chat=1/10: BURST messages at once, and then RATE per second. They override the default limits of these kinds, and * stands for the kinds not listed. Clients going beyond a limit are disconnected."
+	maxGamesUsage       = "How many games may be played at once. Zero means no limit."
+	maxPlayerUsage      = "How many games a player may request at once. Zero means no limit."
+	maxTournamentsUsage = "How many tournaments may be registering or running at once. Zero means no limit."
+	drainUsage          = "How long the games on may go on after SIGINT or SIGTERM before they are cancelled and the server exits. New games are refused meanwhile."
+	adminTokenUsage     = "Token that lets admins in to /admin, as a bearer token or the token query parameter. By default there is no admin page."
+	logFormatUsage      = "Format of the log lines: text or json."
+	logLevelUsage       = "Least level of the lines logged: debug, info, warn or error. Moves are logged at debug level."
 )
 
 func init() {
-	flag.StringVar(&addr, "address", "127.0.0.1:8080", addrUsage)
-	flag.BoolVar(&skipOriginCheck, "skip-origin-check", false, checkOriginUsage)
+	flag.StringVar(&configPath, "config", "", configUsage)
+	flag.BoolVar(&dumpConfig, "dump-config", false, dumpConfigUsage)
+	cmdline.flags(flag.CommandLine)
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  cowbull [serve] [flags]\trun the game server\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  cowbull play [flags]\tplay a game against the computer, offline\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  cowbull bench [flags]\tmeasure how well the computer guesses\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  cowbull solve [flags]\tbuild the decision tree of a guessing strategy\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "\nFlags of serve:\n")
+		flag.PrintDefaults()
+	}
 }
 
 func main() {
-	flag.Parse()
-
-	var checkOrigin func(*http.Request) bool
-	if skipOriginCheck {
-		checkOrigin = func(_ *http.Request) bool {
-			return true
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "play":
+			os.Exit(play(args[1:]))
+		case "bench":
+			os.Exit(benchmark(args[1:]))
+		case "solve":
+			os.Exit(solve(args[1:]))
+		case "serve":
+			args = args[1:]
 		}
+	}
+	flag.CommandLine.Parse(args)
+	serve()
+}
 
+func serve() {
+	cfg, err := loadConfig(configPath, os.LookupEnv, flag.CommandLine)
+	if err == nil && dumpConfig {
+		err = cfg.dump(os.Stdout)
+	}
+	if err == nil {
+		err = cfg.validate()
 	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if dumpConfig {
+		return
+	}
+	logger, err := newLogger(os.Stdout, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
 
 	gamer := gamer{}
-	playerHub := cowbull.NewHub(gamer, log.New(os.Stdout, "hub: ", 0))
+	playerHub := cowbull.NewHub(gamer, logger.With("component", "hub"))
+	for _, path := range cfg.Trees {
+		tree, err := loadTree(path)
+		if err != nil {
+			fatal(logger, "error loading decision tree", "path", path, "err", err)
+		}
+		playerHub.UseTree(tree)
+	}
+	var gameStore cowbull.Store
+	ratings := cowbull.NewRatings()
+	if cfg.Store != "" {
+		var err error
+		if gameStore, err = openStore(cfg.Store); err != nil {
+			fatal(logger, "error opening store", "err", err)
+		}
+		records, err := gameStore.Games()
+		if err != nil {
+			fatal(logger, "error reading game history", "err", err)
+		}
+		for _, r := range records {
+			ratings.Add(r)
+		}
+	}
+	playerHub.UseRatings(ratings)
+	playerHub.FallBackToAI(cfg.MatchAIAfter)
+	playerHub.StrikeGuessers(cfg.Strikes, cfg.Rejoin)
+	playerHub.LimitChat(cfg.ChatRate, cfg.ChatHistory)
+	// Stores to close, flushing them, once the server is shut down.
+	var stores []io.Closer
+	if c, ok := gameStore.(io.Closer); ok {
+		stores = append(stores, c)
+	}
+	var accounts *cowbull.Accounts
+	if cfg.Accounts != "" {
+		// An SQLite database serving as both is opened once.
+		accountStore, ok := gameStore.(cowbull.AccountStore)
+		if !ok || cfg.Accounts != cfg.Store {
+			var err error
+			if accountStore, err = openAccounts(cfg.Accounts); err != nil {
+				fatal(logger, "error opening account store", "err", err)
+			}
+			if c, ok := accountStore.(io.Closer); ok {
+				stores = append(stores, c)
+			}
+		}
+		accounts = cowbull.NewAccounts(accountStore)
+	}
+
+	limits := cowbull.DefaultLimits()
+	limits.MessageSize = cfg.MaxMessageSize
+	for kind, l := range cfg.RateLimits {
+		limits.Messages[kind] = l
+	}
+	limits.Games = cfg.MaxGames
+	limits.GamesPerPlayer = cfg.MaxPlayerGames
+	limits.Tournaments = cfg.MaxTournaments
+
 	srv := cowbull.NewServer(&cowbull.ServerConfig{
-		StaticFilesPath: "./static/",
-		Log:             log.New(os.Stdout, "server: ", 0),
+		StaticFilesPath: cfg.Static,
+		Log:             logger.With("component", "server"),
 		Hub:             playerHub,
+		Store:           gameStore,
+		Accounts:        accounts,
+		Ratings:         ratings,
+		Limits:          &limits,
+		AdminToken:      cfg.AdminToken,
+		PlayerTimeout:   cfg.PlayerTimeout,
+		ClientOptions: []cowbull.ClientOption{
+			cowbull.ReadTimeout(cfg.ReadTimeout),
+			cowbull.RetryCount(cfg.ReadRetries),
+			cowbull.RetryInterval(cfg.RetryInterval),
+		},
 		Upgrader: &websocket.Upgrader{
-			HandshakeTimeout:  time.Second * 5,
-			CheckOrigin:       checkOrigin,
+			HandshakeTimeout:  cfg.HandshakeTimeout,
+			CheckOrigin:       cfg.checkOrigin(),
 			EnableCompression: false,
 		},
 	})
 
-	if err := http.ListenAndServe(addr, srv); err != nil {
-		log.Fatalf("error serving: %v\n", err)
+	httpServer := &http.Server{Addr: cfg.Address, Handler: srv}
+	errs := make(chan error, 1)
+	go func() {
+		if cfg.TLSCert != "" {
+			errs <- httpServer.ListenAndServeTLS(cfg.TLSCert, cfg.TLSKey)
+			return
+		}
+		errs <- httpServer.ListenAndServe()
+	}()
+	logger.Info("serving", "address", cfg.Address, "tls", cfg.TLSCert != "")
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		fatal(logger, "error serving", "err", err)
+	case sig := <-signals:
+		logger.Info("shutting down; signal again to exit at once", "signal", sig.String(), "drain", cfg.Drain)
+	}
+	go func() {
+		<-signals
+		fatal(logger, "exiting before the shutdown is over")
+	}()
+	shutdown(logger, httpServer, srv, stores, cfg.Drain)
+}
+
+// newLogger creates a logger writing lines of a format, text or json, and
+// of at least a level to w.
+func newLogger(w io.Writer, format, level string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q", format)
+}
+
+// fatal logs msg with args as an error and exits.
+func fatal(logger *slog.Logger, msg string, args ...interface{}) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+// shutdown lets the games on go on for the drain period, refusing new ones,
+// then stops serving, cancels the games left and closes the stores.
+func shutdown(logger *slog.Logger, httpServer *http.Server, srv *cowbull.Server, stores []io.Closer, drain time.Duration) {
+	srv.Drain(time.Now().Add(drain))
+	ctx, cancel := context.WithTimeout(context.Background(), drain)
+	if err := srv.Wait(ctx); err != nil {
+		logger.Info("cancelling the games left")
+	}
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		logger.Error("error shutting down the HTTP server", "err", err)
+	}
+	if err := srv.Close(ctx); err != nil {
+		logger.Error("error waiting for the games to be cancelled", "err", err)
+	}
+	for _, s := range stores {
+		if err := s.Close(); err != nil {
+			logger.Error("error closing store", "err", err)
+		}
 	}
+	logger.Info("shut down")
 }
 
 type gamer struct{}
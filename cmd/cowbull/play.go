@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/game"
+)
+
+// play plays a game between the user, on stdin and stdout, and the
+// computer. It returns the exit status.
+func play(args []string) int {
+	fs := flag.NewFlagSet("play", flag.ExitOnError)
+	digits := fs.Int("digits", 4, "Digit count of the number.")
+	role := fs.String("role", cowbull.RoleGuesser, "Your role, either guesser or thinker.")
+	fs.Parse(args)
+
+	if *digits < 1 || *digits > 10 {
+		fmt.Fprintf(os.Stderr, "invalid digit count %d\n", *digits)
+		return 2
+	}
+
+	user := cowbull.NewTerminalPlayer(os.Stdin, os.Stdout, *digits)
+	var g *game.Game
+	switch *role {
+	case cowbull.RoleGuesser:
+		fmt.Printf("Guess the %d-digit number of the computer. Type forfeit to give up.\n", *digits)
+		g = game.New(cowbull.LocalThinker(*digits), user)
+	case cowbull.RoleThinker:
+		fmt.Printf("The computer will guess your number. Type forfeit to give up.\n")
+		g = game.New(user, cowbull.LocalGuesser(*digits))
+	default:
+		fmt.Fprintf(os.Stderr, "invalid role %q\n", *role)
+		return 2
+	}
+
+	if err := g.Play(); err != nil {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Bo0mer/cowbull"
+)
+
+// solve builds the decision tree of a strategy and writes it out. It
+// returns the exit status.
+func solve(args []string) int {
+	fs := flag.NewFlagSet("solve", flag.ExitOnError)
+	digits := fs.Int("digits", 4, "Digit count of the numbers, 3 to 5 is reasonable.")
+	strategy := fs.String("strategy", "minmax", "Strategy to build the tree for: first or minmax.")
+	out := fs.String("o", "", "File to write the tree to, by default standard output.")
+	format := fs.String("format", "", "Format of the tree, json or gob. By default it depends on the file extension.")
+	fs.Parse(args)
+
+	if *format == "" {
+		*format = treeFormat(*out)
+	}
+	tree, err := cowbull.BuildTree(*digits, *strategy)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := tree.Encode(w, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	turns := tree.Turns()
+	var depths []int
+	var total, count int
+	for t, n := range turns {
+		depths = append(depths, t)
+		total += t * n
+		count += n
+	}
+	sort.Ints(depths)
+	fmt.Fprintf(os.Stderr, "%d numbers, guessed in %.3f turns on average, %d at most\n",
+		count, float64(total)/float64(count), depths[len(depths)-1])
+	return 0
+}
+
+// loadTree reads a decision tree written by solve.
+func loadTree(path string) (*cowbull.Tree, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return cowbull.DecodeTree(f, treeFormat(path))
+}
+
+// treeFormat returns the format of a tree file judging by its extension.
+func treeFormat(path string) string {
+	if filepath.Ext(path) == ".gob" {
+		return cowbull.TreeFormatGob
+	}
+	return cowbull.TreeFormatJSON
+}
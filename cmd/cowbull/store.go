@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/store"
+)
+
+// openStore opens the game store described by spec, either jsonl:PATH or
+// sqlite:PATH.
+func openStore(spec string) (cowbull.Store, error) {
+	kind, path, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "jsonl":
+		s, err := store.OpenJSONL(path)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "sqlite":
+		s, err := store.OpenSQLite(path)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown store kind %q", kind)
+}
+
+// openAccounts opens the account store described by spec, either jsonl:PATH
+// or sqlite:PATH.
+func openAccounts(spec string) (cowbull.AccountStore, error) {
+	kind, path, err := parseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "jsonl":
+		s, err := store.OpenJSONLAccounts(path)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "sqlite":
+		s, err := store.OpenSQLite(path)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("unknown account store kind %q", kind)
+}
+
+// parseSpec splits the spec of a store, either jsonl:PATH or sqlite:PATH,
+// into its kind and path.
+func parseSpec(spec string) (kind, path string, err error) {
+	i := strings.Index(spec, ":")
+	if i < 0 {
+		return "", "", fmt.Errorf("invalid store %q, expected jsonl:PATH or sqlite:PATH", spec)
+	}
+	kind, path = spec[:i], spec[i+1:]
+	if kind != "jsonl" && kind != "sqlite" {
+		return "", "", fmt.Errorf("unknown store kind %q", kind)
+	}
+	return kind, path, nil
+}
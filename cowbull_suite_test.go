@@ -1,6 +1,8 @@
 package cowbull_test
 
 import (
+	"github.com/Bo0mer/cowbull/protocol"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -11,3 +13,9 @@ func TestCowbull(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Cowbull Suite")
 }
+
+// code returns the code of a *protocol.Error.
+func code(err error) string {
+	ExpectWithOffset(1, err).To(BeAssignableToTypeOf(&protocol.Error{}))
+	return err.(*protocol.Error).Code
+}
@@ -0,0 +1,166 @@
+// This file was generated by counterfeiter
+package cowbullfakes
+
+import (
+	"sync"
+
+	"github.com/Bo0mer/cowbull"
+)
+
+type FakeAccountStore struct {
+	CreateAccountStub        func(cowbull.Account) error
+	createAccountMutex       sync.RWMutex
+	createAccountArgsForCall []struct {
+		arg1 cowbull.Account
+	}
+	createAccountReturns struct {
+		result1 error
+	}
+	AccountStub        func(username string) (cowbull.Account, error)
+	accountMutex       sync.RWMutex
+	accountArgsForCall []struct {
+		username string
+	}
+	accountReturns struct {
+		result1 cowbull.Account
+		result2 error
+	}
+	AccountByTokenStub        func(tokenHash string) (cowbull.Account, error)
+	accountByTokenMutex       sync.RWMutex
+	accountByTokenArgsForCall []struct {
+		tokenHash string
+	}
+	accountByTokenReturns struct {
+		result1 cowbull.Account
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeAccountStore) CreateAccount(arg1 cowbull.Account) error {
+	fake.createAccountMutex.Lock()
+	fake.createAccountArgsForCall = append(fake.createAccountArgsForCall, struct {
+		arg1 cowbull.Account
+	}{arg1})
+	fake.recordInvocation("CreateAccount", []interface{}{arg1})
+	fake.createAccountMutex.Unlock()
+	if fake.CreateAccountStub != nil {
+		return fake.CreateAccountStub(arg1)
+	} else {
+		return fake.createAccountReturns.result1
+	}
+}
+
+func (fake *FakeAccountStore) CreateAccountCallCount() int {
+	fake.createAccountMutex.RLock()
+	defer fake.createAccountMutex.RUnlock()
+	return len(fake.createAccountArgsForCall)
+}
+
+func (fake *FakeAccountStore) CreateAccountArgsForCall(i int) cowbull.Account {
+	fake.createAccountMutex.RLock()
+	defer fake.createAccountMutex.RUnlock()
+	return fake.createAccountArgsForCall[i].arg1
+}
+
+func (fake *FakeAccountStore) CreateAccountReturns(result1 error) {
+	fake.CreateAccountStub = nil
+	fake.createAccountReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAccountStore) Account(username string) (cowbull.Account, error) {
+	fake.accountMutex.Lock()
+	fake.accountArgsForCall = append(fake.accountArgsForCall, struct {
+		username string
+	}{username})
+	fake.recordInvocation("Account", []interface{}{username})
+	fake.accountMutex.Unlock()
+	if fake.AccountStub != nil {
+		return fake.AccountStub(username)
+	} else {
+		return fake.accountReturns.result1, fake.accountReturns.result2
+	}
+}
+
+func (fake *FakeAccountStore) AccountCallCount() int {
+	fake.accountMutex.RLock()
+	defer fake.accountMutex.RUnlock()
+	return len(fake.accountArgsForCall)
+}
+
+func (fake *FakeAccountStore) AccountArgsForCall(i int) string {
+	fake.accountMutex.RLock()
+	defer fake.accountMutex.RUnlock()
+	return fake.accountArgsForCall[i].username
+}
+
+func (fake *FakeAccountStore) AccountReturns(result1 cowbull.Account, result2 error) {
+	fake.AccountStub = nil
+	fake.accountReturns = struct {
+		result1 cowbull.Account
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAccountStore) AccountByToken(tokenHash string) (cowbull.Account, error) {
+	fake.accountByTokenMutex.Lock()
+	fake.accountByTokenArgsForCall = append(fake.accountByTokenArgsForCall, struct {
+		tokenHash string
+	}{tokenHash})
+	fake.recordInvocation("AccountByToken", []interface{}{tokenHash})
+	fake.accountByTokenMutex.Unlock()
+	if fake.AccountByTokenStub != nil {
+		return fake.AccountByTokenStub(tokenHash)
+	} else {
+		return fake.accountByTokenReturns.result1, fake.accountByTokenReturns.result2
+	}
+}
+
+func (fake *FakeAccountStore) AccountByTokenCallCount() int {
+	fake.accountByTokenMutex.RLock()
+	defer fake.accountByTokenMutex.RUnlock()
+	return len(fake.accountByTokenArgsForCall)
+}
+
+func (fake *FakeAccountStore) AccountByTokenArgsForCall(i int) string {
+	fake.accountByTokenMutex.RLock()
+	defer fake.accountByTokenMutex.RUnlock()
+	return fake.accountByTokenArgsForCall[i].tokenHash
+}
+
+func (fake *FakeAccountStore) AccountByTokenReturns(result1 cowbull.Account, result2 error) {
+	fake.AccountByTokenStub = nil
+	fake.accountByTokenReturns = struct {
+		result1 cowbull.Account
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAccountStore) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.createAccountMutex.RLock()
+	defer fake.createAccountMutex.RUnlock()
+	fake.accountMutex.RLock()
+	defer fake.accountMutex.RUnlock()
+	fake.accountByTokenMutex.RLock()
+	defer fake.accountByTokenMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeAccountStore) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ cowbull.AccountStore = new(FakeAccountStore)
@@ -0,0 +1,156 @@
+// This file was generated by counterfeiter
+package cowbullfakes
+
+import (
+	"sync"
+
+	"github.com/Bo0mer/cowbull"
+)
+
+type FakeStore struct {
+	RecordStub        func(cowbull.GameRecord) error
+	recordMutex       sync.RWMutex
+	recordArgsForCall []struct {
+		arg1 cowbull.GameRecord
+	}
+	recordReturns struct {
+		result1 error
+	}
+	GameStub        func(id string) (cowbull.GameRecord, error)
+	gameMutex       sync.RWMutex
+	gameArgsForCall []struct {
+		id string
+	}
+	gameReturns struct {
+		result1 cowbull.GameRecord
+		result2 error
+	}
+	GamesStub        func() ([]cowbull.GameRecord, error)
+	gamesMutex       sync.RWMutex
+	gamesArgsForCall []struct{}
+	gamesReturns     struct {
+		result1 []cowbull.GameRecord
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeStore) Record(arg1 cowbull.GameRecord) error {
+	fake.recordMutex.Lock()
+	fake.recordArgsForCall = append(fake.recordArgsForCall, struct {
+		arg1 cowbull.GameRecord
+	}{arg1})
+	fake.recordInvocation("Record", []interface{}{arg1})
+	fake.recordMutex.Unlock()
+	if fake.RecordStub != nil {
+		return fake.RecordStub(arg1)
+	} else {
+		return fake.recordReturns.result1
+	}
+}
+
+func (fake *FakeStore) RecordCallCount() int {
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	return len(fake.recordArgsForCall)
+}
+
+func (fake *FakeStore) RecordArgsForCall(i int) cowbull.GameRecord {
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	return fake.recordArgsForCall[i].arg1
+}
+
+func (fake *FakeStore) RecordReturns(result1 error) {
+	fake.RecordStub = nil
+	fake.recordReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStore) Game(id string) (cowbull.GameRecord, error) {
+	fake.gameMutex.Lock()
+	fake.gameArgsForCall = append(fake.gameArgsForCall, struct {
+		id string
+	}{id})
+	fake.recordInvocation("Game", []interface{}{id})
+	fake.gameMutex.Unlock()
+	if fake.GameStub != nil {
+		return fake.GameStub(id)
+	} else {
+		return fake.gameReturns.result1, fake.gameReturns.result2
+	}
+}
+
+func (fake *FakeStore) GameCallCount() int {
+	fake.gameMutex.RLock()
+	defer fake.gameMutex.RUnlock()
+	return len(fake.gameArgsForCall)
+}
+
+func (fake *FakeStore) GameArgsForCall(i int) string {
+	fake.gameMutex.RLock()
+	defer fake.gameMutex.RUnlock()
+	return fake.gameArgsForCall[i].id
+}
+
+func (fake *FakeStore) GameReturns(result1 cowbull.GameRecord, result2 error) {
+	fake.GameStub = nil
+	fake.gameReturns = struct {
+		result1 cowbull.GameRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStore) Games() ([]cowbull.GameRecord, error) {
+	fake.gamesMutex.Lock()
+	fake.gamesArgsForCall = append(fake.gamesArgsForCall, struct{}{})
+	fake.recordInvocation("Games", []interface{}{})
+	fake.gamesMutex.Unlock()
+	if fake.GamesStub != nil {
+		return fake.GamesStub()
+	} else {
+		return fake.gamesReturns.result1, fake.gamesReturns.result2
+	}
+}
+
+func (fake *FakeStore) GamesCallCount() int {
+	fake.gamesMutex.RLock()
+	defer fake.gamesMutex.RUnlock()
+	return len(fake.gamesArgsForCall)
+}
+
+func (fake *FakeStore) GamesReturns(result1 []cowbull.GameRecord, result2 error) {
+	fake.GamesStub = nil
+	fake.gamesReturns = struct {
+		result1 []cowbull.GameRecord
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStore) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.recordMutex.RLock()
+	defer fake.recordMutex.RUnlock()
+	fake.gameMutex.RLock()
+	defer fake.gameMutex.RUnlock()
+	fake.gamesMutex.RLock()
+	defer fake.gamesMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeStore) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ cowbull.Store = new(FakeStore)
@@ -0,0 +1,63 @@
+package cowbull
+
+import (
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+// aiDuelist is the computer taking part in a duel.
+type aiDuelist struct {
+	*AIThinker
+	*AIGuesser
+}
+
+// duelObserver may be implemented by a Player that wants to know when a duel
+// it takes part in starts.
+type duelObserver interface {
+	AnnounceDuel(protocol.Duel) error
+}
+
+// NewDuel creates a duel between a player and the single opponent of the
+// settings, or the computer if settings.AI is set.
+// If the settings do not make up a duel, the returned error is a
+// *protocol.Error describing why.
+func (h *Hub) NewDuel(from Player, settings GameSettings) (*game.Duel, error) {
+	if settings.Digits < 1 || settings.Digits > 10 {
+		return nil, protocol.Errorf(protocol.CodeInvalidSettings, "invalid digit count %d", settings.Digits)
+	}
+	if settings.AI {
+		opponent := &aiDuelist{
+			AIThinker: LocalThinker(settings.Digits),
+			AIGuesser: h.aiGuesser(settings.Digits),
+		}
+		return game.NewDuel(from, opponent, settings.Digits), nil
+	}
+
+	opponents, err := h.opponents(settings.Opponents)
+	if err != nil {
+		return nil, err
+	}
+	if len(opponents) != 1 || opponents[0].ID() == from.ID() {
+		return nil, protocol.Errorf(protocol.CodeInvalidSettings, "a duel needs a single opponent")
+	}
+	return game.NewDuel(from, opponents[0], settings.Digits), nil
+}
+
+// announceDuel tells the duelists of d who they play against.
+func (h *Hub) announceDuel(d *game.Duel, digits int) {
+	duelists := d.Duelists()
+	for i, p := range duelists {
+		o, ok := p.(duelObserver)
+		if !ok {
+			continue
+		}
+		err := o.AnnounceDuel(protocol.Duel{
+			ID:       d.ID(),
+			Digits:   digits,
+			Opponent: participants(duelists[1-i])[0],
+		})
+		if err != nil {
+			h.log.Warn("error announcing duel", "player_id", p.(Player).ID(), "err", err)
+		}
+	}
+}
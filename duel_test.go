@@ -0,0 +1,55 @@
+package cowbull_test
+
+import (
+	"log/slog"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewDuel", func() {
+	var hub *Hub
+	var alice *cowbullfakes.FakePlayer
+
+	BeforeEach(func() {
+		hub = NewHub(new(cowbullfakes.FakeGamer), slog.New(slog.NewTextHandler(GinkgoWriter, nil)))
+		alice = computerPlayer("alice", 3)
+		hub.Add(alice)
+	})
+
+	It("should create a duel against the computer", func() {
+		duel, err := hub.NewDuel(alice, GameSettings{Mode: protocol.ModeDuel, Digits: 3, AI: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(duel.Duelists()[0]).To(BeIdenticalTo(alice))
+
+		Expect(duel.Play()).To(Succeed())
+		r := duel.Result()
+		Expect(r.Games[0].Digits).To(Equal(3))
+		Expect(r.Games[1].Digits).To(Equal(3))
+		Expect(alice.ThinkCallCount()).To(Equal(1))
+		Expect(alice.GuessCallCount()).To(Equal(len(r.Games[0].Moves)))
+	})
+
+	It("should create a duel against another player", func() {
+		bob := computerPlayer("bob", 3)
+		hub.Add(bob)
+		duel, err := hub.NewDuel(alice, GameSettings{Mode: protocol.ModeDuel, Digits: 3, Opponents: []string{"bob"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(duel.Duelists()[1]).To(BeIdenticalTo(bob))
+	})
+
+	It("should reject invalid settings", func() {
+		_, err := hub.NewDuel(alice, GameSettings{Mode: protocol.ModeDuel, Digits: 0, AI: true})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewDuel(alice, GameSettings{Mode: protocol.ModeDuel, Digits: 3, Opponents: []string{"alice"}})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewDuel(alice, GameSettings{Mode: protocol.ModeDuel, Digits: 3})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewDuel(alice, GameSettings{Mode: protocol.ModeDuel, Digits: 3, Opponents: []string{"carol"}})
+		Expect(code(err)).To(Equal(protocol.CodeUnknownOpponent))
+	})
+})
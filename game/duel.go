@@ -0,0 +1,196 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrOutguessed ends the game of a duelist whose opponent guessed first.
+var ErrOutguessed = errors.New("game: the opponent guessed first")
+
+// NoWinner is the winner of a duel that was drawn or that nobody won.
+const NoWinner = -1
+
+// Duelist is a player of a duel. It both thinks of a number and guesses the
+// number of its opponent.
+type Duelist interface {
+	Thinker
+	Guesser
+}
+
+// DuelObserver may be implemented by a Duelist that wants to know how a
+// duel it takes part in ended. Duelists that do not implement it, but
+// implement Observer, are told the result of their guessing instead.
+type DuelObserver interface {
+	// DuelOver tells the duelist at index i the result of the duel.
+	DuelOver(r DuelResult, i int)
+}
+
+// DuelResult describes how a duel ended.
+type DuelResult struct {
+	// ID identifies the duel, if it was given one.
+	ID string `json:"id,omitempty"`
+	// Outcome is win if a number was guessed, even if both were in the
+	// same round. Otherwise it tells how a duelist failed.
+	Outcome string `json:"outcome"`
+	// Reason is the error that ended the duel, if any.
+	Reason string `json:"reason,omitempty"`
+	// Winner is the index of the duelist who won, or NoWinner.
+	Winner int `json:"winner"`
+	// Games holds, by index of duelist, the result of its guessing the
+	// number of the other.
+	Games [2]Result `json:"games"`
+}
+
+// Duel is a game between two duelists, each of whom thinks of a number and
+// guesses the number of the other. In every round, both guess at once. The
+// first to guess wins, and if both guess in the same round the duel is
+// drawn. A duelist who fails loses.
+type Duel struct {
+	duelists [2]Duelist
+	digits   int
+	id       string
+
+	// games[i] is duelist i guessing the number of the other.
+	games  [2]*Game
+	result DuelResult
+}
+
+// NewDuel creates a duel between a and b, in which both think of numbers of
+// a digit count.
+func NewDuel(a, b Duelist, digits int) *Duel {
+	return &Duel{
+		duelists: [2]Duelist{a, b},
+		digits:   digits,
+		games:    [2]*Game{New(b, a), New(a, b)},
+	}
+}
+
+// SetID identifies the duel. Its games are identified by the ID followed
+// by -1 and -2. It should be set before the duel is played.
+func (d *Duel) SetID(id string) {
+	d.id = id
+	for i, g := range d.games {
+		g.SetID(fmt.Sprintf("%s-%d", id, i+1))
+	}
+}
+
+// ID returns the ID of the duel, if it has one.
+func (d *Duel) ID() string {
+	return d.id
+}
+
+// Duelists returns the duelists.
+func (d *Duel) Duelists() [2]Duelist {
+	return d.duelists
+}
+
+// Games returns the games of the duel, by index of the guessing duelist.
+// Their results are valid only after Play returns.
+func (d *Duel) Games() [2]*Game {
+	return d.games
+}
+
+// Result returns the result of the duel. It is valid only after Play
+// returns.
+func (d *Duel) Result() DuelResult {
+	return d.result
+}
+
+// Play plays the duel. Once it is over, no matter how, the duelists are
+// told its result.
+func (d *Duel) Play() error {
+	winner, solved, err := d.play()
+
+	d.result = DuelResult{ID: d.id, Outcome: OutcomeOf(err), Winner: winner}
+	if err != nil {
+		d.result.Reason = err.Error()
+	}
+	for i, g := range d.games {
+		gerr := err
+		if gerr == nil && !solved[i] {
+			gerr = ErrOutguessed
+		}
+		g.result = g.newResult(gerr)
+		d.result.Games[i] = g.result
+	}
+
+	for i, p := range d.duelists {
+		switch o := p.(type) {
+		case DuelObserver:
+			o.DuelOver(d.result, i)
+		case Observer:
+			o.GameOver(d.result.Games[i])
+		}
+	}
+	return err
+}
+
+// play plays the duel round by round. It returns the winner and which
+// duelists guessed, or the error that ended the duel.
+func (d *Duel) play() (int, [2]bool, error) {
+	var solved [2]bool
+	var errs [2]error
+	var wg sync.WaitGroup
+
+	for i := range d.duelists {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			g := d.games[1-i]
+			g.digits, errs[i] = d.duelists[i].Think()
+			if errs[i] == nil && g.digits != d.digits {
+				errs[i] = fmt.Errorf("game: number of %d digits, not %d", g.digits, d.digits)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if i, err := firstError(errs); err != nil {
+		if errs[1-i] != nil {
+			return NoWinner, solved, err
+		}
+		return 1 - i, solved, err
+	}
+
+	for {
+		for i := range d.games {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				solved[i], errs[i] = d.games[i].turn()
+			}(i)
+		}
+		wg.Wait()
+		if i, err := firstError(errs); err != nil {
+			// The guesser of a game failed, or else its thinker.
+			loser := i
+			if !d.games[i].guesserFailed {
+				loser = 1 - i
+			}
+			if errs[1-i] != nil {
+				return NoWinner, solved, err
+			}
+			return 1 - loser, solved, err
+		}
+
+		switch {
+		case solved[0] && solved[1]:
+			return NoWinner, solved, nil
+		case solved[0]:
+			return 0, solved, nil
+		case solved[1]:
+			return 1, solved, nil
+		}
+	}
+}
+
+// firstError returns the first non-nil error and its index.
+func firstError(errs [2]error) (int, error) {
+	for i, err := range errs {
+		if err != nil {
+			return i, err
+		}
+	}
+	return 0, nil
+}
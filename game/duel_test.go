@@ -0,0 +1,201 @@
+package game_test
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	. "github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/game/gamefakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeDuelist thinks of a secret and makes guesses in order, repeating the
+// last one.
+type fakeDuelist struct {
+	*gamefakes.FakeThinker
+	*gamefakes.FakeGuesser
+
+	mu      sync.Mutex
+	results []DuelResult
+	indices []int
+}
+
+func newFakeDuelist(secret string, guesses ...string) *fakeDuelist {
+	d := &fakeDuelist{
+		FakeThinker: new(gamefakes.FakeThinker),
+		FakeGuesser: new(gamefakes.FakeGuesser),
+	}
+	d.ThinkReturns(len(secret), nil)
+	d.TryStub = func(guess string) (int, int, error) {
+		cows, bulls := 0, 0
+		for i, r := range guess {
+			switch strings.IndexRune(secret, r) {
+			case i:
+				bulls++
+			case -1:
+			default:
+				cows++
+			}
+		}
+		return cows, bulls, nil
+	}
+	d.GuessStub = func(int) (string, error) {
+		n := d.GuessCallCount()
+		if n > len(guesses) {
+			n = len(guesses)
+		}
+		return guesses[n-1], nil
+	}
+	return d
+}
+
+func (d *fakeDuelist) DuelOver(r DuelResult, i int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.results = append(d.results, r)
+	d.indices = append(d.indices, i)
+}
+
+// observingDuelist is told the result of its guessing only.
+type observingDuelist struct {
+	*gamefakes.FakeThinker
+	*observingGuesser
+}
+
+var _ = Describe("Duel", func() {
+	var a, b *fakeDuelist
+	var duel *Duel
+	var err error
+
+	JustBeforeEach(func() {
+		duel = NewDuel(a, b, 2)
+		duel.SetID("d1")
+		err = duel.Play()
+	})
+
+	Context("when one duelist guesses first", func() {
+		BeforeEach(func() {
+			a = newFakeDuelist("12", "34", "21", "43")
+			b = newFakeDuelist("43", "21", "12")
+		})
+
+		It("should let it win", func() {
+			Ω(err).ShouldNot(HaveOccurred())
+			r := duel.Result()
+			Ω(r.Outcome).Should(Equal(OutcomeWin))
+			Ω(r.Winner).Should(Equal(1))
+			Ω(r.Games[1].Outcome).Should(Equal(OutcomeWin))
+			Ω(r.Games[1].Moves).Should(HaveLen(2))
+			Ω(r.Games[0].Outcome).Should(Equal(OutcomeAbort))
+			Ω(r.Games[0].Reason).Should(Equal(ErrOutguessed.Error()))
+			Ω(r.Games[0].Moves).Should(HaveLen(2))
+		})
+
+		It("should make both guess in every round", func() {
+			Ω(a.GuessCallCount()).Should(Equal(2))
+			Ω(b.GuessCallCount()).Should(Equal(2))
+			Ω(a.TryCallCount()).Should(Equal(2))
+			Ω(b.TellCallCount()).Should(Equal(2))
+		})
+
+		It("should tell both duelists the result", func() {
+			Ω(a.results).Should(Equal([]DuelResult{duel.Result()}))
+			Ω(a.indices).Should(Equal([]int{0}))
+			Ω(b.indices).Should(Equal([]int{1}))
+		})
+
+		It("should identify the duel and its games", func() {
+			Ω(duel.Result().ID).Should(Equal("d1"))
+			Ω(duel.Games()[0].ID()).Should(Equal("d1-1"))
+			Ω(duel.Games()[1].Result().ID).Should(Equal("d1-2"))
+		})
+	})
+
+	Context("when both guess in the same round", func() {
+		BeforeEach(func() {
+			a = newFakeDuelist("12", "43")
+			b = newFakeDuelist("43", "12")
+		})
+
+		It("should be drawn", func() {
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(duel.Result().Outcome).Should(Equal(OutcomeWin))
+			Ω(duel.Result().Winner).Should(Equal(NoWinner))
+			Ω(duel.Result().Games[0].Outcome).Should(Equal(OutcomeWin))
+			Ω(duel.Result().Games[1].Outcome).Should(Equal(OutcomeWin))
+		})
+	})
+
+	Context("when a duelist gives up", func() {
+		BeforeEach(func() {
+			a = newFakeDuelist("12", "34")
+			b = newFakeDuelist("43", "21")
+			a.GuessReturns("", ErrForfeit)
+		})
+
+		It("should let the other one win", func() {
+			Ω(err).Should(Equal(ErrForfeit))
+			Ω(duel.Result().Outcome).Should(Equal(OutcomeForfeit))
+			Ω(duel.Result().Winner).Should(Equal(1))
+		})
+	})
+
+	Context("when a duelist fails to score a guess", func() {
+		BeforeEach(func() {
+			a = newFakeDuelist("12", "34")
+			b = newFakeDuelist("43", "21")
+			b.TryReturns(0, 0, errors.New("error trying"))
+		})
+
+		It("should let the other one win", func() {
+			Ω(err).Should(MatchError("error trying"))
+			Ω(duel.Result().Outcome).Should(Equal(OutcomeAbort))
+			Ω(duel.Result().Winner).Should(Equal(0))
+		})
+	})
+
+	Context("when a duelist thinks of a number of another digit count", func() {
+		BeforeEach(func() {
+			a = newFakeDuelist("123", "34")
+			b = newFakeDuelist("43", "21")
+		})
+
+		It("should let the other one win", func() {
+			Ω(err).Should(MatchError("game: number of 3 digits, not 2"))
+			Ω(duel.Result().Winner).Should(Equal(1))
+			Ω(b.GuessCallCount()).Should(BeZero())
+		})
+	})
+
+	Context("when both duelists fail", func() {
+		BeforeEach(func() {
+			a = newFakeDuelist("12", "34")
+			b = newFakeDuelist("43", "21")
+			a.ThinkReturns(0, errors.New("not in the mood"))
+			b.ThinkReturns(0, errors.New("neither"))
+		})
+
+		It("should have no winner", func() {
+			Ω(err).Should(HaveOccurred())
+			Ω(duel.Result().Winner).Should(Equal(NoWinner))
+		})
+	})
+
+	Context("with a duelist observing games only", func() {
+		It("should tell it the result of its guessing", func() {
+			a := newFakeDuelist("12", "43")
+			b := newFakeDuelist("43", "21")
+			observing := &observingDuelist{
+				FakeThinker:      b.FakeThinker,
+				observingGuesser: &observingGuesser{FakeGuesser: b.FakeGuesser},
+			}
+			duel := NewDuel(a, observing, 2)
+			Ω(duel.Play()).Should(Succeed())
+			Ω(observing.results).Should(Equal([]Result{duel.Result().Games[1]}))
+			Ω(observing.results[0].Reason).Should(Equal(ErrOutguessed.Error()))
+		})
+	})
+})
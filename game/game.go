@@ -1,5 +1,7 @@
 package game
 
+import "time"
+
 //go:generate counterfeiter . Thinker
 //go:generate counterfeiter . Guesser
 
@@ -23,10 +25,35 @@ type Guesser interface {
 	Tell(string, int, int) error
 }
 
+// Observer may be implemented by a Thinker or a Guesser that wants to know
+// how a game it takes part in ended.
+type Observer interface {
+	// GameOver tells the player the result of the game.
+	GameOver(Result)
+}
+
 // Game represents a cowbull game.
 type Game struct {
 	thinker Thinker
 	guesser Guesser
+	id      string
+
+	digits int
+	moves  []Move
+	result Result
+
+	// guesserFailed tells who ended the game with an error.
+	guesserFailed bool
+
+	onMove []func(Move, Timing)
+}
+
+// Timing is how long the players took to make a move.
+type Timing struct {
+	// Guess is how long the guesser took to guess.
+	Guess time.Duration
+	// Try is how long the thinker took to score the guess.
+	Try time.Duration
 }
 
 // NewGame creates new gime with the provided players.
@@ -38,29 +65,111 @@ func New(thinker Thinker, guesser Guesser) *Game {
 }
 
 // Play plays the game with the players.
+// Once the game is over, no matter how, the players implementing Observer
+// are told its result.
 func (g *Game) Play() error {
+	err := g.play()
+	g.result = g.newResult(err)
+	for _, p := range []interface{}{g.thinker, g.guesser} {
+		if o, ok := p.(Observer); ok {
+			o.GameOver(g.result)
+		}
+	}
+	return err
+}
+
+// OnMove makes the game call f after every move, with how long it took,
+// after the functions it was given before. It should be called before the
+// game is played.
+func (g *Game) OnMove(f func(Move, Timing)) {
+	g.onMove = append(g.onMove, f)
+}
+
+// SetID identifies the game. The ID is part of its result. It should be set
+// before the game is played.
+func (g *Game) SetID(id string) {
+	g.id = id
+}
+
+// ID returns the ID of the game, if it has one.
+func (g *Game) ID() string {
+	return g.id
+}
+
+// Thinker returns the thinker of the game.
+func (g *Game) Thinker() Thinker {
+	return g.thinker
+}
+
+// Guesser returns the guesser of the game.
+func (g *Game) Guesser() Guesser {
+	return g.guesser
+}
+
+// Result returns the result of the game. It is valid only after Play
+// returns.
+func (g *Game) Result() Result {
+	return g.result
+}
+
+func (g *Game) newResult(err error) Result {
+	r := Result{
+		ID:      g.id,
+		Outcome: OutcomeOf(err),
+		Digits:  g.digits,
+		Moves:   g.moves,
+	}
+	if err != nil {
+		r.Reason = err.Error()
+	}
+	if rev, ok := g.thinker.(Revealer); ok {
+		r.Secret = rev.Secret()
+	}
+	if r.Outcome == OutcomeWin {
+		r.Secret = g.moves[len(g.moves)-1].Guess
+	}
+	return r
+}
+
+func (g *Game) play() error {
 	var err error
-	var digits int
-	if digits, err = g.thinker.Think(); err != nil {
+	if g.digits, err = g.thinker.Think(); err != nil {
 		return err
 	}
 	for {
-		guess, err := g.guesser.Guess(digits)
-		if err != nil {
+		solved, err := g.turn()
+		if err != nil || solved {
 			return err
 		}
+	}
+}
 
-		cows, bulls, err := g.thinker.Try(guess)
-		if err != nil {
-			return err
-		}
+// turn lets the guesser make a guess and tells it the score. It reports
+// whether the number was guessed. If the guesser fails, guesserFailed is
+// set.
+func (g *Game) turn() (bool, error) {
+	start := time.Now()
+	guess, err := g.guesser.Guess(g.digits)
+	if err != nil {
+		g.guesserFailed = true
+		return false, err
+	}
 
-		if err = g.guesser.Tell(guess, cows, bulls); err != nil {
-			return err
-		}
+	guessed := time.Now()
+	cows, bulls, err := g.thinker.Try(guess)
+	if err != nil {
+		return false, err
+	}
+	move := Move{Guess: guess, Cows: cows, Bulls: bulls}
+	g.moves = append(g.moves, move)
+	timing := Timing{Guess: guessed.Sub(start), Try: time.Since(guessed)}
+	for _, f := range g.onMove {
+		f(move, timing)
+	}
 
-		if digits == bulls {
-			return nil
-		}
+	if err = g.guesser.Tell(guess, cows, bulls); err != nil {
+		g.guesserFailed = true
+		return false, err
 	}
+	return g.digits == bulls, nil
 }
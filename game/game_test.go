@@ -2,6 +2,7 @@ package game_test
 
 import (
 	"errors"
+	"time"
 
 	. "github.com/Bo0mer/cowbull/game"
 	"github.com/Bo0mer/cowbull/game/gamefakes"
@@ -10,6 +11,23 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+type observingGuesser struct {
+	*gamefakes.FakeGuesser
+	results []Result
+}
+
+func (g *observingGuesser) GameOver(r Result) {
+	g.results = append(g.results, r)
+}
+
+type revealingThinker struct {
+	*gamefakes.FakeThinker
+}
+
+func (revealingThinker) Secret() string {
+	return "12"
+}
+
 var _ = Describe("Game", func() {
 
 	var thinker *gamefakes.FakeThinker
@@ -36,6 +54,150 @@ var _ = Describe("Game", func() {
 		err = game.Play()
 	})
 
+	Describe("Thinker and Guesser", func() {
+		BeforeEach(func() {
+			thinker.ThinkReturns(0, errors.New("not in the mood"))
+		})
+
+		It("should return the players", func() {
+			Ω(game.Thinker()).Should(BeIdenticalTo(thinker))
+			Ω(game.Guesser()).Should(BeIdenticalTo(guesser))
+		})
+	})
+
+	Describe("SetID", func() {
+		JustBeforeEach(func() {
+			game = New(thinker, guesser)
+			game.SetID("g1")
+			err = game.Play()
+		})
+
+		BeforeEach(func() {
+			thinker.ThinkReturns(0, errors.New("not in the mood"))
+		})
+
+		It("should identify the game and its result", func() {
+			Ω(game.ID()).Should(Equal("g1"))
+			Ω(game.Result().ID).Should(Equal("g1"))
+		})
+	})
+
+	Describe("OnMove", func() {
+		var moves []Move
+		var timings []Timing
+		var calls []string
+
+		JustBeforeEach(func() {
+			moves, timings, calls = nil, nil, nil
+			game = New(thinker, guesser)
+			game.OnMove(func(m Move, t Timing) {
+				moves = append(moves, m)
+				timings = append(timings, t)
+				calls = append(calls, "first")
+			})
+			game.OnMove(func(Move, Timing) {
+				calls = append(calls, "second")
+			})
+			err = game.Play()
+		})
+
+		BeforeEach(func() {
+			thinker.ThinkReturns(2, nil)
+			guesser.GuessStub = func(int) (string, error) {
+				time.Sleep(5 * time.Millisecond)
+				return "12", nil
+			}
+			thinker.TryReturns(0, 2, nil)
+		})
+
+		It("should report every move with how long it took", func() {
+			Ω(moves).Should(Equal([]Move{{Guess: "12", Bulls: 2}}))
+			Ω(timings).Should(HaveLen(1))
+			Ω(timings[0].Guess).Should(BeNumerically(">=", 5*time.Millisecond))
+			Ω(timings[0].Try).Should(BeNumerically(">=", 0))
+		})
+
+		It("should call every function in order", func() {
+			Ω(calls).Should(Equal([]string{"first", "second"}))
+		})
+	})
+
+	Describe("Play with a guesser that observes the game", func() {
+		var observing *observingGuesser
+
+		JustBeforeEach(func() {
+			observing = &observingGuesser{FakeGuesser: guesser}
+			game = New(thinker, observing)
+			err = game.Play()
+		})
+
+		Context("when the game is aborted", func() {
+			BeforeEach(func() {
+				expectedErr = errors.New("error thinking")
+				thinker.ThinkReturns(0, expectedErr)
+			})
+
+			It("should tell the guesser why", func() {
+				Ω(observing.results).Should(HaveLen(1))
+				Ω(observing.results[0].Outcome).Should(Equal(OutcomeAbort))
+				Ω(observing.results[0].Reason).Should(Equal("error thinking"))
+			})
+		})
+
+		Context("when the number is guessed", func() {
+			BeforeEach(func() {
+				thinker.ThinkReturns(2, nil)
+				thinker.TryStub = func(guess string) (int, int, error) {
+					if guess == "12" {
+						return 0, 2, nil
+					}
+					return 2, 0, nil
+				}
+				// The guesser guesses on the second attempt in every game.
+				guesses := []string{"21", "12"}
+				guesser.GuessStub = func(int) (string, error) {
+					return guesses[(guesser.GuessCallCount()-1)%2], nil
+				}
+			})
+
+			It("should tell the guesser about the win", func() {
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(observing.results).Should(Equal([]Result{{
+					Outcome: OutcomeWin,
+					Secret:  "12",
+					Digits:  2,
+					Moves: []Move{
+						{Guess: "21", Cows: 2, Bulls: 0},
+						{Guess: "12", Cows: 0, Bulls: 2},
+					},
+				}}))
+			})
+
+			It("should keep the result", func() {
+				Ω(game.Result()).Should(Equal(observing.results[0]))
+			})
+		})
+	})
+
+	Describe("Play with a thinker that reveals its number", func() {
+		JustBeforeEach(func() {
+			game = New(revealingThinker{thinker}, guesser)
+			err = game.Play()
+		})
+
+		Context("when the game is aborted", func() {
+			BeforeEach(func() {
+				thinker.ThinkReturns(2, nil)
+				guesser.GuessReturns("", errors.New("error guessing"))
+			})
+
+			It("should include the number in the result", func() {
+				Ω(game.Result().Secret).Should(Equal("12"))
+				Ω(game.Result().Outcome).Should(Equal(OutcomeAbort))
+			})
+		})
+	})
+
 	Describe("Play", func() {
 		Context("when the thinker fails on thinking", func() {
 			BeforeEach(func() {
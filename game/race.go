@@ -0,0 +1,396 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrOutraced ends the game of a guesser of a race who can no longer beat
+// another guesser.
+var ErrOutraced = errors.New("game: another guesser can no longer be beaten")
+
+// Ranks of a race.
+const (
+	// RankByTurns makes the guesser who guessed in the fewest turns win.
+	// Ties are broken by time.
+	RankByTurns = "turns"
+	// RankByTime makes the first guesser to guess win.
+	RankByTime = "time"
+)
+
+// Standing is how a guesser of a race is doing.
+type Standing struct {
+	// Guesser is the index of the guesser.
+	Guesser int `json:"guesser"`
+	// Place is the position of a guesser who guessed, starting at 1. It
+	// is zero for the rest. Until the race is over, it may still change.
+	Place int `json:"place,omitempty"`
+	// Solved tells whether the guesser guessed the number.
+	Solved bool `json:"solved"`
+	// Done tells whether the guesser is no longer guessing.
+	Done bool `json:"done"`
+	// Turns is the count of guesses made.
+	Turns int `json:"turns"`
+	// Time is how long after the start of the race the last guess was
+	// scored.
+	Time time.Duration `json:"time"`
+}
+
+// RaceResult describes the state of a race, or how it ended.
+type RaceResult struct {
+	// ID identifies the race, if it was given one.
+	ID   string `json:"id,omitempty"`
+	Rank string `json:"rank"`
+	// Over tells whether the race is over.
+	Over bool `json:"over"`
+	// Outcome is win if the number was guessed. Otherwise it tells how
+	// the thinker, or else every guesser, failed. It is set once the race
+	// is over.
+	Outcome string `json:"outcome,omitempty"`
+	// Reason is the error that ended the race, if any.
+	Reason string `json:"reason,omitempty"`
+	// Secret is the number of the thinker, once the race is over and if
+	// known.
+	Secret string `json:"secret,omitempty"`
+	Digits int    `json:"digits"`
+	// Winner is the index of the guesser who won, or NoWinner. It is set
+	// once the race is over.
+	Winner int `json:"winner"`
+	// Standings holds the guessers, best placed first.
+	Standings []Standing `json:"standings"`
+	// Games holds, by index of guesser, the result of its game, once the
+	// race is over.
+	Games []Result `json:"games,omitempty"`
+}
+
+// Race is a game between a thinker and multiple guessers, each of whom
+// guesses the number on its own, in parallel, without knowing the guesses
+// of the others. Depending on its rank, the guesser who guessed in the
+// fewest turns or the first to guess wins. Guessers who can no longer win
+// are stopped. A guesser who fails is out of the race, while a thinker who
+// fails ends it.
+type Race struct {
+	thinker  Thinker
+	guessers []Guesser
+	rank     string
+	id       string
+	onUpdate func(RaceResult)
+	now      func() time.Time
+
+	// games[i] is guesser i guessing the number of the thinker.
+	games []*Game
+	// tmu serializes the scoring of guesses by the thinker.
+	tmu sync.Mutex
+
+	mu        sync.Mutex // guards
+	digits    int
+	started   time.Time
+	standings []Standing // by index of guesser
+	err       error      // of the thinker
+	result    RaceResult
+	updating  bool // whether onUpdate is being called
+	stale     bool // whether the standings changed since it was
+}
+
+// NewRace creates a race of guessers for the number of thinker, ranked by
+// rank. An unknown rank ranks by turns.
+func NewRace(thinker Thinker, guessers []Guesser, rank string) *Race {
+	if rank != RankByTime {
+		rank = RankByTurns
+	}
+	r := &Race{
+		thinker:   thinker,
+		guessers:  guessers,
+		rank:      rank,
+		now:       time.Now,
+		games:     make([]*Game, len(guessers)),
+		standings: make([]Standing, len(guessers)),
+	}
+	for i, g := range guessers {
+		r.games[i] = New(raceThinker{r}, g)
+		r.standings[i].Guesser = i
+	}
+	return r
+}
+
+// SetID identifies the race. The game of guesser i is identified by the ID
+// followed by -i+1. It should be set before the race is played.
+func (r *Race) SetID(id string) {
+	r.id = id
+	for i, g := range r.games {
+		g.SetID(fmt.Sprintf("%s-%d", id, i+1))
+	}
+}
+
+// ID returns the ID of the race, if it has one.
+func (r *Race) ID() string {
+	return r.id
+}
+
+// OnUpdate sets a function to be told the standings once the thinker has
+// thought, after each guess and when a guesser is done, and finally the
+// result of the race. It is never called concurrently: if the standings
+// change while it is called, it is called again with the latest ones once it
+// returns. It should be set before the race is played.
+func (r *Race) OnUpdate(f func(RaceResult)) {
+	r.onUpdate = f
+}
+
+// Thinker returns the thinker of the race.
+func (r *Race) Thinker() Thinker {
+	return r.thinker
+}
+
+// Guessers returns the guessers of the race.
+func (r *Race) Guessers() []Guesser {
+	return r.guessers
+}
+
+// Games returns the games of the race, by index of guesser. Their results
+// are valid only after Play returns.
+func (r *Race) Games() []*Game {
+	return r.games
+}
+
+// Result returns the result of the race. It is valid only after Play
+// returns.
+func (r *Race) Result() RaceResult {
+	return r.result
+}
+
+// Play plays the race. Every guesser implementing Observer is told the
+// result of its game as soon as it is over. The thinker, who takes part in
+// all games, is not; see OnUpdate.
+func (r *Race) Play() error {
+	err := r.play()
+
+	r.mu.Lock()
+	r.result = r.snapshot()
+	r.result.Over = true
+	r.result.Outcome = OutcomeOf(err)
+	if err != nil {
+		r.result.Reason = err.Error()
+	}
+	r.result.Games = make([]Result, len(r.games))
+	for i, g := range r.games {
+		r.result.Games[i] = g.result
+		if r.result.Secret == "" && g.result.Outcome == OutcomeWin {
+			r.result.Secret = g.result.Secret
+		}
+	}
+	if len(r.result.Standings) > 0 && r.result.Standings[0].Solved {
+		r.result.Winner = r.result.Standings[0].Guesser
+	}
+	r.mu.Unlock()
+	if r.onUpdate != nil {
+		r.onUpdate(r.result)
+	}
+	return err
+}
+
+// play lets the thinker think and all guessers guess in parallel. It
+// returns the error of the thinker, or the first error of a guesser if
+// none guessed.
+func (r *Race) play() error {
+	digits, err := r.thinker.Think()
+	if err != nil {
+		for i, g := range r.games {
+			r.over(i, g.newResult(err))
+		}
+		return err
+	}
+	r.mu.Lock()
+	r.digits, r.started = digits, r.now()
+	for _, g := range r.games {
+		g.digits = digits
+	}
+	r.mu.Unlock()
+	r.update()
+
+	errs := make([]error, len(r.games))
+	var wg sync.WaitGroup
+	for i := range r.games {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = r.race(i)
+			r.over(i, r.games[i].newResult(errs[i]))
+		}(i)
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	for _, s := range r.standings {
+		if s.Solved {
+			return nil
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// race lets guesser i guess until it guesses, fails or can no longer win.
+func (r *Race) race(i int) error {
+	g := r.games[i]
+	for {
+		if err := r.check(i); err != nil {
+			return err
+		}
+		solved, err := g.turn()
+
+		r.mu.Lock()
+		s := &r.standings[i]
+		s.Turns, s.Time = len(g.moves), r.now().Sub(r.started)
+		s.Solved, s.Done = solved, solved || err != nil
+		r.mu.Unlock()
+		r.update()
+
+		if err != nil || solved {
+			return err
+		}
+	}
+}
+
+// check returns the error of the thinker, or ErrOutraced if guesser i can
+// no longer win, marking it done.
+func (r *Race) check(i int) error {
+	r.mu.Lock()
+	if err := r.err; err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	s := &r.standings[i]
+	outraced := false
+	for _, other := range r.standings {
+		// Having guessed later, s loses a tie on turns.
+		if other.Solved && (r.rank == RankByTime || s.Turns+1 >= other.Turns) {
+			s.Done, outraced = true, true
+			break
+		}
+	}
+	r.mu.Unlock()
+	if outraced {
+		r.update()
+		return ErrOutraced
+	}
+	return nil
+}
+
+// over sets the result of the game of guesser i and tells it to the
+// guesser.
+func (r *Race) over(i int, res Result) {
+	r.games[i].result = res
+	if o, ok := r.guessers[i].(Observer); ok {
+		o.GameOver(res)
+	}
+}
+
+// update tells the standings to the function set by OnUpdate, without mu
+// held, so that a slow function does not hold up the guessers. If it is
+// being called already, that call is followed by one with the latest
+// standings instead. It must be called without mu held.
+func (r *Race) update() {
+	if r.onUpdate == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stale = true
+	if r.updating {
+		return
+	}
+	r.updating = true
+	for r.stale {
+		r.stale = false
+		standings := r.snapshot()
+		r.mu.Unlock()
+		r.onUpdate(standings)
+		r.mu.Lock()
+	}
+	r.updating = false
+}
+
+// snapshot returns the state of the race. It must be called with mu held.
+func (r *Race) snapshot() RaceResult {
+	standings := make([]Standing, len(r.standings))
+	copy(standings, r.standings)
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Solved != b.Solved {
+			return a.Solved
+		}
+		if !a.Solved {
+			return false
+		}
+		if r.rank == RankByTime && a.Time != b.Time {
+			return a.Time < b.Time
+		}
+		if a.Turns != b.Turns {
+			return a.Turns < b.Turns
+		}
+		return a.Time < b.Time
+	})
+	for i := range standings {
+		if standings[i].Solved {
+			standings[i].Place = i + 1
+		}
+	}
+	return RaceResult{
+		ID:        r.id,
+		Rank:      r.rank,
+		Digits:    r.digits,
+		Winner:    NoWinner,
+		Standings: standings,
+	}
+}
+
+// raceThinker is the thinker of a race as seen by the game of each
+// guesser. It scores one guess at a time, and stops scoring once the
+// thinker has failed.
+type raceThinker struct {
+	r *Race
+}
+
+// Think is never called, as the games of a race start out thought.
+func (t raceThinker) Think() (int, error) {
+	return t.r.digits, nil
+}
+
+func (t raceThinker) Try(guess string) (int, int, error) {
+	t.r.tmu.Lock()
+	defer t.r.tmu.Unlock()
+
+	t.r.mu.Lock()
+	err := t.r.err
+	t.r.mu.Unlock()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cows, bulls, err := t.r.thinker.Try(guess)
+	if err != nil {
+		t.r.mu.Lock()
+		t.r.err = err
+		t.r.mu.Unlock()
+	}
+	return cows, bulls, err
+}
+
+// Secret reveals the number of the thinker, if it is a Revealer.
+func (t raceThinker) Secret() string {
+	if rev, ok := t.r.thinker.(Revealer); ok {
+		return rev.Secret()
+	}
+	return ""
+}
@@ -0,0 +1,299 @@
+package game_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/game/gamefakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newRacer returns a guesser making guesses in order, repeating the last
+// one.
+func newRacer(guesses ...string) *observingGuesser {
+	g := &observingGuesser{FakeGuesser: new(gamefakes.FakeGuesser)}
+	g.GuessStub = func(int) (string, error) {
+		n := g.GuessCallCount()
+		if n > len(guesses) {
+			n = len(guesses)
+		}
+		return guesses[n-1], nil
+	}
+	return g
+}
+
+// waitFor makes g, on its guess number n, close started and wait until
+// done is closed. Either may be nil.
+func waitFor(g *observingGuesser, n int, started chan struct{}, done <-chan struct{}) {
+	stub := g.GuessStub
+	g.GuessStub = func(digits int) (string, error) {
+		if g.GuessCallCount() == n {
+			if started != nil {
+				close(started)
+			}
+			if done != nil {
+				<-done
+			}
+		}
+		return stub(digits)
+	}
+}
+
+// closeOnSolved closes done once g is told that it guessed a 2-digit number.
+func closeOnSolved(g *observingGuesser, done chan struct{}) {
+	g.TellStub = func(_ string, _, bulls int) error {
+		if bulls == 2 {
+			close(done)
+		}
+		return nil
+	}
+}
+
+var _ = Describe("Race", func() {
+	var thinker *fakeDuelist
+	var guessers []*observingGuesser
+	var race *Race
+	var rank string
+	var updates []RaceResult
+	var onUpdate func(RaceResult)
+	var err error
+
+	BeforeEach(func() {
+		thinker = newFakeDuelist("12")
+		rank = RankByTurns
+		updates = nil
+		onUpdate = nil
+	})
+
+	JustBeforeEach(func() {
+		gs := make([]Guesser, len(guessers))
+		for i, g := range guessers {
+			gs[i] = g
+		}
+		race = NewRace(thinker, gs, rank)
+		race.SetID("r1")
+		race.OnUpdate(func(r RaceResult) {
+			updates = append(updates, r)
+			if onUpdate != nil {
+				onUpdate(r)
+			}
+		})
+		err = race.Play()
+	})
+
+	Context("when a guesser guesses in fewer turns", func() {
+		BeforeEach(func() {
+			// The first guesser makes its second guess only once the
+			// second has guessed, and the second its last only once the
+			// first is guessing again.
+			guessing, solved := make(chan struct{}), make(chan struct{})
+			guessers = []*observingGuesser{newRacer("34", "21", "12"), newRacer("21", "12")}
+			waitFor(guessers[0], 2, guessing, solved)
+			waitFor(guessers[1], 2, nil, guessing)
+			closeOnSolved(guessers[1], solved)
+		})
+
+		It("should let it win", func() {
+			Ω(err).ShouldNot(HaveOccurred())
+			r := race.Result()
+			Ω(r.Over).Should(BeTrue())
+			Ω(r.Outcome).Should(Equal(OutcomeWin))
+			Ω(r.Secret).Should(Equal("12"))
+			Ω(r.Winner).Should(Equal(1))
+			Ω(r.Standings[0].Guesser).Should(Equal(1))
+			Ω(r.Standings[0].Place).Should(Equal(1))
+			Ω(r.Standings[0].Turns).Should(Equal(2))
+			Ω(r.Games[1].Outcome).Should(Equal(OutcomeWin))
+			Ω(r.Games[1].Moves).Should(HaveLen(2))
+		})
+
+		It("should stop the guessers who can no longer win", func() {
+			r := race.Result()
+			Ω(r.Standings[1].Guesser).Should(Equal(0))
+			Ω(r.Standings[1].Place).Should(BeZero())
+			Ω(r.Standings[1].Solved).Should(BeFalse())
+			Ω(r.Standings[1].Done).Should(BeTrue())
+			Ω(r.Games[0].Outcome).Should(Equal(OutcomeAbort))
+			Ω(r.Games[0].Reason).Should(Equal(ErrOutraced.Error()))
+			Ω(guessers[0].GuessCallCount()).Should(Equal(2))
+		})
+
+		It("should have the thinker score every guess once", func() {
+			Ω(thinker.ThinkCallCount()).Should(Equal(1))
+			Ω(thinker.TryCallCount()).Should(Equal(4))
+		})
+
+		It("should tell each guesser only its own scores", func() {
+			Ω(guessers[0].TellCallCount()).Should(Equal(2))
+			number, _, _ := guessers[0].TellArgsForCall(1)
+			Ω(number).Should(Equal("21"))
+			Ω(guessers[1].TellCallCount()).Should(Equal(2))
+		})
+
+		It("should tell each guesser the result of its game", func() {
+			for i, g := range guessers {
+				Ω(g.results).Should(Equal([]Result{race.Result().Games[i]}))
+			}
+		})
+
+		It("should push the standings as the race goes on", func() {
+			Ω(len(updates)).Should(BeNumerically(">", 4))
+			first := updates[0]
+			Ω(first.Over).Should(BeFalse())
+			Ω(first.Digits).Should(Equal(2))
+			Ω(first.Winner).Should(Equal(NoWinner))
+			Ω(first.Standings).Should(HaveLen(2))
+			for _, s := range first.Standings {
+				Ω(s.Turns).Should(BeZero())
+			}
+			Ω(updates[len(updates)-1]).Should(Equal(race.Result()))
+		})
+
+		It("should identify the race and its games", func() {
+			Ω(race.Result().ID).Should(Equal("r1"))
+			Ω(race.Games()[0].ID()).Should(Equal("r1-1"))
+			Ω(race.Result().Games[1].ID).Should(Equal("r1-2"))
+		})
+	})
+
+	Context("when a guesser guesses first, but in more turns", func() {
+		BeforeEach(func() {
+			guessing, solved := make(chan struct{}), make(chan struct{})
+			guessers = []*observingGuesser{newRacer("12"), newRacer("34", "12")}
+			waitFor(guessers[0], 1, guessing, solved)
+			waitFor(guessers[1], 2, nil, guessing)
+			closeOnSolved(guessers[1], solved)
+		})
+
+		It("should let the other win by turns", func() {
+			Ω(err).ShouldNot(HaveOccurred())
+			r := race.Result()
+			Ω(r.Rank).Should(Equal(RankByTurns))
+			Ω(r.Winner).Should(Equal(0))
+			Ω(r.Standings[0].Place).Should(Equal(1))
+			Ω(r.Standings[1].Place).Should(Equal(2))
+		})
+
+		Context("when ranking by time", func() {
+			BeforeEach(func() {
+				rank = RankByTime
+			})
+
+			It("should let it win", func() {
+				Ω(err).ShouldNot(HaveOccurred())
+				r := race.Result()
+				Ω(r.Rank).Should(Equal(RankByTime))
+				Ω(r.Winner).Should(Equal(1))
+				Ω(r.Standings[0].Place).Should(Equal(1))
+				Ω(r.Standings[1].Place).Should(Equal(2))
+			})
+		})
+	})
+
+	Context("when a guesser fails", func() {
+		BeforeEach(func() {
+			guessers = []*observingGuesser{newRacer("34"), newRacer("21", "12")}
+			guessers[0].GuessReturns("", errors.New("boom"))
+		})
+
+		It("should let the others race on", func() {
+			Ω(err).ShouldNot(HaveOccurred())
+			r := race.Result()
+			Ω(r.Winner).Should(Equal(1))
+			Ω(r.Games[0].Outcome).Should(Equal(OutcomeAbort))
+			Ω(r.Games[0].Reason).Should(Equal("boom"))
+			Ω(r.Standings[1].Done).Should(BeTrue())
+			Ω(r.Standings[1].Solved).Should(BeFalse())
+		})
+	})
+
+	Context("when every guesser fails", func() {
+		BeforeEach(func() {
+			guessers = []*observingGuesser{newRacer("34"), newRacer("21")}
+			guessers[0].GuessReturns("", ErrForfeit)
+			guessers[1].GuessReturns("", ErrForfeit)
+		})
+
+		It("should end the race without a winner", func() {
+			Ω(err).Should(MatchError(ErrForfeit))
+			Ω(race.Result().Outcome).Should(Equal(OutcomeForfeit))
+			Ω(race.Result().Winner).Should(Equal(NoWinner))
+		})
+	})
+
+	Context("when the thinker fails to score", func() {
+		BeforeEach(func() {
+			guessers = []*observingGuesser{newRacer("34"), newRacer("21")}
+			thinker.TryReturns(0, 0, errors.New("boom"))
+		})
+
+		It("should end the race", func() {
+			Ω(err).Should(MatchError("boom"))
+			r := race.Result()
+			Ω(r.Outcome).Should(Equal(OutcomeAbort))
+			Ω(r.Winner).Should(Equal(NoWinner))
+			Ω(thinker.TryCallCount()).Should(Equal(1))
+			for i, g := range guessers {
+				Ω(r.Games[i].Reason).Should(Equal("boom"))
+				Ω(g.results).Should(HaveLen(1))
+			}
+		})
+	})
+
+	Context("when the standings are told slowly", func() {
+		var heldUp bool
+
+		BeforeEach(func() {
+			// The standings after the first guess of the first guesser
+			// are told only once the second has been told its score.
+			telling, told := make(chan struct{}), make(chan struct{})
+			guessers = []*observingGuesser{newRacer("34", "12"), newRacer("21", "12")}
+			waitFor(guessers[1], 1, nil, telling)
+			guessers[1].TellStub = func(string, int, int) error {
+				if guessers[1].TellCallCount() == 1 {
+					close(told)
+				}
+				return nil
+			}
+			heldUp = false
+			onUpdate = func(r RaceResult) {
+				for _, s := range r.Standings {
+					if s.Guesser == 0 && s.Turns == 1 && telling != nil {
+						close(telling)
+						telling = nil
+						select {
+						case <-told:
+						case <-time.After(time.Second):
+							heldUp = true
+						}
+					}
+				}
+			}
+		})
+
+		It("should not hold up the guessers", func() {
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(heldUp).Should(BeFalse())
+		})
+	})
+
+	Context("when the thinker fails to think", func() {
+		BeforeEach(func() {
+			guessers = []*observingGuesser{newRacer("34"), newRacer("21")}
+			thinker.ThinkReturns(0, ErrForfeit)
+		})
+
+		It("should end the race before anyone guesses", func() {
+			Ω(err).Should(MatchError(ErrForfeit))
+			Ω(updates).Should(HaveLen(1))
+			for _, g := range guessers {
+				Ω(g.GuessCallCount()).Should(BeZero())
+				Ω(g.results).Should(HaveLen(1))
+				Ω(g.results[0].Outcome).Should(Equal(OutcomeForfeit))
+			}
+		})
+	})
+})
@@ -0,0 +1,63 @@
+package game
+
+import "errors"
+
+// ErrForfeit should be returned by a player that gives up a game.
+var ErrForfeit = errors.New("game: player forfeited")
+
+// Outcomes of a game.
+const (
+	// OutcomeWin means that the number was guessed.
+	OutcomeWin = "win"
+	// OutcomeAbort means that a player failed.
+	OutcomeAbort = "abort"
+	// OutcomeTimeout means that a player did not answer in time.
+	OutcomeTimeout = "timeout"
+	// OutcomeForfeit means that a player gave up.
+	OutcomeForfeit = "forfeit"
+)
+
+// Revealer may be implemented by a Thinker that is willing to reveal its
+// number once the game is over.
+type Revealer interface {
+	// Secret returns the number the thinker thought of, or empty string if
+	// it is not known.
+	Secret() string
+}
+
+// Move is a guess and its score.
+type Move struct {
+	Guess string `json:"guess"`
+	Cows  int    `json:"cows"`
+	Bulls int    `json:"bulls"`
+}
+
+// Result describes how a game ended.
+type Result struct {
+	// ID identifies the game, if it was given one.
+	ID      string `json:"id,omitempty"`
+	Outcome string `json:"outcome"`
+	// Reason is the error that ended the game, if any.
+	Reason string `json:"reason,omitempty"`
+	// Secret is the number of the thinker, if known.
+	Secret string `json:"secret,omitempty"`
+	Digits int    `json:"digits"`
+	Moves  []Move `json:"moves"`
+}
+
+// OutcomeOf returns the outcome of a game that ended with err.
+// Errors with a Timeout method reporting true, like the ones of the net
+// package, mean timeout.
+func OutcomeOf(err error) string {
+	var timeout interface{ Timeout() bool }
+	switch {
+	case err == nil:
+		return OutcomeWin
+	case errors.Is(err, ErrForfeit):
+		return OutcomeForfeit
+	case errors.As(err, &timeout) && timeout.Timeout():
+		return OutcomeTimeout
+	default:
+		return OutcomeAbort
+	}
+}
@@ -0,0 +1,30 @@
+package game_test
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/Bo0mer/cowbull/game"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string { return "timed out" }
+func (timeoutError) Timeout() bool { return true }
+
+var _ = Describe("OutcomeOf", func() {
+	DescribeTable("outcomes",
+		func(err error, outcome string) {
+			Ω(OutcomeOf(err)).Should(Equal(outcome))
+		},
+		Entry("no error", nil, OutcomeWin),
+		Entry("forfeit", ErrForfeit, OutcomeForfeit),
+		Entry("wrapped forfeit", fmt.Errorf("player 1: %w", ErrForfeit), OutcomeForfeit),
+		Entry("timeout", timeoutError{}, OutcomeTimeout),
+		Entry("wrapped timeout", fmt.Errorf("player 1: %w", timeoutError{}), OutcomeTimeout),
+		Entry("any other error", errors.New("kaboom"), OutcomeAbort))
+})
@@ -2,51 +2,98 @@ package cowbull
 
 import (
 	"errors"
-	"strings"
+	"sort"
 )
 
 // AIGuesser is an artificial intelligence that can guess numbers.
 type AIGuesser struct {
-	patterns  map[string]struct{}
-	lastGuess string
+	candidates []string
+	strategy   Strategy
+	lastGuess  string
+
+	// node is where the guesser is in its decision tree, if it plays from
+	// one.
+	node *Node
 }
 
-// LocalGuesser creates new AIGuesser that guesses n-digit numbers.
+// LocalGuesser creates new AIGuesser that guesses n-digit numbers at random.
 func LocalGuesser(n int) *AIGuesser {
-	patterns := make(map[string]struct{})
-	variations([]byte("1234567890"), n, 0, patterns)
-	g := &AIGuesser{
-		patterns: patterns,
+	return NewAIGuesser(n, RandomStrategy)
+}
+
+// NewAIGuesser creates new AIGuesser that guesses n-digit numbers as told
+// by strategy.
+func NewAIGuesser(n int, strategy Strategy) *AIGuesser {
+	return &AIGuesser{
+		candidates: Numbers(n),
+		strategy:   strategy,
 	}
+}
 
+// NewTreeGuesser creates new AIGuesser that plays from t, without searching.
+// Should the game leave the tree, e.g. because others guess too, it keeps
+// playing with the strategy of the tree.
+func NewTreeGuesser(t *Tree) *AIGuesser {
+	strategy, ok := Strategies[t.Strategy]
+	if !ok {
+		strategy = FirstStrategy
+	}
+	g := NewAIGuesser(t.Digits, strategy)
+	g.node = t.Root
 	return g
 }
 
 // Guess returns a guess number.
 func (g *AIGuesser) Guess(n int) (string, error) {
-	var pattern string
-	for pattern = range g.patterns {
-		delete(g.patterns, pattern)
-		break
+	if len(g.candidates) == 0 {
+		return "", errors.New("aiguesser: no possible numbers left")
+	}
+	var guess string
+	if g.node != nil && len(g.node.Guess) == n {
+		guess = g.node.Guess
+	} else {
+		g.node = nil
+		guess = g.strategy(g.candidates)
 	}
-	g.lastGuess = pattern
-	return pattern, nil
+	g.remove(guess)
+	g.lastGuess = guess
+	return guess, nil
 }
 
 // Tell tells the resource of a specific guess.
 func (g *AIGuesser) Tell(number string, cows, bulls int) error {
-	for pattern := range g.patterns {
-		c, b := computeCowsBulls(pattern, number)
-		if cows != c || bulls != b {
-			delete(g.patterns, pattern)
+	if bulls == len(number) {
+		// The number is guessed, there is nothing left to rule out.
+		return nil
+	}
+	if g.node != nil {
+		if number == g.node.Guess {
+			g.node = g.node.Next(cows, bulls)
+		} else {
+			g.node = nil
 		}
 	}
-	if len(g.patterns) == 0 {
+	left := g.candidates[:0]
+	for _, c := range g.candidates {
+		if c2, b2 := computeCowsBulls(c, number); c2 == cows && b2 == bulls {
+			left = append(left, c)
+		}
+	}
+	g.candidates = left
+	if len(g.candidates) == 0 {
 		return errors.New("invalid input")
 	}
 	return nil
 }
 
+// remove rules out number.
+func (g *AIGuesser) remove(number string) {
+	i := sort.SearchStrings(g.candidates, number)
+	if i < len(g.candidates) && g.candidates[i] == number {
+		g.candidates = append(g.candidates[:i], g.candidates[i+1:]...)
+	}
+}
+
 func variations(alphabet []byte, n int, k int, out map[string]struct{}) {
 	if k == n {
 		out[string(alphabet[:n])] = struct{}{}
@@ -59,14 +106,24 @@ func variations(alphabet []byte, n int, k int, out map[string]struct{}) {
 	}
 }
 
+// Score returns the cows and bulls of guess when the thought of number is
+// secret. Both should have the same digit count.
+func Score(secret, guess string) (cows int, bulls int) {
+	return computeCowsBulls(secret, guess)
+}
+
 func computeCowsBulls(origin, guess string) (int, int) {
+	var digits uint16
+	for i := 0; i < len(origin); i++ {
+		digits |= 1 << (origin[i] - '0')
+	}
 	var cows, bulls int
 	for i := range guess {
 		if guess[i] == origin[i] {
 			bulls++
 			continue
 		}
-		if strings.Contains(origin, string(guess[i])) {
+		if digits&(1<<(guess[i]-'0')) != 0 {
 			cows++
 		}
 	}
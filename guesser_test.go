@@ -46,6 +46,15 @@ var _ = Describe("AIGuesser", func() {
 		})
 	})
 
+	Context("when told that the number is guessed", func() {
+		It("should not return an error", func() {
+			g = LocalGuesser(4)
+			guess, err := g.Guess(4)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(g.Tell(guess, 0, 4)).Should(Succeed())
+		})
+	})
+
 	Context("when the thinker is speculating", func() {
 		BeforeEach(func() {
 			g = LocalGuesser(4)
@@ -65,6 +74,14 @@ var _ = Describe("AIGuesser", func() {
 	})
 })
 
+var _ = Describe("Score", func() {
+	It("should count cows and bulls of a guess", func() {
+		cows, bulls := Score("4201", "1204")
+		Ω(cows).Should(Equal(2))
+		Ω(bulls).Should(Equal(2))
+	})
+})
+
 func computeCowsBulls(origin, guess string) (int, int) {
 	var cows, bulls int
 	for i := range guess {
@@ -0,0 +1,83 @@
+package cowbull
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// readyTimeout is how long the loop of a hub may take to answer before the
+// hub is no longer ready.
+const readyTimeout = time.Second
+
+// pinger may be implemented by a Store that can tell whether it is
+// reachable.
+type pinger interface {
+	Ping() error
+}
+
+// Ready fails unless the hub may start games: its loop answers and it is
+// not draining.
+func (h *Hub) Ready() error {
+	draining := make(chan bool, 1)
+	op := func(map[string]Player) {
+		draining <- h.draining
+	}
+	// Unlike do, the op is not run inline once the hub is closed.
+	select {
+	case h.ops <- op:
+	case <-h.closed:
+		return errors.New("the hub is closed")
+	case <-time.After(readyTimeout):
+		return errors.New("the hub is not answering")
+	}
+	if <-draining {
+		return errors.New("the server is shutting down")
+	}
+	return nil
+}
+
+// Ready fails unless the server may start games: its hub is ready, and its
+// store, if any, is reachable.
+func (s *Server) Ready() error {
+	if s.hub == nil {
+		return errors.New("there is no hub")
+	}
+	if err := s.hub.Ready(); err != nil {
+		return err
+	}
+	if p, ok := s.store.(pinger); ok {
+		if err := p.Ping(); err != nil {
+			return fmt.Errorf("the store is unreachable: %v", err)
+		}
+	}
+	return nil
+}
+
+// serveHealth answers GET /healthz as long as the server serves HTTP.
+func (s *Server) serveHealth(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// serveReady answers GET /readyz with 200 OK if the server is ready, and
+// with 503 Service Unavailable and the reason otherwise.
+func (s *Server) serveReady(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.Ready(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
@@ -0,0 +1,101 @@
+package cowbull_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Health", func() {
+	var hub *Hub
+	var store *pingingStore
+	var server *Server
+
+	BeforeEach(func() {
+		logger := slog.New(slog.NewTextHandler(GinkgoWriter, nil))
+		hub = NewHub(new(cowbullfakes.FakeGamer), logger)
+		store = &pingingStore{FakeStore: new(cowbullfakes.FakeStore)}
+		server = NewServer(&ServerConfig{
+			Log:   logger,
+			Hub:   hub,
+			Store: store,
+		})
+	})
+
+	get := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	Describe("GET /healthz", func() {
+		It("should answer as long as the server serves HTTP", func() {
+			Expect(hub.Close(context.Background())).To(Succeed())
+			w := get("GET", "/healthz")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(Equal("ok\n"))
+		})
+
+		It("should only allow GET and HEAD", func() {
+			Expect(get("HEAD", "/healthz").Code).To(Equal(http.StatusOK))
+			Expect(get("POST", "/healthz").Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe("GET /readyz", func() {
+		It("should answer once the hub and the store are up", func() {
+			w := get("GET", "/readyz")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(Equal("ok\n"))
+			Expect(server.Ready()).To(Succeed())
+		})
+
+		It("should not be ready while draining", func() {
+			server.Drain(time.Now().Add(time.Minute))
+			w := get("GET", "/readyz")
+			Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(w.Body.String()).To(ContainSubstring("shutting down"))
+		})
+
+		It("should not be ready once the hub is closed", func() {
+			Expect(hub.Close(context.Background())).To(Succeed())
+			Expect(hub.Ready()).To(MatchError("the hub is closed"))
+			Expect(get("GET", "/readyz").Code).To(Equal(http.StatusServiceUnavailable))
+		})
+
+		It("should not be ready while the store is unreachable", func() {
+			store.err = errors.New("disk on fire")
+			w := get("GET", "/readyz")
+			Expect(w.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(w.Body.String()).To(ContainSubstring("disk on fire"))
+		})
+
+		It("should not be ready without a hub", func() {
+			server = NewServer(&ServerConfig{Log: slog.New(slog.NewTextHandler(GinkgoWriter, nil))})
+			Expect(get("GET", "/readyz").Code).To(Equal(http.StatusServiceUnavailable))
+		})
+
+		It("should only allow GET and HEAD", func() {
+			Expect(get("POST", "/readyz").Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+})
+
+// pingingStore is a store that fails pings with err.
+type pingingStore struct {
+	*cowbullfakes.FakeStore
+	err error
+}
+
+func (s *pingingStore) Ping() error {
+	return s.err
+}
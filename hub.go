@@ -2,9 +2,13 @@ package cowbull
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
 )
 
 //go:generate counterfeiter . Player
@@ -12,16 +16,13 @@ import (
 
 const (
 	// RoleThinker labels the thinker role.
-	RoleThinker = "thinker"
+	RoleThinker = protocol.RoleThinker
 	// RoleGuesser labels the guesser role.
-	RoleGuesser = "guesser"
+	RoleGuesser = protocol.RoleGuesser
 )
 
 // PlayerEntry holds metadata for a player.
-type PlayerEntry struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
-}
+type PlayerEntry = protocol.PlayerEntry
 
 // Player represents a hub member.
 type Player interface {
@@ -46,12 +47,7 @@ type Gamer interface {
 }
 
 // GameSettings represents settings for a game request to a Hub.
-type GameSettings struct {
-	Role      string   `json:"role"`      // role of the player, either thinker or guesser
-	Digits    int      `json:"digits"`    // how many digits should the number have
-	AI        bool     `json:"ai"`        // whether the game is versus AI
-	Opponents []string `json:"opponents"` // the opponents of the player
-}
+type GameSettings = protocol.GameSettings
 
 type hubOp func(map[string]Player)
 
@@ -59,48 +55,151 @@ type hubOp func(map[string]Player)
 type Hub struct {
 	gamer   Gamer
 	players map[string]Player
+	trees   map[int]*Tree
+
+	// Matchmaking. The queue is only touched by hub ops.
+	ratings  *Ratings
+	aiWait   time.Duration
+	finished func(g *game.Game, settings GameSettings, started time.Time)
+	queue    []*queued
+
+	// Games with several guessers. The multis are only touched by hub ops.
+	maxStrikes int
+	rejoin     bool
+	multis     map[*MultiGuesser]bool
+
+	// Teams whose game may be on. They are only touched by hub ops.
+	teams map[*TeamGuesser]bool
+
+	// Chat. Its state is only touched by hub ops.
+	chatRate    int
+	chatHistory int
+	rooms       map[string]map[string]bool // IDs of the members of each room
+	history     map[string][]protocol.Chat // last messages of the lobby and rooms
+	chatSent    map[string][]time.Time     // recent messages of each player
+
+	// Games on. They are only touched by hub ops.
+	live     map[*liveGame]bool
+	lastGame int // ID of the last game on
 
-	log *log.Logger
+	// Games played at once, started by the hub or for a server.
+	games *gameSlots
 
+	// Shutdown. Draining and idle are only touched by hub ops.
+	draining  bool
+	drained   chan struct{}   // closed once the hub drains
+	idle      []chan struct{} // closed once no game is on
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	metrics *Metrics
+	log     *slog.Logger
+
+	mu  sync.Mutex // held while an op runs
 	ops chan hubOp
 }
 
-// NewHub creates a brand new hub.
-func NewHub(gamer Gamer, log *log.Logger) *Hub {
+// NewHub creates a brand new hub, which logs to log.
+func NewHub(gamer Gamer, log *slog.Logger) *Hub {
 	hub := &Hub{
 		gamer:   gamer,
 		players: make(map[string]Player),
-		log:     log,
-		ops:     make(chan hubOp, 1),
+		trees:   make(map[int]*Tree),
+		multis:  make(map[*MultiGuesser]bool),
+		teams:   make(map[*TeamGuesser]bool),
+
+		chatRate:    5,
+		chatHistory: 50,
+		rooms:       make(map[string]map[string]bool),
+		history:     make(map[string][]protocol.Chat),
+		chatSent:    make(map[string][]time.Time),
+		live:        make(map[*liveGame]bool),
+
+		drained: make(chan struct{}),
+		closed:  make(chan struct{}),
+
+		log: log,
+		ops: make(chan hubOp),
 	}
 	go hub.loop()
 
 	return hub
 }
 
+// UseTree makes the AI guessers of the hub play games with t.Digits digits
+// from t. It should be called before the hub is in use.
+func (h *Hub) UseTree(t *Tree) {
+	h.trees[t.Digits] = t
+}
+
+// UseMetrics makes the hub count the games it starts on its own in m. It
+// should be called before the hub is in use.
+func (h *Hub) UseMetrics(m *Metrics) {
+	h.metrics = m
+}
+
+// StrikeGuessers makes the guessers of games with several guessers be
+// dropped after maxStrikes failures, instead of the first. With rejoin,
+// dropped guessers joining the hub again are let back in their game. It
+// should be called before the hub is in use.
+func (h *Hub) StrikeGuessers(maxStrikes int, rejoin bool) {
+	h.maxStrikes, h.rejoin = maxStrikes, rejoin
+}
+
+// LimitGames caps the games played at once to max, and the games a player
+// may play at once to perPlayer, zero meaning no limit. Games the hub
+// starts on its own, by matchmaking or for tournaments, take part in the
+// caps. It should be called before the hub is in use.
+func (h *Hub) LimitGames(max, perPlayer int) {
+	h.games = newGameSlots(max, perPlayer)
+}
+
 // Add adds a player to the hub.
-// Once added, it will get updates by the hub for any significant events.
+// Once added, it will get updates by the hub for any significant events,
+// starting with the last messages of the lobby chat.
+// A player dropped from a game with several guessers may rejoin it.
 func (h *Hub) Add(p Player) {
-	h.ops <- func(players map[string]Player) {
+	h.do(func(players map[string]Player) {
 		players[p.ID()] = p
-		h.log.Printf("player %s joined", p.ID())
-	}
+		h.log.Info("player joined", "player_id", p.ID(), "name", p.Name())
+		h.replay(p, chatKey(protocol.ScopeLobby, ""))
+		for m := range h.multis {
+			if m.finished() {
+				delete(h.multis, m)
+				continue
+			}
+			go func(m *MultiGuesser) {
+				if m.Rejoin(p) {
+					h.log.Info("player rejoined its game", "player_id", p.ID())
+					h.do(func(map[string]Player) {
+						h.rejoinLive(m, p)
+					})
+				}
+			}(m)
+		}
+	})
 	h.broadcastPlayers()
 }
 
-// Remove removes a player from the hub.
+// Remove removes a player from the hub, from the matchmaking queue and from
+// the chat rooms.
 func (h *Hub) Remove(pid string) {
-	h.ops <- func(players map[string]Player) {
+	h.do(func(players map[string]Player) {
 		delete(players, pid)
-		h.log.Printf("player %s left", pid)
-	}
+		h.dequeue(func(e *queued) bool { return e.p.ID() == pid })
+		for room := range h.rooms {
+			h.leaveRoom(pid, room)
+		}
+		delete(h.chatSent, pid)
+		h.log.Info("player left", "player_id", pid)
+	})
 	h.broadcastPlayers()
 }
 
 // Players returns all player entries in the hub.
 func (h *Hub) getPlayers() []PlayerEntry {
 	playersChan := make(chan []PlayerEntry, 1)
-	h.ops <- func(players map[string]Player) {
+	h.do(func(players map[string]Player) {
 		i := 0
 		ret := make([]PlayerEntry, len(players))
 
@@ -110,11 +209,21 @@ func (h *Hub) getPlayers() []PlayerEntry {
 			i++
 		}
 		playersChan <- ret
-	}
+	})
 	return <-playersChan
 }
 
+// aiGuesser returns an AI guesser of n-digit numbers.
+func (h *Hub) aiGuesser(n int) *AIGuesser {
+	if t, ok := h.trees[n]; ok {
+		return NewTreeGuesser(t)
+	}
+	return LocalGuesser(n)
+}
+
 // NewGame creates a new Game based on the provided settings.
+// If the settings do not make up a game, the returned error is a
+// *protocol.Error describing why.
 func (h *Hub) NewGame(from Player, settings GameSettings) (*game.Game, error) {
 	var thinker game.Thinker
 	var guesser game.Guesser
@@ -123,17 +232,26 @@ func (h *Hub) NewGame(from Player, settings GameSettings) (*game.Game, error) {
 	case RoleThinker:
 		thinker = from
 		if settings.AI {
-			guesser = LocalGuesser(settings.Digits)
+			guesser = h.aiGuesser(settings.Digits)
 			break
 		}
-		opponents := h.playersWithIDs(settings.Opponents)
+		opponents, err := h.opponents(settings.Opponents)
+		if err != nil {
+			return nil, err
+		}
 		// invalid input
 		if len(opponents) == 0 {
-			return nil, fmt.Errorf("no guesser specified")
+			return nil, protocol.Errorf(protocol.CodeInvalidSettings, "no guesser specified")
 		}
 		// multiple guessers
 		if len(opponents) > 1 {
-			guesser = &MultiGuesser{Players: opponents}
+			multi := &MultiGuesser{Players: opponents, MaxStrikes: h.maxStrikes, AllowRejoin: h.rejoin, Log: h.log}
+			if h.rejoin {
+				h.do(func(map[string]Player) {
+					h.multis[multi] = true
+				})
+			}
+			guesser = multi
 			break
 		}
 		// single guesser
@@ -141,35 +259,61 @@ func (h *Hub) NewGame(from Player, settings GameSettings) (*game.Game, error) {
 	case RoleGuesser:
 		guesser = from
 		if settings.AI {
+			if settings.Digits < 1 || settings.Digits > 10 {
+				return nil, protocol.Errorf(protocol.CodeInvalidSettings, "invalid digit count %d", settings.Digits)
+			}
 			thinker = LocalThinker(settings.Digits)
 			break
 		}
-		opponents := h.playersWithIDs(settings.Opponents)
+		opponents, err := h.opponents(settings.Opponents)
+		if err != nil {
+			return nil, err
+		}
 		if len(opponents) != 1 {
 			// there is no game with multiple thinkers
-			return nil, fmt.Errorf("invalid number of thinkers: %d", len(opponents))
+			return nil, protocol.Errorf(protocol.CodeInvalidSettings, "invalid number of thinkers: %d", len(opponents))
 		}
 		thinker = opponents[0]
 	default:
-		return nil, fmt.Errorf("invalid role: %s", settings.Role)
+		return nil, protocol.Errorf(protocol.CodeInvalidRole, "invalid role: %s", settings.Role)
 	}
 	return h.gamer.Game(thinker, guesser)
 }
 
+// opponents returns the players with the specified ids.
+// It fails if any of them is not in the hub.
+func (h *Hub) opponents(ids []string) ([]Player, error) {
+	players := h.playersWithIDs(ids)
+	if len(players) == len(ids) {
+		return players, nil
+	}
+	found := make(map[string]bool)
+	for _, p := range players {
+		found[p.ID()] = true
+	}
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, fmt.Sprintf("%q", id))
+		}
+	}
+	return nil, protocol.Errorf(protocol.CodeUnknownOpponent, "unknown opponents: %s", strings.Join(missing, ", "))
+}
+
 func (h *Hub) broadcastPlayers() {
 	playerIDs := h.getPlayers()
-	h.ops <- func(players map[string]Player) {
+	h.do(func(players map[string]Player) {
 		for _, p := range players {
 			if err := p.AnnouncePlayers(playerIDs); err != nil {
-				h.log.Printf("error announcing players to %s: %v", p.ID(), err)
+				h.log.Warn("error announcing players", "player_id", p.ID(), "err", err)
 			}
 		}
-	}
+	})
 }
 
 func (h *Hub) playersWithIDs(ids []string) []Player {
 	playersChan := make(chan []Player, 1)
-	h.ops <- func(players map[string]Player) {
+	h.do(func(players map[string]Player) {
 		var ret []Player
 		for _, id := range ids {
 			if p, ok := players[id]; ok {
@@ -177,12 +321,40 @@ func (h *Hub) playersWithIDs(ids []string) []Player {
 			}
 		}
 		playersChan <- ret
-	}
+	})
 	return <-playersChan
 }
 
+// do runs op in the loop of the hub, or right away once the hub is closed.
+func (h *Hub) do(op hubOp) {
+	select {
+	case h.ops <- op:
+	case <-h.closed:
+		h.run(op)
+	}
+}
+
+func (h *Hub) run(op hubOp) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	op(h.players)
+}
+
 func (h *Hub) loop() {
-	for op := range h.ops {
-		op(h.players)
+	for {
+		select {
+		case op := <-h.ops:
+			h.run(op)
+		case <-h.closed:
+			return
+		}
+	}
+}
+
+// logger returns l, or the default logger if l is nil.
+func logger(l *slog.Logger) *slog.Logger {
+	if l == nil {
+		return slog.Default()
 	}
+	return l
 }
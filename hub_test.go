@@ -1,11 +1,12 @@
 package cowbull_test
 
 import (
-	"log"
+	"log/slog"
 
 	. "github.com/Bo0mer/cowbull"
 	"github.com/Bo0mer/cowbull/cowbullfakes"
 	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -17,7 +18,7 @@ var _ = Describe("Hub", func() {
 	var hub *Hub
 
 	initHub := func() {
-		logger := log.New(GinkgoWriter, "", 0)
+		logger := slog.New(slog.NewTextHandler(GinkgoWriter, nil))
 		gamer = new(cowbullfakes.FakeGamer)
 		hub = NewHub(gamer, logger)
 	}
@@ -109,13 +110,56 @@ var _ = Describe("Hub", func() {
 				Expect(err).To(HaveOccurred())
 				Expect(g).To(BeNil())
 			})
+
+			It("should return an 'invalid_role' error", func() {
+				Expect(err.(*protocol.Error).Code).To(Equal(protocol.CodeInvalidRole))
+			})
+		})
+
+		Context("with AI thinker and invalid digit count", func() {
+			BeforeEach(func() {
+				settings = GameSettings{
+					Role:   RoleGuesser,
+					AI:     true,
+					Digits: 11,
+				}
+			})
+
+			It("should return an 'invalid_settings' error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.(*protocol.Error).Code).To(Equal(protocol.CodeInvalidSettings))
+				Expect(gamer.GameCallCount()).To(BeZero())
+			})
+		})
+
+		Context("with unknown opponents", func() {
+			BeforeEach(func() {
+				settings = GameSettings{
+					Role:      RoleThinker,
+					Opponents: []string{"random2", "ghost"},
+				}
+				player = playerWithId("random1")
+				player2 := playerWithId("random2")
+
+				initHub()
+				hub.Add(player)
+				hub.Add(player2)
+			})
+
+			It("should return an 'unknown_opponent' error naming them", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.(*protocol.Error).Code).To(Equal(protocol.CodeUnknownOpponent))
+				Expect(err.Error()).To(ContainSubstring(`"ghost"`))
+				Expect(err.Error()).NotTo(ContainSubstring(`"random2"`))
+			})
 		})
 
 		Context("with AI thinker and player guesser", func() {
 			BeforeEach(func() {
 				settings = GameSettings{
-					Role: RoleGuesser,
-					AI:   true,
+					Role:   RoleGuesser,
+					AI:     true,
+					Digits: 4,
 				}
 			})
 
@@ -130,6 +174,27 @@ var _ = Describe("Hub", func() {
 			})
 		})
 
+		Context("with AI guesser and a decision tree", func() {
+			BeforeEach(func() {
+				settings = GameSettings{
+					Role:   RoleThinker,
+					AI:     true,
+					Digits: 2,
+				}
+				tree, err := BuildTree(2, "first")
+				Expect(err).NotTo(HaveOccurred())
+				hub.UseTree(tree)
+			})
+
+			It("should play from the tree", func() {
+				Expect(err).ShouldNot(HaveOccurred())
+				_, g := gamer.GameArgsForCall(0)
+				guess, err := g.Guess(2)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(guess).To(Equal("01"))
+			})
+		})
+
 		Context("with request for guesser for two players", func() {
 			// TODO(ivan): DRY this out
 			BeforeEach(func() {
@@ -218,6 +283,36 @@ var _ = Describe("Hub", func() {
 				Expect(ok).To(BeTrue())
 			})
 
+			Context("when dropped guessers may rejoin", func() {
+				BeforeEach(func() {
+					initHub()
+					hub.StrikeGuessers(2, true)
+					hub.Add(player)
+					hub.Add(playerWithId("random2"))
+					hub.Add(playerWithId("random3"))
+				})
+
+				It("should let a guesser connecting again back in", func() {
+					Expect(err).ShouldNot(HaveOccurred())
+					_, g := gamer.GameArgsForCall(0)
+					multi := g.(*MultiGuesser)
+					Expect(multi.MaxStrikes).To(Equal(2))
+					multi.Players[0].(*cowbullfakes.FakePlayer).GuessReturns("", game.ErrForfeit)
+					multi.Players[1].(*cowbullfakes.FakePlayer).GuessReturns("12", nil)
+					for i := 0; i < 4; i++ {
+						multi.Guess(2)
+					}
+					Expect(multi.Players).To(HaveLen(1))
+
+					back := playerWithId("random2")
+					back.GuessReturns("34", nil)
+					hub.Add(back)
+					Eventually(func() string {
+						n, _ := multi.Guess(2)
+						return n
+					}).Should(Equal("34"))
+				})
+			})
 		})
 	})
 })
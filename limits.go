@@ -0,0 +1,195 @@
+package cowbull
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+// AnyKind is the key of Limits.Messages limiting the message kinds not
+// listed on their own.
+const AnyKind = "*"
+
+// Limit is a token bucket: a client may send Burst messages at once, and
+// then Rate messages per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Limits bounds what clients may do. Zero values mean no bound.
+type Limits struct {
+	// MessageSize is how many bytes of data a message may carry.
+	MessageSize int
+	// Messages limits how often a client may send messages of each kind,
+	// or of AnyKind.
+	Messages map[string]Limit
+	// Games is how many games may be played at once on the server.
+	Games int
+	// GamesPerPlayer is how many games a player may request at once.
+	GamesPerPlayer int
+	// Tournaments is how many tournaments may be registering or running at
+	// once on the server.
+	Tournaments int
+}
+
+// DefaultLimits returns limits generous enough for players, but not for
+// scripts flooding the server.
+func DefaultLimits() Limits {
+	return Limits{
+		MessageSize: 4096,
+		Messages: map[string]Limit{
+			AnyKind:                 {Rate: 20, Burst: 40},
+			protocol.KindPlay:       {Rate: 0.5, Burst: 5},
+			protocol.KindRegister:   {Rate: 0.1, Burst: 3},
+			protocol.KindLogin:      {Rate: 0.1, Burst: 3},
+			protocol.KindChat:       {Rate: 1, Burst: 10},
+			protocol.KindTeamChat:   {Rate: 1, Burst: 10},
+			protocol.KindQueue:      {Rate: 0.5, Burst: 5},
+			protocol.KindTournament: {Rate: 0.1, Burst: 3},
+		},
+		Games:          1000,
+		GamesPerPlayer: 1,
+		Tournaments:    100,
+	}
+}
+
+// tokenBucket tells whether an event may happen under a Limit.
+type tokenBucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(l Limit, now time.Time) *tokenBucket {
+	return &tokenBucket{limit: l, tokens: float64(l.Burst), last: now}
+}
+
+// allow reports whether an event may happen at now, and takes a token for
+// it if so.
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.Rate
+	if b.tokens > float64(b.limit.Burst) {
+		b.tokens = float64(b.limit.Burst)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// gameSlots caps the games played at once, in total and by each player.
+// All methods of a nil *gameSlots do nothing.
+type gameSlots struct {
+	max, perPlayer int
+
+	mu      sync.Mutex
+	total   int
+	players map[string]int
+	freed   chan struct{} // closed and replaced whenever a slot is freed
+}
+
+func newGameSlots(max, perPlayer int) *gameSlots {
+	return &gameSlots{
+		max:       max,
+		perPlayer: perPlayer,
+		players:   make(map[string]int),
+		freed:     make(chan struct{}),
+	}
+}
+
+// acquire takes a slot for a game of the players with ids. It fails with a
+// *protocol.Error if there is none left.
+func (s *gameSlots) acquire(ids ...string) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.take(ids, true)
+}
+
+// check fails like acquire would, without taking a slot.
+func (s *gameSlots) check(ids ...string) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.take(ids, false)
+}
+
+// wait takes a slot for a game of the players with ids as soon as there is
+// one. It reports false if stop is closed first.
+func (s *gameSlots) wait(stop <-chan struct{}, ids ...string) bool {
+	if s == nil {
+		return true
+	}
+	for {
+		s.mu.Lock()
+		err := s.take(ids, true)
+		freed := s.freed
+		s.mu.Unlock()
+		if err == nil {
+			return true
+		}
+		select {
+		case <-freed:
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// take checks that there is a slot for a game of the players with ids, and
+// takes it if do is set. It must be called with mu held.
+func (s *gameSlots) take(ids []string, do bool) error {
+	if s.max > 0 && s.total >= s.max {
+		return protocol.Errorf(protocol.CodeTooManyGames, "the server plays %d games already", s.total)
+	}
+	for _, id := range ids {
+		if s.perPlayer > 0 && s.players[id] >= s.perPlayer {
+			if len(ids) == 1 {
+				return protocol.Errorf(protocol.CodeTooManyGames, "you play %d games already", s.players[id])
+			}
+			return protocol.Errorf(protocol.CodeTooManyGames, "%s plays %d games already", id, s.players[id])
+		}
+	}
+	if do {
+		s.total++
+		for _, id := range ids {
+			s.players[id]++
+		}
+	}
+	return nil
+}
+
+// playing reports whether the player with id plays a game.
+func (s *gameSlots) playing(id string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.players[id] > 0
+}
+
+// release frees a slot taken for a game of the players with ids.
+func (s *gameSlots) release(ids ...string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.total--
+	for _, id := range ids {
+		if s.players[id]--; s.players[id] <= 0 {
+			delete(s.players, id)
+		}
+	}
+	close(s.freed)
+	s.freed = make(chan struct{})
+}
@@ -0,0 +1,36 @@
+package cowbull_test
+
+import (
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DefaultLimits", func() {
+	var limits Limits
+
+	BeforeEach(func() {
+		limits = DefaultLimits()
+	})
+
+	It("should limit every kind of message", func() {
+		Expect(limits.Messages).To(HaveKey(AnyKind))
+		for kind, l := range limits.Messages {
+			Expect(l.Rate).To(BeNumerically(">", 0), kind)
+			Expect(l.Burst).To(BeNumerically(">=", 1), kind)
+		}
+	})
+
+	It("should let the hub refuse chat messages before the client is disconnected", func() {
+		// The hub lets players send 5 chat messages every 10 seconds.
+		Expect(limits.Messages[protocol.KindChat].Burst).To(BeNumerically(">", 5))
+	})
+
+	It("should cap the games", func() {
+		Expect(limits.MessageSize).To(BeNumerically(">", 0))
+		Expect(limits.Games).To(BeNumerically(">", limits.GamesPerPlayer))
+		Expect(limits.GamesPerPlayer).To(BeNumerically(">", 0))
+	})
+})
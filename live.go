@@ -0,0 +1,223 @@
+package cowbull
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Bo0mer/cowbull/game"
+)
+
+// ErrAborted ends a game aborted by an admin.
+var ErrAborted = errors.New("cowbull: the game was aborted")
+
+// gameJoiner may be implemented by a Player waiting for answers, so that
+// the games it plays can be aborted.
+type gameJoiner interface {
+	// JoinGame tells the player that it plays the game of ctx, which is
+	// done once the game is aborted, with the reason as its cause. The
+	// player should fail with the cause whenever it waits for an answer in
+	// the game.
+	JoinGame(ctx context.Context)
+	// LeaveGame tells the player that the game of ctx is over.
+	LeaveGame(ctx context.Context)
+}
+
+// LiveGame describes a game in progress.
+type LiveGame struct {
+	ID   string `json:"id"`
+	Mode string `json:"mode"`
+	// Players are the participants. The computer takes part with AIPlayer
+	// as its ID.
+	Players []PlayerEntry `json:"players"`
+	Started time.Time     `json:"started"`
+	// Elapsed is how many seconds ago the game started.
+	Elapsed float64 `json:"elapsed"`
+}
+
+// liveGame is a game in progress, among its thinkers and guessers.
+type liveGame struct {
+	seq      int
+	id       string
+	mode     string
+	started  time.Time
+	thinkers []game.Thinker
+	guessers []game.Guesser
+	ctx      context.Context // done once the game is aborted or over
+	abort    context.CancelCauseFunc
+}
+
+// players returns the players taking part in the game.
+func (g *liveGame) players() []Player {
+	var players []Player
+	for _, t := range g.thinkers {
+		players = append(players, playersOf(t)...)
+	}
+	for _, gu := range g.guessers {
+		players = append(players, playersOf(gu)...)
+	}
+	return players
+}
+
+// describe describes the game as of now.
+func (g *liveGame) describe(now time.Time) LiveGame {
+	lg := LiveGame{
+		ID:      g.id,
+		Mode:    modeLabel(g.mode),
+		Started: g.started,
+		Elapsed: now.Sub(g.started).Seconds(),
+	}
+	seen := make(map[string]bool)
+	add := func(p interface{}) {
+		for _, e := range participants(p) {
+			if !seen[e.ID] {
+				seen[e.ID] = true
+				lg.Players = append(lg.Players, e)
+			}
+		}
+	}
+	for _, t := range g.thinkers {
+		add(t)
+	}
+	for _, gu := range g.guessers {
+		add(gu)
+	}
+	return lg
+}
+
+// playersOf returns the players behind a game player.
+func playersOf(p interface{}) []Player {
+	switch p := p.(type) {
+	case *matchPlayer:
+		return []Player{p.Player}
+	case Player:
+		return []Player{p}
+	case *MultiGuesser:
+		return p.all()
+	case *TeamGuesser:
+		return p.Players
+	}
+	return nil
+}
+
+// gameOn lets the hub know that a game of a mode among thinkers and
+// guessers is on, and opens its chat. It returns the logger of the game,
+// whose lines carry its ID as game_id, and a function that must be called
+// once the game is over.
+func (h *Hub) gameOn(mode string, thinkers []game.Thinker, guessers []game.Guesser) (*slog.Logger, func()) {
+	ctx, abort := context.WithCancelCause(context.Background())
+	g := &liveGame{mode: mode, started: time.Now(), thinkers: thinkers, guessers: guessers, ctx: ctx, abort: abort}
+	ids := make(chan string, 1)
+	h.do(func(map[string]Player) {
+		h.lastGame++
+		g.seq, g.id = h.lastGame, strconv.Itoa(h.lastGame)
+		h.live[g] = true
+		ids <- g.id
+	})
+	log := h.log.With("game_id", <-ids)
+	var players []string
+	for _, p := range g.describe(g.started).Players {
+		players = append(players, p.ID)
+	}
+	log.Info("game started", "mode", modeLabel(mode), "players", players)
+	for _, p := range g.players() {
+		if j, ok := p.(gameJoiner); ok {
+			j.JoinGame(ctx)
+		}
+	}
+	return log, func() {
+		h.do(func(map[string]Player) {
+			delete(h.live, g)
+			if len(h.live) == 0 {
+				h.notifyIdle()
+			}
+		})
+		// The game cannot be aborted anymore once it is no longer live.
+		for _, p := range g.players() {
+			if j, ok := p.(gameJoiner); ok {
+				j.LeaveGame(ctx)
+			}
+		}
+		abort(nil)
+	}
+}
+
+// rejoinLive lets p, who rejoined the game of m, be aborted with it. It
+// must be called by a hub op.
+func (h *Hub) rejoinLive(m *MultiGuesser, p Player) {
+	j, ok := p.(gameJoiner)
+	if !ok {
+		return
+	}
+	for g := range h.live {
+		for _, gu := range g.guessers {
+			if gu == game.Guesser(m) {
+				j.JoinGame(g.ctx)
+			}
+		}
+	}
+}
+
+// traceMoves makes g log its moves to log, at debug level.
+func traceMoves(log *slog.Logger, g *game.Game) {
+	g.OnMove(func(m game.Move, t game.Timing) {
+		log.Debug("move", "guess", m.Guess, "cows", m.Cows, "bulls", m.Bulls,
+			"guess_seconds", t.Guess.Seconds(), "try_seconds", t.Try.Seconds())
+	})
+}
+
+// traceResult logs the result of a game that is over to log, with the ID
+// it is recorded under, if any.
+func traceResult(log *slog.Logger, r game.Result) {
+	args := []interface{}{"outcome", r.Outcome, "turns", len(r.Moves)}
+	if r.Reason != "" {
+		args = append(args, "reason", r.Reason)
+	}
+	if r.ID != "" {
+		args = append(args, "record_id", r.ID)
+	}
+	log.Info("game finished", args...)
+}
+
+// LiveGames returns the games in progress, the oldest first.
+func (h *Hub) LiveGames() []LiveGame {
+	games := make(chan []*liveGame, 1)
+	h.do(func(map[string]Player) {
+		gs := make([]*liveGame, 0, len(h.live))
+		for g := range h.live {
+			gs = append(gs, g)
+		}
+		games <- gs
+	})
+	gs := <-games
+	sort.Slice(gs, func(i, j int) bool {
+		return gs[i].seq < gs[j].seq
+	})
+	now := time.Now()
+	described := make([]LiveGame, len(gs))
+	for i, g := range gs {
+		described[i] = g.describe(now)
+	}
+	return described
+}
+
+// Abort aborts the game in progress with an ID, making its players fail
+// with ErrAborted. It reports whether there is such a game.
+func (h *Hub) Abort(id string) bool {
+	found := make(chan bool, 1)
+	h.do(func(map[string]Player) {
+		for g := range h.live {
+			if g.id != id {
+				continue
+			}
+			g.abort(ErrAborted)
+			found <- true
+			return
+		}
+		found <- false
+	})
+	return <-found
+}
@@ -0,0 +1,173 @@
+package cowbull_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Live games", func() {
+	var hub *Hub
+	var bob *abortingPlayer
+	var finished chan game.Result
+	var logs *gbytes.Buffer
+
+	BeforeEach(func() {
+		gamer := new(cowbullfakes.FakeGamer)
+		gamer.GameStub = func(t game.Thinker, g game.Guesser) (*game.Game, error) {
+			return game.New(t, g), nil
+		}
+		logs = gbytes.NewBuffer()
+		hub = NewHub(gamer, slog.New(slog.NewTextHandler(io.MultiWriter(logs, GinkgoWriter), &slog.HandlerOptions{Level: slog.LevelDebug})))
+		finished = make(chan game.Result, 1)
+		hub.OnGameFinished(func(g *game.Game, _ GameSettings, _ time.Time) {
+			finished <- g.Result()
+		})
+		_, bob = startAbortableGame(hub)
+	})
+
+	It("should list the games in progress", func() {
+		games := hub.LiveGames()
+		Expect(games).To(HaveLen(1))
+		Expect(games[0].ID).To(Equal("1"))
+		Expect(games[0].Mode).To(Equal("classic"))
+		Expect(games[0].Players).To(Equal([]PlayerEntry{{ID: "alice", Name: "alice"}, {ID: "bob", Name: "bob"}}))
+		Expect(games[0].Elapsed).To(BeNumerically(">=", 0))
+		Expect(games[0].Started).To(BeTemporally("~", time.Now(), time.Second))
+	})
+
+	It("should abort a game, making its players fail", func() {
+		Expect(hub.Abort("2")).To(BeFalse())
+		Expect(hub.Abort("1")).To(BeTrue())
+		var r game.Result
+		Eventually(finished).Should(Receive(&r))
+		Expect(r.Outcome).To(Equal(game.OutcomeAbort))
+		Expect(r.Reason).To(Equal(ErrAborted.Error()))
+		Eventually(hub.LiveGames).Should(BeEmpty())
+		Expect(bob.aborted(0)).To(Equal(ErrAborted))
+	})
+
+	It("should abort only the game aborted of a player playing several", func() {
+		// Alice thinks in the first game and guesses in the second.
+		alice, carol := newAbortingPlayer("alice"), newAbortingPlayer("carol")
+		alice.ThinkReturns(4, nil)
+		alice.TryStub = func(string) (int, int, error) {
+			return 0, 0, alice.aborted(0)
+		}
+		alice.GuessStub = func(int) (string, error) {
+			return "", alice.aborted(1)
+		}
+		carol.GuessReturns("5678", nil)
+		Expect(hub.Abort("1")).To(BeTrue())
+		Eventually(finished).Should(Receive())
+		Eventually(hub.LiveGames).Should(BeEmpty())
+
+		dave := newAbortingPlayer("dave")
+		dave.ThinkReturns(4, nil)
+		Expect(hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 4})).To(Succeed())
+		Expect(hub.Enqueue(carol, Queue{Role: RoleGuesser, Digits: 4})).To(Succeed())
+		Eventually(alice.TryCallCount).Should(Equal(1))
+		Expect(hub.Enqueue(alice, Queue{Role: RoleGuesser, Digits: 4})).To(Succeed())
+		Expect(hub.Enqueue(dave, Queue{Role: RoleThinker, Digits: 4})).To(Succeed())
+		Eventually(alice.GuessCallCount).Should(Equal(1))
+		Expect(hub.LiveGames()).To(HaveLen(2))
+
+		Expect(hub.Abort("2")).To(BeTrue())
+		var r game.Result
+		Eventually(finished).Should(Receive(&r))
+		Expect(r.Outcome).To(Equal(game.OutcomeAbort))
+		Eventually(hub.LiveGames).Should(HaveLen(1))
+		Expect(hub.LiveGames()[0].ID).To(Equal("3"))
+		Consistently(finished).ShouldNot(Receive())
+
+		Expect(hub.Abort("3")).To(BeTrue())
+		Eventually(finished).Should(Receive(&r))
+		Expect(r.Outcome).To(Equal(game.OutcomeAbort))
+		Eventually(hub.LiveGames).Should(BeEmpty())
+	})
+
+	It("should not let an abort outlive its game", func() {
+		gamer := new(cowbullfakes.FakeGamer)
+		gamer.GameStub = func(t game.Thinker, g game.Guesser) (*game.Game, error) {
+			return game.New(t, g), nil
+		}
+		hub = NewHub(gamer, slog.New(slog.NewTextHandler(GinkgoWriter, nil)))
+		aborted := make(chan bool, 1)
+		hub.OnGameFinished(func(g *game.Game, _ GameSettings, _ time.Time) {
+			// The game is over, but not gone yet.
+			aborted <- hub.Abort("1")
+		})
+		carol := NewRemotePlayer(new(cowbullfakes.FakeMessenger), 50*time.Millisecond)
+		Expect(hub.Enqueue(carol, Queue{Role: RoleThinker, Digits: 4})).To(Succeed())
+		Expect(hub.Enqueue(newAbortingPlayer("dave"), Queue{Role: RoleGuesser, Digits: 4})).To(Succeed())
+		Eventually(aborted).Should(Receive(BeTrue()))
+		Eventually(hub.LiveGames).Should(BeEmpty())
+
+		_, err := carol.Think()
+		Expect(err).To(MatchError("remoteplayer: think timed out"))
+	})
+
+	It("should log the game from start to end", func() {
+		Expect(logs).To(gbytes.Say(`msg="game started" game_id=1 mode=classic players="\[alice bob\]"`))
+		hub.Abort("1")
+		Eventually(logs).Should(gbytes.Say(`msg="game finished" game_id=1 outcome=abort turns=0 reason="cowbull: the game was aborted"`))
+	})
+})
+
+// startAbortableGame starts a matched game in hub in which alice thinks and
+// bob guesses until it is aborted.
+func startAbortableGame(hub *Hub) (alice, bob *abortingPlayer) {
+	alice, bob = newAbortingPlayer("alice"), newAbortingPlayer("bob")
+	alice.ThinkReturns(4, nil)
+	alice.TryStub = func(string) (int, int, error) {
+		return 0, 0, alice.aborted(0)
+	}
+	bob.GuessReturns("5678", nil)
+	Expect(hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 4})).To(Succeed())
+	Expect(hub.Enqueue(bob, Queue{Role: RoleGuesser, Digits: 4})).To(Succeed())
+	Eventually(alice.TryCallCount).Should(Equal(1))
+	return alice, bob
+}
+
+// abortingPlayer notes the games it joins, so that it can wait for them to
+// be aborted.
+type abortingPlayer struct {
+	*cowbullfakes.FakePlayer
+	mu    sync.Mutex
+	games []context.Context
+}
+
+func newAbortingPlayer(id string) *abortingPlayer {
+	p := &abortingPlayer{FakePlayer: new(cowbullfakes.FakePlayer)}
+	p.IDReturns(id)
+	p.NameReturns(id)
+	return p
+}
+
+func (p *abortingPlayer) JoinGame(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.games = append(p.games, ctx)
+}
+
+func (p *abortingPlayer) LeaveGame(context.Context) {}
+
+// aborted waits until the game the player joined i-th, from zero, is
+// aborted, and returns why.
+func (p *abortingPlayer) aborted(i int) error {
+	p.mu.Lock()
+	ctx := p.games[i]
+	p.mu.Unlock()
+	<-ctx.Done()
+	return context.Cause(ctx)
+}
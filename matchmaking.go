@@ -0,0 +1,298 @@
+package cowbull
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+// Queue asks to be paired with a random opponent.
+type Queue = protocol.Queue
+
+// Matched tells a player that it was paired with an opponent.
+type Matched = protocol.Matched
+
+// matchObserver may be implemented by a Player that wants to know who it
+// was paired with by matchmaking.
+type matchObserver interface {
+	AnnounceMatch(Matched) error
+}
+
+// queued is a player waiting in the matchmaking queue.
+type queued struct {
+	p      Player
+	q      Queue
+	rating float64 // in the role the player asked for
+}
+
+// UseRatings makes the matchmaking of the hub pair players by the ratings
+// in r. It should be called before the hub is in use.
+func (h *Hub) UseRatings(r *Ratings) {
+	h.ratings = r
+}
+
+// FallBackToAI makes the matchmaking of the hub pair players left waiting
+// for longer than wait with the computer. It should be called before the
+// hub is in use.
+func (h *Hub) FallBackToAI(wait time.Duration) {
+	h.aiWait = wait
+}
+
+// OnGameFinished makes the hub call f with every game it starts on its own,
+// once it is over. It should be called before the hub is in use.
+func (h *Hub) OnGameFinished(f func(g *game.Game, settings GameSettings, started time.Time)) {
+	h.finished = f
+}
+
+// checkQueue returns a *protocol.Error if q is not a valid request to be
+// queued.
+func checkQueue(q Queue) error {
+	if q.Role != RoleThinker && q.Role != RoleGuesser {
+		return protocol.Errorf(protocol.CodeInvalidRole, "invalid role: %s", q.Role)
+	}
+	if q.Digits < 1 || q.Digits > 10 {
+		return protocol.Errorf(protocol.CodeInvalidSettings, "invalid digit count %d", q.Digits)
+	}
+	if q.Band < 0 {
+		return protocol.Errorf(protocol.CodeInvalidSettings, "invalid rating band %v", q.Band)
+	}
+	return nil
+}
+
+// Enqueue puts a player in the matchmaking queue, replacing any request it
+// made before. As soon as a player asking for the other role, the same digit
+// count and a rating within both bands is queued, and both may play one more
+// game, both are taken out of the queue and their game is started.
+// If the request is invalid, or the player may not play one more game, the
+// returned error is a *protocol.Error describing why.
+func (h *Hub) Enqueue(p Player, q Queue) error {
+	if err := checkQueue(q); err != nil {
+		return err
+	}
+	if err := h.accepting(); err != nil {
+		return err
+	}
+	if err := h.games.check(p.ID()); err != nil {
+		return err
+	}
+	entry := &queued{p: p, q: q, rating: initialRating}
+	if h.ratings != nil {
+		entry.rating = h.ratings.Rating(p.ID(), q.Role, q.Digits)
+	}
+
+	h.do(func(map[string]Player) {
+		if h.draining {
+			return
+		}
+		h.dequeue(func(e *queued) bool { return e.p.ID() == p.ID() })
+		for _, other := range h.queue {
+			if !entry.pairs(other) || h.games.acquire(p.ID(), other.p.ID()) != nil {
+				continue
+			}
+			h.dequeue(func(e *queued) bool { return e == other })
+			h.log.Info("players matched", "player_id", p.ID(), "opponent_id", other.p.ID())
+			go h.playMatch(entry, other)
+			return
+		}
+		h.queue = append(h.queue, entry)
+		h.log.Info("player queued", "player_id", p.ID(), "role", q.Role, "digits", q.Digits)
+		if h.aiWait > 0 {
+			h.fallBackLater(entry)
+		}
+	})
+	return nil
+}
+
+// fallBackLater pairs a queued player with the computer once it has waited
+// for too long, or later on if it may not play one more game by then. It
+// must be called from within a hub op.
+func (h *Hub) fallBackLater(entry *queued) {
+	time.AfterFunc(h.aiWait, func() {
+		h.do(func(map[string]Player) {
+			waiting := false
+			for _, e := range h.queue {
+				waiting = waiting || e == entry
+			}
+			if !waiting {
+				return
+			}
+			if err := h.games.acquire(entry.p.ID()); err != nil {
+				h.fallBackLater(entry)
+				return
+			}
+			h.dequeue(func(e *queued) bool { return e == entry })
+			h.log.Info("player matched with the computer", "player_id", entry.p.ID())
+			go h.playMatch(entry, nil)
+		})
+	})
+}
+
+// Dequeue takes a player out of the matchmaking queue.
+func (h *Hub) Dequeue(pid string) {
+	h.do(func(map[string]Player) {
+		h.dequeue(func(e *queued) bool { return e.p.ID() == pid })
+	})
+}
+
+// dequeue removes the queued players matching f. It reports whether any
+// was removed, and must be called from within a hub op.
+func (h *Hub) dequeue(f func(*queued) bool) bool {
+	queue := h.queue[:0]
+	for _, e := range h.queue {
+		if !f(e) {
+			queue = append(queue, e)
+		}
+	}
+	removed := len(queue) < len(h.queue)
+	for i := len(queue); i < len(h.queue); i++ {
+		h.queue[i] = nil
+	}
+	h.queue = queue
+	return removed
+}
+
+// pairs reports whether two queued players may play each other.
+func (e *queued) pairs(other *queued) bool {
+	if e.p.ID() == other.p.ID() || e.q.Role == other.q.Role || e.q.Digits != other.q.Digits {
+		return false
+	}
+	diff := math.Abs(e.rating - other.rating)
+	return (e.q.Band == 0 || diff <= e.q.Band) && (other.q.Band == 0 || diff <= other.q.Band)
+}
+
+// playMatch plays the game of two queued players, or of a queued player and
+// the computer if other is nil, and frees the game slot taken for them.
+func (h *Hub) playMatch(e, other *queued) {
+	ids := []string{e.p.ID()}
+	if other != nil {
+		ids = append(ids, other.p.ID())
+	}
+	defer h.games.release(ids...)
+
+	digits := e.q.Digits
+	thinker, guesser := e, other
+	if e.q.Role == RoleGuesser {
+		thinker, guesser = other, e
+	}
+
+	settings := GameSettings{Role: RoleThinker, Digits: digits}
+	var t game.Thinker
+	var g game.Guesser
+	if thinker != nil {
+		t = &matchPlayer{Player: thinker.p, digits: digits}
+	} else {
+		t = LocalThinker(digits)
+		settings.Role, settings.AI = RoleGuesser, true
+	}
+	if guesser != nil {
+		g = guesser.p
+	} else {
+		g = h.aiGuesser(digits)
+		settings.AI = true
+	}
+	if !settings.AI {
+		settings.Opponents = []string{guesser.p.ID()}
+	}
+
+	gm, err := h.gamer.Game(t, g)
+	if err != nil {
+		h.log.Error("error creating matched game", "err", err)
+		return
+	}
+	gm.SetID(newGameID())
+	h.announceMatch(e, other)
+	if other != nil {
+		h.announceMatch(other, e)
+	}
+
+	log, over := h.gameOn(settings.Mode, []game.Thinker{t}, []game.Guesser{g})
+	defer over()
+	h.metrics.timeMoves(gm, t, g)
+	traceMoves(log, gm)
+	defer h.metrics.playing(settings.Mode)()
+	started := time.Now()
+	err = gm.Play()
+	traceResult(log, gm.Result())
+	if h.finished != nil {
+		h.finished(gm, settings, started)
+	}
+	if err != nil {
+		log.Warn("error running matched game", "err", err)
+	}
+}
+
+// announceMatch tells a queued player who its opponent is, the computer if
+// opponent is nil.
+func (h *Hub) announceMatch(e, opponent *queued) {
+	o, ok := e.p.(matchObserver)
+	if !ok {
+		return
+	}
+	m := Matched{Role: e.q.Role, Digits: e.q.Digits}
+	if opponent != nil {
+		m.Opponent = PlayerEntry{ID: opponent.p.ID(), Name: opponent.p.Name()}
+	} else {
+		m.Opponent = PlayerEntry{ID: AIPlayer, Name: "computer"}
+		m.AI = true
+	}
+	if err := o.AnnounceMatch(m); err != nil {
+		h.log.Warn("error announcing match", "player_id", e.p.ID(), "err", err)
+	}
+}
+
+// matchPlayer is a player of a game arranged by the server, for a
+// tournament or by matchmaking. It notes whether the player failed, and
+// holds it to the digit count agreed on.
+type matchPlayer struct {
+	Player
+	digits int
+	failed bool
+}
+
+func (p *matchPlayer) Think() (int, error) {
+	n, err := p.Player.Think()
+	if err == nil && n != p.digits {
+		err = fmt.Errorf("the game is played with %d digits, not %d", p.digits, n)
+	}
+	return n, p.fail(err)
+}
+
+func (p *matchPlayer) Try(number string) (int, int, error) {
+	cows, bulls, err := p.Player.Try(number)
+	return cows, bulls, p.fail(err)
+}
+
+func (p *matchPlayer) Guess(n int) (string, error) {
+	number, err := p.Player.Guess(n)
+	return number, p.fail(err)
+}
+
+func (p *matchPlayer) Tell(number string, cows, bulls int) error {
+	return p.fail(p.Player.Tell(number, cows, bulls))
+}
+
+// GameOver passes the result of a game on to the player.
+func (p *matchPlayer) GameOver(r game.Result) {
+	if o, ok := p.Player.(game.Observer); ok {
+		o.GameOver(r)
+	}
+}
+
+// Secret reveals the number of the player, if it is a Revealer.
+func (p *matchPlayer) Secret() string {
+	if rev, ok := p.Player.(game.Revealer); ok {
+		return rev.Secret()
+	}
+	return ""
+}
+
+// fail notes that the player failed, if err is not nil, and returns err.
+func (p *matchPlayer) fail(err error) error {
+	if err != nil {
+		p.failed = true
+	}
+	return err
+}
@@ -0,0 +1,218 @@
+package cowbull_test
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+// matchedPlayer is a computer player noting who it is matched with.
+type matchedPlayer struct {
+	*cowbullfakes.FakePlayer
+	matched chan Matched
+}
+
+func (p *matchedPlayer) AnnounceMatch(m Matched) error {
+	p.matched <- m
+	return nil
+}
+
+var _ = Describe("Matchmaking", func() {
+	var hub *Hub
+	var finished chan GameSettings
+
+	queuePlayer := func(id string, n int) *matchedPlayer {
+		return &matchedPlayer{FakePlayer: computerPlayer(id, n), matched: make(chan Matched, 1)}
+	}
+
+	BeforeEach(func() {
+		gamer := new(cowbullfakes.FakeGamer)
+		gamer.GameStub = func(t game.Thinker, g game.Guesser) (*game.Game, error) {
+			return game.New(t, g), nil
+		}
+		hub = NewHub(gamer, slog.New(slog.NewTextHandler(GinkgoWriter, nil)))
+		// Games of earlier specs may still be running.
+		done := make(chan GameSettings, 4)
+		finished = done
+		hub.OnGameFinished(func(_ *game.Game, settings GameSettings, _ time.Time) {
+			done <- settings
+		})
+	})
+
+	It("should log the matched game from start to end", func() {
+		logs := gbytes.NewBuffer()
+		gamer := new(cowbullfakes.FakeGamer)
+		gamer.GameStub = func(t game.Thinker, g game.Guesser) (*game.Game, error) {
+			return game.New(t, g), nil
+		}
+		hub = NewHub(gamer, slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+		Expect(hub.Enqueue(queuePlayer("alice", 3), Queue{Role: RoleThinker, Digits: 3})).To(Succeed())
+		Expect(hub.Enqueue(queuePlayer("bob", 3), Queue{Role: RoleGuesser, Digits: 3})).To(Succeed())
+
+		Eventually(logs).Should(gbytes.Say(`msg="game started" game_id=1 mode=classic players="\[alice bob\]"`))
+		Eventually(logs).Should(gbytes.Say(`level=DEBUG msg=move game_id=1 guess=\d{3} cows=\d bulls=\d`))
+		Eventually(logs, 5*time.Second).Should(gbytes.Say(`msg="game finished" game_id=1 outcome=win turns=\d+ record_id=\w+`))
+	})
+
+	It("should pair a thinker and a guesser of the same digit count", func() {
+		alice, bob := queuePlayer("alice", 3), queuePlayer("bob", 3)
+		Expect(hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 3})).To(Succeed())
+		Expect(hub.Enqueue(bob, Queue{Role: RoleGuesser, Digits: 3})).To(Succeed())
+
+		var m Matched
+		Eventually(alice.matched).Should(Receive(&m))
+		Expect(m).To(Equal(Matched{Role: RoleThinker, Digits: 3, Opponent: PlayerEntry{ID: "bob", Name: "bob"}}))
+		Eventually(bob.matched).Should(Receive(&m))
+		Expect(m.Role).To(Equal(RoleGuesser))
+		Expect(m.Opponent.ID).To(Equal("alice"))
+
+		var settings GameSettings
+		Eventually(finished, 5*time.Second).Should(Receive(&settings))
+		Expect(settings).To(Equal(GameSettings{Role: RoleThinker, Digits: 3, Opponents: []string{"bob"}}))
+		Expect(alice.ThinkCallCount()).To(Equal(1))
+		Expect(bob.GuessCallCount()).To(BeNumerically(">", 0))
+	})
+
+	It("should reveal the number of the thinker when the game is not won", func() {
+		results := make(chan game.Result, 1)
+		hub.OnGameFinished(func(g *game.Game, _ GameSettings, _ time.Time) {
+			results <- g.Result()
+		})
+		alice, bob := revealingPlayer{queuePlayer("alice", 3)}, queuePlayer("bob", 3)
+		bob.GuessReturns("", errors.New("gone"))
+		Expect(hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 3})).To(Succeed())
+		Expect(hub.Enqueue(bob, Queue{Role: RoleGuesser, Digits: 3})).To(Succeed())
+
+		var r game.Result
+		Eventually(results, 5*time.Second).Should(Receive(&r))
+		Expect(r.Outcome).NotTo(Equal(game.OutcomeWin))
+		Expect(r.Secret).To(Equal("123"))
+	})
+
+	It("should not pair players asking for the same role or other digits", func() {
+		Expect(hub.Enqueue(queuePlayer("alice", 3), Queue{Role: RoleThinker, Digits: 3})).To(Succeed())
+		Expect(hub.Enqueue(queuePlayer("bob", 3), Queue{Role: RoleThinker, Digits: 3})).To(Succeed())
+		Expect(hub.Enqueue(queuePlayer("carol", 4), Queue{Role: RoleGuesser, Digits: 4})).To(Succeed())
+		Consistently(finished).ShouldNot(Receive())
+	})
+
+	It("should not pair a player with itself", func() {
+		alice := queuePlayer("alice", 3)
+		Expect(hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 3})).To(Succeed())
+		Expect(hub.Enqueue(alice, Queue{Role: RoleGuesser, Digits: 3})).To(Succeed())
+		Consistently(alice.matched).ShouldNot(Receive())
+	})
+
+	It("should not pair players taken out of the queue", func() {
+		alice, bob := queuePlayer("alice", 3), queuePlayer("bob", 3)
+		hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 3})
+		hub.Dequeue("alice")
+		hub.Enqueue(bob, Queue{Role: RoleGuesser, Digits: 3})
+		Consistently(bob.matched).ShouldNot(Receive())
+	})
+
+	It("should take players leaving the hub out of the queue", func() {
+		alice, bob := queuePlayer("alice", 3), queuePlayer("bob", 3)
+		hub.Add(alice)
+		hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 3})
+		hub.Remove("alice")
+		hub.Enqueue(bob, Queue{Role: RoleGuesser, Digits: 3})
+		Consistently(bob.matched).ShouldNot(Receive())
+	})
+
+	It("should pair players within both rating bands", func() {
+		ratings := NewRatings()
+		moves := make([]game.Move, 12)
+		moves[11].Bulls = 3
+		ratings.Add(GameRecord{
+			ID:       "g1",
+			Thinker:  PlayerEntry{ID: "alice"},
+			Guessers: []PlayerEntry{{ID: "bob"}},
+			Result:   game.Result{Outcome: game.OutcomeWin, Digits: 3, Moves: moves},
+		})
+		hub.UseRatings(ratings)
+
+		alice, bob, carol := queuePlayer("alice", 3), queuePlayer("bob", 3), queuePlayer("carol", 3)
+		hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 3})
+		hub.Enqueue(carol, Queue{Role: RoleGuesser, Digits: 3, Band: 10})
+		Consistently(carol.matched).ShouldNot(Receive())
+
+		hub.Enqueue(bob, Queue{Role: RoleGuesser, Digits: 3, Band: 40})
+		var m Matched
+		Eventually(bob.matched).Should(Receive(&m))
+		Expect(m.Opponent.ID).To(Equal("alice"))
+	})
+
+	It("should pair players waiting too long with the computer", func() {
+		hub.FallBackToAI(50 * time.Millisecond)
+		alice := queuePlayer("alice", 3)
+		hub.Enqueue(alice, Queue{Role: RoleGuesser, Digits: 3})
+
+		var m Matched
+		Eventually(alice.matched).Should(Receive(&m))
+		Expect(m.AI).To(BeTrue())
+		Expect(m.Opponent.ID).To(Equal(AIPlayer))
+		var settings GameSettings
+		Eventually(finished, 5*time.Second).Should(Receive(&settings))
+		Expect(settings).To(Equal(GameSettings{Role: RoleGuesser, Digits: 3, AI: true}))
+	})
+
+	It("should not fall back to the computer for players already paired", func() {
+		hub.FallBackToAI(50 * time.Millisecond)
+		alice, bob := queuePlayer("alice", 3), queuePlayer("bob", 3)
+		hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 3})
+		hub.Enqueue(bob, Queue{Role: RoleGuesser, Digits: 3})
+
+		Eventually(finished, 5*time.Second).Should(Receive())
+		Consistently(finished, 200*time.Millisecond).ShouldNot(Receive())
+		Expect(alice.matched).To(HaveLen(1))
+	})
+
+	It("should not start more games than the server and each player may play", func() {
+		hub.LimitGames(1, 1)
+		release := make(chan struct{})
+		alice, bob := queuePlayer("alice", 3), queuePlayer("bob", 3)
+		think := alice.ThinkStub
+		alice.ThinkStub = func() (int, error) {
+			<-release
+			return think()
+		}
+		Expect(hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 3})).To(Succeed())
+		Expect(hub.Enqueue(bob, Queue{Role: RoleGuesser, Digits: 3})).To(Succeed())
+		Eventually(alice.ThinkCallCount).Should(Equal(1))
+
+		Expect(code(hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 3}))).To(Equal(protocol.CodeTooManyGames))
+		Expect(code(hub.Enqueue(queuePlayer("carol", 3), Queue{Role: RoleGuesser, Digits: 3}))).To(Equal(protocol.CodeTooManyGames))
+
+		close(release)
+		Eventually(finished, 5*time.Second).Should(Receive())
+		Eventually(func() error {
+			return hub.Enqueue(queuePlayer("carol", 3), Queue{Role: RoleGuesser, Digits: 3})
+		}).Should(Succeed())
+	})
+
+	It("should reject invalid requests", func() {
+		alice := queuePlayer("alice", 3)
+		Expect(code(hub.Enqueue(alice, Queue{Role: "referee", Digits: 3}))).To(Equal(protocol.CodeInvalidRole))
+		Expect(code(hub.Enqueue(alice, Queue{Role: RoleGuesser, Digits: 0}))).To(Equal(protocol.CodeInvalidSettings))
+		Expect(code(hub.Enqueue(alice, Queue{Role: RoleGuesser, Digits: 3, Band: -1}))).To(Equal(protocol.CodeInvalidSettings))
+	})
+})
+
+// revealingPlayer reveals its number.
+type revealingPlayer struct {
+	*matchedPlayer
+}
+
+func (revealingPlayer) Secret() string {
+	return "123"
+}
@@ -0,0 +1,325 @@
+package cowbull
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+// Kinds of metrics.
+const (
+	counter   = "counter"
+	gauge     = "gauge"
+	histogram = "histogram"
+)
+
+// Metrics of the server.
+const (
+	metricClients       = "cowbull_clients"
+	metricHubPlayers    = "cowbull_hub_players"
+	metricGamesPlaying  = "cowbull_games_in_progress"
+	metricGamesFinished = "cowbull_games_finished_total"
+	metricGameTurns     = "cowbull_game_turns"
+	metricMoveSeconds   = "cowbull_move_seconds"
+	metricReceived      = "cowbull_messages_received_total"
+	metricSent          = "cowbull_messages_sent_total"
+	metricReadRetries   = "cowbull_read_retries_total"
+	metricTimeouts      = "cowbull_remote_timeouts_total"
+)
+
+// Metrics collects counters, gauges and histograms about the server, and
+// serves them in the Prometheus text format. All methods of a nil *Metrics
+// do nothing.
+type Metrics struct {
+	mu       sync.Mutex // guards families
+	families map[string]*family
+	collect  []func()
+}
+
+// family is a metric, with a series for every combination of label values.
+type family struct {
+	name, help, kind string
+	labels           []string
+	buckets          []float64
+	series           map[string]*series
+}
+
+// series holds the value of a counter or gauge, or the observations of a
+// histogram.
+type series struct {
+	values []string
+	value  float64
+	counts []uint64 // by bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// NewMetrics creates the metrics of a server, all zero.
+func NewMetrics() *Metrics {
+	m := &Metrics{families: make(map[string]*family)}
+	m.register(metricClients, gauge, "Connected clients.", nil)
+	m.register(metricHubPlayers, gauge, "Players in the hub.", nil)
+	m.register(metricGamesPlaying, gauge, "Games in progress, by mode.", nil, "mode")
+	m.register(metricGamesFinished, counter, "Games finished, by mode and outcome.", nil, "mode", "outcome")
+	m.register(metricGameTurns, histogram, "Turns taken by finished games.",
+		[]float64{1, 2, 3, 4, 5, 6, 7, 8, 10, 12, 15, 20, 30})
+	m.register(metricMoveSeconds, histogram, "Seconds taken to guess and to score a guess, by player type.",
+		[]float64{0.001, 0.01, 0.1, 0.5, 1, 2, 5, 10, 30, 60}, "move", "player")
+	m.register(metricReceived, counter, "WebSocket messages received, by kind.", nil, "kind")
+	m.register(metricSent, counter, "WebSocket messages sent, by kind.", nil, "kind")
+	m.register(metricReadRetries, counter, "Failed reads from clients that were retried.", nil)
+	m.register(metricTimeouts, counter, "Remote players that did not answer in time, by message kind.", nil, "kind")
+	return m
+}
+
+func (m *Metrics) register(name, kind, help string, buckets []float64, labels ...string) {
+	m.families[name] = &family{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+}
+
+// onCollect makes m call f before the metrics are written, to update
+// gauges.
+func (m *Metrics) onCollect(f func()) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.collect = append(m.collect, f)
+}
+
+// get returns the series of the metric with name and label values. It must
+// be called with mu held.
+func (m *Metrics) get(name string, values []string) (*family, *series) {
+	f, ok := m.families[name]
+	if !ok || len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: unknown metric %s%v", name, values))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: values}
+		if f.kind == histogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return f, s
+}
+
+// add adds v to a counter or gauge.
+func (m *Metrics) add(name string, v float64, values ...string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, s := m.get(name, values)
+	s.value += v
+}
+
+// inc adds 1 to a counter or gauge.
+func (m *Metrics) inc(name string, values ...string) {
+	m.add(name, 1, values...)
+}
+
+// set sets a gauge to v.
+func (m *Metrics) set(name string, v float64, values ...string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, s := m.get(name, values)
+	s.value = v
+}
+
+// observe records v in a histogram.
+func (m *Metrics) observe(name string, v float64, values ...string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, s := m.get(name, values)
+	if i := sort.SearchFloat64s(f.buckets, v); i < len(f.buckets) {
+		s.counts[i]++
+	}
+	s.sum += v
+	s.count++
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	collect := m.collect
+	m.mu.Unlock()
+	for _, f := range collect {
+		f()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m.families[name].write(cw)
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// write writes the family, its series ordered by label values.
+func (f *family) write(w *countingWriter) {
+	w.printf("# HELP %s %s\n", f.name, f.help)
+	w.printf("# TYPE %s %s\n", f.name, f.kind)
+	if len(f.labels) == 0 && len(f.series) == 0 {
+		// Metrics without labels exist from the start.
+		f.series[""] = &series{counts: make([]uint64, len(f.buckets))}
+	}
+	keys := make([]string, 0, len(f.series))
+	for key := range f.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := f.series[key]
+		if f.kind != histogram {
+			w.printf("%s%s %s\n", f.name, labelSet(f.labels, s.values), formatFloat(s.value))
+			continue
+		}
+		labels := append(append([]string(nil), f.labels...), "le")
+		values := append(append([]string(nil), s.values...), "")
+		var cumulative uint64
+		for i, b := range f.buckets {
+			cumulative += s.counts[i]
+			values[len(values)-1] = formatFloat(b)
+			w.printf("%s_bucket%s %d\n", f.name, labelSet(labels, values), cumulative)
+		}
+		values[len(values)-1] = "+Inf"
+		w.printf("%s_bucket%s %d\n", f.name, labelSet(labels, values), s.count)
+		w.printf("%s_sum%s %s\n", f.name, labelSet(f.labels, s.values), formatFloat(s.sum))
+		w.printf("%s_count%s %d\n", f.name, labelSet(f.labels, s.values), s.count)
+	}
+}
+
+// labelSet formats labels with their values, empty if there are none.
+func labelSet(labels, values []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(labels))
+	for i, l := range labels {
+		pairs[i] = l + `="` + labelEscaper.Replace(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// countingWriter counts the bytes written, and keeps the first error.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
+
+// ServeHTTP serves the metrics.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// playing counts a game of a mode as in progress, until the returned
+// function is called.
+func (m *Metrics) playing(mode string) func() {
+	mode = modeLabel(mode)
+	m.add(metricGamesPlaying, 1, mode)
+	return func() {
+		m.add(metricGamesPlaying, -1, mode)
+	}
+}
+
+// finished counts a finished game.
+func (m *Metrics) finished(r GameRecord) {
+	m.inc(metricGamesFinished, modeLabel(r.Settings.Mode), r.Result.Outcome)
+	m.observe(metricGameTurns, float64(len(r.Result.Moves)))
+}
+
+// timeMoves makes g report how long thinker and guesser, its players, take
+// to move.
+func (m *Metrics) timeMoves(g *game.Game, thinker, guesser interface{}) {
+	if m == nil {
+		return
+	}
+	thinkerType, guesserType := playerType(thinker), playerType(guesser)
+	g.OnMove(func(_ game.Move, t game.Timing) {
+		m.observe(metricMoveSeconds, t.Guess.Seconds(), "guess", guesserType)
+		m.observe(metricMoveSeconds, t.Try.Seconds(), "try", thinkerType)
+	})
+}
+
+func modeLabel(mode string) string {
+	if mode == "" {
+		return protocol.ModeClassic
+	}
+	return mode
+}
+
+// playerType labels the metrics of a player by what kind of player it is.
+func playerType(p interface{}) string {
+	switch p := p.(type) {
+	case *matchPlayer:
+		return playerType(p.Player)
+	case *RemotePlayer:
+		return "remote"
+	case *AIThinker, *AIGuesser, *aiDuelist:
+		return "ai"
+	case *TeamGuesser:
+		return "team"
+	case *MultiGuesser:
+		return "multi"
+	}
+	return "other"
+}
@@ -0,0 +1,149 @@
+package cowbull_test
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	var metrics *Metrics
+
+	scrape := func() string {
+		var b strings.Builder
+		_, err := metrics.WriteTo(&b)
+		Expect(err).NotTo(HaveOccurred())
+		return b.String()
+	}
+
+	BeforeEach(func() {
+		metrics = NewMetrics()
+	})
+
+	It("should expose the metrics without labels from the start", func() {
+		out := scrape()
+		Expect(out).To(ContainSubstring("# HELP cowbull_clients Connected clients.\n# TYPE cowbull_clients gauge\ncowbull_clients 0\n"))
+		Expect(out).To(ContainSubstring("# TYPE cowbull_read_retries_total counter\ncowbull_read_retries_total 0\n"))
+		Expect(out).To(ContainSubstring("# TYPE cowbull_game_turns histogram\ncowbull_game_turns_bucket{le=\"1\"} 0\n"))
+		Expect(out).To(ContainSubstring("cowbull_game_turns_bucket{le=\"+Inf\"} 0\ncowbull_game_turns_sum 0\ncowbull_game_turns_count 0\n"))
+		Expect(out).To(ContainSubstring("# TYPE cowbull_games_in_progress gauge\n# HELP"))
+	})
+
+	Describe("of a client", func() {
+		var conn *cowbullfakes.FakeConn
+		var c *Client
+
+		BeforeEach(func() {
+			conn = new(cowbullfakes.FakeConn)
+			conn.RemoteAddrReturns(fakeNetAddr{})
+		})
+
+		AfterEach(func() {
+			Expect(c.Close()).To(Succeed())
+		})
+
+		It("should count the messages by kind", func() {
+			msgs := []string{`{"name":"ping"}`, `{"name":"bogus"}`}
+			var mu sync.Mutex
+			conn.ReadJSONStub = func(val interface{}) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if len(msgs) == 0 {
+					return errors.New("closed")
+				}
+				msg := msgs[0]
+				msgs = msgs[1:]
+				return json.Unmarshal([]byte(msg), val)
+			}
+			c = NewClient(conn, Instrument(metrics), RetryCount(1), RetryInterval(time.Millisecond))
+			c.OnMessage("ping", func(string) {})
+			Expect(c.SendMessage("pong", "")).To(Succeed())
+
+			Eventually(scrape).Should(ContainSubstring(`cowbull_read_retries_total 1`))
+			out := scrape()
+			Expect(out).To(ContainSubstring(`cowbull_messages_received_total{kind="ping"} 1`))
+			Expect(out).To(ContainSubstring(`cowbull_messages_received_total{kind="unknown"} 1`))
+			Expect(out).To(ContainSubstring(`cowbull_messages_sent_total{kind="pong"} 1`))
+		})
+	})
+
+	Describe("of a remote player", func() {
+		It("should count the timeouts by kind", func() {
+			player := NewRemotePlayer(new(cowbullfakes.FakeMessenger), time.Millisecond)
+			player.UseMetrics(metrics)
+			_, err := player.Guess(4)
+			Expect(err).To(HaveOccurred())
+			Expect(scrape()).To(ContainSubstring(`cowbull_remote_timeouts_total{kind="guess"} 1`))
+		})
+	})
+
+	Describe("of a hub", func() {
+		It("should count the games on and time the moves", func() {
+			gamer := new(cowbullfakes.FakeGamer)
+			gamer.GameStub = func(t game.Thinker, g game.Guesser) (*game.Game, error) {
+				return game.New(t, g), nil
+			}
+			hub := NewHub(gamer, slog.New(slog.NewTextHandler(GinkgoWriter, nil)))
+			hub.UseMetrics(metrics)
+			thinker, guesser := newChattingPlayer("alice"), newChattingPlayer("bob")
+			over := make(chan struct{})
+			thinker.ThinkReturns(4, nil)
+			thinker.TryStub = func(guess string) (int, int, error) {
+				if thinker.TryCallCount() == 1 {
+					return 0, 1, nil
+				}
+				<-over
+				return 0, 4, nil
+			}
+			guesser.GuessReturns("1234", nil)
+			Expect(hub.Enqueue(thinker, Queue{Role: RoleThinker, Digits: 4})).To(Succeed())
+			Expect(hub.Enqueue(guesser, Queue{Role: RoleGuesser, Digits: 4})).To(Succeed())
+
+			Eventually(scrape).Should(ContainSubstring(`cowbull_games_in_progress{mode="classic"} 1`))
+			Expect(scrape()).To(ContainSubstring(`cowbull_move_seconds_count{move="guess",player="other"} 1`))
+			Expect(scrape()).To(ContainSubstring(`cowbull_move_seconds_count{move="try",player="other"} 1`))
+			close(over)
+			Eventually(scrape).Should(ContainSubstring(`cowbull_games_in_progress{mode="classic"} 0`))
+		})
+	})
+
+	Describe("GET /metrics", func() {
+		var server *Server
+
+		BeforeEach(func() {
+			logger := slog.New(slog.NewTextHandler(GinkgoWriter, nil))
+			server = NewServer(&ServerConfig{
+				Log:     logger,
+				Hub:     NewHub(new(cowbullfakes.FakeGamer), logger),
+				Metrics: metrics,
+			})
+		})
+
+		It("should serve the metrics in the text format", func() {
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("Content-Type")).To(HavePrefix("text/plain; version=0.0.4"))
+			Expect(w.Body.String()).To(ContainSubstring("cowbull_hub_players 0\n"))
+			Expect(w.Body.String()).To(ContainSubstring("cowbull_clients 0\n"))
+		})
+
+		It("should only allow GET", func() {
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+			Expect(w.Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+})
@@ -1,29 +1,207 @@
 package cowbull
 
+import (
+	"log/slog"
+	"sync"
+
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+// guessersObserver may be implemented by a Player that wants to know when
+// another guesser of its game fails or rejoins it.
+type guessersObserver interface {
+	AnnounceStrike(protocol.Strike) error
+	AnnounceRejoin(PlayerEntry) error
+}
+
 // MultiGuesser makes multiple guessers to look like one.
 // Each guesser will be asked to guess in turn.
+// A guesser that fails to guess or to be told a score is struck and
+// skipped, and dropped once it has MaxStrikes strikes. The others are told,
+// and the game goes on until no guessers remain.
 type MultiGuesser struct {
 	Players []Player
+	// MaxStrikes is how many failures drop a guesser. Zero means one.
+	MaxStrikes int
+	// AllowRejoin lets dropped guessers back in, see Rejoin.
+	AllowRejoin bool
+	// Log is where failures to tell the players are logged. Nil means the
+	// default logger.
+	Log *slog.Logger
+
+	mu      sync.Mutex // guards Players and the fields below
 	turn    int
+	strikes map[string]int
+	dropped map[string]bool
+	left    []Player // dropped players, in the order they were dropped
+	err     error    // the last failure
+	over    bool
 }
 
-// Guess will ask the next player for a guess and return it.
+// Guess will ask the next player for a guess and return it. Players that
+// fail are struck and the one after them is asked instead. Once no players
+// remain, the last failure is returned.
 func (g *MultiGuesser) Guess(n int) (string, error) {
-	if g.turn == len(g.Players) {
+	for {
+		p, err := g.next()
+		if err != nil {
+			return "", err
+		}
+		number, err := p.Guess(n)
+		if err == nil {
+			return number, nil
+		}
+		g.strike(p, err)
+	}
+}
+
+// next returns the player whose turn it is, or the last failure if no
+// players remain.
+func (g *MultiGuesser) next() (Player, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.Players) == 0 {
+		return nil, g.err
+	}
+	if g.turn >= len(g.Players) {
 		g.turn = 0
 	}
-	number, err := g.Players[g.turn].Guess(n)
+	p := g.Players[g.turn]
 	g.turn++
-	return number, err
+	return p, nil
 }
 
 // Tell tells all players the result of a guess.
-// It returns on the first non-nil error.
-func (g MultiGuesser) Tell(number string, cows, bulls int) error {
-	for _, player := range g.Players {
+// Players that fail are struck. Once no players remain, the last failure is
+// returned.
+func (g *MultiGuesser) Tell(number string, cows, bulls int) error {
+	for _, player := range g.players() {
 		if err := player.Tell(number, cows, bulls); err != nil {
-			return err
+			g.strike(player, err)
 		}
 	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.Players) == 0 {
+		return g.err
+	}
 	return nil
 }
+
+// GameOver tells all players the result of the game.
+func (g *MultiGuesser) GameOver(r game.Result) {
+	g.mu.Lock()
+	g.over = true
+	g.mu.Unlock()
+
+	for _, player := range g.players() {
+		if o, ok := player.(game.Observer); ok {
+			o.GameOver(r)
+		}
+	}
+}
+
+// Rejoin lets a dropped player with the ID of p back in, in place of the
+// one that failed. It reports whether p rejoined, which it may only if
+// AllowRejoin is set and the game is not over. The other players are told.
+func (g *MultiGuesser) Rejoin(p Player) bool {
+	g.mu.Lock()
+	if !g.AllowRejoin || g.over || !g.dropped[p.ID()] {
+		g.mu.Unlock()
+		return false
+	}
+	delete(g.dropped, p.ID())
+	delete(g.strikes, p.ID())
+	for i, player := range g.left {
+		if player.ID() == p.ID() {
+			g.left = append(g.left[:i], g.left[i+1:]...)
+			break
+		}
+	}
+	g.Players = append(g.Players, p)
+	g.mu.Unlock()
+
+	entry := PlayerEntry{ID: p.ID(), Name: p.Name()}
+	for _, player := range g.players() {
+		if o, ok := player.(guessersObserver); ok {
+			if err := o.AnnounceRejoin(entry); err != nil {
+				logger(g.Log).Warn("error announcing rejoin", "player_id", player.ID(), "err", err)
+			}
+		}
+	}
+	return true
+}
+
+// finished reports whether the game of the guesser is over.
+func (g *MultiGuesser) finished() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.over
+}
+
+// players returns the players still guessing.
+func (g *MultiGuesser) players() []Player {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]Player(nil), g.Players...)
+}
+
+// all returns the players still guessing, followed by those dropped.
+func (g *MultiGuesser) all() []Player {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append(append([]Player(nil), g.Players...), g.left...)
+}
+
+// strike strikes p for failing with err, dropping it once it has MaxStrikes
+// strikes, and tells the players, including p.
+func (g *MultiGuesser) strike(p Player, err error) {
+	g.mu.Lock()
+	if g.strikes == nil {
+		g.strikes = make(map[string]int)
+		g.dropped = make(map[string]bool)
+	}
+	g.err = err
+	g.strikes[p.ID()]++
+	s := protocol.Strike{
+		Player:  PlayerEntry{ID: p.ID(), Name: p.Name()},
+		Reason:  err.Error(),
+		Strikes: g.strikes[p.ID()],
+		Dropped: g.strikes[p.ID()] >= g.MaxStrikes,
+	}
+	if s.Dropped {
+		g.drop(p)
+	}
+	g.mu.Unlock()
+
+	players := g.players()
+	if s.Dropped {
+		players = append(players, p)
+	}
+	for _, player := range players {
+		if o, ok := player.(guessersObserver); ok {
+			if err := o.AnnounceStrike(s); err != nil {
+				logger(g.Log).Warn("error announcing strike", "player_id", player.ID(), "err", err)
+			}
+		}
+	}
+}
+
+// drop removes p from the players, keeping the turn of the next one. It
+// must be called with mu held.
+func (g *MultiGuesser) drop(p Player) {
+	for i, player := range g.Players {
+		if player != p {
+			continue
+		}
+		g.Players = append(g.Players[:i], g.Players[i+1:]...)
+		if i < g.turn {
+			g.turn--
+		}
+		g.dropped[p.ID()] = true
+		g.left = append(g.left, p)
+		return
+	}
+}
@@ -2,9 +2,12 @@ package cowbull_test
 
 import (
 	"errors"
+	"time"
 
 	. "github.com/Bo0mer/cowbull"
 	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -63,14 +66,174 @@ var _ = Describe("MultiGuesser", func() {
 				player1.TellReturns(errors.New("talk to my hand"))
 			})
 
-			It("should stop telling and return error", func() {
+			It("should drop the player and tell the rest", func() {
 				err := multi.Tell("42", 1, 1)
-				Expect(err).To(HaveOccurred())
+				Expect(err).NotTo(HaveOccurred())
 				Expect(player1.TellCallCount()).To(Equal(1))
-				Expect(player2.TellCallCount()).To(BeZero())
+				Expect(player2.TellCallCount()).To(Equal(1))
 
+				Expect(multi.Tell("43", 0, 1)).To(Succeed())
+				Expect(player1.TellCallCount()).To(Equal(1))
+				Expect(player2.TellCallCount()).To(Equal(2))
+			})
+
+			It("should return the error once no players remain", func() {
+				player2.TellReturns(errors.New("nobody home"))
+				err := multi.Tell("42", 1, 1)
+				Expect(err).To(MatchError("nobody home"))
+			})
+		})
+	})
+
+	Describe("failing guessers", func() {
+		var player3 *cowbullfakes.FakePlayer
+		var watching *watchingPlayer
+
+		BeforeEach(func() {
+			player1.IDReturns("p1")
+			player2.IDReturns("p2")
+			player2.NameReturns("bob")
+			player3 = new(cowbullfakes.FakePlayer)
+			player3.IDReturns("p3")
+			watching = &watchingPlayer{FakePlayer: player3}
+			multi.Players = []Player{player1, player2, watching}
+			player2.GuessReturns("", game.ErrForfeit)
+		})
+
+		It("should skip a player that fails to guess", func() {
+			player1.GuessReturns("12", nil)
+			player3.GuessReturns("34", nil)
+
+			Expect(multi.Guess(2)).To(Equal("12"))
+			Expect(multi.Guess(2)).To(Equal("34"))
+			Expect(player2.GuessCallCount()).To(Equal(1))
+			Expect(multi.Guess(2)).To(Equal("12"))
+			Expect(multi.Guess(2)).To(Equal("34"))
+			Expect(player2.GuessCallCount()).To(Equal(1))
+		})
+
+		It("should tell the players who was dropped", func() {
+			multi.Guess(2)
+			multi.Guess(2)
+			Expect(watching.strikes).To(Equal([]protocol.Strike{{
+				Player:  PlayerEntry{ID: "p2", Name: "bob"},
+				Reason:  game.ErrForfeit.Error(),
+				Strikes: 1,
+				Dropped: true,
+			}}))
+		})
+
+		Context("with strikes", func() {
+			BeforeEach(func() {
+				multi.MaxStrikes = 2
+			})
+
+			It("should drop a player only once it failed as often", func() {
+				for i := 0; i < 6; i++ {
+					multi.Guess(2)
+				}
+				Expect(player2.GuessCallCount()).To(Equal(2))
+				Expect(watching.strikes).To(HaveLen(2))
+				Expect(watching.strikes[0].Dropped).To(BeFalse())
+				Expect(watching.strikes[1].Strikes).To(Equal(2))
+				Expect(watching.strikes[1].Dropped).To(BeTrue())
+			})
+		})
+
+		It("should return the last failure once no players remain", func() {
+			player1.GuessReturns("", errors.New("gone"))
+			player3.GuessReturns("", errors.New("timed out"))
+			_, err := multi.Guess(2)
+			Expect(err).To(MatchError("timed out"))
+			Expect(player1.GuessCallCount()).To(Equal(1))
+			Expect(player3.GuessCallCount()).To(Equal(1))
+		})
+
+		It("should count the dropped players as participants", func() {
+			multi.Guess(2)
+			multi.Guess(2)
+			g := game.New(new(cowbullfakes.FakePlayer), multi)
+			Expect(NewGameRecord(g, GameSettings{}, time.Now()).Guessers).To(ConsistOf(
+				PlayerEntry{ID: "p1"}, PlayerEntry{ID: "p2", Name: "bob"}, PlayerEntry{ID: "p3"}))
+		})
+
+		Describe("Rejoin", func() {
+			var back *cowbullfakes.FakePlayer
+
+			BeforeEach(func() {
+				back = new(cowbullfakes.FakePlayer)
+				back.IDReturns("p2")
+				back.GuessReturns("56", nil)
+				player1.GuessReturns("12", nil)
+				player3.GuessReturns("34", nil)
+				multi.Guess(2)
+				multi.Guess(2)
+			})
+
+			It("should not let dropped players back in by default", func() {
+				Expect(multi.Rejoin(back)).To(BeFalse())
+			})
+
+			Context("when allowed", func() {
+				BeforeEach(func() {
+					multi.AllowRejoin = true
+				})
+
+				It("should let them guess again, last in turn", func() {
+					Expect(multi.Rejoin(back)).To(BeTrue())
+					Expect(watching.rejoined).To(Equal([]PlayerEntry{{ID: "p2"}}))
+					Expect(multi.Guess(2)).To(Equal("56"))
+					Expect(multi.Guess(2)).To(Equal("12"))
+					Expect(multi.Guess(2)).To(Equal("34"))
+					Expect(multi.Guess(2)).To(Equal("56"))
+				})
+
+				It("should let only dropped players back in", func() {
+					Expect(multi.Rejoin(player1)).To(BeFalse())
+				})
+
+				It("should not let them back in once the game is over", func() {
+					multi.GameOver(game.Result{})
+					Expect(multi.Rejoin(back)).To(BeFalse())
+				})
 			})
 		})
 	})
 
+	Describe("GameOver", func() {
+		It("should tell all players that observe games", func() {
+			observing := &observingPlayer{FakePlayer: player2}
+			multi.Players = []Player{player1, observing}
+
+			result := game.Result{Outcome: game.OutcomeAbort, Reason: "thinker left"}
+			multi.GameOver(result)
+			Expect(observing.results).To(Equal([]game.Result{result}))
+		})
+	})
 })
+
+type observingPlayer struct {
+	*cowbullfakes.FakePlayer
+	results []game.Result
+}
+
+func (p *observingPlayer) GameOver(r game.Result) {
+	p.results = append(p.results, r)
+}
+
+// watchingPlayer records what it is told about the other guessers.
+type watchingPlayer struct {
+	*cowbullfakes.FakePlayer
+	strikes  []protocol.Strike
+	rejoined []PlayerEntry
+}
+
+func (p *watchingPlayer) AnnounceStrike(s protocol.Strike) error {
+	p.strikes = append(p.strikes, s)
+	return nil
+}
+
+func (p *watchingPlayer) AnnounceRejoin(e PlayerEntry) error {
+	p.rejoined = append(p.rejoined, e)
+	return nil
+}
@@ -1,11 +1,14 @@
 package cowbull
 
 import (
-	"encoding/json"
-	"errors"
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
 	"sync"
 	"time"
+
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
 )
 
 //go:generate counterfeiter . Messenger
@@ -13,20 +16,6 @@ import (
 // ensure RemotePlayer satisfies Player.
 var _ Player = &RemotePlayer{}
 
-type cowsbulls struct {
-	Number string `json:"number"`
-	Cows   int    `json:"cows"`
-	Bulls  int    `json:"bulls"`
-}
-
-type digits struct {
-	Digits int `json:"digits"`
-}
-
-type number struct {
-	Number string `json:"number"`
-}
-
 // Messenger sends, receives and acts on messages.
 type Messenger interface {
 	// ID is the messenger's id.
@@ -43,12 +32,39 @@ type RemotePlayer struct {
 	m           Messenger
 	waitTimeout time.Duration
 
-	mu   sync.RWMutex // guards
-	name string
+	mu      sync.RWMutex // guards
+	name    string
+	secret  string
+	account *Account
+	abort   *gameAbort      // the abort of the player until its current game is over
+	game    context.Context // of the game the player plays, Background if none
+
+	digits chan protocol.Digits    // number of digits of the unknown number
+	number chan protocol.Number    // the last guess of the player
+	try    chan protocol.CowsBulls // the result of the last try to guess the number
+
+	forfeit chan struct{} // signalled when the player gives up
+
+	cancel     chan struct{} // closed when the games of the player are cancelled
+	cancelOnce sync.Once
 
-	digits chan digits    // number of digits of the unknown number
-	number chan number    // the last guess of the player
-	try    chan cowsbulls // the result of the last try to guess the number
+	metrics *Metrics
+	log     *slog.Logger
+}
+
+// timeoutError is returned when a remote player does not answer in time.
+type timeoutError struct {
+	kind string
+}
+
+func (e timeoutError) Error() string {
+	return fmt.Sprintf("remoteplayer: %s timed out", e.kind)
+}
+
+// Timeout reports that the error is a timeout, so that games end with
+// timeout outcome.
+func (timeoutError) Timeout() bool {
+	return true
 }
 
 // NewRemotePlayer creates a player based on a messenger.
@@ -56,53 +72,63 @@ func NewRemotePlayer(m Messenger, waitTimeout time.Duration) *RemotePlayer {
 	p := &RemotePlayer{
 		m:           m,
 		waitTimeout: waitTimeout,
-		digits:      make(chan digits),
-		number:      make(chan number),
-		try:         make(chan cowsbulls),
+		digits:      make(chan protocol.Digits),
+		number:      make(chan protocol.Number),
+		try:         make(chan protocol.CowsBulls),
+		forfeit:     make(chan struct{}, 1),
+		abort:       newGameAbort(),
+		game:        context.Background(),
+		cancel:      make(chan struct{}),
+		log:         slog.Default(),
 	}
 
-	m.OnMessage("name", func(data string) {
-		var name struct {
-			Name string `json:"name"`
-		}
-		if err := json.Unmarshal([]byte(data), &name); err != nil {
-			log.Printf("remoteplayer: bad input for name: %s\n", data)
+	m.OnMessage(protocol.KindName, func(data string) {
+		var name protocol.Name
+		if !p.decode(protocol.KindName, data, &name) {
 			return
 		}
 		p.mu.Lock()
-		defer p.mu.Unlock()
-		p.name = name.Name
+		loggedIn := p.account != nil
+		if !loggedIn {
+			p.name = name.Name
+		}
+		p.mu.Unlock()
+		if loggedIn {
+			p.reject(protocol.KindName, protocol.CodeBadRequest, "players logged in are named after their account")
+		}
 	})
 
-	m.OnMessage("think", func(data string) {
+	m.OnMessage(protocol.KindThink, func(data string) {
 		go func() {
-			var thinkResp digits
-			err := json.Unmarshal([]byte(data), &thinkResp)
-			if err != nil {
-				log.Printf("remoteplayer: bad input for think: %s\n", data)
+			var d protocol.Digits
+			if !p.decode(protocol.KindThink, data, &d) {
 				return
 			}
-			p.digits <- thinkResp
+			p.digits <- d
 		}()
 	})
 
-	m.OnMessage("guess", func(data string) {
+	m.OnMessage(protocol.KindForfeit, func(_ string) {
+		select {
+		case p.forfeit <- struct{}{}:
+		default:
+		}
+	})
+
+	m.OnMessage(protocol.KindGuess, func(data string) {
 		go func() {
-			var n number
-			err := json.Unmarshal([]byte(data), &n)
-			if err != nil {
-				log.Printf("remoteplayer: bad input for guess: %s\n", data)
+			var n protocol.Number
+			if !p.decode(protocol.KindGuess, data, &n) {
+				return
 			}
 			p.number <- n
 		}()
 	})
 
-	m.OnMessage("try", func(data string) {
+	m.OnMessage(protocol.KindTry, func(data string) {
 		go func() {
-			var cb cowsbulls
-			err := json.Unmarshal([]byte(data), &cb)
-			if err != nil {
-				log.Printf("remoteplayer: bad input for try: %s\n", data)
+			var cb protocol.CowsBulls
+			if !p.decode(protocol.KindTry, data, &cb) {
 				return
 			}
 			p.try <- cb
@@ -112,11 +138,56 @@ func NewRemotePlayer(m Messenger, waitTimeout time.Duration) *RemotePlayer {
 	return p
 }
 
-// ID returns the remote player's id.
+// decode decodes the data of a message of a kind into v. If the data is
+// malformed, the player is sent a bad request error and false is returned.
+func (p *RemotePlayer) decode(kind, data string, v interface{}) bool {
+	if err := protocol.Decode(data, v); err != nil {
+		p.logger().Info("bad input", "kind", kind, "data", data)
+		p.reject(kind, protocol.CodeBadRequest, "malformed %s data: %v", kind, err)
+		return false
+	}
+	return true
+}
+
+// send sends a message of a kind with payload.
+func (p *RemotePlayer) send(kind string, payload interface{}) error {
+	data, err := protocol.Encode(payload)
+	if err != nil {
+		return err
+	}
+	return p.m.SendMessage(kind, data)
+}
+
+// ID returns the remote player's id. Once logged in, it is the ID of the
+// account.
 func (p *RemotePlayer) ID() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.account != nil {
+		return p.account.ID
+	}
 	return p.m.ID()
 }
 
+// Login binds the player to an account. From then on, the player has the ID
+// of the account and is named after it.
+func (p *RemotePlayer) Login(acc Account) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.account = &acc
+	p.name = acc.Username
+}
+
+// Account returns the account the player is logged in to, if any.
+func (p *RemotePlayer) Account() (Account, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.account == nil {
+		return Account{}, false
+	}
+	return *p.account, true
+}
+
 // Name returns the remote player's name. It may be empty.
 func (p *RemotePlayer) Name() string {
 	p.mu.RLock()
@@ -126,73 +197,372 @@ func (p *RemotePlayer) Name() string {
 
 // AnnouncePlayers sends message announcing all players specified.
 func (p *RemotePlayer) AnnouncePlayers(players []PlayerEntry) error {
-	playersBytes, err := json.Marshal(players)
-	if err != nil {
-		return err
-	}
-	return p.m.SendMessage("players", string(playersBytes))
+	return p.send(protocol.KindPlayers, players)
+}
+
+// AnnounceTournament sends the state of a tournament the player takes part
+// in.
+func (p *RemotePlayer) AnnounceTournament(t Tournament) error {
+	return p.send(protocol.KindTournament, t)
+}
+
+// AnnounceMatch tells the player who it was paired with by matchmaking.
+func (p *RemotePlayer) AnnounceMatch(m Matched) error {
+	return p.send(protocol.KindMatched, m)
+}
+
+// SendError sends an error message.
+func (p *RemotePlayer) SendError(e *protocol.Error) error {
+	return p.send(protocol.KindError, e)
 }
 
 // Think sends a think messages and returns its response.
+// Responses with invalid digit count are rejected and the player is given
+// another chance to answer until the wait timeout expires.
 func (p *RemotePlayer) Think() (int, error) {
-	if err := p.m.SendMessage("think", ""); err != nil {
+	if err := p.send(protocol.KindThink, nil); err != nil {
 		return 0, err
 	}
 
-	select {
-	case <-time.Tick(p.waitTimeout):
-		return 0, errors.New("remoteplayer: think timed out")
-	case d := <-p.digits:
-		return d.Digits, nil
+	aborted, played := p.aborted(), p.playing()
+	timeout := time.After(p.waitTimeout)
+	for {
+		select {
+		case <-timeout:
+			return 0, p.timedOut(protocol.KindThink)
+		case <-p.forfeit:
+			return 0, game.ErrForfeit
+		case <-p.cancel:
+			return 0, ErrShutdown
+		case <-aborted.done:
+			return 0, aborted.err
+		case <-played.Done():
+			return 0, context.Cause(played)
+		case d := <-p.digits:
+			if d.Digits < 1 || d.Digits > 10 {
+				p.reject(protocol.KindThink, protocol.CodeInvalidDigits, "invalid digit count %d", d.Digits)
+				continue
+			}
+			if d.Secret != "" && !validNumber(d.Secret, d.Digits) {
+				p.reject(protocol.KindThink, protocol.CodeInvalidDigits, "secret %q is not a %d-digit number with distinct digits", d.Secret, d.Digits)
+				continue
+			}
+			p.mu.Lock()
+			p.secret = d.Secret
+			p.mu.Unlock()
+			return d.Digits, nil
+		}
 	}
 }
 
 // Guess sends a guess message and returns its response.
+// Responses that are not n-digit numbers with distinct digits are rejected
+// and the player is given another chance to answer until the wait timeout
+// expires.
 func (p *RemotePlayer) Guess(n int) (string, error) {
-	guessReq := digits{Digits: n}
-	data, err := json.Marshal(&guessReq)
-	if err != nil {
+	if err := p.send(protocol.KindGuess, protocol.Digits{Digits: n}); err != nil {
 		return "", err
 	}
 
-	if err := p.m.SendMessage("guess", string(data)); err != nil {
-		return "", err
-	}
-
-	select {
-	case <-time.Tick(p.waitTimeout):
-		return "", errors.New("remoteplayer: guess timed out")
-	case res := <-p.number:
-		return res.Number, nil
+	aborted, played := p.aborted(), p.playing()
+	timeout := time.After(p.waitTimeout)
+	for {
+		select {
+		case <-timeout:
+			return "", p.timedOut(protocol.KindGuess)
+		case <-p.forfeit:
+			return "", game.ErrForfeit
+		case <-p.cancel:
+			return "", ErrShutdown
+		case <-aborted.done:
+			return "", aborted.err
+		case <-played.Done():
+			return "", context.Cause(played)
+		case res := <-p.number:
+			if !validNumber(res.Number, n) {
+				p.reject(protocol.KindGuess, protocol.CodeInvalidGuess, "%q is not a %d-digit number with distinct digits", res.Number, n)
+				continue
+			}
+			return res.Number, nil
+		}
 	}
 }
 
 // Try sends a try message and returns its response.
+// Responses with impossible cows and bulls are rejected and the player is
+// given another chance to answer until the wait timeout expires.
 func (p *RemotePlayer) Try(guess string) (int, int, error) {
-	tryReq := number{Number: guess}
-	data, err := json.Marshal(&tryReq)
-	if err != nil {
+	if err := p.send(protocol.KindTry, protocol.Number{Number: guess}); err != nil {
 		return 0, 0, err
 	}
 
-	if err := p.m.SendMessage("try", string(data)); err != nil {
-		return 0, 0, err
+	aborted, played := p.aborted(), p.playing()
+	timeout := time.After(p.waitTimeout)
+	for {
+		select {
+		case <-timeout:
+			return 0, 0, p.timedOut(protocol.KindTry)
+		case <-p.forfeit:
+			return 0, 0, game.ErrForfeit
+		case <-p.cancel:
+			return 0, 0, ErrShutdown
+		case <-aborted.done:
+			return 0, 0, aborted.err
+		case <-played.Done():
+			return 0, 0, context.Cause(played)
+		case res := <-p.try:
+			if res.Cows < 0 || res.Bulls < 0 || res.Cows+res.Bulls > len(guess) {
+				p.reject(protocol.KindTry, protocol.CodeInvalidScore, "%d cows and %d bulls are impossible for %q", res.Cows, res.Bulls, guess)
+				continue
+			}
+			return res.Cows, res.Bulls, nil
+		}
 	}
+}
+
+// UseMetrics makes the player count its timeouts in m. It should be called
+// before the player is in use.
+func (p *RemotePlayer) UseMetrics(m *Metrics) {
+	p.metrics = m
+}
+
+// UseLogger makes the player log to l instead of the default logger. It
+// should be called before the player is in use.
+func (p *RemotePlayer) UseLogger(l *slog.Logger) {
+	p.log = l
+}
+
+// logger returns the logger of the player, with its current ID as
+// player_id.
+func (p *RemotePlayer) logger() *slog.Logger {
+	return p.log.With("player_id", p.ID())
+}
+
+// Cancel makes the player fail with ErrShutdown whenever it waits for an
+// answer, from now on.
+func (p *RemotePlayer) Cancel() {
+	p.cancelOnce.Do(func() {
+		close(p.cancel)
+	})
+}
 
+// gameAbort ends the waits of a player in a game once it is aborted.
+type gameAbort struct {
+	done chan struct{} // closed when the game is aborted
+	err  error         // why the game was aborted, set before done is closed
+}
+
+func newGameAbort() *gameAbort {
+	return &gameAbort{done: make(chan struct{})}
+}
+
+// Abort makes the player fail with err whenever it waits for an answer,
+// until its current game is over. Aborting the game again does nothing.
+func (p *RemotePlayer) Abort(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.abort.done:
+	default:
+		p.abort.err = err
+		close(p.abort.done)
+	}
+}
+
+// aborted returns the abort of the current game of the player.
+func (p *RemotePlayer) aborted() *gameAbort {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.abort
+}
+
+// endAbort forgets the abort of a game that is over, so that it does not
+// end the next game of the player.
+func (p *RemotePlayer) endAbort() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.abort.done:
+		p.abort = newGameAbort()
+	default:
+	}
+}
+
+// JoinGame makes the player fail with the cause of ctx whenever it waits
+// for an answer once ctx is done, until it leaves the game of ctx or joins
+// another.
+func (p *RemotePlayer) JoinGame(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.game = ctx
+}
+
+// LeaveGame forgets the game of ctx, unless the player joined another
+// since.
+func (p *RemotePlayer) LeaveGame(ctx context.Context) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.game == ctx {
+		p.game = context.Background()
+	}
+}
+
+// playing returns the context of the game the player plays.
+func (p *RemotePlayer) playing() context.Context {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.game
+}
+
+// AnnounceShutdown tells the player that the server is shutting down.
+func (p *RemotePlayer) AnnounceShutdown(s protocol.Shutdown) error {
+	return p.send(protocol.KindShutdown, s)
+}
+
+// Secret returns the number the player thought of in its last think
+// answer, if it revealed it.
+func (p *RemotePlayer) Secret() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.secret
+}
+
+// GameOver sends a gameover message with the result of a game.
+func (p *RemotePlayer) GameOver(r game.Result) {
+	// A forfeit sent or an abort made after the player's last move must not
+	// end its next game.
+	p.drain()
+
+	if err := p.send(protocol.KindGameOver, gameOver(r)); err != nil {
+		p.logger().Warn("error sending message", "kind", protocol.KindGameOver, "err", err)
+	}
+}
+
+// AnnounceStrike tells the player that a guesser of its game failed.
+func (p *RemotePlayer) AnnounceStrike(s protocol.Strike) error {
+	return p.send(protocol.KindStrike, s)
+}
+
+// AnnounceRejoin tells the player that a dropped guesser rejoined its game.
+func (p *RemotePlayer) AnnounceRejoin(e PlayerEntry) error {
+	return p.send(protocol.KindRejoin, e)
+}
+
+// AnnounceProposals tells the player what the members of its team proposed.
+func (p *RemotePlayer) AnnounceProposals(ps protocol.Proposals) error {
+	return p.send(protocol.KindProposals, ps)
+}
+
+// TeamChat relays a message from a member of the team of the player.
+func (p *RemotePlayer) TeamChat(m protocol.TeamChat) error {
+	return p.send(protocol.KindTeamChat, m)
+}
+
+// AnnounceChat relays a chat message to the player.
+func (p *RemotePlayer) AnnounceChat(c protocol.Chat) error {
+	return p.send(protocol.KindChat, c)
+}
+
+// AnnounceRoom tells the player who is in a chat room it joined.
+func (p *RemotePlayer) AnnounceRoom(r protocol.Room) error {
+	return p.send(protocol.KindRoom, r)
+}
+
+// AnnounceDuel tells the player that a duel it takes part in starts.
+func (p *RemotePlayer) AnnounceDuel(d protocol.Duel) error {
+	return p.send(protocol.KindDuel, d)
+}
+
+// AnnounceRace tells the player how a race it takes part in goes.
+func (p *RemotePlayer) AnnounceRace(r protocol.Race) error {
+	return p.send(protocol.KindRace, r)
+}
+
+// DuelOver sends a duelover message with the result of a duel, in which the
+// player is the duelist at index i.
+func (p *RemotePlayer) DuelOver(r game.DuelResult, i int) {
+	p.drain()
+
+	over := protocol.DuelOver{
+		ID:      r.ID,
+		Outcome: r.Outcome,
+		Reason:  r.Reason,
+		Guessed: gameOver(r.Games[i]),
+		Thought: gameOver(r.Games[1-i]),
+	}
+	switch {
+	case r.Winner == i:
+		over.Result = protocol.DuelWon
+	case r.Winner != game.NoWinner:
+		over.Result = protocol.DuelLost
+	case r.Outcome == game.OutcomeWin:
+		over.Result = protocol.DuelDraw
+	default:
+		over.Result = protocol.DuelNone
+	}
+	if err := p.send(protocol.KindDuelOver, over); err != nil {
+		p.logger().Warn("error sending message", "kind", protocol.KindDuelOver, "err", err)
+	}
+}
+
+// drain forgets a forfeit or an abort left over from a game that is over.
+func (p *RemotePlayer) drain() {
 	select {
-	case <-time.Tick(p.waitTimeout):
-		return 0, 0, errors.New("remoteplayer: try timed out")
-	case res := <-p.try:
-		return res.Cows, res.Bulls, nil
+	case <-p.forfeit:
+	default:
+	}
+	p.endAbort()
+}
+
+// gameOver returns the gameover message of a game result.
+func gameOver(r game.Result) protocol.GameOver {
+	moves := make([]protocol.Move, len(r.Moves))
+	for i, m := range r.Moves {
+		moves[i] = protocol.Move{Guess: m.Guess, Cows: m.Cows, Bulls: m.Bulls}
+	}
+	return protocol.GameOver{
+		ID:      r.ID,
+		Outcome: r.Outcome,
+		Reason:  r.Reason,
+		Secret:  r.Secret,
+		Digits:  r.Digits,
+		Moves:   moves,
+	}
+}
+
+// reject tells the player that its answer to a message of a kind is invalid.
+func (p *RemotePlayer) reject(kind, code, format string, args ...interface{}) {
+	perr := protocol.Errorf(code, format, args...)
+	perr.Kind = kind
+	if err := p.SendError(perr); err != nil {
+		p.logger().Warn("error sending message", "kind", protocol.KindError, "err", err)
+	}
+}
+
+// timedOut tells the player that it did not answer a message of a kind in
+// time and returns the error to be reported to the game.
+func (p *RemotePlayer) timedOut(kind string) error {
+	p.reject(kind, protocol.CodeTimeout, "no %s answer within %v", kind, p.waitTimeout)
+	p.metrics.inc(metricTimeouts, kind)
+	return timeoutError{kind: kind}
+}
+
+// validNumber reports whether number consists of n distinct digits.
+func validNumber(number string, n int) bool {
+	if len(number) != n {
+		return false
 	}
+	var seen [10]bool
+	for _, r := range number {
+		if r < '0' || r > '9' || seen[r-'0'] {
+			return false
+		}
+		seen[r-'0'] = true
+	}
+	return true
 }
 
 // Tell sends a tell message.
 func (p *RemotePlayer) Tell(number string, cows, bulls int) error {
-	tellReq := cowsbulls{Number: number, Cows: cows, Bulls: bulls}
-	data, err := json.Marshal(&tellReq)
-	if err != nil {
-		return err
-	}
-	return p.m.SendMessage("tell", string(data))
+	return p.send(protocol.KindTell, protocol.CowsBulls{Number: number, Cows: cows, Bulls: bulls})
 }
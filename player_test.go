@@ -1,11 +1,14 @@
 package cowbull_test
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	. "github.com/Bo0mer/cowbull"
 	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -110,6 +113,44 @@ var _ = Describe("RemotePlayer", func() {
 			})
 		})
 
+		Context("when the think response is malformed", func() {
+			BeforeEach(func() {
+				messenger.OnMessageStub = func(kind string, action func(data string)) {
+					if kind == "think" {
+						action(`{"digits":"four"}`)
+					}
+				}
+			})
+
+			It("should send a 'bad_request' error", func() {
+				Expect(messenger.SendMessageCallCount()).To(BeNumerically(">=", 2))
+				argKind, argData := messenger.SendMessageArgsForCall(1)
+				Expect(argKind).To(Equal("error"))
+				Expect(argData).To(ContainSubstring(`"code":"bad_request"`))
+				Expect(argData).To(ContainSubstring(`"kind":"think"`))
+			})
+
+			It("should keep waiting for a response", func() {
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(Equal("remoteplayer: think timed out"))
+			})
+		})
+
+		Context("when the think response reveals the number", func() {
+			BeforeEach(func() {
+				messenger.OnMessageStub = func(kind string, action func(data string)) {
+					if kind == "think" {
+						action(`{"digits":4,"secret":"1234"}`)
+					}
+				}
+			})
+
+			It("should remember the number", func() {
+				Expect(err).ToNot(HaveOccurred())
+				Expect(player.Secret()).To(Equal("1234"))
+			})
+		})
+
 		Context("when there is no think response", func() {
 			It("should return a timed out error", func() {
 				Ω(digits).Should(BeZero())
@@ -124,14 +165,20 @@ var _ = Describe("RemotePlayer", func() {
 		var expectedNumber string
 		var number string
 		var err error
+		var timeout time.Duration
+
+		BeforeEach(func() {
+			timeout = time.Millisecond * 10
+		})
 
 		JustBeforeEach(func() {
-			player = NewRemotePlayer(messenger, time.Millisecond*10)
+			player = NewRemotePlayer(messenger, timeout)
 			number, err = player.Guess(digits)
 		})
 
 		Context("when the guess response arrives on time", func() {
 			BeforeEach(func() {
+				timeout = time.Second
 				expectedNumber = "4201"
 				digits = len(expectedNumber)
 				messenger.OnMessageStub = func(kind string, action func(data string)) {
@@ -154,11 +201,43 @@ var _ = Describe("RemotePlayer", func() {
 			})
 		})
 
+		Context("when the guess response is invalid", func() {
+			BeforeEach(func() {
+				digits = 4
+				messenger.OnMessageStub = func(kind string, action func(data string)) {
+					if kind == "guess" {
+						action(`{"number":"1123"}`)
+					}
+				}
+			})
+
+			It("should reject it with an 'invalid_guess' error", func() {
+				Expect(messenger.SendMessageCallCount()).To(BeNumerically(">=", 2))
+				argKind, argData := messenger.SendMessageArgsForCall(1)
+				Expect(argKind).To(Equal("error"))
+				Expect(argData).To(ContainSubstring(`"code":"invalid_guess"`))
+			})
+
+			It("should not return it", func() {
+				Ω(number).Should(BeEmpty())
+				Ω(err).Should(HaveOccurred())
+			})
+		})
+
 		Context("when there is no guess response", func() {
 			It("should return a timed out error", func() {
 				Ω(number).Should(BeEmpty())
 				Ω(err).Should(HaveOccurred())
 				Ω(err.Error()).Should(Equal("remoteplayer: guess timed out"))
+				Ω(game.OutcomeOf(err)).Should(Equal(game.OutcomeTimeout))
+			})
+
+			It("should tell the player with a 'timeout' error", func() {
+				Expect(messenger.SendMessageCallCount()).To(Equal(2))
+				argKind, argData := messenger.SendMessageArgsForCall(1)
+				Expect(argKind).To(Equal("error"))
+				Expect(argData).To(ContainSubstring(`"code":"timeout"`))
+				Expect(argData).To(ContainSubstring(`"kind":"guess"`))
 			})
 		})
 	})
@@ -235,6 +314,215 @@ var _ = Describe("RemotePlayer", func() {
 		})
 	})
 
+	Describe("Cancel", func() {
+		BeforeEach(func() {
+			player = NewRemotePlayer(messenger, time.Minute)
+		})
+
+		It("should end the wait for an answer with ErrShutdown", func() {
+			errs := make(chan error, 1)
+			go func() {
+				_, err := player.Guess(4)
+				errs <- err
+			}()
+			Eventually(messenger.SendMessageCallCount).Should(Equal(1))
+			player.Cancel()
+			Eventually(errs).Should(Receive(Equal(ErrShutdown)))
+		})
+
+		It("should make later waits fail at once", func() {
+			player.Cancel()
+			player.Cancel()
+			_, err := player.Think()
+			Expect(err).To(Equal(ErrShutdown))
+			_, _, err = player.Try("1234")
+			Expect(err).To(Equal(ErrShutdown))
+		})
+	})
+
+	Describe("Abort", func() {
+		BeforeEach(func() {
+			player = NewRemotePlayer(messenger, time.Minute)
+		})
+
+		It("should end the wait for an answer with the error", func() {
+			errs := make(chan error, 1)
+			go func() {
+				_, _, err := player.Try("1234")
+				errs <- err
+			}()
+			Eventually(messenger.SendMessageCallCount).Should(Equal(1))
+			player.Abort(ErrAborted)
+			Eventually(errs).Should(Receive(Equal(ErrAborted)))
+		})
+
+		It("should end every wait until the game is over", func() {
+			player.Abort(ErrKicked)
+			player.Abort(ErrAborted)
+			_, err := player.Think()
+			Expect(err).To(Equal(ErrKicked))
+			_, err = player.Guess(4)
+			Expect(err).To(Equal(ErrKicked))
+			_, _, err = player.Try("1234")
+			Expect(err).To(Equal(ErrKicked))
+		})
+
+		It("should not outlive the game", func() {
+			player = NewRemotePlayer(messenger, time.Millisecond)
+			player.Abort(ErrAborted)
+			player.Abort(ErrKicked)
+			player.GameOver(game.Result{})
+			_, err := player.Think()
+			Expect(err).To(MatchError("remoteplayer: think timed out"))
+		})
+	})
+
+	Describe("JoinGame", func() {
+		var ctx context.Context
+		var abort context.CancelCauseFunc
+
+		BeforeEach(func() {
+			player = NewRemotePlayer(messenger, time.Millisecond)
+			ctx, abort = context.WithCancelCause(context.Background())
+			player.JoinGame(ctx)
+		})
+
+		It("should end the waits in the game once it is aborted", func() {
+			abort(ErrAborted)
+			_, err := player.Guess(4)
+			Expect(err).To(Equal(ErrAborted))
+		})
+
+		It("should not end the waits once the player left the game", func() {
+			player.LeaveGame(ctx)
+			abort(ErrAborted)
+			_, err := player.Guess(4)
+			Expect(err).To(MatchError("remoteplayer: guess timed out"))
+		})
+
+		It("should not end the waits in a game joined since", func() {
+			player.JoinGame(context.Background())
+			player.LeaveGame(ctx)
+			abort(ErrAborted)
+			_, err := player.Guess(4)
+			Expect(err).To(MatchError("remoteplayer: guess timed out"))
+		})
+	})
+
+	Describe("AnnounceShutdown", func() {
+		It("should send a 'shutdown' message", func() {
+			player = NewRemotePlayer(messenger, time.Second)
+			Expect(player.AnnounceShutdown(protocol.Shutdown{Deadline: 1000})).To(Succeed())
+			kind, data := messenger.SendMessageArgsForCall(0)
+			Expect(kind).To(Equal("shutdown"))
+			Expect(data).To(MatchJSON(`{"deadline": 1000}`))
+		})
+	})
+
+	Describe("GameOver", func() {
+		BeforeEach(func() {
+			player = NewRemotePlayer(messenger, time.Second)
+			player.GameOver(game.Result{
+				ID:      "g1",
+				Outcome: game.OutcomeTimeout,
+				Reason:  "remoteplayer: guess timed out",
+				Secret:  "42",
+				Digits:  2,
+				Moves:   []game.Move{{Guess: "24", Cows: 2}},
+			})
+		})
+
+		It("should send a 'gameover' message with the result", func() {
+			Expect(messenger.SendMessageCallCount()).To(Equal(1))
+			argKind, argData := messenger.SendMessageArgsForCall(0)
+			Expect(argKind).To(Equal("gameover"))
+			Expect(argData).To(MatchJSON(`{
+				"id": "g1",
+				"outcome": "timeout",
+				"reason": "remoteplayer: guess timed out",
+				"secret": "42",
+				"digits": 2,
+				"moves": [{"guess": "24", "cows": 2, "bulls": 0}]
+			}`))
+		})
+	})
+
+	Describe("DuelOver", func() {
+		var result game.DuelResult
+
+		BeforeEach(func() {
+			player = NewRemotePlayer(messenger, time.Second)
+			result = game.DuelResult{
+				ID:      "d1",
+				Outcome: game.OutcomeWin,
+				Winner:  1,
+				Games: [2]game.Result{
+					{ID: "d1-1", Outcome: game.OutcomeAbort, Reason: "game: the opponent guessed first", Secret: "12", Digits: 2, Moves: []game.Move{{Guess: "21", Cows: 2}}},
+					{ID: "d1-2", Outcome: game.OutcomeWin, Secret: "34", Digits: 2, Moves: []game.Move{{Guess: "34", Bulls: 2}}},
+				},
+			}
+		})
+
+		It("should send a 'duelover' message from the point of view of the player", func() {
+			player.DuelOver(result, 0)
+			Expect(messenger.SendMessageCallCount()).To(Equal(1))
+			argKind, argData := messenger.SendMessageArgsForCall(0)
+			Expect(argKind).To(Equal("duelover"))
+			Expect(argData).To(MatchJSON(`{
+				"id": "d1",
+				"outcome": "win",
+				"result": "lost",
+				"guessed": {
+					"id": "d1-1",
+					"outcome": "abort",
+					"reason": "game: the opponent guessed first",
+					"secret": "12",
+					"digits": 2,
+					"moves": [{"guess": "21", "cows": 2, "bulls": 0}]
+				},
+				"thought": {
+					"id": "d1-2",
+					"outcome": "win",
+					"secret": "34",
+					"digits": 2,
+					"moves": [{"guess": "34", "cows": 0, "bulls": 2}]
+				}
+			}`))
+		})
+
+		It("should tell the winner and drawn duels apart", func() {
+			player.DuelOver(result, 1)
+			result.Winner = game.NoWinner
+			player.DuelOver(result, 1)
+			result.Outcome = game.OutcomeTimeout
+			player.DuelOver(result, 1)
+
+			var over protocol.DuelOver
+			for i, want := range []string{protocol.DuelWon, protocol.DuelDraw, protocol.DuelNone} {
+				_, data := messenger.SendMessageArgsForCall(i)
+				Expect(protocol.Decode(data, &over)).To(Succeed())
+				Expect(over.Result).To(Equal(want))
+			}
+		})
+	})
+
+	Describe("forfeit", func() {
+		var err error
+		BeforeEach(func() {
+			messenger.OnMessageStub = func(kind string, action func(data string)) {
+				if kind == "forfeit" {
+					action("")
+				}
+			}
+			player = NewRemotePlayer(messenger, time.Second)
+			_, err = player.Guess(4)
+		})
+
+		It("should make the pending request fail", func() {
+			Expect(err).To(Equal(game.ErrForfeit))
+		})
+	})
+
 	Describe("Name", func() {
 		Context("when name is not set", func() {
 			BeforeEach(func() {
@@ -265,4 +553,44 @@ var _ = Describe("RemotePlayer", func() {
 		})
 	})
 
+	Describe("Login", func() {
+		var setName func(data string)
+
+		BeforeEach(func() {
+			messenger.IDReturns("conn-id")
+			messenger.OnMessageStub = func(kind string, action func(data string)) {
+				if kind == "name" {
+					setName = action
+				}
+			}
+			player = NewRemotePlayer(messenger, time.Second)
+			setName(`{"name":"Guest"}`)
+			player.Login(Account{ID: "u-alice", Username: "alice"})
+		})
+
+		It("should take the ID and name of the account", func() {
+			Expect(player.ID()).To(Equal("u-alice"))
+			Expect(player.Name()).To(Equal("alice"))
+			acc, ok := player.Account()
+			Expect(ok).To(BeTrue())
+			Expect(acc.Username).To(Equal("alice"))
+		})
+
+		It("should reject a new name", func() {
+			setName(`{"name":"Mallory"}`)
+			Expect(player.Name()).To(Equal("alice"))
+			Expect(messenger.SendMessageCallCount()).To(Equal(1))
+			kind, data := messenger.SendMessageArgsForCall(0)
+			Expect(kind).To(Equal("error"))
+			Expect(data).To(ContainSubstring(`"code":"bad_request"`))
+		})
+	})
+
+	Describe("Account", func() {
+		It("should report a player not logged in", func() {
+			player = NewRemotePlayer(messenger, time.Second)
+			_, ok := player.Account()
+			Expect(ok).To(BeFalse())
+		})
+	})
 })
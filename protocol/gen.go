@@ -0,0 +1,31 @@
+//go:build ignore
+
+// This program generates schema.json and PROTOCOL.md from the message
+// types of the protocol. Run it with go generate.
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+func main() {
+	schema, err := protocol.Schema()
+	if err != nil {
+		log.Fatalf("error generating schema: %v\n", err)
+	}
+	if err := os.WriteFile("schema.json", schema, 0644); err != nil {
+		log.Fatalf("error writing schema: %v\n", err)
+	}
+
+	doc, err := os.Create("PROTOCOL.md")
+	if err != nil {
+		log.Fatalf("error creating doc: %v\n", err)
+	}
+	defer doc.Close()
+	if err := protocol.WriteDoc(doc); err != nil {
+		log.Fatalf("error writing doc: %v\n", err)
+	}
+}
@@ -0,0 +1,541 @@
+// Package protocol defines the messages exchanged between a cowbull server
+// and its clients over a WebSocket connection.
+//
+// Every message is a JSON object with a name, identifying the message kind,
+// and data, holding the JSON encoding of the kind's payload as a string.
+// The payload of each kind is described by the types in this package.
+package protocol
+
+//go:generate go run gen.go
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// Version is the latest protocol version spoken by this package.
+	Version = 1
+	// MinVersion is the oldest protocol version still supported.
+	MinVersion = 1
+)
+
+// Message kinds.
+const (
+	KindConnect  = "connect"
+	KindName     = "name"
+	KindPlayers  = "players"
+	KindPlay     = "play"
+	KindThink    = "think"
+	KindGuess    = "guess"
+	KindTry      = "try"
+	KindTell     = "tell"
+	KindForfeit  = "forfeit"
+	KindGameOver = "gameover"
+	KindError    = "error"
+	KindRegister = "register"
+	KindLogin    = "login"
+	KindStats    = "stats"
+
+	KindTournament = "tournament"
+	KindJoin       = "join"
+	KindStart      = "start"
+
+	KindQueue   = "queue"
+	KindDequeue = "dequeue"
+	KindMatched = "matched"
+
+	KindDuel     = "duel"
+	KindDuelOver = "duelover"
+
+	KindRace = "race"
+
+	KindStrike = "strike"
+	KindRejoin = "rejoin"
+
+	KindProposals = "proposals"
+	KindTeamChat  = "teamchat"
+
+	KindChat = "chat"
+	KindRoom = "room"
+
+	KindShutdown = "shutdown"
+
+	// KindDisconnect is never sent over the wire. It is dispatched locally
+	// once a connection is closed.
+	KindDisconnect = "disconnect"
+)
+
+// Roles a player may take in a game.
+const (
+	RoleThinker = "thinker"
+	RoleGuesser = "guesser"
+)
+
+// Kinds of game.
+const (
+	ModeClassic = "classic"
+	ModeDuel    = "duel"
+	ModeRace    = "race"
+	ModeTeam    = "team"
+)
+
+// Ranks of a race.
+const (
+	RankTurns = "turns"
+	RankTime  = "time"
+)
+
+// Scopes of a chat message.
+const (
+	ScopeLobby = "lobby"
+	ScopeRoom  = "room"
+	ScopeGame  = "game"
+)
+
+// Ways a team decides on its guess.
+const (
+	DecideByVote    = "vote"
+	DecideByCaptain = "captain"
+)
+
+// Message is the envelope of every message.
+type Message struct {
+	// Name identifies a message kind.
+	Name string `json:"name"`
+	// Data is the JSON encoded payload of the message.
+	Data string `json:"data"`
+}
+
+// Connect is sent by a client to join the hub, and by the server in reply
+// once the protocol version is agreed upon.
+type Connect struct {
+	Version int    `json:"version" doc:"Protocol version. Clients send the latest version they speak; the server replies with the negotiated one. Missing means 1."`
+	ID      string `json:"id,omitempty" doc:"ID of the player, set only by the server."`
+}
+
+// Credentials identify an account.
+type Credentials struct {
+	Username string `json:"username" doc:"Name of the account: 3 to 32 letters, digits, underscores or dashes."`
+	Password string `json:"password,omitempty" doc:"Password of the account, at least 8 characters and at most 72 bytes. Accounts may be registered without one, relying on the token alone."`
+	Token    string `json:"token,omitempty" doc:"Token of the account, given when it was registered. Used to log in instead of username and password."`
+}
+
+// Account describes the account a player is logged in to.
+type Account struct {
+	ID       string `json:"id" doc:"ID of the player, stable across connections."`
+	Username string `json:"username" doc:"Name of the account. Also the in-game name of the player."`
+	Token    string `json:"token,omitempty" doc:"Secret token to log in with, sent only once, when the account is registered."`
+}
+
+// Name sets the in-game name of a player.
+type Name struct {
+	Name string `json:"name" doc:"In-game name of the player."`
+}
+
+// PlayerEntry holds metadata for a player.
+type PlayerEntry struct {
+	ID   string `json:"id" doc:"Unique ID of the player."`
+	Name string `json:"name" doc:"In-game name of the player. It may be empty."`
+}
+
+// GameSettings represents settings for a game request.
+type GameSettings struct {
+	Mode      string   `json:"mode,omitempty" doc:"Kind of game. In a duel, the player and a single opponent both think of a number and guess the number of the other at once. In a race, every guesser guesses the same number on its own, in parallel. In a team game, the guessers propose guesses and agree on one guess per turn. Missing means classic." enum:"classic,duel,race,team"`
+	Role      string   `json:"role" doc:"Role of the requesting player. Ignored in duels. In a race or a team game, a guesser must play against an AI thinker, together with the opponents." enum:"thinker,guesser"`
+	Digits    int      `json:"digits" doc:"How many digits the number should have. Used only when playing against an AI thinker, or in a duel."`
+	Rank      string   `json:"rank,omitempty" doc:"How a race is won: by guessing in the fewest turns, or first. Missing means turns." enum:"turns,time"`
+	Decision  string   `json:"decision,omitempty" doc:"How a team agrees on its guess: by a vote of its members, or by the pick of its captain, the first of them. Missing means vote." enum:"vote,captain"`
+	AI        bool     `json:"ai" doc:"Whether the game is versus AI."`
+	Opponents []string `json:"opponents" doc:"IDs of the opponents. Ignored when playing versus AI, except in races and team games."`
+}
+
+// Digits carries the digit count of the secret number.
+type Digits struct {
+	Digits int    `json:"digits" doc:"Digit count of the secret number."`
+	Secret string `json:"secret,omitempty" doc:"The secret number itself. Thinkers may send it when answering a think, so that it is revealed to the guessers once the game is over."`
+}
+
+// Number carries a guess.
+type Number struct {
+	Number string `json:"number" doc:"The guessed number."`
+}
+
+// CowsBulls carries the score of a guess.
+type CowsBulls struct {
+	Number string `json:"number,omitempty" doc:"The guessed number. Omitted when answering a try."`
+	Cows   int    `json:"cows" doc:"Digits present in the secret, but at a different position."`
+	Bulls  int    `json:"bulls" doc:"Digits present in the secret at the same position."`
+}
+
+// Rating describes how well a player does in a role with numbers of a digit
+// count.
+type Rating struct {
+	ID           string  `json:"id" doc:"ID of the player."`
+	Name         string  `json:"name" doc:"In-game name of the player, as of its last game."`
+	Role         string  `json:"role" doc:"Role the rating is for." enum:"thinker,guesser"`
+	Digits       int     `json:"digits" doc:"Digit count the rating is for."`
+	Rating       float64 `json:"rating" doc:"Elo rating, starting at 1500."`
+	Games        int     `json:"games" doc:"Games played."`
+	Solved       int     `json:"solved" doc:"Games in which the number was guessed."`
+	AverageTurns float64 `json:"average_turns,omitempty" doc:"Average turns the number took to guess, over the solved games."`
+	BestGame     string  `json:"best_game,omitempty" doc:"ID of the best solved game: the one guessed in the fewest turns, or for thinkers in the most."`
+	BestTurns    int     `json:"best_turns,omitempty" doc:"Turns of the best game."`
+}
+
+// Stats asks for the ratings of a player, and carries them in reply.
+type Stats struct {
+	ID      string   `json:"id,omitempty" doc:"ID of the player. Clients may omit it to ask for their own ratings."`
+	Ratings []Rating `json:"ratings,omitempty" doc:"Ratings of the player by role and digit count, set only by the server."`
+}
+
+// Tournament formats.
+const (
+	FormatRoundRobin        = "round-robin"
+	FormatSwiss             = "swiss"
+	FormatSingleElimination = "single-elimination"
+)
+
+// Tournament states.
+const (
+	StateRegistering = "registering"
+	StateRunning     = "running"
+	StateFinished    = "finished"
+	StateAborted     = "aborted"
+)
+
+// TournamentSettings describes a tournament to be created.
+type TournamentSettings struct {
+	Format string `json:"format" doc:"How players are paired." enum:"round-robin,swiss,single-elimination"`
+	Digits int    `json:"digits" doc:"Digit count of the numbers thought of in every game."`
+	Rounds int    `json:"rounds,omitempty" doc:"Rounds of a swiss tournament. By default, enough to tell the winner apart. Ignored by the other formats."`
+}
+
+// TournamentID refers to a tournament.
+type TournamentID struct {
+	ID string `json:"id" doc:"ID of the tournament."`
+}
+
+// Match is a pair of games between two players of a tournament, with
+// swapped roles.
+type Match struct {
+	Round  int      `json:"round" doc:"Round of the match, starting at 1."`
+	A      string   `json:"a" doc:"ID of the player thinking first."`
+	B      string   `json:"b,omitempty" doc:"ID of the player guessing first. Empty if A has a bye."`
+	TurnsA int      `json:"turns_a" doc:"Turns A took to guess the number of B. Zero if A did not guess it."`
+	TurnsB int      `json:"turns_b" doc:"Turns B took to guess the number of A. Zero if B did not guess it."`
+	Winner string   `json:"winner,omitempty" doc:"ID of the winner. Empty for a draw or a match not over yet."`
+	Games  []string `json:"games,omitempty" doc:"IDs of the games of the match."`
+	Done   bool     `json:"done" doc:"Whether the match is over."`
+}
+
+// Standing is the position of a player in a tournament.
+type Standing struct {
+	Rank       int     `json:"rank" doc:"Position of the player, starting at 1."`
+	ID         string  `json:"id" doc:"ID of the player."`
+	Name       string  `json:"name" doc:"In-game name of the player."`
+	Points     float64 `json:"points" doc:"One point per win or bye, half per draw."`
+	Wins       int     `json:"wins" doc:"Matches won, including byes."`
+	Draws      int     `json:"draws" doc:"Matches drawn."`
+	Losses     int     `json:"losses" doc:"Matches lost."`
+	Solved     int     `json:"solved" doc:"Numbers the player guessed."`
+	Turns      int     `json:"turns" doc:"Turns the player took to guess, over all numbers it guessed. Fewer turns per number guessed break ties in points, ahead of players who guessed none."`
+	Eliminated bool    `json:"eliminated,omitempty" doc:"Whether the player is out of a single elimination tournament."`
+}
+
+// Tournament describes a tournament and how it stands.
+type Tournament struct {
+	ID        string        `json:"id" doc:"ID of the tournament."`
+	Format    string        `json:"format" doc:"How players are paired." enum:"round-robin,swiss,single-elimination"`
+	Digits    int           `json:"digits" doc:"Digit count of the numbers thought of in every game."`
+	Organiser string        `json:"organiser" doc:"ID of the player who created the tournament."`
+	State     string        `json:"state" doc:"Whether players may still join, the tournament is being played, it is over, or it was stopped by the server shutting down." enum:"registering,running,finished,aborted"`
+	Round     int           `json:"round" doc:"Current round, zero before the tournament starts."`
+	Rounds    int           `json:"rounds" doc:"Rounds to be played, known once the tournament starts."`
+	Players   []PlayerEntry `json:"players" doc:"Registered players, in order of registration, which is also their seed."`
+	Matches   []Match       `json:"matches" doc:"Matches paired so far, by round."`
+	Standings []Standing    `json:"standings" doc:"Players ranked by their results so far."`
+}
+
+// Queue asks to be paired with a random opponent.
+type Queue struct {
+	Role   string  `json:"role" doc:"Role the player wants to take." enum:"thinker,guesser"`
+	Digits int     `json:"digits" doc:"Digit count of the number to be guessed."`
+	Band   float64 `json:"band,omitempty" doc:"How far the rating of the opponent may be from that of the player, in the roles they take. Zero means any opponent will do."`
+}
+
+// Matched tells a player that it was paired with an opponent.
+type Matched struct {
+	Role     string      `json:"role" doc:"Role the player takes." enum:"thinker,guesser"`
+	Digits   int         `json:"digits" doc:"Digit count of the number to be guessed."`
+	Opponent PlayerEntry `json:"opponent" doc:"The opponent, the computer if no one was found in time."`
+	AI       bool        `json:"ai" doc:"Whether the opponent is the computer."`
+}
+
+// Duel announces a duel, right before both duelists are asked to think.
+type Duel struct {
+	ID       string      `json:"id,omitempty" doc:"ID of the duel, if it is recorded. Its games are recorded as the ID followed by -1 and -2."`
+	Digits   int         `json:"digits" doc:"Digit count both numbers must have."`
+	Opponent PlayerEntry `json:"opponent" doc:"The other duelist."`
+}
+
+// Duel results, from the point of view of a duelist.
+const (
+	DuelWon  = "won"
+	DuelLost = "lost"
+	DuelDraw = "draw"
+	DuelNone = "none"
+)
+
+// DuelOver tells a duelist how a duel ended.
+type DuelOver struct {
+	ID      string   `json:"id,omitempty" doc:"ID of the duel, if it was recorded."`
+	Outcome string   `json:"outcome" doc:"How the duel ended: win means that a number was guessed, the rest that a duelist failed, did not answer in time or gave up." enum:"win,abort,timeout,forfeit"`
+	Reason  string   `json:"reason,omitempty" doc:"Why the duel ended, unless a number was guessed."`
+	Result  string   `json:"result" doc:"Whether the duelist won, lost or drew, which is when both guessed in the same round. None means that both failed." enum:"won,lost,draw,none"`
+	Guessed GameOver `json:"guessed" doc:"The game in which the duelist guessed."`
+	Thought GameOver `json:"thought" doc:"The game in which the duelist thought."`
+}
+
+// Strike tells the guessers of a game with several guessers that one of
+// them failed.
+type Strike struct {
+	Player  PlayerEntry `json:"player" doc:"The guesser who failed."`
+	Reason  string      `json:"reason" doc:"How the guesser failed."`
+	Strikes int         `json:"strikes" doc:"Failures of the guesser in the game so far."`
+	Dropped bool        `json:"dropped" doc:"Whether the guesser was dropped from the game, having failed too often."`
+}
+
+// Chat is a chat message.
+type Chat struct {
+	Scope   string      `json:"scope" doc:"Who the message is for: everyone in the hub, the members of a room, or everyone taking part in the game of the sender. Missing means lobby." enum:"lobby,room,game"`
+	Room    string      `json:"room,omitempty" doc:"Name of the room, for the room scope."`
+	From    PlayerEntry `json:"from" doc:"The sender, set only by the server. Its name is HTML-escaped."`
+	Text    string      `json:"text" doc:"The message, up to 500 characters. The server trims and HTML-escapes it."`
+	Time    int64       `json:"time" doc:"When the server received the message, in milliseconds since the Unix epoch, set only by the server."`
+	History bool        `json:"history,omitempty" doc:"Whether the message was sent before the player joined the lobby or room, set only by the server."`
+}
+
+// Room joins or leaves a chat room.
+type Room struct {
+	Room    string        `json:"room" doc:"Name of the room: 1 to 32 letters, digits, underscores or dashes."`
+	Leave   bool          `json:"leave,omitempty" doc:"Whether to leave the room instead of joining it."`
+	Members []PlayerEntry `json:"members,omitempty" doc:"The members of the room, set only by the server."`
+}
+
+// Shutdown tells the players that the server is shutting down.
+type Shutdown struct {
+	Deadline int64 `json:"deadline" doc:"When the games still running are cancelled, in milliseconds since the Unix epoch."`
+}
+
+// Proposal is a guess proposed by a member of a team.
+type Proposal struct {
+	Player PlayerEntry `json:"player" doc:"The member who proposed it."`
+	Number string      `json:"number" doc:"The proposed guess."`
+}
+
+// Proposals tells the members of a team what they proposed this turn.
+type Proposals struct {
+	Decision  string      `json:"decision" doc:"How the team agrees on its guess." enum:"vote,captain"`
+	Captain   PlayerEntry `json:"captain" doc:"The captain of the team, its first member."`
+	Proposals []Proposal  `json:"proposals" doc:"The proposals, in the order of the members."`
+}
+
+// TeamChat is a message to the other members of a team.
+type TeamChat struct {
+	From PlayerEntry `json:"from" doc:"The member who sent it, set only by the server."`
+	Text string      `json:"text" doc:"The message, up to 500 characters."`
+}
+
+// RaceStanding is how a guesser of a race is doing.
+type RaceStanding struct {
+	Place  int         `json:"place,omitempty" doc:"Position of a guesser who guessed, starting at 1. It may change until the race is over."`
+	Player PlayerEntry `json:"player" doc:"The guesser."`
+	Turns  int         `json:"turns" doc:"Guesses made so far."`
+	Millis int64       `json:"millis" doc:"Milliseconds from the start of the race to the last scored guess of the guesser."`
+	Solved bool        `json:"solved" doc:"Whether the guesser guessed the number."`
+	Done   bool        `json:"done" doc:"Whether the guesser is done, having guessed, failed or no chance left to win."`
+}
+
+// Race tells the players of a race how it goes, or how it ended.
+type Race struct {
+	ID        string         `json:"id,omitempty" doc:"ID of the race, if it is recorded. The game of each guesser is recorded as the ID followed by -1, -2 and so on, in the order the guessers were given."`
+	Rank      string         `json:"rank" doc:"How the race is won." enum:"turns,time"`
+	Digits    int            `json:"digits" doc:"Digit count of the number."`
+	Thinker   PlayerEntry    `json:"thinker" doc:"The thinker, the computer if its ID is ai."`
+	Over      bool           `json:"over" doc:"Whether the race is over."`
+	Outcome   string         `json:"outcome,omitempty" doc:"How the race ended, once it is over: win means that the number was guessed, the rest that the thinker, or else every guesser, failed, did not answer in time or gave up." enum:"win,abort,timeout,forfeit"`
+	Reason    string         `json:"reason,omitempty" doc:"Why the race ended, unless the number was guessed."`
+	Secret    string         `json:"secret,omitempty" doc:"The number, once the race is over, if known."`
+	Standings []RaceStanding `json:"standings" doc:"The guessers, best placed first."`
+}
+
+// Outcomes of a game.
+const (
+	OutcomeWin     = "win"
+	OutcomeAbort   = "abort"
+	OutcomeTimeout = "timeout"
+	OutcomeForfeit = "forfeit"
+)
+
+// Move is a guess and its score.
+type Move struct {
+	Guess string `json:"guess" doc:"The guessed number."`
+	Cows  int    `json:"cows" doc:"Cows of the guess."`
+	Bulls int    `json:"bulls" doc:"Bulls of the guess."`
+}
+
+// GameOver tells a participant how a game ended.
+type GameOver struct {
+	ID      string `json:"id,omitempty" doc:"ID of the game, if it was recorded. The game can be replayed at /games/{id}."`
+	Outcome string `json:"outcome" doc:"How the game ended: win means that the number was guessed, the rest that a player failed, did not answer in time or gave up." enum:"win,abort,timeout,forfeit"`
+	Reason  string `json:"reason,omitempty" doc:"Why the game ended, unless the number was guessed."`
+	Secret  string `json:"secret,omitempty" doc:"The secret number, if known."`
+	Digits  int    `json:"digits" doc:"Digit count of the secret number. Zero if the thinker never thought of one."`
+	Moves   []Move `json:"moves" doc:"All guesses in the game, in order."`
+}
+
+// Error codes.
+const (
+	// CodeBadRequest means that a message could not be decoded.
+	CodeBadRequest = "bad_request"
+	// CodeUnsupportedVersion means that the client speaks a protocol version
+	// the server does not support. The server closes the connection after
+	// sending it.
+	CodeUnsupportedVersion = "unsupported_version"
+	// CodeInvalidRole means that a game was requested with an unknown role.
+	CodeInvalidRole = "invalid_role"
+	// CodeInvalidSettings means that a game was requested with settings
+	// that do not make up a game, e.g. a wrong digit count.
+	CodeInvalidSettings = "invalid_settings"
+	// CodeUnknownOpponent means that some of the requested opponents are
+	// not in the hub.
+	CodeUnknownOpponent = "unknown_opponent"
+	// CodeInvalidDigits means that a think answer has an invalid digit
+	// count. The server keeps waiting for a valid answer.
+	CodeInvalidDigits = "invalid_digits"
+	// CodeInvalidGuess means that a guess answer is not a number of the
+	// requested digit count with distinct digits. The server keeps waiting
+	// for a valid answer.
+	CodeInvalidGuess = "invalid_guess"
+	// CodeInvalidScore means that a try answer holds impossible cows and
+	// bulls. The server keeps waiting for a valid answer.
+	CodeInvalidScore = "invalid_score"
+	// CodeTimeout means that the player did not answer in time.
+	CodeTimeout = "timeout"
+	// CodeInvalidAccount means that a username or password does not meet
+	// the rules, or that accounts are not supported by the server.
+	CodeInvalidAccount = "invalid_account"
+	// CodeUsernameTaken means that an account with the username exists.
+	CodeUsernameTaken = "username_taken"
+	// CodeAuthFailed means that the credentials are wrong, or that the
+	// account is already in use by another connection.
+	CodeAuthFailed = "auth_failed"
+	// CodeUnknownTournament means that there is no tournament with the
+	// requested ID.
+	CodeUnknownTournament = "unknown_tournament"
+	// CodeNotAllowed means that the player may not do what it asked for,
+	// e.g. start a tournament organised by someone else.
+	CodeNotAllowed = "not_allowed"
+	// CodeRateLimited means that the player sent too many messages of a
+	// kind in a short time. Unless it is about chat, the server closes the
+	// connection after sending it.
+	CodeRateLimited = "rate_limited"
+	// CodeTooLarge means that a message carried more data than the server
+	// accepts. The server closes the connection after sending it.
+	CodeTooLarge = "too_large"
+	// CodeTooManyGames means that the player, or the server, plays as many
+	// games at once as allowed.
+	CodeTooManyGames = "too_many_games"
+	// CodeTooManyTournaments means that the server holds as many
+	// tournaments at once as allowed.
+	CodeTooManyTournaments = "too_many_tournaments"
+	// CodeShuttingDown means that the server is shutting down and starts no
+	// more games.
+	CodeShuttingDown = "shutting_down"
+	// CodeKicked means that an admin disconnected the player. The server
+	// closes the connection after sending it.
+	CodeKicked = "kicked"
+)
+
+// Codes lists all error codes.
+var Codes = []string{
+	CodeBadRequest,
+	CodeUnsupportedVersion,
+	CodeInvalidRole,
+	CodeInvalidSettings,
+	CodeUnknownOpponent,
+	CodeInvalidDigits,
+	CodeInvalidGuess,
+	CodeInvalidScore,
+	CodeTimeout,
+	CodeInvalidAccount,
+	CodeUsernameTaken,
+	CodeAuthFailed,
+	CodeUnknownTournament,
+	CodeNotAllowed,
+	CodeRateLimited,
+	CodeTooLarge,
+	CodeTooManyGames,
+	CodeTooManyTournaments,
+	CodeShuttingDown,
+	CodeKicked,
+}
+
+// Error is sent by the server when it rejects a message.
+type Error struct {
+	Code    string `json:"code" doc:"Machine-readable error code." enum:"bad_request,unsupported_version,invalid_role,invalid_settings,unknown_opponent,invalid_digits,invalid_guess,invalid_score,timeout,invalid_account,username_taken,auth_failed,unknown_tournament,not_allowed,rate_limited,too_large,too_many_games,too_many_tournaments,shutting_down,kicked"`
+	Message string `json:"message" doc:"Human-readable description of the error."`
+	Kind    string `json:"kind,omitempty" doc:"Kind of the message that caused the error, if any."`
+}
+
+// Errorf creates an error with code and formatted message.
+func Errorf(code, format string, args ...interface{}) *Error {
+	return &Error{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Encode returns the message data for payload.
+// A nil payload results in empty data.
+func Encode(payload interface{}) (string, error) {
+	if payload == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Decode stores the payload held by data in the value pointed to by v.
+// Empty data leaves v untouched.
+func Decode(data string, v interface{}) error {
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), v)
+}
+
+// Negotiate returns the protocol version to be used with a client that
+// speaks at most version v. Zero stands for a client that did not specify
+// a version, which is treated as version 1.
+func Negotiate(v int) (int, error) {
+	if v == 0 {
+		v = 1
+	}
+	if v < MinVersion {
+		return 0, Errorf(CodeUnsupportedVersion, "version %d is not supported, minimum is %d", v, MinVersion)
+	}
+	if v > Version {
+		return Version, nil
+	}
+	return v, nil
+}
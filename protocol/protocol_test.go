@@ -0,0 +1,85 @@
+package protocol_test
+
+import (
+	"reflect"
+	"strings"
+
+	. "github.com/Bo0mer/cowbull/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Protocol", func() {
+
+	Describe("Encode", func() {
+		It("should encode the payload as JSON", func() {
+			data, err := Encode(CowsBulls{Number: "1234", Cows: 1, Bulls: 2})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(data).Should(Equal(`{"number":"1234","cows":1,"bulls":2}`))
+		})
+
+		It("should omit the number of a try answer", func() {
+			data, err := Encode(CowsBulls{Cows: 1, Bulls: 2})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(data).Should(Equal(`{"cows":1,"bulls":2}`))
+		})
+
+		It("should encode nil as empty data", func() {
+			data, err := Encode(nil)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(data).Should(BeEmpty())
+		})
+	})
+
+	Describe("Decode", func() {
+		It("should decode the payload", func() {
+			var n Number
+			Ω(Decode(`{"number":"4201"}`, &n)).Should(Succeed())
+			Ω(n.Number).Should(Equal("4201"))
+		})
+
+		It("should leave the value untouched on empty data", func() {
+			c := Connect{Version: 7}
+			Ω(Decode("", &c)).Should(Succeed())
+			Ω(c.Version).Should(Equal(7))
+		})
+
+		It("should fail on malformed data", func() {
+			var d Digits
+			Ω(Decode(`{"digits":"four"}`, &d)).ShouldNot(Succeed())
+		})
+	})
+
+	Describe("Negotiate", func() {
+		DescribeTable("supported versions",
+			func(requested, expected int) {
+				v, err := Negotiate(requested)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(v).Should(Equal(expected))
+			},
+			Entry("unspecified", 0, 1),
+			Entry("current", Version, Version),
+			Entry("newer than the server", Version+1, Version))
+
+		It("should reject versions older than the minimum", func() {
+			_, err := Negotiate(-1)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.(*Error).Code).Should(Equal(CodeUnsupportedVersion))
+		})
+	})
+
+	Describe("Error", func() {
+		It("should include the code in its message", func() {
+			err := Errorf(CodeBadRequest, "bad %s", "input")
+			Ω(err.Error()).Should(Equal("bad_request: bad input"))
+		})
+
+		It("should list every code in the schema of its code field", func() {
+			field, ok := reflect.TypeOf(Error{}).FieldByName("Code")
+			Ω(ok).Should(BeTrue())
+			Ω(strings.Split(field.Tag.Get("enum"), ",")).Should(Equal(Codes))
+		})
+	})
+})
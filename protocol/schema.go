@@ -0,0 +1,252 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type schema map[string]interface{}
+
+// Schema returns a JSON Schema describing every message of the protocol.
+func Schema() ([]byte, error) {
+	defs := make(schema)
+	var server, client []interface{}
+	for _, spec := range Specs {
+		if spec.Server != nil {
+			server = append(server, envelope(spec.Kind, typeSchema(reflect.TypeOf(spec.Server), defs)))
+		}
+		if spec.Client != nil {
+			client = append(client, envelope(spec.Kind, typeSchema(reflect.TypeOf(spec.Client), defs)))
+		}
+	}
+	defs["ServerMessage"] = schema{
+		"description": "A message sent by the server.",
+		"oneOf":       server,
+	}
+	defs["ClientMessage"] = schema{
+		"description": "A message sent by a client.",
+		"oneOf":       client,
+	}
+
+	root := schema{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     fmt.Sprintf("https://github.com/Bo0mer/cowbull/protocol/v%d", Version),
+		"title":   fmt.Sprintf("cowbull protocol v%d", Version),
+		"$defs":   defs,
+		"oneOf": []interface{}{
+			schema{"$ref": "#/$defs/ServerMessage"},
+			schema{"$ref": "#/$defs/ClientMessage"},
+		},
+	}
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func envelope(kind string, data schema) schema {
+	if data["$ref"] == "#/$defs/Empty" {
+		return schema{
+			"type": "object",
+			"properties": schema{
+				"name": schema{"const": kind},
+				"data": schema{"const": ""},
+			},
+			"required": []string{"name", "data"},
+		}
+	}
+	return schema{
+		"type": "object",
+		"properties": schema{
+			"name": schema{"const": kind},
+			"data": schema{
+				"type":             "string",
+				"contentMediaType": "application/json",
+				"contentSchema":    data,
+			},
+		},
+		"required": []string{"name", "data"},
+	}
+}
+
+// typeSchema returns the schema of t, registering the definitions of all
+// structs it refers to in defs.
+func typeSchema(t reflect.Type, defs schema) schema {
+	switch t.Kind() {
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return schema{"type": "array", "items": typeSchema(t.Elem(), defs)}
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": typeSchema(t.Elem(), defs)}
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), defs)
+	case reflect.Struct:
+		ref := schema{"$ref": "#/$defs/" + t.Name()}
+		if _, ok := defs[t.Name()]; ok {
+			return ref
+		}
+		// Register before descending, so that recursive types terminate.
+		defs[t.Name()] = schema{}
+		properties := make(schema)
+		required := []string{}
+		for _, f := range fields(t) {
+			s := typeSchema(f.typ, defs)
+			if f.doc != "" {
+				s["description"] = f.doc
+			}
+			if len(f.enum) > 0 {
+				s["enum"] = f.enum
+			}
+			properties[f.name] = s
+			if !f.optional {
+				required = append(required, f.name)
+			}
+		}
+		defs[t.Name()] = schema{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}
+		return ref
+	}
+	panic(fmt.Sprintf("protocol: unsupported type %s", t))
+}
+
+type field struct {
+	name     string
+	typ      reflect.Type
+	doc      string
+	enum     []string
+	optional bool
+}
+
+// fields returns the JSON fields of struct t.
+func fields(t reflect.Type) []field {
+	var ret []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		name := tag[0]
+		if name == "" {
+			name = f.Name
+		}
+		ff := field{
+			name: name,
+			typ:  f.Type,
+			doc:  f.Tag.Get("doc"),
+		}
+		if enum := f.Tag.Get("enum"); enum != "" {
+			ff.enum = strings.Split(enum, ",")
+		}
+		for _, opt := range tag[1:] {
+			if opt == "omitempty" {
+				ff.optional = true
+			}
+		}
+		ret = append(ret, ff)
+	}
+	return ret
+}
+
+// WriteDoc writes a Markdown reference of the protocol to w.
+func WriteDoc(w io.Writer) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# cowbull protocol v%d\n\n", Version)
+	buf.WriteString("<!-- Code generated by protocol/gen.go. DO NOT EDIT. -->\n\n")
+	buf.WriteString("Messages are exchanged over the `/websocket` endpoint as JSON objects\n")
+	buf.WriteString("with two fields: `name`, the message kind, and `data`, a string holding\n")
+	buf.WriteString("the JSON encoding of the payload.\n\n")
+	buf.WriteString("```json\n{\"name\": \"guess\", \"data\": \"{\\\"number\\\":\\\"1234\\\"}\"}\n```\n\n")
+	fmt.Fprintf(&buf, "Supported versions: %d to %d.\n\n", MinVersion, Version)
+
+	buf.WriteString("## Messages\n\n")
+	types := make(map[string]reflect.Type)
+	for _, spec := range Specs {
+		fmt.Fprintf(&buf, "### `%s` message\n\n%s\n\n", spec.Kind, spec.Doc)
+		if spec.Server != nil {
+			fmt.Fprintf(&buf, "- Server to client: %s\n", typeName(reflect.TypeOf(spec.Server), types))
+		}
+		if spec.Client != nil {
+			fmt.Fprintf(&buf, "- Client to server: %s\n", typeName(reflect.TypeOf(spec.Client), types))
+		}
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("## Payloads\n\n")
+	var names []string
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&buf, "### %s\n\n", name)
+		fs := fields(types[name])
+		if len(fs) == 0 {
+			buf.WriteString("Empty data, not even a JSON object.\n\n")
+			continue
+		}
+		buf.WriteString("| Field | Type | Required | Description |\n")
+		buf.WriteString("|-------|------|----------|-------------|\n")
+		for _, f := range fs {
+			required := "yes"
+			if f.optional {
+				required = "no"
+			}
+			doc := f.doc
+			if len(f.enum) > 0 {
+				doc = fmt.Sprintf("%s One of: %s.", doc, strings.Join(f.enum, ", "))
+			}
+			fmt.Fprintf(&buf, "| `%s` | %s | %s | %s |\n", f.name, typeName(f.typ, types), required, doc)
+		}
+		buf.WriteString("\n")
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// typeName returns a human-readable name of t, registering the structs it
+// refers to in types.
+func typeName(t reflect.Type, types map[string]reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return "array of " + typeName(t.Elem(), types)
+	case reflect.Ptr:
+		return typeName(t.Elem(), types)
+	case reflect.Struct:
+		if _, ok := types[t.Name()]; !ok {
+			types[t.Name()] = t
+			for _, f := range fields(t) {
+				typeName(f.typ, types)
+			}
+		}
+		return fmt.Sprintf("[%s](#%s)", t.Name(), strings.ToLower(t.Name()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Bool:
+		return "boolean"
+	default:
+		return t.Kind().String()
+	}
+}
@@ -0,0 +1,51 @@
+package protocol_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+
+	. "github.com/Bo0mer/cowbull/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Schema", func() {
+	var schema []byte
+
+	BeforeEach(func() {
+		var err error
+		schema, err = Schema()
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should be valid JSON", func() {
+		var v map[string]interface{}
+		Ω(json.Unmarshal(schema, &v)).Should(Succeed())
+		Ω(v).Should(HaveKey("$defs"))
+	})
+
+	It("should describe every message kind", func() {
+		for _, spec := range Specs {
+			Ω(string(schema)).Should(ContainSubstring(`"const": "%s"`, spec.Kind))
+		}
+	})
+
+	It("should match the generated schema.json", func() {
+		generated, err := os.ReadFile("schema.json")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(generated)).Should(Equal(string(schema)), "run go generate")
+	})
+})
+
+var _ = Describe("WriteDoc", func() {
+	It("should match the generated PROTOCOL.md", func() {
+		var buf bytes.Buffer
+		Ω(WriteDoc(&buf)).Should(Succeed())
+
+		generated, err := os.ReadFile("PROTOCOL.md")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(string(generated)).Should(Equal(buf.String()), "run go generate")
+	})
+})
@@ -0,0 +1,232 @@
+package protocol
+
+// Spec describes a message kind.
+type Spec struct {
+	// Kind is the name of the messages.
+	Kind string
+	// Doc describes when the message is sent.
+	Doc string
+	// Server is the payload of messages of this kind sent by the server.
+	// It is nil if the server never sends them.
+	Server interface{}
+	// Client is the payload of messages of this kind sent by a client.
+	// It is nil if clients never send them.
+	Client interface{}
+}
+
+// Empty is the payload of messages without data.
+type Empty struct{}
+
+// Specs describes all message kinds of the protocol.
+var Specs = []Spec{
+	{
+		Kind: KindConnect,
+		Doc: "Sent by a client to join the hub, after optionally setting its name or logging in. " +
+			"The server replies with the negotiated version and the player's ID, " +
+			"or with an unsupported_version error, after which it closes the connection.",
+		Server: Connect{},
+		Client: Connect{},
+	},
+	{
+		Kind: KindRegister,
+		Doc: "Registers an account and logs the player in to it. " +
+			"The server replies with the account, including the token to log in with later.",
+		Server: Account{},
+		Client: Credentials{},
+	},
+	{
+		Kind: KindLogin,
+		Doc: "Logs the player in to an account, with username and password or with the token. " +
+			"From then on the player has the ID of the account, also across connections. " +
+			"The server replies with the account.",
+		Server: Account{},
+		Client: Credentials{},
+	},
+	{
+		Kind:   KindName,
+		Doc:    "Sets the in-game name of a player not logged in to an account.",
+		Client: Name{},
+	},
+	{
+		Kind:   KindPlayers,
+		Doc:    "Announces all players in the hub. Sent whenever a player joins or leaves.",
+		Server: []PlayerEntry{},
+	},
+	{
+		Kind:   KindPlay,
+		Doc:    "Requests a new game.",
+		Client: GameSettings{},
+	},
+	{
+		Kind: KindThink,
+		Doc: "Asks the player to think of a number. " +
+			"The client answers with the digit count of the number.",
+		Server: Empty{},
+		Client: Digits{},
+	},
+	{
+		Kind: KindGuess,
+		Doc: "Asks the player to guess the number. " +
+			"The client answers with its guess.",
+		Server: Digits{},
+		Client: Number{},
+	},
+	{
+		Kind: KindTry,
+		Doc: "Asks the thinker to score a guess. " +
+			"The client answers with the cows and bulls of the guess.",
+		Server: Number{},
+		Client: CowsBulls{},
+	},
+	{
+		Kind:   KindTell,
+		Doc:    "Tells a guesser the score of a guess.",
+		Server: CowsBulls{},
+	},
+	{
+		Kind: KindForfeit,
+		Doc: "Gives up all games the player takes part in. " +
+			"The games end with a forfeit outcome.",
+		Client: Empty{},
+	},
+	{
+		Kind: KindGameOver,
+		Doc: "Tells every participant of a game how it ended, " +
+			"no matter whether the number was guessed or not.",
+		Server: GameOver{},
+	},
+	{
+		Kind: KindStats,
+		Doc: "Asks for the ratings of a player, computed from finished games. " +
+			"The server replies with one rating per role and digit count the player has played.",
+		Server: Stats{},
+		Client: Stats{},
+	},
+	{
+		Kind: KindTournament,
+		Doc: "Creates a tournament organised by the player, which is not registered to play in it. " +
+			"The server replies with the tournament, and sends it again to the organiser and " +
+			"every registered player whenever it changes: as players join, matches finish and rounds advance.",
+		Server: Tournament{},
+		Client: TournamentSettings{},
+	},
+	{
+		Kind: KindJoin,
+		Doc: "Registers the player to play in a tournament. " +
+			"The server replies with the tournament.",
+		Client: TournamentID{},
+	},
+	{
+		Kind: KindStart,
+		Doc: "Starts a tournament, closing its registration. Only the organiser may start it. " +
+			"From then on the server pairs players round by round, and starts the games of every " +
+			"match on its own: first with A thinking and B guessing, then the other way round.",
+		Client: TournamentID{},
+	},
+	{
+		Kind: KindQueue,
+		Doc: "Puts the player in the matchmaking queue, replacing any earlier request. " +
+			"Players must connect first, or are refused with a not_allowed error. The server replies with the request, and starts a game as soon as a player asking " +
+			"for the other role, the same digit count and a rating within both bands is queued. " +
+			"Thinkers must think of a number of the requested digit count. If the server falls " +
+			"back to the computer, a player waiting long enough plays against it instead.",
+		Server: Queue{},
+		Client: Queue{},
+	},
+	{
+		Kind:   KindDequeue,
+		Doc:    "Takes the player out of the matchmaking queue.",
+		Client: Empty{},
+	},
+	{
+		Kind:   KindMatched,
+		Doc:    "Tells a queued player who its opponent is, right before their game starts.",
+		Server: Matched{},
+	},
+	{
+		Kind: KindDuel,
+		Doc: "Announces a duel the player takes part in, requested with a play message of mode duel. " +
+			"Both duelists are then asked to think, and in every round both are asked to guess and " +
+			"to score the guess of the other.",
+		Server: Duel{},
+	},
+	{
+		Kind: KindDuelOver,
+		Doc: "Tells a duelist how a duel ended. It is sent instead of gameover. " +
+			"The first duelist to guess wins; if both guess in the same round, the duel is drawn. " +
+			"A duelist who fails, does not answer in time or gives up loses.",
+		Server: DuelOver{},
+	},
+	{
+		Kind: KindRace,
+		Doc: "Tells the players of a race, requested with a play message of mode race, how it goes: " +
+			"once the thinker has thought, after every scored guess, and when it is over. " +
+			"Each guesser is asked to guess and told the scores of its own guesses only, and is sent " +
+			"gameover once its game is over. The thinker scores the guesses of all, one at a time. " +
+			"Guessers who can no longer win are stopped.",
+		Server: Race{},
+	},
+	{
+		Kind: KindStrike,
+		Doc: "Tells the guessers of a game with several guessers that one of them failed to guess, " +
+			"or to be told a score, by disconnecting, not answering in time or giving up. The guesser " +
+			"is skipped, and once it has failed as often as the server allows, dropped, in which case " +
+			"it is told too. The game goes on until no guessers remain.",
+		Server: Strike{},
+	},
+	{
+		Kind: KindRejoin,
+		Doc: "Tells the guessers of a game with several guessers that a dropped guesser rejoined it, " +
+			"by connecting again under the same ID, if the server allows it. The guesser is told too, " +
+			"and guesses last in every round.",
+		Server: PlayerEntry{},
+	},
+	{
+		Kind: KindProposals,
+		Doc: "Tells the members of a team, in a play of mode team, what each of them proposed when " +
+			"asked to guess. Then, with decision vote, every member is asked to guess again, which is " +
+			"its vote, and the guess with the most votes is the team guess, ties going to the one " +
+			"proposed first. With decision captain, only the captain is asked again and picks the " +
+			"team guess. Every member is told its score. A team of one is never sent proposals.",
+		Server: Proposals{},
+	},
+	{
+		Kind: KindTeamChat,
+		Doc: "Sends a message to the members of the team of the player, while their game is on. " +
+			"The server relays it to every member, including the sender.",
+		Server: TeamChat{},
+		Client: TeamChat{},
+	},
+	{
+		Kind: KindChat,
+		Doc: "Sends a chat message to the lobby, a room the player is a member of, or the game the " +
+			"player takes part in. The server relays it to everyone in scope, including the sender. " +
+			"Players are sent the last messages of the lobby when they join the hub, and those of a room " +
+			"when they join it, marked as history. Messages are rejected with rate_limited when sent too " +
+			"often, and game messages that contain the secret of a thinker, once it is known, with not_allowed.",
+		Server: Chat{},
+		Client: Chat{},
+	},
+	{
+		Kind: KindRoom,
+		Doc: "Joins or leaves a chat room, created as soon as someone joins it. The server replies to a " +
+			"join with the members of the room, followed by its last messages. Players leave all rooms " +
+			"when they leave the hub.",
+		Server: Room{},
+		Client: Room{},
+	},
+	{
+		Kind: KindShutdown,
+		Doc: "Tells every player that the server is shutting down. From then on, new games are refused " +
+			"with shutting_down, while the games already on may go on until the deadline. Then they are " +
+			"over with outcome abort, and the connection is closed.",
+		Server: Shutdown{},
+	},
+	{
+		Kind: KindError,
+		Doc: "Reports a rejected message or a failed action. " +
+			"Errors about invalid answers to think, guess and try leave the " +
+			"request open, so the client may answer again.",
+		Server: Error{},
+	},
+}
@@ -0,0 +1,113 @@
+package cowbull
+
+import (
+	"time"
+
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+// raceObserver may be implemented by a Player that wants to know how a race
+// it takes part in goes.
+type raceObserver interface {
+	AnnounceRace(protocol.Race) error
+}
+
+// NewRace creates a race requested by a player with settings. A thinker
+// races the opponents of the settings on its number, while a guesser races
+// them on the number of the computer.
+// If the settings do not make up a race, the returned error is a
+// *protocol.Error describing why.
+func (h *Hub) NewRace(from Player, settings GameSettings) (*game.Race, error) {
+	switch settings.Rank {
+	case "", game.RankByTurns, game.RankByTime:
+	default:
+		return nil, protocol.Errorf(protocol.CodeInvalidSettings, "unknown rank %q", settings.Rank)
+	}
+
+	opponents, err := h.opponents(settings.Opponents)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range opponents {
+		if p.ID() == from.ID() {
+			return nil, protocol.Errorf(protocol.CodeInvalidSettings, "a player cannot race itself")
+		}
+	}
+
+	var thinker game.Thinker
+	var guessers []game.Guesser
+	switch settings.Role {
+	case RoleThinker:
+		if len(opponents) < 2 {
+			return nil, protocol.Errorf(protocol.CodeInvalidSettings, "a race needs at least two guessers")
+		}
+		thinker = from
+	case RoleGuesser:
+		if !settings.AI {
+			return nil, protocol.Errorf(protocol.CodeInvalidSettings, "guessers race on the number of the computer")
+		}
+		if settings.Digits < 1 || settings.Digits > 10 {
+			return nil, protocol.Errorf(protocol.CodeInvalidSettings, "invalid digit count %d", settings.Digits)
+		}
+		if len(opponents) == 0 {
+			return nil, protocol.Errorf(protocol.CodeInvalidSettings, "a race needs at least two guessers")
+		}
+		thinker = LocalThinker(settings.Digits)
+		guessers = append(guessers, from)
+	default:
+		return nil, protocol.Errorf(protocol.CodeInvalidRole, "invalid role: %s", settings.Role)
+	}
+	for _, p := range opponents {
+		guessers = append(guessers, p)
+	}
+	return game.NewRace(thinker, guessers, settings.Rank), nil
+}
+
+// announceRace tells the players of race how it goes, as of r.
+func (h *Hub) announceRace(race *game.Race, r game.RaceResult) {
+	guessers := race.Guessers()
+	msg := protocol.Race{
+		ID:        r.ID,
+		Rank:      r.Rank,
+		Digits:    r.Digits,
+		Thinker:   participants(race.Thinker())[0],
+		Over:      r.Over,
+		Outcome:   r.Outcome,
+		Reason:    r.Reason,
+		Secret:    r.Secret,
+		Standings: make([]protocol.RaceStanding, len(r.Standings)),
+	}
+	for i, s := range r.Standings {
+		msg.Standings[i] = protocol.RaceStanding{
+			Place:  s.Place,
+			Player: participants(guessers[s.Guesser])[0],
+			Turns:  s.Turns,
+			Millis: int64(s.Time / time.Millisecond),
+			Solved: s.Solved,
+			Done:   s.Done,
+		}
+	}
+
+	players := []interface{}{race.Thinker()}
+	for _, g := range guessers {
+		players = append(players, g)
+	}
+	for _, p := range players {
+		o, ok := p.(raceObserver)
+		if !ok {
+			continue
+		}
+		if err := o.AnnounceRace(msg); err != nil {
+			h.log.Warn("error announcing race", "player_id", p.(Player).ID(), "err", err)
+		}
+	}
+}
+
+// NewRaceRecord describes the game of guesser i of a race played with
+// settings and finished just now.
+func NewRaceRecord(race *game.Race, i int, settings GameSettings, started time.Time) GameRecord {
+	r := NewGameRecord(race.Games()[i], settings, started)
+	r.Thinker = participants(race.Thinker())[0]
+	return r
+}
@@ -0,0 +1,78 @@
+package cowbull_test
+
+import (
+	"log/slog"
+	"time"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewRace", func() {
+	var hub *Hub
+	var alice, bob *cowbullfakes.FakePlayer
+
+	BeforeEach(func() {
+		hub = NewHub(new(cowbullfakes.FakeGamer), slog.New(slog.NewTextHandler(GinkgoWriter, nil)))
+		alice = computerPlayer("alice", 3)
+		bob = computerPlayer("bob", 3)
+		hub.Add(alice)
+		hub.Add(bob)
+	})
+
+	It("should race guessers on the number of the computer", func() {
+		settings := GameSettings{Mode: protocol.ModeRace, Role: RoleGuesser, Digits: 3, AI: true, Opponents: []string{"bob"}}
+		race, err := hub.NewRace(alice, settings)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(race.Guessers()).To(Equal([]game.Guesser{alice, bob}))
+
+		Expect(race.Play()).To(Succeed())
+		r := race.Result()
+		Expect(r.Rank).To(Equal(game.RankByTurns))
+		Expect(r.Digits).To(Equal(3))
+		Expect(r.Winner).NotTo(Equal(game.NoWinner))
+		Expect(alice.ThinkCallCount()).To(BeZero())
+
+		record := NewRaceRecord(race, 1, settings, time.Now())
+		Expect(record.Thinker).To(Equal(PlayerEntry{ID: AIPlayer, Name: "computer"}))
+		Expect(record.Guessers).To(Equal([]PlayerEntry{{ID: "bob", Name: "bob"}}))
+		Expect(record.Result).To(Equal(r.Games[1]))
+	})
+
+	It("should race opponents on the number of a thinker", func() {
+		carol := computerPlayer("carol", 3)
+		hub.Add(carol)
+		settings := GameSettings{Mode: protocol.ModeRace, Role: RoleThinker, Rank: game.RankByTime, Opponents: []string{"alice", "bob"}}
+		race, err := hub.NewRace(carol, settings)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(race.Play()).To(Succeed())
+		Expect(race.Result().Rank).To(Equal(game.RankByTime))
+		Expect(carol.ThinkCallCount()).To(Equal(1))
+		Expect(NewRaceRecord(race, 0, settings, time.Now()).Thinker).To(Equal(PlayerEntry{ID: "carol", Name: "carol"}))
+	})
+
+	It("should reject invalid settings", func() {
+		_, err := hub.NewRace(alice, GameSettings{Role: RoleThinker, Opponents: []string{"bob"}})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewRace(alice, GameSettings{Role: RoleThinker, Opponents: []string{"alice", "bob"}})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewRace(alice, GameSettings{Role: RoleGuesser, Opponents: []string{"bob"}})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewRace(alice, GameSettings{Role: RoleGuesser, AI: true, Digits: 3})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewRace(alice, GameSettings{Role: RoleGuesser, AI: true, Digits: 11, Opponents: []string{"bob"}})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewRace(alice, GameSettings{Role: RoleGuesser, AI: true, Digits: 3, Rank: "luck", Opponents: []string{"bob"}})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewRace(alice, GameSettings{Role: "referee", Opponents: []string{"bob"}})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidRole))
+		_, err = hub.NewRace(alice, GameSettings{Role: RoleThinker, Opponents: []string{"bob", "carol"}})
+		Expect(code(err)).To(Equal(protocol.CodeUnknownOpponent))
+	})
+})
@@ -0,0 +1,177 @@
+package cowbull
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+// Rating describes how well a player does in a role with numbers of a digit
+// count.
+type Rating = protocol.Rating
+
+const (
+	// initialRating is the rating of a player yet to finish a game.
+	initialRating = 1500
+	// ratingK is the largest change of a rating a game may cause.
+	ratingK = 32
+)
+
+// parTurns are the mean turns a guesser picking any possible number at
+// random needs, by digit count, as measured by cowbull bench.
+var parTurns = [11]float64{0, 5.5, 5.3, 5.2, 5.5, 6, 6.6, 7.2, 7.8, 8.4, 9}
+
+// Leaderboard ranks the players of a role and digit count.
+type Leaderboard struct {
+	Role    string   `json:"role"`
+	Digits  int      `json:"digits"`
+	Ratings []Rating `json:"ratings"`
+}
+
+type ratingKey struct {
+	id     string
+	role   string
+	digits int
+}
+
+// Ratings computes Elo ratings of players from finished games, separately
+// for each role and digit count. It is safe for concurrent use.
+//
+// Each guesser of a game is matched against the thinker. The guesser scores
+// 1 for guessing in a single turn, half for guessing in parTurns and nothing
+// for twice as many; the thinker scores the rest. Games that end before the
+// number is guessed count as played, but do not change ratings, as their
+// records do not tell whose fault it was. The computer plays at the initial
+// rating, and is not rated itself.
+type Ratings struct {
+	mu      sync.Mutex
+	ratings map[ratingKey]*Rating
+}
+
+// NewRatings creates ratings with no games.
+func NewRatings() *Ratings {
+	return &Ratings{ratings: make(map[ratingKey]*Rating)}
+}
+
+// Add rates the players of a finished game. Games should be added in the
+// order they finished.
+func (r *Ratings) Add(g GameRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	digits := g.Result.Digits
+	if digits < 1 || digits >= len(parTurns) || len(g.Guessers) == 0 {
+		return
+	}
+	turns := len(g.Result.Moves)
+	solved := g.Result.Outcome == game.OutcomeWin
+
+	thinker := r.rating(g.Thinker, RoleThinker, digits)
+	thinker.Games++
+	guessers := make([]*Rating, len(g.Guessers))
+	for i, p := range g.Guessers {
+		guessers[i] = r.rating(p, RoleGuesser, digits)
+		guessers[i].Games++
+	}
+	if !solved {
+		return
+	}
+
+	score := guesserScore(turns, digits)
+	var thinkerDelta float64
+	for _, guesser := range guessers {
+		expected := 1 / (1 + math.Pow(10, (thinker.Rating-guesser.Rating)/400))
+		delta := ratingK * (score - expected)
+		guesser.Rating += delta
+		thinkerDelta -= delta
+		solve(guesser, g.ID, turns, turns < guesser.BestTurns)
+	}
+	thinker.Rating += thinkerDelta / float64(len(guessers))
+	solve(thinker, g.ID, turns, turns > thinker.BestTurns)
+}
+
+// rating returns the rating of a player, creating it if needed. The rating
+// of the computer is a new one each time, and is not kept.
+func (r *Ratings) rating(p PlayerEntry, role string, digits int) *Rating {
+	if p.ID == AIPlayer {
+		return &Rating{ID: p.ID, Role: role, Digits: digits, Rating: initialRating}
+	}
+	k := ratingKey{id: p.ID, role: role, digits: digits}
+	rating, ok := r.ratings[k]
+	if !ok {
+		rating = &Rating{ID: p.ID, Role: role, Digits: digits, Rating: initialRating}
+		r.ratings[k] = rating
+	}
+	rating.Name = p.Name
+	return rating
+}
+
+// solve accounts for a game solved in turns. If the game is better than the
+// best one so far, it becomes the best.
+func solve(r *Rating, id string, turns int, better bool) {
+	r.AverageTurns = (r.AverageTurns*float64(r.Solved) + float64(turns)) / float64(r.Solved+1)
+	r.Solved++
+	if r.BestGame == "" || better {
+		r.BestGame, r.BestTurns = id, turns
+	}
+}
+
+// guesserScore returns the score of a guesser that needed turns to guess an
+// n-digit number.
+func guesserScore(turns, n int) float64 {
+	s := 1 - float64(turns-1)/(2*(parTurns[n]-1))
+	return math.Max(0, math.Min(1, s))
+}
+
+// Leaderboard returns the ratings of a role and digit count, best first.
+func (r *Ratings) Leaderboard(role string, digits int) Leaderboard {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	l := Leaderboard{Role: role, Digits: digits, Ratings: []Rating{}}
+	for k, rating := range r.ratings {
+		if k.role == role && k.digits == digits {
+			l.Ratings = append(l.Ratings, *rating)
+		}
+	}
+	sort.Slice(l.Ratings, func(i, j int) bool {
+		if l.Ratings[i].Rating != l.Ratings[j].Rating {
+			return l.Ratings[i].Rating > l.Ratings[j].Rating
+		}
+		return l.Ratings[i].ID < l.Ratings[j].ID
+	})
+	return l
+}
+
+// Player returns the ratings of the player with id, by role and then digit
+// count.
+func (r *Ratings) Player(id string) []Rating {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ratings []Rating
+	for k, rating := range r.ratings {
+		if k.id == id {
+			ratings = append(ratings, *rating)
+		}
+	}
+	sort.Slice(ratings, func(i, j int) bool {
+		if ratings[i].Role != ratings[j].Role {
+			return ratings[i].Role < ratings[j].Role
+		}
+		return ratings[i].Digits < ratings[j].Digits
+	})
+	return ratings
+}
+
+// Rating returns the rating of the player with id in a role with numbers of
+// a digit count, or the initial one if it has not played such games.
+func (r *Ratings) Rating(id, role string, digits int) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rating, ok := r.ratings[ratingKey{id: id, role: role, digits: digits}]; ok {
+		return rating.Rating
+	}
+	return initialRating
+}
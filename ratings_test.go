@@ -0,0 +1,131 @@
+package cowbull_test
+
+import (
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/game"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ratings", func() {
+	var ratings *Ratings
+
+	alice := PlayerEntry{ID: "alice-id", Name: "alice"}
+	bob := PlayerEntry{ID: "bob-id", Name: "bob"}
+	carol := PlayerEntry{ID: "carol-id", Name: "carol"}
+
+	// solved returns a record of an n-digit game guessed in turns.
+	solved := func(id string, thinker PlayerEntry, guessers []PlayerEntry, n, turns int) GameRecord {
+		moves := make([]game.Move, turns)
+		moves[turns-1].Bulls = n
+		return GameRecord{
+			ID:       id,
+			Thinker:  thinker,
+			Guessers: guessers,
+			Result:   game.Result{Outcome: game.OutcomeWin, Digits: n, Moves: moves},
+		}
+	}
+
+	BeforeEach(func() {
+		ratings = NewRatings()
+	})
+
+	It("should favour guessers needing fewer turns than par", func() {
+		ratings.Add(solved("g1", alice, []PlayerEntry{bob}, 4, 3))
+
+		guesser := ratings.Player("bob-id")
+		Ω(guesser).Should(HaveLen(1))
+		Ω(guesser[0].Rating).Should(BeNumerically("~", 1500+32*(1-2.0/9-0.5)))
+		guesser[0].Rating = 0
+		Ω(guesser[0]).Should(Equal(Rating{
+			ID: "bob-id", Name: "bob", Role: RoleGuesser, Digits: 4,
+			Games: 1, Solved: 1, AverageTurns: 3, BestGame: "g1", BestTurns: 3,
+		}))
+		thinker := ratings.Player("alice-id")
+		Ω(thinker).Should(HaveLen(1))
+		Ω(thinker[0].Role).Should(Equal(RoleThinker))
+		Ω(thinker[0].Rating).Should(BeNumerically("~", 1500-32*(1-2.0/9-0.5)))
+	})
+
+	It("should favour thinkers whose numbers take long to guess", func() {
+		ratings.Add(solved("g1", alice, []PlayerEntry{bob}, 4, 12))
+
+		Ω(ratings.Player("alice-id")[0].Rating).Should(Equal(1516.0))
+		Ω(ratings.Player("bob-id")[0].Rating).Should(Equal(1484.0))
+	})
+
+	It("should keep ratings per role and digit count", func() {
+		ratings.Add(solved("g1", alice, []PlayerEntry{bob}, 4, 5))
+		ratings.Add(solved("g2", bob, []PlayerEntry{alice}, 3, 5))
+		ratings.Add(solved("g3", alice, []PlayerEntry{bob}, 3, 5))
+
+		var keys []string
+		for _, r := range ratings.Player("bob-id") {
+			keys = append(keys, r.Role+":"+string(rune('0'+r.Digits)))
+		}
+		Ω(keys).Should(Equal([]string{"guesser:3", "guesser:4", "thinker:3"}))
+	})
+
+	It("should track average turns and the best game", func() {
+		ratings.Add(solved("g1", alice, []PlayerEntry{bob}, 4, 6))
+		ratings.Add(solved("g2", alice, []PlayerEntry{bob}, 4, 4))
+		ratings.Add(solved("g3", alice, []PlayerEntry{bob}, 4, 8))
+
+		guesser := ratings.Player("bob-id")[0]
+		Ω(guesser.Games).Should(Equal(3))
+		Ω(guesser.AverageTurns).Should(Equal(6.0))
+		Ω(guesser.BestGame).Should(Equal("g2"))
+		Ω(guesser.BestTurns).Should(Equal(4))
+
+		thinker := ratings.Player("alice-id")[0]
+		Ω(thinker.BestGame).Should(Equal("g3"))
+		Ω(thinker.BestTurns).Should(Equal(8))
+	})
+
+	It("should count unsolved games without rating them", func() {
+		r := solved("g1", alice, []PlayerEntry{bob}, 4, 2)
+		r.Result.Outcome = game.OutcomeForfeit
+		ratings.Add(r)
+
+		guesser := ratings.Player("bob-id")[0]
+		Ω(guesser.Games).Should(Equal(1))
+		Ω(guesser.Solved).Should(BeZero())
+		Ω(guesser.Rating).Should(Equal(1500.0))
+		Ω(guesser.BestGame).Should(BeEmpty())
+	})
+
+	It("should rate every guesser of a multi-guesser game", func() {
+		ratings.Add(solved("g1", alice, []PlayerEntry{bob, carol}, 4, 3))
+
+		Ω(ratings.Player("bob-id")[0].Rating).Should(BeNumerically(">", 1500))
+		Ω(ratings.Player("carol-id")[0].Rating).Should(Equal(ratings.Player("bob-id")[0].Rating))
+		Ω(ratings.Player("alice-id")[0].Rating).Should(BeNumerically("~", 3000-ratings.Player("bob-id")[0].Rating))
+	})
+
+	It("should rate players against the computer without rating it", func() {
+		computer := PlayerEntry{ID: AIPlayer, Name: "computer"}
+		ratings.Add(solved("g1", computer, []PlayerEntry{bob}, 4, 12))
+		ratings.Add(solved("g2", computer, []PlayerEntry{alice}, 4, 12))
+
+		Ω(ratings.Player("bob-id")[0].Rating).Should(Equal(1484.0))
+		Ω(ratings.Player("alice-id")[0].Rating).Should(Equal(1484.0))
+		Ω(ratings.Player(AIPlayer)).Should(BeEmpty())
+		Ω(ratings.Leaderboard(RoleThinker, 4).Ratings).Should(BeEmpty())
+	})
+
+	It("should rank the players of a leaderboard", func() {
+		ratings.Add(solved("g1", alice, []PlayerEntry{bob}, 4, 2))
+		ratings.Add(solved("g2", alice, []PlayerEntry{carol}, 4, 9))
+		ratings.Add(solved("g3", carol, []PlayerEntry{alice}, 3, 2))
+
+		l := ratings.Leaderboard(RoleGuesser, 4)
+		Ω(l.Role).Should(Equal(RoleGuesser))
+		Ω(l.Digits).Should(Equal(4))
+		Ω(l.Ratings).Should(HaveLen(2))
+		Ω(l.Ratings[0].ID).Should(Equal("bob-id"))
+		Ω(l.Ratings[1].ID).Should(Equal("carol-id"))
+
+		Ω(ratings.Leaderboard(RoleThinker, 5).Ratings).Should(BeEmpty())
+	})
+})
@@ -0,0 +1,94 @@
+package cowbull
+
+import (
+	"sync"
+
+	"github.com/Bo0mer/cowbull/game"
+)
+
+// maxAnalysedDigits is the most digits of a game worth analysing. Beyond it
+// there are too many numbers to search.
+const maxAnalysedDigits = 5
+
+// replayCacheSize is how many replays a replayCache keeps.
+const replayCacheSize = 256
+
+// Replay is a finished game with each of its turns analysed.
+type Replay struct {
+	GameRecord
+	Turns []Turn `json:"turns"`
+}
+
+// Turn is a move of a replayed game.
+type Turn struct {
+	game.Move
+	// Possible is the count of numbers that were still possible before the
+	// guess, judging by the earlier scores.
+	Possible int `json:"possible,omitempty"`
+	// Optimal is what MinMaxStrategy would have guessed instead.
+	Optimal string `json:"optimal,omitempty"`
+}
+
+// NewReplay analyses a finished game the way AIGuesser would have played
+// it. Games with too many digits are not analysed.
+func NewReplay(r GameRecord) Replay {
+	replay := Replay{
+		GameRecord: r,
+		Turns:      make([]Turn, len(r.Result.Moves)),
+	}
+	var g *AIGuesser
+	if r.Result.Digits <= maxAnalysedDigits {
+		g = NewAIGuesser(r.Result.Digits, MinMaxStrategy)
+	}
+	for i, m := range r.Result.Moves {
+		replay.Turns[i].Move = m
+		if g == nil || len(g.candidates) == 0 || len(m.Guess) != r.Result.Digits {
+			continue
+		}
+		replay.Turns[i].Possible = len(g.candidates)
+		replay.Turns[i].Optimal = g.strategy(g.candidates)
+		if g.Tell(m.Guess, m.Cows, m.Bulls) != nil {
+			// The scores contradict each other, so nothing more can be
+			// told about the game.
+			g = nil
+		}
+	}
+	return replay
+}
+
+// replayCache keeps the latest replays by game ID, so that games are not
+// analysed again each time they are served. Recorded games never change, and
+// neither do their replays.
+type replayCache struct {
+	mu      sync.Mutex
+	replays map[string]Replay
+	ids     []string // oldest first
+}
+
+func newReplayCache() *replayCache {
+	return &replayCache{replays: make(map[string]Replay)}
+}
+
+// get returns the replay of the game with id, if it is kept.
+func (c *replayCache) get(id string) (Replay, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	replay, ok := c.replays[id]
+	return replay, ok
+}
+
+// put keeps the replay of the game with id, forgetting the oldest one if the
+// cache is full.
+func (c *replayCache) put(id string, replay Replay) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.replays[id]; ok {
+		return
+	}
+	if len(c.ids) == replayCacheSize {
+		delete(c.replays, c.ids[0])
+		c.ids = c.ids[1:]
+	}
+	c.replays[id] = replay
+	c.ids = append(c.ids, id)
+}
@@ -0,0 +1,62 @@
+package cowbull_test
+
+import (
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/game"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewReplay", func() {
+	record := func(digits int, moves ...game.Move) GameRecord {
+		return GameRecord{
+			ID:     "g1",
+			Result: game.Result{Outcome: game.OutcomeWin, Digits: digits, Moves: moves},
+		}
+	}
+
+	It("should tell how many numbers were possible at each turn", func() {
+		replay := NewReplay(record(2,
+			game.Move{Guess: "12", Cows: 0, Bulls: 0},
+			game.Move{Guess: "34", Cows: 2, Bulls: 0},
+			game.Move{Guess: "43", Cows: 0, Bulls: 2},
+		))
+		Ω(replay.ID).Should(Equal("g1"))
+		Ω(replay.Turns).Should(HaveLen(3))
+		Ω(replay.Turns[0].Move).Should(Equal(game.Move{Guess: "12"}))
+		Ω(replay.Turns[0].Possible).Should(Equal(90))
+		// 56 numbers have neither 1 nor 2, and only 43 remains after 34.
+		Ω(replay.Turns[1].Possible).Should(Equal(56))
+		Ω(replay.Turns[2].Possible).Should(Equal(1))
+		Ω(replay.Turns[2].Optimal).Should(Equal("43"))
+	})
+
+	It("should suggest the minmax guess", func() {
+		replay := NewReplay(record(3, game.Move{Guess: "123", Cows: 0, Bulls: 0}, game.Move{Guess: "456", Cows: 1, Bulls: 0}))
+		candidates := []string{}
+		for _, n := range Numbers(3) {
+			if cows, bulls := Score(n, "123"); cows+bulls == 0 {
+				candidates = append(candidates, n)
+			}
+		}
+		Ω(replay.Turns[1].Optimal).Should(Equal(MinMaxStrategy(candidates)))
+	})
+
+	It("should stop analysing once the scores contradict each other", func() {
+		replay := NewReplay(record(2,
+			game.Move{Guess: "12", Cows: 0, Bulls: 0},
+			game.Move{Guess: "21", Cows: 1, Bulls: 0},
+			game.Move{Guess: "34", Cows: 0, Bulls: 2},
+		))
+		Ω(replay.Turns[1].Possible).Should(Equal(56))
+		Ω(replay.Turns[2].Possible).Should(BeZero())
+		Ω(replay.Turns[2].Guess).Should(Equal("34"))
+	})
+
+	It("should not analyse games with too many digits", func() {
+		replay := NewReplay(record(6, game.Move{Guess: "123456", Bulls: 6}))
+		Ω(replay.Turns[0].Possible).Should(BeZero())
+		Ω(replay.Turns[0].Optimal).Should(BeEmpty())
+	})
+})
@@ -1,21 +1,28 @@
 package cowbull
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
 	"github.com/gorilla/websocket"
 )
 
 // ServerConfig holds configuration for a server.
 type ServerConfig struct {
-	// Path to directory from where to serve content.
+	// Path to directory from where to serve content. Optional; by default
+	// the content built into the binary is served.
 	StaticFilesPath string
 
 	// Log is used to log messages from the server and its clients.
-	Log *log.Logger
+	Log *slog.Logger
 
 	// Upgrader used for upgrading from an HTTP connection to a WebSocket
 	// connection.
@@ -23,23 +30,76 @@ type ServerConfig struct {
 
 	// Hub for connected players.
 	Hub *Hub
+
+	// Store records finished games. Optional.
+	Store Store
+
+	// Accounts registers and authenticates players. Optional; without it
+	// players cannot log in.
+	Accounts *Accounts
+
+	// Ratings rates players as their games finish. Optional; by default
+	// ratings start from scratch.
+	Ratings *Ratings
+
+	// Limits bounds what clients may do. Optional; by default they are
+	// unbounded.
+	Limits *Limits
+
+	// Metrics collects the metrics served at /metrics. Optional; by default
+	// the server collects its own.
+	Metrics *Metrics
+
+	// AdminToken lets admins in to /admin. Optional; without it there is no
+	// admin page.
+	AdminToken string
+
+	// PlayerTimeout is how long players have to answer. Optional; by default
+	// they have 60 seconds.
+	PlayerTimeout time.Duration
+
+	// ClientOptions configure the client of every connection, after the
+	// options the server sets itself. Optional.
+	ClientOptions []ClientOption
 }
 
 // Server implements a cowbull game server.
 type Server struct {
 	mux     http.Handler
 	websock *websocket.Upgrader
-	log     *log.Logger
+	log     *slog.Logger
+
+	hub      *Hub
+	store    Store
+	replays  *replayCache
+	accounts *Accounts
+	ratings  *Ratings
+
+	tournaments *Tournaments
+
+	limits  Limits
+	metrics *Metrics
 
-	hub *Hub
+	mu      sync.Mutex // guards clients and logins
+	clients map[*Client]*RemotePlayer
+	logins  map[string]*Client // by account ID
+
+	started    time.Time
+	adminToken string
+
+	playerTimeout time.Duration
+	clientOpts    []ClientOption
 
 	fs http.Handler
 }
 
+// defaultPlayerTimeout is how long players have to answer by default.
+const defaultPlayerTimeout = 60 * time.Second
+
 // NewServer creates a new server.
 // After once initialized the config object should not be modified.
 func NewServer(cfg *ServerConfig) *Server {
-	fs := http.FileServer(http.Dir(cfg.StaticFilesPath))
+	fs := newAssets(cfg.StaticFilesPath)
 	mux := http.NewServeMux()
 
 	s := &Server{
@@ -48,60 +108,661 @@ func NewServer(cfg *ServerConfig) *Server {
 		log:     cfg.Log,
 		fs:      fs,
 		hub:     cfg.Hub,
+		store:   cfg.Store,
+		replays: newReplayCache(),
+
+		accounts: cfg.Accounts,
+		ratings:  cfg.Ratings,
+		metrics:  cfg.Metrics,
+		clients:  make(map[*Client]*RemotePlayer),
+		logins:   make(map[string]*Client),
+
+		started:    time.Now(),
+		adminToken: cfg.AdminToken,
+
+		playerTimeout: cfg.PlayerTimeout,
+		clientOpts:    cfg.ClientOptions,
+	}
+	if s.playerTimeout <= 0 {
+		s.playerTimeout = defaultPlayerTimeout
+	}
+	if s.ratings == nil {
+		s.ratings = NewRatings()
+	}
+	if cfg.Limits != nil {
+		s.limits = *cfg.Limits
+	}
+	if s.metrics == nil {
+		s.metrics = NewMetrics()
+	}
+	s.metrics.onCollect(func() {
+		s.mu.Lock()
+		clients := len(s.clients)
+		s.mu.Unlock()
+		s.metrics.set(metricClients, float64(clients))
+		if s.hub != nil {
+			s.metrics.set(metricHubPlayers, float64(len(s.hub.getPlayers())))
+		}
+	})
+	record := func(g *game.Game, settings GameSettings, started time.Time) {
+		s.record(NewGameRecord(g, settings, started))
+	}
+	s.tournaments = NewTournaments(cfg.Hub, cfg.Log, record)
+	s.tournaments.Limit(s.limits.Tournaments, defaultTournamentRetention)
+	if cfg.Hub != nil {
+		cfg.Hub.OnGameFinished(record)
+		cfg.Hub.UseMetrics(s.metrics)
+		cfg.Hub.LimitGames(s.limits.Games, s.limits.GamesPerPlayer)
 	}
 
 	mux.Handle("/", s.fs)
 	mux.HandleFunc("/websocket", s.upgrade)
+	mux.HandleFunc("/games/", s.serveGame)
+	mux.HandleFunc("/leaderboard", s.serveLeaderboard)
+	mux.HandleFunc("/tournaments/", s.serveTournaments)
+	mux.Handle("/metrics", s.metrics)
+	mux.HandleFunc("/healthz", s.serveHealth)
+	mux.HandleFunc("/readyz", s.serveReady)
+	mux.HandleFunc("/admin", s.serveAdmin)
+	mux.HandleFunc("/admin/kick", s.serveKick)
+	mux.HandleFunc("/admin/abort", s.serveAbort)
 
 	return s
 }
 
+// Drain makes the server refuse new games and tells the players that the
+// games still on are cancelled at deadline.
+func (s *Server) Drain(deadline time.Time) {
+	s.hub.Drain(deadline)
+}
+
+// Wait waits until no game is on, or until ctx is done.
+func (s *Server) Wait(ctx context.Context) error {
+	return s.hub.Wait(ctx)
+}
+
+// Close cancels the games still on and waits for them to be recorded, or
+// for ctx to be done. Then it closes the connections of all clients,
+// telling them that the server is going away. The HTTP server serving it
+// should be shut down first.
+func (s *Server) Close(ctx context.Context) error {
+	err := s.hub.Close(ctx)
+	s.mu.Lock()
+	clients := make([]*Client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+	for _, c := range clients {
+		if err := c.GoAway(); err != nil {
+			s.log.Warn("error closing client", "client_id", c.ID(), "err", err)
+		}
+	}
+	return err
+}
+
 // ServeHTTP serves HTTP requests.
 func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	s.mux.ServeHTTP(w, req)
 }
 
+// serveGame serves the replay of a recorded game as JSON on
+// GET /games/{id}.
+func (s *Server) serveGame(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(req.URL.Path, "/games/")
+	if s.store == nil || id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, req)
+		return
+	}
+	replay, ok := s.replays.get(id)
+	if !ok {
+		r, err := s.store.Game(id)
+		if err == ErrGameNotFound {
+			http.NotFound(w, req)
+			return
+		}
+		if err != nil {
+			s.log.Error("error looking up game", "record_id", id, "err", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		replay = NewReplay(r)
+		s.replays.put(id, replay)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(replay); err != nil {
+		s.log.Warn("error sending game", "record_id", id, "err", err)
+	}
+}
+
+// serveLeaderboard serves the leaderboard of a role and digit count, given
+// by the role and digits query parameters, on GET /leaderboard. They default
+// to guessers of 4-digit numbers. Browsers asking for HTML are served the
+// leaderboard page instead, which asks for the JSON in turn.
+func (s *Server) serveLeaderboard(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if strings.Contains(req.Header.Get("Accept"), "text/html") {
+		page := req.Clone(req.Context())
+		page.URL.Path = "/leaderboard.html"
+		s.fs.ServeHTTP(w, page)
+		return
+	}
+
+	q := req.URL.Query()
+	role := q.Get("role")
+	if role == "" {
+		role = RoleGuesser
+	}
+	digits := 4
+	if d := q.Get("digits"); d != "" {
+		var err error
+		if digits, err = strconv.Atoi(d); err != nil {
+			http.Error(w, "invalid digits", http.StatusBadRequest)
+			return
+		}
+	}
+	if role != RoleGuesser && role != RoleThinker {
+		http.Error(w, "invalid role", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.ratings.Leaderboard(role, digits)); err != nil {
+		s.log.Warn("error sending leaderboard", "err", err)
+	}
+}
+
+// serveTournaments serves all tournaments as JSON on GET /tournaments/, and
+// the one with an ID on GET /tournaments/{id}.
+func (s *Server) serveTournaments(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var resp interface{}
+	id := strings.TrimPrefix(req.URL.Path, "/tournaments/")
+	if id == "" {
+		resp = s.tournaments.List()
+	} else {
+		t, ok := s.tournaments.Tournament(id)
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		resp = t
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		s.log.Warn("error sending tournaments", "err", err)
+	}
+}
+
 // upgrade upgrades an HTTP connection to a WebSocket connection and
 // forks off a client of the WebSocket connection.
 func (s *Server) upgrade(w http.ResponseWriter, req *http.Request) {
 	conn, err := s.websock.Upgrade(w, req, nil)
 	if err != nil {
-		s.log.Printf("error upgrading to websocket conn: %v\n", err)
+		s.log.Warn("error upgrading to websocket conn", "err", err)
 		return
 	}
 
-	c := NewClient(conn)
-	player := NewRemotePlayer(c, 60*time.Second)
-	c.OnMessage("connect", func(_ string) {
-		s.log.Printf("client connected: %s\n", c.ID())
+	opts := []ClientOption{MaxMessageSize(s.limits.MessageSize), RateLimits(s.limits.Messages), Instrument(s.metrics), LogTo(s.log)}
+	c := NewClient(conn, append(opts, s.clientOpts...)...)
+	player := NewRemotePlayer(c, s.playerTimeout)
+	player.UseMetrics(s.metrics)
+	player.UseLogger(s.log.With("client_id", c.ID()))
+	s.mu.Lock()
+	s.clients[c] = player
+	s.mu.Unlock()
+	// Actions are invoked one at a time, so connected needs no guarding.
+	connected := false
+	c.OnMessage(protocol.KindRegister, func(data string) {
+		s.login(c, player, protocol.KindRegister, data, connected)
+	})
+	c.OnMessage(protocol.KindLogin, func(data string) {
+		s.login(c, player, protocol.KindLogin, data, connected)
+	})
+	c.OnMessage(protocol.KindConnect, func(data string) {
+		var req protocol.Connect
+		if err := protocol.Decode(data, &req); err != nil {
+			s.reject(c, player, protocol.KindConnect, protocol.Errorf(protocol.CodeBadRequest, "malformed connect data: %v", err))
+			return
+		}
+		version, err := protocol.Negotiate(req.Version)
+		if err != nil {
+			s.clientLog(c, player).Info("unsupported protocol version", "version", req.Version)
+			s.reject(c, player, protocol.KindConnect, err.(*protocol.Error))
+			// The action is invoked while the client holds its lock, so
+			// closing it must happen elsewhere.
+			go c.Close()
+			return
+		}
+
+		s.clientLog(c, player).Info("client connected", "version", version)
+		resp, err := protocol.Encode(protocol.Connect{Version: version, ID: player.ID()})
+		if err != nil {
+			s.clientLog(c, player).Error("error encoding response", "kind", protocol.KindConnect, "err", err)
+			return
+		}
+		if err := c.SendMessage(protocol.KindConnect, resp); err != nil {
+			s.clientLog(c, player).Warn("error sending response", "kind", protocol.KindConnect, "err", err)
+		}
 		s.hub.Add(player)
+		connected = true
 	})
 
-	c.OnMessage("disconnect", func(_ string) {
-		s.log.Printf("client disconnected: %s\n", c.ID())
+	c.OnMessage(protocol.KindStats, func(data string) {
+		var req protocol.Stats
+		if err := protocol.Decode(data, &req); err != nil {
+			s.reject(c, player, protocol.KindStats, protocol.Errorf(protocol.CodeBadRequest, "malformed stats data: %v", err))
+			return
+		}
+		if req.ID == "" {
+			req.ID = player.ID()
+		}
+		resp, err := protocol.Encode(protocol.Stats{ID: req.ID, Ratings: s.ratings.Player(req.ID)})
+		if err != nil {
+			s.clientLog(c, player).Error("error encoding response", "kind", protocol.KindStats, "err", err)
+			return
+		}
+		if err := c.SendMessage(protocol.KindStats, resp); err != nil {
+			s.clientLog(c, player).Warn("error sending response", "kind", protocol.KindStats, "err", err)
+		}
+	})
+
+	c.OnMessage(protocol.KindTeamChat, func(data string) {
+		var msg protocol.TeamChat
+		if err := protocol.Decode(data, &msg); err != nil {
+			s.reject(c, player, protocol.KindTeamChat, protocol.Errorf(protocol.CodeBadRequest, "malformed team chat: %v", err))
+			return
+		}
+		go func() {
+			if err := s.hub.TeamChat(player, msg.Text); err != nil {
+				perr, ok := err.(*protocol.Error)
+				if !ok {
+					perr = protocol.Errorf(protocol.CodeBadRequest, "%v", err)
+				}
+				s.reject(c, player, protocol.KindTeamChat, perr)
+			}
+		}()
+	})
+
+	c.OnMessage(protocol.KindChat, func(data string) {
+		var msg protocol.Chat
+		if err := protocol.Decode(data, &msg); err != nil {
+			s.reject(c, player, protocol.KindChat, protocol.Errorf(protocol.CodeBadRequest, "malformed chat: %v", err))
+			return
+		}
+		go func() {
+			if err := s.hub.Chat(player, msg); err != nil {
+				s.reject(c, player, protocol.KindChat, err.(*protocol.Error))
+			}
+		}()
+	})
+
+	c.OnMessage(protocol.KindRoom, func(data string) {
+		var room protocol.Room
+		if err := protocol.Decode(data, &room); err != nil {
+			s.reject(c, player, protocol.KindRoom, protocol.Errorf(protocol.CodeBadRequest, "malformed room: %v", err))
+			return
+		}
+		if room.Leave {
+			s.hub.LeaveRoom(player.ID(), room.Room)
+			return
+		}
+		go func() {
+			if err := s.hub.JoinRoom(player, room.Room); err != nil {
+				s.reject(c, player, protocol.KindRoom, err.(*protocol.Error))
+			}
+		}()
+	})
+
+	c.OnMessage(protocol.KindTournament, func(data string) {
+		var settings TournamentSettings
+		if err := protocol.Decode(data, &settings); err != nil {
+			s.reject(c, player, protocol.KindTournament, protocol.Errorf(protocol.CodeBadRequest, "malformed tournament data: %v", err))
+			return
+		}
+		s.tournamentReply(c, player, protocol.KindTournament, func() (Tournament, error) {
+			return s.tournaments.Create(player, settings)
+		})
+	})
+
+	c.OnMessage(protocol.KindJoin, func(data string) {
+		var req protocol.TournamentID
+		if err := protocol.Decode(data, &req); err != nil {
+			s.reject(c, player, protocol.KindJoin, protocol.Errorf(protocol.CodeBadRequest, "malformed join data: %v", err))
+			return
+		}
+		s.tournamentReply(c, player, protocol.KindJoin, func() (Tournament, error) {
+			return s.tournaments.Join(req.ID, player)
+		})
+	})
+
+	c.OnMessage(protocol.KindStart, func(data string) {
+		var req protocol.TournamentID
+		if err := protocol.Decode(data, &req); err != nil {
+			s.reject(c, player, protocol.KindStart, protocol.Errorf(protocol.CodeBadRequest, "malformed start data: %v", err))
+			return
+		}
+		s.tournamentReply(c, player, protocol.KindStart, func() (Tournament, error) {
+			return s.tournaments.Start(req.ID, player)
+		})
+	})
+
+	c.OnMessage(protocol.KindQueue, func(data string) {
+		// Only players in the hub can be matched.
+		if !connected {
+			s.reject(c, player, protocol.KindQueue, protocol.Errorf(protocol.CodeNotAllowed, "connect before queueing"))
+			return
+		}
+		var q Queue
+		if err := protocol.Decode(data, &q); err != nil {
+			s.reject(c, player, protocol.KindQueue, protocol.Errorf(protocol.CodeBadRequest, "malformed queue data: %v", err))
+			return
+		}
+		if err := checkQueue(q); err != nil {
+			s.reject(c, player, protocol.KindQueue, err.(*protocol.Error))
+			return
+		}
+		// The reply goes first, so that it is never sent after the player
+		// is matched.
+		resp, err := protocol.Encode(q)
+		if err != nil {
+			s.clientLog(c, player).Error("error encoding response", "kind", protocol.KindQueue, "err", err)
+			return
+		}
+		if err := c.SendMessage(protocol.KindQueue, resp); err != nil {
+			s.clientLog(c, player).Warn("error sending response", "kind", protocol.KindQueue, "err", err)
+		}
+		s.hub.Enqueue(player, q)
+	})
+
+	c.OnMessage(protocol.KindDequeue, func(_ string) {
+		s.hub.Dequeue(player.ID())
+	})
+
+	c.OnMessage(protocol.KindDisconnect, func(_ string) {
+		s.clientLog(c, player).Info("client disconnected")
+		s.mu.Lock()
+		delete(s.clients, c)
+		if acc, ok := player.Account(); ok {
+			delete(s.logins, acc.ID)
+		}
+		s.mu.Unlock()
 		s.hub.Remove(player.ID())
 	})
 
-	c.OnMessage("play", func(data string) {
-		s.log.Printf("game initiated by player %s with settings %s\n", player.ID(), data)
+	c.OnMessage(protocol.KindPlay, func(data string) {
+		s.clientLog(c, player).Info("game requested", "settings", data)
 
 		var settings GameSettings
-		if err := json.Unmarshal([]byte(data), &settings); err != nil {
-			s.log.Printf("malformed settings provided by %s\n", player.ID())
+		if err := protocol.Decode(data, &settings); err != nil {
+			s.reject(c, player, protocol.KindPlay, protocol.Errorf(protocol.CodeBadRequest, "malformed settings: %v", err))
 			return
 		}
 
+		var play func()
+		switch settings.Mode {
+		case "", protocol.ModeClassic:
+			play = func() { s.playGame(c, player, settings, s.hub.NewGame) }
+		case protocol.ModeTeam:
+			play = func() { s.playGame(c, player, settings, s.hub.NewTeamGame) }
+		case protocol.ModeDuel:
+			play = func() { s.playDuel(c, player, settings) }
+		case protocol.ModeRace:
+			play = func() { s.playRace(c, player, settings) }
+		default:
+			s.reject(c, player, protocol.KindPlay, protocol.Errorf(protocol.CodeInvalidSettings, "unknown mode %q", settings.Mode))
+			return
+		}
+
+		if err := s.hub.accepting(); err != nil {
+			s.reject(c, player, protocol.KindPlay, err.(*protocol.Error))
+			return
+		}
+		id := player.ID()
+		// A remote player is asked for one move at a time, so it may not
+		// request a game while it plays one, whatever the limits.
+		if s.hub.games.playing(id) {
+			s.reject(c, player, protocol.KindPlay, protocol.Errorf(protocol.CodeTooManyGames, "you play a game already"))
+			return
+		}
+		if err := s.hub.games.acquire(id); err != nil {
+			s.clientLog(c, player).Info("refusing game", "err", err)
+			s.reject(c, player, protocol.KindPlay, err.(*protocol.Error))
+			return
+		}
 		go func() {
-			game, err := s.hub.NewGame(player, settings)
-			if err != nil {
-				s.log.Printf("error creating game: %v\n", err)
-				return
-			}
-			if err := game.Play(); err != nil {
-				s.log.Printf("error running game: %v\n", err)
-				return
-			}
-			s.log.Printf("game finished\n")
+			defer s.hub.games.release(id)
+			play()
 		}()
 	})
 }
+
+// playGame plays a game requested by player with settings, created by
+// newGame, and records it.
+func (s *Server) playGame(c *Client, player *RemotePlayer, settings GameSettings, newGame func(Player, GameSettings) (*game.Game, error)) {
+	g, err := newGame(player, settings)
+	if err != nil {
+		s.clientLog(c, player).Info("error creating game", "err", err)
+		perr, ok := err.(*protocol.Error)
+		if !ok {
+			perr = protocol.Errorf(protocol.CodeInvalidSettings, "%v", err)
+		}
+		s.reject(c, player, protocol.KindPlay, perr)
+		return
+	}
+	// Participants are told by the game itself if it is aborted.
+	if s.store != nil {
+		g.SetID(newGameID())
+	}
+	log, over := s.hub.gameOn(settings.Mode, []game.Thinker{g.Thinker()}, []game.Guesser{g.Guesser()})
+	defer over()
+	s.metrics.timeMoves(g, g.Thinker(), g.Guesser())
+	traceMoves(log, g)
+	defer s.metrics.playing(settings.Mode)()
+	started := time.Now()
+	err = g.Play()
+	traceResult(log, g.Result())
+	s.record(NewGameRecord(g, settings, started))
+	if err != nil {
+		log.Warn("error running game", "err", err)
+	}
+}
+
+// playDuel plays a duel requested by player with settings, and records its
+// games.
+func (s *Server) playDuel(c *Client, player *RemotePlayer, settings GameSettings) {
+	duel, err := s.hub.NewDuel(player, settings)
+	if err != nil {
+		s.clientLog(c, player).Info("error creating duel", "err", err)
+		s.reject(c, player, protocol.KindPlay, err.(*protocol.Error))
+		return
+	}
+	if s.store != nil {
+		duel.SetID(newGameID())
+	}
+	s.hub.announceDuel(duel, settings.Digits)
+	d := duel.Duelists()
+	log, over := s.hub.gameOn(settings.Mode, []game.Thinker{d[0], d[1]}, nil)
+	defer over()
+	logs := make([]*slog.Logger, len(duel.Games()))
+	for i, g := range duel.Games() {
+		s.metrics.timeMoves(g, d[1-i], d[i])
+		logs[i] = log.With("guesser_id", participants(d[i])[0].ID)
+		traceMoves(logs[i], g)
+	}
+	defer s.metrics.playing(settings.Mode)()
+	started := time.Now()
+	err = duel.Play()
+	for i, g := range duel.Games() {
+		traceResult(logs[i], g.Result())
+		s.record(NewGameRecord(g, settings, started))
+	}
+	if err != nil {
+		log.Warn("error running duel", "err", err)
+	}
+}
+
+// playRace plays a race requested by player with settings, pushing its
+// standings to the players, and records the game of every guesser.
+func (s *Server) playRace(c *Client, player *RemotePlayer, settings GameSettings) {
+	race, err := s.hub.NewRace(player, settings)
+	if err != nil {
+		s.clientLog(c, player).Info("error creating race", "err", err)
+		s.reject(c, player, protocol.KindPlay, err.(*protocol.Error))
+		return
+	}
+	if s.store != nil {
+		race.SetID(newGameID())
+	}
+	race.OnUpdate(func(r game.RaceResult) {
+		s.hub.announceRace(race, r)
+	})
+	log, over := s.hub.gameOn(settings.Mode, []game.Thinker{race.Thinker()}, race.Guessers())
+	defer over()
+	logs := make([]*slog.Logger, len(race.Games()))
+	for i, g := range race.Games() {
+		s.metrics.timeMoves(g, race.Thinker(), race.Guessers()[i])
+		logs[i] = log.With("guesser_id", participants(race.Guessers()[i])[0].ID)
+		traceMoves(logs[i], g)
+	}
+	defer s.metrics.playing(settings.Mode)()
+	started := time.Now()
+	err = race.Play()
+	for i, g := range race.Games() {
+		traceResult(logs[i], g.Result())
+		s.record(NewRaceRecord(race, i, settings, started))
+	}
+	if err != nil {
+		log.Warn("error running race", "err", err)
+	}
+}
+
+// login registers player or logs it in to an account, as asked by a message
+// of kind with data. A player already in the hub rejoins it under the ID of
+// the account.
+func (s *Server) login(c *Client, player *RemotePlayer, kind, data string, inHub bool) {
+	if s.accounts == nil {
+		s.reject(c, player, kind, protocol.Errorf(protocol.CodeInvalidAccount, "accounts are not supported"))
+		return
+	}
+	if _, ok := player.Account(); ok {
+		s.reject(c, player, kind, protocol.Errorf(protocol.CodeBadRequest, "already logged in"))
+		return
+	}
+	var creds protocol.Credentials
+	if err := protocol.Decode(data, &creds); err != nil {
+		s.reject(c, player, kind, protocol.Errorf(protocol.CodeBadRequest, "malformed %s data: %v", kind, err))
+		return
+	}
+
+	var acc Account
+	var token string
+	var err error
+	switch {
+	case kind == protocol.KindRegister:
+		acc, token, err = s.accounts.Register(creds.Username, creds.Password)
+	case creds.Token != "":
+		acc, err = s.accounts.LoginWithToken(creds.Token)
+	default:
+		acc, err = s.accounts.Login(creds.Username, creds.Password)
+	}
+	if err == nil && !s.useAccount(c, acc.ID) {
+		err = protocol.Errorf(protocol.CodeAuthFailed, "account %s is in use", acc.Username)
+	}
+	if err != nil {
+		perr, ok := err.(*protocol.Error)
+		if !ok {
+			s.clientLog(c, player).Error("error logging in", "kind", kind, "err", err)
+			perr = protocol.Errorf(protocol.CodeAuthFailed, "could not log in, try again later")
+		}
+		s.reject(c, player, kind, perr)
+		return
+	}
+
+	oldID := player.ID()
+	player.Login(acc)
+	s.clientLog(c, player).Info("client logged in", "username", acc.Username)
+	resp, err := protocol.Encode(protocol.Account{ID: acc.ID, Username: acc.Username, Token: token})
+	if err != nil {
+		s.clientLog(c, player).Error("error encoding response", "kind", kind, "err", err)
+		return
+	}
+	if err := c.SendMessage(kind, resp); err != nil {
+		s.clientLog(c, player).Warn("error sending response", "kind", kind, "err", err)
+	}
+	if inHub {
+		s.hub.Remove(oldID)
+		s.hub.Add(player)
+	}
+}
+
+// useAccount reserves the account with id for c until it disconnects,
+// unless another client uses it already.
+func (s *Server) useAccount(c *Client, id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.logins[id]; ok {
+		return false
+	}
+	s.logins[id] = c
+	return true
+}
+
+// tournamentReply sends the tournament returned by action, taken on a message
+// of kind, to player, or rejects the message if the action fails.
+func (s *Server) tournamentReply(c *Client, player *RemotePlayer, kind string, action func() (Tournament, error)) {
+	t, err := action()
+	if err != nil {
+		perr, ok := err.(*protocol.Error)
+		if !ok {
+			perr = protocol.Errorf(protocol.CodeInvalidSettings, "%v", err)
+		}
+		s.reject(c, player, kind, perr)
+		return
+	}
+	if err := player.AnnounceTournament(t); err != nil {
+		s.clientLog(c, player).Warn("error sending response", "kind", protocol.KindTournament, "err", err)
+	}
+}
+
+// record rates the players of a finished game and records it, if there is
+// a store.
+func (s *Server) record(r GameRecord) {
+	s.metrics.finished(r)
+	s.ratings.Add(r)
+	if s.store == nil {
+		return
+	}
+	if err := s.store.Record(r); err != nil {
+		s.log.Error("error recording game", "record_id", r.ID, "err", err)
+	}
+}
+
+// reject sends an error caused by a message of a kind to player.
+func (s *Server) reject(c *Client, player *RemotePlayer, kind string, e *protocol.Error) {
+	e.Kind = kind
+	if err := player.SendError(e); err != nil {
+		s.clientLog(c, player).Warn("error sending message", "kind", protocol.KindError, "err", err)
+	}
+}
+
+// clientLog returns the logger of the server, with the IDs of the client
+// and of its player, as of now.
+func (s *Server) clientLog(c *Client, player *RemotePlayer) *slog.Logger {
+	return s.log.With("client_id", c.ID(), "player_id", player.ID())
+}
@@ -0,0 +1,335 @@
+package cowbull_test
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+	"github.com/gorilla/websocket"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	var store *cowbullfakes.FakeStore
+	var server *Server
+
+	BeforeEach(func() {
+		store = new(cowbullfakes.FakeStore)
+		logger := slog.New(slog.NewTextHandler(GinkgoWriter, nil))
+		server = NewServer(&ServerConfig{
+			Log:   logger,
+			Hub:   NewHub(new(cowbullfakes.FakeGamer), logger),
+			Store: store,
+		})
+	})
+
+	get := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	Describe("GET /games/{id}", func() {
+		It("should serve the replay of the game", func() {
+			store.GameReturns(GameRecord{
+				ID: "g1",
+				Result: game.Result{
+					Outcome: game.OutcomeWin,
+					Digits:  2,
+					Moves:   []game.Move{{Guess: "12", Bulls: 2}},
+				},
+			}, nil)
+
+			w := get("GET", "/games/g1")
+			Ω(w.Code).Should(Equal(http.StatusOK))
+			Ω(w.Header().Get("Content-Type")).Should(Equal("application/json"))
+			Ω(store.GameArgsForCall(0)).Should(Equal("g1"))
+
+			var replay Replay
+			Ω(json.Unmarshal(w.Body.Bytes(), &replay)).Should(Succeed())
+			Ω(replay.ID).Should(Equal("g1"))
+			Ω(replay.Turns).Should(HaveLen(1))
+			Ω(replay.Turns[0].Possible).Should(Equal(90))
+		})
+
+		It("should analyse each game once", func() {
+			store.GameReturns(GameRecord{ID: "g1", Result: game.Result{Digits: 2, Moves: []game.Move{{Guess: "12", Bulls: 2}}}}, nil)
+			first := get("GET", "/games/g1").Body.String()
+			Ω(get("GET", "/games/g1").Body.String()).Should(Equal(first))
+			Ω(store.GameCallCount()).Should(Equal(1))
+		})
+
+		It("should not find unknown games", func() {
+			store.GameReturns(GameRecord{}, ErrGameNotFound)
+			Ω(get("GET", "/games/g2").Code).Should(Equal(http.StatusNotFound))
+			Ω(get("GET", "/games/").Code).Should(Equal(http.StatusNotFound))
+			Ω(get("GET", "/games/g1/moves").Code).Should(Equal(http.StatusNotFound))
+		})
+
+		It("should fail when the store does", func() {
+			store.GameReturns(GameRecord{}, errors.New("disk on fire"))
+			Ω(get("GET", "/games/g1").Code).Should(Equal(http.StatusInternalServerError))
+		})
+
+		It("should only allow GET", func() {
+			Ω(get("POST", "/games/g1").Code).Should(Equal(http.StatusMethodNotAllowed))
+		})
+
+		It("should not find games without a store", func() {
+			server = NewServer(&ServerConfig{Log: slog.New(slog.NewTextHandler(GinkgoWriter, nil))})
+			Ω(get("GET", "/games/g1").Code).Should(Equal(http.StatusNotFound))
+		})
+	})
+
+	Describe("GET /leaderboard", func() {
+		var ratings *Ratings
+
+		BeforeEach(func() {
+			ratings = NewRatings()
+			ratings.Add(GameRecord{
+				ID:       "g1",
+				Thinker:  PlayerEntry{ID: "alice-id", Name: "alice"},
+				Guessers: []PlayerEntry{{ID: "bob-id", Name: "bob"}},
+				Result: game.Result{
+					Outcome: game.OutcomeWin,
+					Digits:  3,
+					Moves:   []game.Move{{Guess: "123", Bulls: 3}},
+				},
+			})
+			logger := slog.New(slog.NewTextHandler(GinkgoWriter, nil))
+			server = NewServer(&ServerConfig{Log: logger, Ratings: ratings})
+		})
+
+		leaderboard := func(path string) Leaderboard {
+			w := get("GET", path)
+			Ω(w.Code).Should(Equal(http.StatusOK))
+			Ω(w.Header().Get("Content-Type")).Should(Equal("application/json"))
+			var l Leaderboard
+			Ω(json.Unmarshal(w.Body.Bytes(), &l)).Should(Succeed())
+			return l
+		}
+
+		It("should serve the leaderboard of a role and digit count", func() {
+			l := leaderboard("/leaderboard?role=thinker&digits=3")
+			Ω(l.Role).Should(Equal(RoleThinker))
+			Ω(l.Ratings).Should(HaveLen(1))
+			Ω(l.Ratings[0].Name).Should(Equal("alice"))
+		})
+
+		It("should default to guessers of 4-digit numbers", func() {
+			l := leaderboard("/leaderboard")
+			Ω(l.Role).Should(Equal(RoleGuesser))
+			Ω(l.Digits).Should(Equal(4))
+			Ω(l.Ratings).Should(BeEmpty())
+		})
+
+		It("should reject invalid parameters", func() {
+			Ω(get("GET", "/leaderboard?role=referee").Code).Should(Equal(http.StatusBadRequest))
+			Ω(get("GET", "/leaderboard?digits=four").Code).Should(Equal(http.StatusBadRequest))
+		})
+
+		It("should only allow GET", func() {
+			Ω(get("POST", "/leaderboard").Code).Should(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe("GET /tournaments/", func() {
+		It("should list no tournaments at first", func() {
+			w := get("GET", "/tournaments/")
+			Ω(w.Code).Should(Equal(http.StatusOK))
+			Ω(w.Body.String()).Should(MatchJSON("[]"))
+		})
+
+		It("should not find unknown tournaments", func() {
+			Ω(get("GET", "/tournaments/t1").Code).Should(Equal(http.StatusNotFound))
+		})
+
+		It("should only allow GET", func() {
+			Ω(get("POST", "/tournaments/").Code).Should(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe("WebSocket connections", func() {
+		var web *httptest.Server
+		var conn *websocket.Conn
+		var clients chan string
+
+		BeforeEach(func() {
+			logger := slog.New(slog.NewTextHandler(GinkgoWriter, nil))
+			gamer := new(cowbullfakes.FakeGamer)
+			gamer.GameStub = func(t game.Thinker, g game.Guesser) (*game.Game, error) {
+				return game.New(t, g), nil
+			}
+			clients = make(chan string, 1)
+			server = NewServer(&ServerConfig{
+				Log:           logger,
+				Hub:           NewHub(gamer, logger),
+				Upgrader:      &websocket.Upgrader{},
+				PlayerTimeout: 50 * time.Millisecond,
+				ClientOptions: []ClientOption{func(c *Client) {
+					clients <- c.ID()
+				}},
+			})
+			web = httptest.NewServer(server)
+			var err error
+			conn, _, err = websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(web.URL, "http")+"/websocket", nil)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			conn.Close()
+			web.Close()
+		})
+
+		// next returns the next message of a kind sent to the client.
+		next := func(kind string) protocol.Message {
+			var msg protocol.Message
+			for msg.Name != kind {
+				Ω(conn.ReadJSON(&msg)).Should(Succeed())
+			}
+			return msg
+		}
+
+		It("should configure their clients with the client options", func() {
+			Eventually(clients).Should(Receive(Not(BeEmpty())))
+		})
+
+		Context("when logging in to accounts", func() {
+			BeforeEach(func() {
+				logger := slog.New(slog.NewTextHandler(GinkgoWriter, nil))
+				store := new(cowbullfakes.FakeAccountStore)
+				accounts := NewAccounts(store)
+				acc, _, err := accounts.Register("alice", "secret-password")
+				Ω(err).ShouldNot(HaveOccurred())
+				store.AccountReturns(acc, nil)
+				conn.Close()
+				web.Close()
+				web = httptest.NewServer(NewServer(&ServerConfig{
+					Log:      logger,
+					Hub:      NewHub(new(cowbullfakes.FakeGamer), logger),
+					Upgrader: &websocket.Upgrader{},
+					Accounts: accounts,
+					// Closed connections disconnect their clients at once.
+					ClientOptions: []ClientOption{RetryCount(0)},
+				}))
+			})
+
+			dial := func() *websocket.Conn {
+				conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(web.URL, "http")+"/websocket", nil)
+				Ω(err).ShouldNot(HaveOccurred())
+				return conn
+			}
+
+			// login logs conn in to the account of alice, and returns the
+			// kind of the reply.
+			login := func(conn *websocket.Conn) string {
+				data, err := protocol.Encode(protocol.Credentials{Username: "alice", Password: "secret-password"})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(conn.WriteJSON(protocol.Message{Name: protocol.KindLogin, Data: data})).Should(Succeed())
+				var msg protocol.Message
+				Ω(conn.ReadJSON(&msg)).Should(Succeed())
+				return msg.Name
+			}
+
+			It("should let one connection at a time use an account", func() {
+				conns := []*websocket.Conn{dial(), dial()}
+				defer conns[1].Close()
+				replies := make([]string, len(conns))
+				done := make(chan struct{})
+				for i := range conns {
+					go func(i int) {
+						defer GinkgoRecover()
+						replies[i] = login(conns[i])
+						done <- struct{}{}
+					}(i)
+				}
+				<-done
+				<-done
+				Ω(replies).Should(ConsistOf(protocol.KindLogin, protocol.KindError))
+
+				winner, loser := conns[0], conns[1]
+				if replies[1] == protocol.KindLogin {
+					winner, loser = loser, winner
+				}
+				Ω(login(loser)).Should(Equal(protocol.KindError))
+				winner.Close()
+				Eventually(func() string { return login(loser) }).Should(Equal(protocol.KindLogin))
+			})
+		})
+
+		Context("when playing a game", func() {
+			BeforeEach(func() {
+				logger := slog.New(slog.NewTextHandler(GinkgoWriter, nil))
+				gamer := new(cowbullfakes.FakeGamer)
+				gamer.GameStub = func(t game.Thinker, g game.Guesser) (*game.Game, error) {
+					return game.New(t, g), nil
+				}
+				limits := DefaultLimits()
+				limits.GamesPerPlayer = 0
+				conn.Close()
+				web.Close()
+				web = httptest.NewServer(NewServer(&ServerConfig{
+					Log:           logger,
+					Hub:           NewHub(gamer, logger),
+					Upgrader:      &websocket.Upgrader{},
+					Limits:        &limits,
+					PlayerTimeout: time.Minute,
+				}))
+				var err error
+				conn, _, err = websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(web.URL, "http")+"/websocket", nil)
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("should refuse another game, whatever the limits", func() {
+				data, err := protocol.Encode(protocol.GameSettings{Role: RoleGuesser, Digits: 4, AI: true})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(conn.WriteJSON(protocol.Message{Name: protocol.KindPlay, Data: data})).Should(Succeed())
+				next(protocol.KindGuess)
+				Ω(conn.WriteJSON(protocol.Message{Name: protocol.KindPlay, Data: data})).Should(Succeed())
+				var perr protocol.Error
+				Ω(protocol.Decode(next(protocol.KindError).Data, &perr)).Should(Succeed())
+				Ω(perr.Code).Should(Equal(protocol.CodeTooManyGames))
+				Ω(perr.Kind).Should(Equal(protocol.KindPlay))
+			})
+		})
+
+		It("should let only connected players queue", func() {
+			send := func(kind string, v interface{}) {
+				data, err := protocol.Encode(v)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(conn.WriteJSON(protocol.Message{Name: kind, Data: data})).Should(Succeed())
+			}
+			q := protocol.Queue{Role: RoleGuesser, Digits: 4}
+			send(protocol.KindQueue, q)
+			var perr protocol.Error
+			Ω(protocol.Decode(next(protocol.KindError).Data, &perr)).Should(Succeed())
+			Ω(perr.Code).Should(Equal(protocol.CodeNotAllowed))
+			Ω(perr.Kind).Should(Equal(protocol.KindQueue))
+
+			send(protocol.KindConnect, protocol.Connect{Version: protocol.Version})
+			next(protocol.KindConnect)
+			send(protocol.KindQueue, q)
+			next(protocol.KindQueue)
+		})
+
+		It("should give players the player timeout to answer", func() {
+			data, err := protocol.Encode(protocol.GameSettings{Role: RoleGuesser, Digits: 4, AI: true})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(conn.WriteJSON(protocol.Message{Name: protocol.KindPlay, Data: data})).Should(Succeed())
+			next(protocol.KindGuess)
+			started := time.Now()
+			next(protocol.KindGameOver)
+			Ω(time.Since(started)).Should(BeNumerically("<", time.Second))
+		})
+	})
+})
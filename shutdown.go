@@ -0,0 +1,110 @@
+package cowbull
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+// ErrShutdown ends the games still on when the server shuts down.
+var ErrShutdown = errors.New("cowbull: the server is shutting down")
+
+// shutdownObserver may be implemented by a Player that wants to know that
+// the server is shutting down.
+type shutdownObserver interface {
+	AnnounceShutdown(protocol.Shutdown) error
+}
+
+// canceler may be implemented by a Player waiting for answers, so that its
+// games can be cancelled.
+type canceler interface {
+	// Cancel makes the player fail with ErrShutdown whenever it waits for
+	// an answer, from now on.
+	Cancel()
+}
+
+// Drain makes the hub refuse new games, empties the matchmaking queue and
+// tells the players that the games still on are cancelled at deadline.
+// Draining a hub again does nothing.
+func (h *Hub) Drain(deadline time.Time) {
+	h.do(func(players map[string]Player) {
+		if h.draining {
+			return
+		}
+		h.draining = true
+		close(h.drained)
+		h.dequeue(func(*queued) bool { return true })
+		h.log.Info("draining", "deadline", deadline)
+
+		msg := protocol.Shutdown{Deadline: deadline.UnixNano() / int64(time.Millisecond)}
+		for _, p := range players {
+			if o, ok := p.(shutdownObserver); ok {
+				if err := o.AnnounceShutdown(msg); err != nil {
+					h.log.Warn("error announcing shutdown", "player_id", p.ID(), "err", err)
+				}
+			}
+		}
+	})
+}
+
+// accepting fails with a *protocol.Error once the hub is draining.
+func (h *Hub) accepting() error {
+	errs := make(chan error, 1)
+	h.do(func(map[string]Player) {
+		if h.draining {
+			errs <- protocol.Errorf(protocol.CodeShuttingDown, "the server is shutting down")
+			return
+		}
+		errs <- nil
+	})
+	return <-errs
+}
+
+// Wait waits until no game is on, or until ctx is done.
+func (h *Hub) Wait(ctx context.Context) error {
+	idle := make(chan struct{})
+	h.do(func(map[string]Player) {
+		if len(h.live) == 0 {
+			close(idle)
+			return
+		}
+		h.idle = append(h.idle, idle)
+	})
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// notifyIdle lets the callers of Wait know that no game is on. It must be
+// called by a hub op.
+func (h *Hub) notifyIdle() {
+	for _, idle := range h.idle {
+		close(idle)
+	}
+	h.idle = nil
+}
+
+// Close drains the hub, cancels the games still on, whose players are told
+// that they ended with ErrShutdown, and waits for them to be over or for
+// ctx to be done. Then the loop of the hub stops, and its methods run in the
+// goroutines calling them.
+func (h *Hub) Close(ctx context.Context) error {
+	h.Drain(time.Now())
+	h.do(func(players map[string]Player) {
+		for _, p := range players {
+			if c, ok := p.(canceler); ok {
+				c.Cancel()
+			}
+		}
+	})
+	err := h.Wait(ctx)
+	h.closeOnce.Do(func() {
+		close(h.closed)
+	})
+	return err
+}
@@ -0,0 +1,175 @@
+package cowbull_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shutdown", func() {
+	var hub *Hub
+	var alice, bob *stoppingPlayer
+	var finished chan game.Result
+
+	// startGame starts a matched game in which alice thinks and bob guesses
+	// until they are cancelled.
+	startGame := func() {
+		alice.ThinkReturns(4, nil)
+		alice.TryStub = func(string) (int, int, error) {
+			<-alice.cancelled
+			return 0, 0, ErrShutdown
+		}
+		bob.GuessReturns("5678", nil)
+		Expect(hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 4})).To(Succeed())
+		Expect(hub.Enqueue(bob, Queue{Role: RoleGuesser, Digits: 4})).To(Succeed())
+		Eventually(alice.TryCallCount).Should(Equal(1))
+	}
+
+	BeforeEach(func() {
+		gamer := new(cowbullfakes.FakeGamer)
+		gamer.GameStub = func(t game.Thinker, g game.Guesser) (*game.Game, error) {
+			return game.New(t, g), nil
+		}
+		hub = NewHub(gamer, slog.New(slog.NewTextHandler(GinkgoWriter, nil)))
+		finished = make(chan game.Result, 1)
+		hub.OnGameFinished(func(g *game.Game, _ GameSettings, _ time.Time) {
+			finished <- g.Result()
+		})
+		alice = newStoppingPlayer("alice")
+		bob = newStoppingPlayer("bob")
+		hub.Add(alice)
+		hub.Add(bob)
+	})
+
+	Describe("Drain", func() {
+		var deadline time.Time
+
+		BeforeEach(func() {
+			deadline = time.Now().Add(time.Minute)
+			Expect(hub.Enqueue(alice, Queue{Role: RoleThinker, Digits: 4})).To(Succeed())
+			hub.Drain(deadline)
+		})
+
+		It("should tell the players when the games are cancelled", func() {
+			want := protocol.Shutdown{Deadline: deadline.UnixNano() / int64(time.Millisecond)}
+			Eventually(alice.shutdowns).Should(Equal([]protocol.Shutdown{want}))
+			Eventually(bob.shutdowns).Should(Equal([]protocol.Shutdown{want}))
+		})
+
+		It("should tell them only once", func() {
+			hub.Drain(time.Now())
+			Consistently(bob.shutdowns, "50ms").Should(HaveLen(1))
+		})
+
+		It("should refuse new games", func() {
+			err := hub.Enqueue(bob, Queue{Role: RoleGuesser, Digits: 4})
+			Expect(code(err)).To(Equal(protocol.CodeShuttingDown))
+			Consistently(alice.ThinkCallCount, "50ms").Should(BeZero())
+		})
+	})
+
+	Describe("Wait", func() {
+		It("should return at once if no game is on", func() {
+			Expect(hub.Wait(context.Background())).To(Succeed())
+		})
+
+		It("should wait for the games on", func() {
+			startGame()
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			Expect(hub.Wait(ctx)).To(Equal(context.DeadlineExceeded))
+
+			waited := make(chan error, 1)
+			go func() {
+				waited <- hub.Wait(context.Background())
+			}()
+			Consistently(waited, "50ms").ShouldNot(Receive())
+			alice.Cancel()
+			Eventually(waited).Should(Receive(BeNil()))
+		})
+	})
+
+	Describe("Close", func() {
+		It("should cancel the games on and wait for them to be over", func() {
+			startGame()
+			Expect(hub.Close(context.Background())).To(Succeed())
+			var r game.Result
+			Expect(finished).To(Receive(&r))
+			Expect(r.Outcome).To(Equal(game.OutcomeAbort))
+			Expect(r.Reason).To(Equal(ErrShutdown.Error()))
+			Expect(bob.shutdowns()).To(HaveLen(1))
+		})
+
+		It("should keep the hub usable", func() {
+			Expect(hub.Close(context.Background())).To(Succeed())
+			Expect(hub.Close(context.Background())).To(Succeed())
+			hub.Remove("alice")
+			Expect(hub.Chat(bob, protocol.Chat{Text: "bye"})).To(Succeed())
+			Expect(alice.AnnounceChatCallCount()).To(BeZero())
+		})
+	})
+})
+
+// stoppingPlayer records the shutdown announcements it is sent, and can be
+// cancelled.
+type stoppingPlayer struct {
+	*cowbullfakes.FakePlayer
+	cancelled chan struct{}
+
+	mu    sync.Mutex
+	once  sync.Once
+	stops []protocol.Shutdown
+	chats int
+}
+
+func newStoppingPlayer(id string) *stoppingPlayer {
+	p := &stoppingPlayer{FakePlayer: new(cowbullfakes.FakePlayer), cancelled: make(chan struct{})}
+	p.IDReturns(id)
+	p.NameReturns(id)
+	return p
+}
+
+func (p *stoppingPlayer) Cancel() {
+	p.once.Do(func() {
+		close(p.cancelled)
+	})
+}
+
+func (p *stoppingPlayer) AnnounceShutdown(s protocol.Shutdown) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.stops = append(p.stops, s)
+	return nil
+}
+
+func (p *stoppingPlayer) AnnounceChat(protocol.Chat) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.chats++
+	return nil
+}
+
+func (p *stoppingPlayer) AnnounceRoom(protocol.Room) error {
+	return nil
+}
+
+func (p *stoppingPlayer) AnnounceChatCallCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.chats
+}
+
+func (p *stoppingPlayer) shutdowns() []protocol.Shutdown {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]protocol.Shutdown(nil), p.stops...)
+}
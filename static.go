@@ -0,0 +1,97 @@
+package cowbull
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// staticFiles is the content served to browsers, built into the binary.
+//
+//go:embed static
+var staticFiles embed.FS
+
+// assets serves the content for browsers with ETags, so that browsers may
+// check cheaply whether their copies are still fresh. The files under
+// vendor/ have their version in their name and may be cached for good,
+// while the others have to be checked every time.
+type assets struct {
+	files  fs.FS
+	server http.Handler
+
+	mu    sync.Mutex // guards etags
+	etags map[string]etag
+}
+
+// etag is the ETag of a file as of its modification time and size.
+type etag struct {
+	modTime time.Time
+	size    int64
+	tag     string
+}
+
+// newAssets serves the content in dir, or the content built into the
+// binary if dir is empty.
+func newAssets(dir string) *assets {
+	var files fs.FS = os.DirFS(dir)
+	if dir == "" {
+		var err error
+		if files, err = fs.Sub(staticFiles, "static"); err != nil {
+			panic(err)
+		}
+	}
+	return &assets{
+		files:  files,
+		server: http.FileServer(http.FS(files)),
+		etags:  make(map[string]etag),
+	}
+}
+
+func (a *assets) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+req.URL.Path), "/")
+	if name == "" || strings.HasSuffix(req.URL.Path, "/") {
+		name = path.Join(name, "index.html")
+	}
+	if tag, ok := a.etag(name); ok {
+		w.Header().Set("ETag", tag)
+		if strings.HasPrefix(name, "vendor/") {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+	}
+	a.server.ServeHTTP(w, req)
+}
+
+// etag returns the ETag of the file with a name, computed again only when
+// the file changes. It reports whether there is such a file.
+func (a *assets) etag(name string) (string, bool) {
+	fi, err := fs.Stat(a.files, name)
+	if err != nil || fi.IsDir() {
+		return "", false
+	}
+	a.mu.Lock()
+	e, ok := a.etags[name]
+	a.mu.Unlock()
+	if ok && e.modTime.Equal(fi.ModTime()) && e.size == fi.Size() {
+		return e.tag, true
+	}
+
+	data, err := fs.ReadFile(a.files, name)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(data)
+	e = etag{modTime: fi.ModTime(), size: fi.Size(), tag: `"` + hex.EncodeToString(sum[:12]) + `"`}
+	a.mu.Lock()
+	a.etags[name] = e
+	a.mu.Unlock()
+	return e.tag, true
+}
@@ -0,0 +1,78 @@
+$(function() {
+
+    var token = new URLSearchParams(window.location.search).get("token") || "";
+    var $uptime = $('.uptime');
+    var $players = $('.playersTable tbody');
+    var $games = $('.gamesTable tbody');
+    var $status = $('.status');
+
+    $('.refreshButton').click(load);
+    load();
+
+    function request(method, url) {
+        return $.ajax({
+            method: method,
+            url: url,
+            dataType: method === "GET" ? "json" : "text",
+            headers: {"Authorization": "Bearer " + token}
+        });
+    }
+
+    function load() {
+        request("GET", "/admin")
+            .done(show)
+            .fail(function(xhr) {
+                $status.text("The status could not be loaded: " + xhr.status + " " + xhr.statusText);
+            });
+    }
+
+    function act(path, param, id) {
+        request("POST", path + "?" + param + "=" + encodeURIComponent(id))
+            .done(load)
+            .fail(function(xhr) {
+                $status.text("Failed: " + xhr.status + " " + xhr.statusText);
+                load();
+            });
+    }
+
+    function show(status) {
+        $status.text("");
+        $uptime.text("Up for " + duration(status.uptime) + (status.draining ? ", shutting down" : "") + ".");
+
+        $players.empty();
+        $.each(status.players, function(_, player) {
+            var $row = $('<tr/>');
+            $row.append($('<td/>').text(player.id));
+            $row.append($('<td/>').text(player.name));
+            $row.append($('<td/>').append($('<input type="button" value="Kick"/>').click(function() {
+                act("/admin/kick", "player", player.id);
+            })));
+            $players.append($row);
+        });
+
+        $games.empty();
+        $.each(status.games, function(_, game) {
+            var names = $.map(game.players, function(player) {
+                return player.name || player.id;
+            });
+            var $row = $('<tr/>');
+            $row.append($('<td/>').text(game.id));
+            $row.append($('<td/>').text(game.mode));
+            $row.append($('<td/>').text(names.join(", ")));
+            $row.append($('<td/>').text(duration(game.elapsed)));
+            $row.append($('<td/>').append($('<input type="button" value="Abort"/>').click(function() {
+                act("/admin/abort", "game", game.id);
+            })));
+            $games.append($row);
+        });
+    }
+
+    function duration(seconds) {
+        seconds = Math.floor(seconds);
+        var h = Math.floor(seconds / 3600);
+        var m = Math.floor(seconds % 3600 / 60);
+        var s = seconds % 60;
+        return (h > 0 ? h + "h " : "") + (h > 0 || m > 0 ? m + "m " : "") + s + "s";
+    }
+
+});
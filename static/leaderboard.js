@@ -0,0 +1,46 @@
+$(function() {
+
+    var $role = $('.roleSelect');
+    var $digits = $('.digitsInput');
+    var $ratings = $('.ratingsTable tbody');
+    var $status = $('.status');
+
+    $('.showButton').click(load);
+    load();
+
+    function load() {
+        $.getJSON("/leaderboard", {role: $role.val(), digits: $digits.val()})
+            .done(show)
+            .fail(function() {
+                $ratings.empty();
+                $status.text("The leaderboard could not be loaded.");
+            });
+    }
+
+    function show(leaderboard) {
+        $ratings.empty();
+        $status.text(leaderboard.ratings.length === 0 ? "No games played yet." : "");
+        for (var i = 0; i < leaderboard.ratings.length; i++) {
+            var rating = leaderboard.ratings[i];
+            var $row = $('<tr/>');
+            $row.append($('<td/>').text(i + 1));
+            $row.append($('<td/>').text(rating.name || rating.id));
+            $row.append($('<td/>').text(Math.round(rating.rating)));
+            $row.append($('<td/>').text(rating.games));
+            $row.append($('<td/>').text(rating.solved));
+            $row.append($('<td/>').text(rating.average_turns ? rating.average_turns.toFixed(2) : "-"));
+            $row.append($('<td/>').append(bestGame(rating)));
+            $ratings.append($row);
+        }
+    }
+
+    function bestGame(rating) {
+        if (!rating.best_game) {
+            return "-";
+        }
+        return $('<a/>')
+            .attr("href", "/replay.html?id=" + encodeURIComponent(rating.best_game))
+            .text(rating.best_turns + " turns");
+    }
+
+});
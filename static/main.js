@@ -3,12 +3,19 @@ $(function() {
     var $settingsDiv = $('.settingsDiv');
     var $gameDiv = $('.gameDiv');
     var $gameLog = $('.gameLogDiv');
+    var $race = $('.raceDiv');
+    var $teamChat = $('.teamChatDiv');
+    var $chatLog = $('.chatLogDiv');
 
     initView();
 
     function initView() {
         $('.playButton').click(clickPlay);
         $('.numberInput').keydown(keydownNumber);
+        $('.forfeitButton').click(clickForfeit);
+        $('.teamChatInput').keydown(keydownTeamChat);
+        $('.chatInput').keydown(keydownChat);
+        $('.joinRoomButton').click(clickJoinRoom);
     }
 
     function clickPlay(event) {
@@ -44,11 +51,46 @@ $(function() {
 
             waitsForThink = true;
             break;
+        case "any_thinker":
+            enqueue("guesser", digits);
+            return;
+        case "any_guesser":
+            waitsForThink = true;
+            enqueue("thinker", digits);
+            return;
+        case "ai_duel":
+            waitsForThink = true;
+            beginDuel(true, digits, []);
+            return;
+        case "duel":
+            waitsForThink = true;
+            beginDuel(false, digits, [promptForOpponent()]);
+            return;
+        case "ai_race":
+            beginRace("guesser", true, digits, promptForMultipleOpponents());
+            return;
+        case "race":
+            waitsForThink = true;
+            beginRace("thinker", false, digits, promptForMultipleOpponents());
+            return;
+        case "ai_team":
+            beginTeam("guesser", true, digits, promptForMultipleOpponents());
+            return;
+        case "team":
+            waitsForThink = true;
+            beginTeam("thinker", false, digits, promptForMultipleOpponents());
+            return;
         }
 
         beginGame(againstAI, digits, playerRole, opponents);
     }
     
+    function clickForfeit(event) {
+        if (inGame) {
+            forfeit();
+        }
+    }
+
     function keydownNumber(event) {
         if (event.which === 13)  {
             number = cleanInput($('.numberInput').val().trim());
@@ -58,6 +100,34 @@ $(function() {
         }
     }
 
+    function keydownTeamChat(event) {
+        if (event.which === 13)  {
+            var text = $(this).val().trim();
+            if (text !== "") {
+                sendTeamChat(text);
+            }
+            $(this).val('');
+        }
+    }
+
+    function keydownChat(event) {
+        if (event.which === 13)  {
+            var text = $(this).val().trim();
+            if (text !== "") {
+                sendChat($('#chatScopeSelect').val(), $('.chatRoomInput').val().trim(), text);
+            }
+            $(this).val('');
+        }
+    }
+
+    function clickJoinRoom(event) {
+        var room = {
+            name: "room",
+            data: JSON.stringify({room: $('.chatRoomInput').val().trim()}),
+        };
+        socket.send(JSON.stringify(room));
+    }
+
     function initGameField(playerRole) {
         $settingsDiv.fadeOut();
         $gameDiv.show();
@@ -68,6 +138,9 @@ $(function() {
         $settingsDiv.show();
         $gameDiv.fadeOut();
         $gameLog.empty();
+        $race.empty();
+        $teamChat.empty();
+        $('.teamChatInput').hide();
     }
 
     function showGuessRequest(digitsCount) {
@@ -101,7 +174,7 @@ $(function() {
             if (displayName === "" || displayName === undefined) {
                 displayName = players[i].id;
             }
-            $playersDiv.append('<span>'+displayName+'</span><br/>');
+            $playersDiv.append($('<span/>').text(displayName), '<br/>');
         }
     }
 
@@ -129,22 +202,99 @@ $(function() {
         return opponents;
     }
 
-    function showGameEnd(won) {
-        if (won) {
-            alert("You have just WON!!!");
+    function showGameEnd(result) {
+        var message;
+        if (result.outcome === "win") {
+            if (currentRole === "thinker") {
+                message = "The remote player guessed your number.";
+            } else if (result.secret === lastGuess) {
+                message = "You have just WON!!!";
+            } else {
+                message = "Another player guessed the number " + result.secret + ".";
+            }
         } else {
-            alert("The remote player guessed your number.");
+            message = "The game is over (" + result.outcome + "): " + result.reason;
+            if (result.secret) {
+                message += "\nThe number was " + result.secret + ".";
+            }
+        }
+        message += "\n" + result.moves.length + " guesses were made.";
+        if (result.id) {
+            message += "\nReplay the game at " + window.location.origin + "/replay.html?id=" + result.id;
+        }
+        alert(message);
+        resetGameField();
+    }
+
+    function showDuelEnd(result) {
+        var message;
+        switch (result.result) {
+        case "won":
+            message = "You have just WON the duel!!!";
+            break;
+        case "lost":
+            message = "You lost the duel.";
+            break;
+        case "draw":
+            message = "The duel is drawn.";
+            break;
+        default:
+            message = "The duel is over (" + result.outcome + "): " + result.reason;
+        }
+        if (result.guessed.secret) {
+            message += "\nThe number of your opponent was " + result.guessed.secret + ".";
+        }
+        message += "\nYou made " + result.guessed.moves.length + " guesses, your opponent " + result.thought.moves.length + ".";
+        if (result.guessed.id) {
+            message += "\nReplay your game at " + window.location.origin + "/replay.html?id=" + result.guessed.id;
         }
+        alert(message);
         resetGameField();
     }
 
+    function showStandings(race) {
+        $race.empty();
+        for (var i = 0; i < race.standings.length; i++) {
+            var standing = race.standings[i];
+            var place = standing.place ? standing.place + "." : "-";
+            var status = "guessing";
+            if (standing.solved) {
+                status = "guessed";
+            } else if (standing.done) {
+                status = "out";
+            }
+            var line = place + " " + (standing.player.name || standing.player.id) + ": " +
+                standing.turns + " turns, " + (standing.millis / 1000).toFixed(1) + "s, " + status;
+            $race.append($('<span/>').text(line), '<br/>');
+        }
+    }
+
+    function showRaceEnd(race) {
+        var message;
+        if (race.outcome === "win") {
+            message = "The race is over. The number was " + race.secret + ".";
+        } else {
+            message = "The race is over (" + race.outcome + "): " + race.reason;
+        }
+        for (var i = 0; i < race.standings.length && race.standings[i].place; i++) {
+            var player = race.standings[i].player;
+            message += "\n" + race.standings[i].place + ". " + (player.name || player.id) +
+                " in " + race.standings[i].turns + " turns";
+        }
+        alert(message);
+        resetGameField();
+    }
+
+    function showError(error) {
+        alert("Error: " + error.message);
+    }
+
     function showDisconnected() {
         alert("You have been disconnected. Please reload the page to connect again.");
     }
 
     function gameLog(message) {
-        // TODO(ivan): this is a security hole
-        $gameLog.append('<span>' + message + '</span><br/>');
+        $gameLog.append($('<span/>').text(message), '<br/>');
     }
 
     function cleanInput (input) {
@@ -153,10 +303,16 @@ $(function() {
 
     /////////////// controller ///////////////////
 
+    var protocolVersion = 1;
+
     var socket;
+    var playerId;
     var inGame = false;
     var waitsForThink = false;
+    var racing = false;
     var currentNumber;
+    var currentRole;
+    var lastGuess;
     var currentNumberDigits;
 
     var connectedPlayers;
@@ -195,12 +351,96 @@ $(function() {
         socket.send(JSON.stringify(play))
     }
 
-    function endGame(won) {
+    function beginDuel(againstAI, digits, opponents) {
+        initGameField("duelist");
+        inGame = true;
+
+        var play = {
+            name: "play",
+            data: JSON.stringify({
+                AI: againstAI,
+                digits: digits,
+                mode: "duel",
+                opponents: opponents,
+            }),
+        };
+        socket.send(JSON.stringify(play));
+    }
+
+    function beginRace(playerRole, againstAI, digits, opponents) {
+        initGameField(playerRole);
+        inGame = true;
+
+        var play = {
+            name: "play",
+            data: JSON.stringify({
+                AI: againstAI,
+                digits: digits,
+                mode: "race",
+                role: playerRole,
+                opponents: opponents,
+            }),
+        };
+        socket.send(JSON.stringify(play));
+    }
+
+    function beginTeam(playerRole, againstAI, digits, opponents) {
+        initGameField(playerRole);
+        inGame = true;
+        if (playerRole === "guesser") {
+            $('.teamChatInput').show();
+        }
+
+        var play = {
+            name: "play",
+            data: JSON.stringify({
+                AI: againstAI,
+                digits: digits,
+                mode: "team",
+                decision: $('#decisionSelect').val(),
+                role: playerRole,
+                opponents: opponents,
+            }),
+        };
+        socket.send(JSON.stringify(play));
+    }
+
+    function enqueue(playerRole, digits) {
+        initGameField(playerRole);
+        inGame = true;
+
+        var queue = {
+            name: "queue",
+            data: JSON.stringify({
+                role: playerRole,
+                digits: digits,
+            }),
+        };
+        socket.send(JSON.stringify(queue));
+    }
+
+    function endDuel(result) {
+        inGame = false;
+        waitsForThink = false;
+        showDuelEnd(result);
+    }
+
+    function endGame(result) {
         inGame = false;
-        showGameEnd(won);
+        waitsForThink = false;
+        showGameEnd(result);
+    }
+
+    function forfeit() {
+        var forfeit = {
+            name: "forfeit",
+            data: "",
+        };
+        socket.send(JSON.stringify(forfeit));
     }
 
     function sendGuess(number) {
+        lastGuess = number;
         var guess = {
             name: "guess",
             data: JSON.stringify({number: number}),
@@ -208,12 +448,28 @@ $(function() {
         socket.send(JSON.stringify(guess));
     }
 
+    function sendTeamChat(text) {
+        var chat = {
+            name: "teamchat",
+            data: JSON.stringify({text: text}),
+        };
+        socket.send(JSON.stringify(chat));
+    }
+
+    function sendChat(scope, room, text) {
+        var chat = {
+            name: "chat",
+            data: JSON.stringify({scope: scope, room: room, text: text}),
+        };
+        socket.send(JSON.stringify(chat));
+    }
+
     function onOpen(event) {
         console.log("socket opened");
         setName(promptForName());
         var connect = {
             name: "connect",
-            data: "",
+            data: JSON.stringify({version: protocolVersion}),
         };
         socket.send(JSON.stringify(connect));
     }
@@ -240,9 +496,71 @@ $(function() {
         case "try":
             console.log("try message recved");
             handleTry(msg.data);
+            break;
         case "players":
             console.log("players message recved");
             handlePlayers(msg.data);
+            break;
+        case "connect":
+            console.log("connect message recved");
+            handleConnect(msg.data);
+            break;
+        case "gameover":
+            console.log("gameover message recved");
+            handleGameOver(msg.data);
+            break;
+        case "queue":
+            console.log("queue message recved");
+            handleQueue(msg.data);
+            break;
+        case "matched":
+            console.log("matched message recved");
+            handleMatched(msg.data);
+            break;
+        case "duel":
+            console.log("duel message recved");
+            handleDuel(msg.data);
+            break;
+        case "duelover":
+            console.log("duelover message recved");
+            handleDuelOver(msg.data);
+            break;
+        case "race":
+            console.log("race message recved");
+            handleRace(msg.data);
+            break;
+        case "strike":
+            console.log("strike message recved");
+            handleStrike(msg.data);
+            break;
+        case "rejoin":
+            console.log("rejoin message recved");
+            handleRejoin(msg.data);
+            break;
+        case "proposals":
+            console.log("proposals message recved");
+            handleProposals(msg.data);
+            break;
+        case "teamchat":
+            console.log("teamchat message recved");
+            handleTeamChat(msg.data);
+            break;
+        case "chat":
+            console.log("chat message recved");
+            handleChat(msg.data);
+            break;
+        case "room":
+            console.log("room message recved");
+            handleRoom(msg.data);
+            break;
+        case "shutdown":
+            console.log("shutdown message recved");
+            handleShutdown(msg.data);
+            break;
+        case "error":
+            console.log("error message recved");
+            handleError(msg.data);
+            break;
         }
     }
 
@@ -251,7 +569,9 @@ $(function() {
     }
 
     function handleGuess(data) {
+        currentRole = "guesser";
         if (!inGame) {
+            inGame = true;
             initGameField("guesser");
         }
         var guess = JSON.parse(data);
@@ -263,10 +583,6 @@ $(function() {
     function handleTell(data) {
         var cowsbulls = JSON.parse(data); 
         showGuessResult(cowsbulls.number, cowsbulls.cows, cowsbulls.bulls); 
-
-        if (cowsbulls.bulls === currentNumberDigits) {
-            endGame(true);
-        }
     }
 
     function handleThink(data) {
@@ -278,14 +594,15 @@ $(function() {
         }
 
         inGame = true;
+        currentRole = "thinker";
         initGameField("thinker");
 
-        currentNumber = parseInt(promptForNumber());
-        currentNumberDigits = currentNumber.toString().length;
+        currentNumber = promptForNumber().trim();
+        currentNumberDigits = currentNumber.length;
 
         var think = {
             name: "think",
-            data: JSON.stringify({digits: currentNumberDigits}),
+            data: JSON.stringify({digits: currentNumberDigits, secret: currentNumber}),
         };
         socket.send(JSON.stringify(think));
     }
@@ -303,9 +620,172 @@ $(function() {
             data: JSON.stringify(cowsbulls),
         };
         socket.send(JSON.stringify(tryResponse));
+    }
+
+    function handleQueue(data) {
+        var queue = JSON.parse(data);
+        gameLog("Waiting for an opponent to play as " + queue.role + " of a " + queue.digits + "-digit number ...");
+    }
+
+    function handleMatched(data) {
+        var matched = JSON.parse(data);
+        var opponent = matched.opponent.name || matched.opponent.id;
+        if (matched.ai) {
+            opponent = "the computer";
+        }
+        gameLog("Playing against " + opponent + ".");
+    }
+
+    function handleStrike(data) {
+        var strike = JSON.parse(data);
+        var name = strike.player.name || strike.player.id;
+        if (strike.player.id !== playerId) {
+            if (strike.dropped) {
+                gameLog(name + " was dropped from the game: " + strike.reason);
+            } else {
+                gameLog(name + " was skipped (strike " + strike.strikes + "): " + strike.reason);
+            }
+            return;
+        }
+        $('.numberInput').hide();
+        if (!strike.dropped) {
+            gameLog("You were skipped (strike " + strike.strikes + "): " + strike.reason);
+            return;
+        }
+        inGame = false;
+        alert("You were dropped from the game: " + strike.reason);
+        resetGameField();
+    }
+
+    function handleRejoin(data) {
+        var player = JSON.parse(data);
+        if (player.id === playerId) {
+            inGame = true;
+            initGameField("guesser");
+            gameLog("You are back in the game.");
+        } else {
+            gameLog((player.name || player.id) + " rejoined the game.");
+        }
+    }
+
+    function handleProposals(data) {
+        var proposals = JSON.parse(data);
+        $('.teamChatInput').show();
+        var message = "The team proposes:";
+        for (var i = 0; i < proposals.proposals.length; i++) {
+            var proposal = proposals.proposals[i];
+            message += " " + proposal.number + " (" + (proposal.player.name || proposal.player.id) + ")";
+        }
+        gameLog(message);
+        if (proposals.decision === "vote") {
+            gameLog("Vote for the guess of the team.");
+        } else if (proposals.captain.id === playerId) {
+            gameLog("You are the captain, pick the guess of the team.");
+        } else {
+            gameLog((proposals.captain.name || proposals.captain.id) + " picks the guess of the team.");
+        }
+    }
+
+    function handleTeamChat(data) {
+        var chat = JSON.parse(data);
+        var line = (chat.from.name || chat.from.id) + ": " + chat.text;
+        $teamChat.append($('<span/>').text(line), '<br/>');
+    }
+
+    function handleChat(data) {
+        var chat = JSON.parse(data);
+        var where = chat.scope === "room" ? "#" + chat.room : chat.scope;
+        // The server escapes the name and the text.
+        var $line = $('<span/>').html((chat.from.name || chat.from.id) + ": " + chat.text);
+        var time = new Date(chat.time).toLocaleTimeString();
+        $chatLog.append($('<span/>').text("[" + where + " " + time + "] "), $line, '<br/>');
+    }
 
-        if (cowsbulls.bulls === currentNumberDigits) {
-            endGame(false);
+    function handleRoom(data) {
+        var room = JSON.parse(data);
+        var names = [];
+        for (var i = 0; i < room.members.length; i++) {
+            names.push(room.members[i].name || room.members[i].id);
+        }
+        $chatLog.append($('<span/>').text("Joined #" + room.room + " with " + names.join(", ") + "."), '<br/>');
+    }
+
+    function handleShutdown(data) {
+        var shutdown = JSON.parse(data);
+        var deadline = new Date(shutdown.deadline).toLocaleTimeString();
+        alert("The server is shutting down. Games still on are cancelled at " + deadline + ".");
+    }
+
+    function handleDuel(data) {
+        var duel = JSON.parse(data);
+        var opponent = duel.opponent.name || duel.opponent.id;
+        gameLog("Duel against " + opponent + ": think of a " + duel.digits + "-digit number and guess theirs.");
+    }
+
+    function handleDuelOver(data) {
+        endDuel(JSON.parse(data));
+    }
+
+    function handleRace(data) {
+        var race = JSON.parse(data);
+        if (!race.over) {
+            racing = true;
+            showStandings(race);
+            return;
+        }
+        racing = false;
+        inGame = false;
+        waitsForThink = false;
+        showRaceEnd(race);
+    }
+
+    function handleGameOver(data) {
+        var result = JSON.parse(data);
+        if (racing) {
+            // The race goes on until every guesser is done.
+            $('.numberInput').hide();
+            if (result.outcome === "win") {
+                gameLog("You guessed it in " + result.moves.length + " turns.");
+            } else {
+                gameLog("You are out of the race: " + result.reason);
+            }
+            return;
+        }
+        endGame(result);
+    }
+
+    function handleConnect(data) {
+        var connect = JSON.parse(data);
+        protocolVersion = connect.version;
+        playerId = connect.id;
+    }
+
+    function handleError(data) {
+        var error = JSON.parse(data);
+        console.log("server error " + error.code + ": " + error.message);
+        if (error.kind === "chat" || error.kind === "room" || error.kind === "teamchat") {
+            $chatLog.append($('<span/>').text("Not sent: " + error.message), '<br/>');
+            return;
+        }
+        switch (error.code) {
+        case "invalid_guess":
+            // The server is still waiting for a guess.
+            gameLog("Invalid guess: " + error.message);
+            $('.numberInput').show();
+            break;
+        case "invalid_role":
+        case "invalid_settings":
+        case "unknown_opponent":
+        case "too_many_games":
+        case "shutting_down":
+        case "kicked":
+            inGame = false;
+            waitsForThink = false;
+            showError(error);
+            resetGameField();
+            break;
+        default:
+            showError(error);
         }
     }
 
@@ -0,0 +1,85 @@
+$(function() {
+
+    var $summary = $('.summary');
+    var $moves = $('.movesTable tbody');
+    var $outcome = $('.outcome');
+
+    var replay;
+    var shown = 0;
+
+    initView();
+    loadGame(gameId());
+
+    function initView() {
+        $('.firstButton').click(function() { show(0); });
+        $('.prevButton').click(function() { show(shown - 1); });
+        $('.nextButton').click(function() { show(shown + 1); });
+        $('.lastButton').click(function() { show(replay.turns.length); });
+    }
+
+    function gameId() {
+        var match = /[?&]id=([^&]*)/.exec(window.location.search);
+        return match ? decodeURIComponent(match[1]) : "";
+    }
+
+    function loadGame(id) {
+        if (id === "") {
+            $summary.text("No game to replay.");
+            return;
+        }
+        $.getJSON("/games/" + encodeURIComponent(id))
+            .done(function(data) {
+                replay = data;
+                showSummary();
+                show(0);
+            })
+            .fail(function() {
+                $summary.text("The game " + id + " could not be found.");
+            });
+    }
+
+    function showSummary() {
+        var guessers = $.map(replay.guessers || [], playerName).join(", ");
+        $summary.text(guessers + " guessing the " + replay.result.digits +
+            "-digit number of " + playerName(replay.thinker) + ", " +
+            new Date(replay.started).toLocaleString() + ".");
+    }
+
+    function playerName(player) {
+        return player.name || player.id;
+    }
+
+    // show shows the first n turns of the game.
+    function show(n) {
+        n = Math.max(0, Math.min(n, replay.turns.length));
+        shown = n;
+        $moves.empty();
+        for (var i = 0; i < n; i++) {
+            var turn = replay.turns[i];
+            var $row = $('<tr/>');
+            $row.append($('<td/>').text(i + 1));
+            $row.append($('<td/>').text(turn.guess));
+            $row.append($('<td/>').text(turn.cows));
+            $row.append($('<td/>').text(turn.bulls));
+            $row.append($('<td/>').text(turn.possible || "-"));
+            $row.append($('<td/>').text(turn.optimal || "-"));
+            $moves.append($row);
+        }
+        $outcome.text(n === replay.turns.length ? outcomeText() : "");
+    }
+
+    function outcomeText() {
+        var result = replay.result;
+        var text;
+        if (result.outcome === "win") {
+            text = "The number " + result.secret + " was guessed in " + result.moves.length + " moves.";
+        } else {
+            text = "The game is over (" + result.outcome + "): " + result.reason;
+            if (result.secret) {
+                text += " The number was " + result.secret + ".";
+            }
+        }
+        return text;
+    }
+
+});
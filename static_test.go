@@ -0,0 +1,113 @@
+package cowbull_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/Bo0mer/cowbull"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Static content", func() {
+	var server *Server
+
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	Context("by default", func() {
+		BeforeEach(func() {
+			server = NewServer(&ServerConfig{Log: slog.New(slog.NewTextHandler(GinkgoWriter, nil))})
+		})
+
+		It("should serve the content built into the binary", func() {
+			w := get("/", "")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Header().Get("Content-Type")).To(HavePrefix("text/html"))
+			Expect(w.Body.String()).To(ContainSubstring(`<script src="/vendor/jquery-3.6.1.min.js"></script>`))
+			Expect(get("/main.js", "").Code).To(Equal(http.StatusOK))
+		})
+
+		It("should serve jQuery itself", func() {
+			w := get("/vendor/jquery-3.6.1.min.js", "")
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(HavePrefix("/*! jQuery v3.6.1"))
+		})
+
+		It("should serve the jQuery recorded in the README", func() {
+			sum := sha256.Sum256(get("/vendor/jquery-3.6.1.min.js", "").Body.Bytes())
+			Expect(hex.EncodeToString(sum[:])).To(Equal("03378a725b68b791419d83f47f10ff7ca5819c7d9d1dadba9edd26ef2ce588fd"))
+		})
+
+		It("should make browsers check their copies of the pages every time", func() {
+			w := get("/", "")
+			Expect(w.Header().Get("Cache-Control")).To(Equal("no-cache"))
+			etag := w.Header().Get("ETag")
+			Expect(etag).To(MatchRegexp(`^"[0-9a-f]+"$`))
+			Expect(get("/index.html", "").Code).To(Equal(http.StatusMovedPermanently))
+			Expect(get("/main.js", "").Header().Get("ETag")).NotTo(Equal(etag))
+
+			w = get("/", etag)
+			Expect(w.Code).To(Equal(http.StatusNotModified))
+			Expect(w.Body.Len()).To(BeZero())
+			Expect(get("/", `"stale"`).Code).To(Equal(http.StatusOK))
+		})
+
+		It("should let browsers keep vendored files for good", func() {
+			w := get("/vendor/jquery-3.6.1.min.js", "")
+			Expect(w.Header().Get("Cache-Control")).To(Equal("public, max-age=31536000, immutable"))
+			Expect(w.Header().Get("ETag")).NotTo(BeEmpty())
+		})
+
+		It("should not find unknown files", func() {
+			w := get("/missing.js", "")
+			Expect(w.Code).To(Equal(http.StatusNotFound))
+			Expect(w.Header().Get("ETag")).To(BeEmpty())
+			Expect(w.Header().Get("Cache-Control")).To(BeEmpty())
+		})
+	})
+
+	Context("from a directory", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "cowbull-static")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.WriteFile(filepath.Join(dir, "index.html"), []byte("<p>v1</p>"), 0644)).To(Succeed())
+			server = NewServer(&ServerConfig{
+				Log:             slog.New(slog.NewTextHandler(GinkgoWriter, nil)),
+				StaticFilesPath: dir,
+			})
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should serve the files as they change", func() {
+			w := get("/", "")
+			Expect(w.Body.String()).To(Equal("<p>v1</p>"))
+			etag := w.Header().Get("ETag")
+
+			Expect(os.WriteFile(filepath.Join(dir, "index.html"), []byte("<p>v2!</p>"), 0644)).To(Succeed())
+			w = get("/", etag)
+			Expect(w.Code).To(Equal(http.StatusOK))
+			Expect(w.Body.String()).To(Equal("<p>v2!</p>"))
+			Expect(w.Header().Get("ETag")).NotTo(Equal(etag))
+		})
+	})
+})
@@ -0,0 +1,91 @@
+package cowbull
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Bo0mer/cowbull/game"
+)
+
+//go:generate counterfeiter . Store
+
+// GameRecord describes a finished game.
+type GameRecord struct {
+	ID       string       `json:"id"`
+	Settings GameSettings `json:"settings"`
+	// Thinker and Guessers are the participants. The computer takes part
+	// with AIPlayer as its ID.
+	Thinker  PlayerEntry   `json:"thinker"`
+	Guessers []PlayerEntry `json:"guessers"`
+	Result   game.Result   `json:"result"`
+	Started  time.Time     `json:"started"`
+	Finished time.Time     `json:"finished"`
+}
+
+// AIPlayer is the ID of the computer in game records.
+const AIPlayer = "ai"
+
+// ErrGameNotFound is returned by a Store asked for a game it has no record
+// of.
+var ErrGameNotFound = errors.New("game not found")
+
+// Store records finished games.
+type Store interface {
+	// Record records a finished game.
+	Record(GameRecord) error
+	// Game returns the record of the game with an ID, or ErrGameNotFound.
+	Game(id string) (GameRecord, error)
+	// Games returns the records of all games, in the order they were
+	// recorded.
+	Games() ([]GameRecord, error)
+}
+
+// NewGameRecord describes a game played with settings and finished just now.
+// Unless the game has an ID, the record is given a new one.
+func NewGameRecord(g *game.Game, settings GameSettings, started time.Time) GameRecord {
+	id := g.ID()
+	if id == "" {
+		id = newGameID()
+	}
+	return GameRecord{
+		ID:       id,
+		Settings: settings,
+		Thinker:  participants(g.Thinker())[0],
+		Guessers: participants(g.Guesser()),
+		Result:   g.Result(),
+		Started:  started,
+		Finished: time.Now(),
+	}
+}
+
+// participants returns the entries of the players behind a game player.
+func participants(p interface{}) []PlayerEntry {
+	switch p := p.(type) {
+	case Player:
+		return []PlayerEntry{{ID: p.ID(), Name: p.Name()}}
+	case *MultiGuesser:
+		var entries []PlayerEntry
+		for _, player := range p.all() {
+			entries = append(entries, PlayerEntry{ID: player.ID(), Name: player.Name()})
+		}
+		return entries
+	case *TeamGuesser:
+		var entries []PlayerEntry
+		for _, player := range p.Players {
+			entries = append(entries, PlayerEntry{ID: player.ID(), Name: player.Name()})
+		}
+		return entries
+	}
+	return []PlayerEntry{{ID: AIPlayer, Name: "computer"}}
+}
+
+// newGameID returns a random game ID.
+func newGameID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
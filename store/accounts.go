@@ -0,0 +1,103 @@
+package store
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Bo0mer/cowbull"
+)
+
+// JSONLAccounts keeps accounts in a file, one JSON object per account and
+// line. The accounts are read once, when the file is opened, and kept in
+// memory. It is safe for concurrent use.
+type JSONLAccounts struct {
+	mu         sync.Mutex
+	f          *os.File
+	enc        *json.Encoder
+	byUsername map[string]cowbull.Account
+	byToken    map[string]cowbull.Account
+}
+
+// OpenJSONLAccounts opens the file at path for keeping accounts, creating it
+// if needed.
+func OpenJSONLAccounts(path string) (*JSONLAccounts, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	s := &JSONLAccounts{
+		f:          f,
+		enc:        json.NewEncoder(f),
+		byUsername: make(map[string]cowbull.Account),
+		byToken:    make(map[string]cowbull.Account),
+	}
+	dec := json.NewDecoder(f)
+	for {
+		var a cowbull.Account
+		err := dec.Decode(&a)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		s.add(a)
+	}
+	return s, nil
+}
+
+func (s *JSONLAccounts) add(a cowbull.Account) {
+	s.byUsername[a.Username] = a
+	s.byToken[a.TokenHash] = a
+}
+
+// CreateAccount appends an account to the file, unless its username is
+// taken.
+func (s *JSONLAccounts) CreateAccount(a cowbull.Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byUsername[a.Username]; ok {
+		return cowbull.ErrUsernameTaken
+	}
+	if err := s.enc.Encode(a); err != nil {
+		return err
+	}
+	s.add(a)
+	return nil
+}
+
+// Account looks up the account with username.
+func (s *JSONLAccounts) Account(username string) (cowbull.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.byUsername[username]
+	if !ok {
+		return cowbull.Account{}, cowbull.ErrAccountNotFound
+	}
+	return a, nil
+}
+
+// AccountByToken looks up the account with tokenHash.
+func (s *JSONLAccounts) AccountByToken(tokenHash string) (cowbull.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.byToken[tokenHash]
+	if !ok {
+		return cowbull.Account{}, cowbull.ErrAccountNotFound
+	}
+	return a, nil
+}
+
+// Close flushes the file to disk and closes it.
+func (s *JSONLAccounts) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.f.Sync(); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
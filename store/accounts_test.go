@@ -0,0 +1,101 @@
+package store_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Bo0mer/cowbull"
+	. "github.com/Bo0mer/cowbull/store"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newAccount returns an account of username.
+func newAccount(username string) cowbull.Account {
+	return cowbull.Account{
+		ID:           "u-" + username,
+		Username:     username,
+		PasswordHash: "hash-" + username,
+		TokenHash:    "token-" + username,
+		Created:      time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// describeAccountStore describes the behaviour shared by account stores,
+// opened at path by open.
+func describeAccountStore(open func(path string) (cowbull.AccountStore, func() error)) {
+	var dir, path string
+	var s cowbull.AccountStore
+	var closeStore func() error
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "cowbull-accounts")
+		Ω(err).ShouldNot(HaveOccurred())
+		path = filepath.Join(dir, "accounts")
+		s, closeStore = open(path)
+	})
+
+	AfterEach(func() {
+		closeStore()
+		os.RemoveAll(dir)
+	})
+
+	It("should look up accounts by username and token", func() {
+		Ω(s.CreateAccount(newAccount("alice"))).Should(Succeed())
+		Ω(s.CreateAccount(newAccount("bob"))).Should(Succeed())
+
+		a, err := s.Account("bob")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(a).Should(Equal(newAccount("bob")))
+
+		a, err = s.AccountByToken("token-alice")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(a).Should(Equal(newAccount("alice")))
+
+		_, err = s.Account("carol")
+		Ω(err).Should(Equal(cowbull.ErrAccountNotFound))
+		_, err = s.AccountByToken("token-carol")
+		Ω(err).Should(Equal(cowbull.ErrAccountNotFound))
+	})
+
+	It("should reject a taken username", func() {
+		Ω(s.CreateAccount(newAccount("alice"))).Should(Succeed())
+		other := newAccount("alice")
+		other.ID, other.TokenHash = "u-other", "token-other"
+		Ω(s.CreateAccount(other)).Should(Equal(cowbull.ErrUsernameTaken))
+
+		a, err := s.Account("alice")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(a.ID).Should(Equal("u-alice"))
+	})
+
+	It("should keep the accounts once reopened", func() {
+		Ω(s.CreateAccount(newAccount("alice"))).Should(Succeed())
+		Ω(closeStore()).Should(Succeed())
+
+		s, closeStore = open(path)
+		a, err := s.Account("alice")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(a).Should(Equal(newAccount("alice")))
+	})
+}
+
+var _ = Describe("JSONLAccounts", func() {
+	describeAccountStore(func(path string) (cowbull.AccountStore, func() error) {
+		s, err := OpenJSONLAccounts(path)
+		Ω(err).ShouldNot(HaveOccurred())
+		return s, s.Close
+	})
+})
+
+var _ = Describe("SQL accounts", func() {
+	describeAccountStore(func(path string) (cowbull.AccountStore, func() error) {
+		s, err := OpenSQLite(path)
+		Ω(err).ShouldNot(HaveOccurred())
+		return s, s.Close
+	})
+})
@@ -0,0 +1,113 @@
+// Package store provides backends recording finished cowbull games.
+package store
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/Bo0mer/cowbull"
+)
+
+// JSONL records games to a file, appending one JSON object per game and
+// line. It is safe for concurrent use.
+type JSONL struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	enc  *json.Encoder
+}
+
+// OpenJSONL opens the file at path for recording games, creating it if
+// needed.
+func OpenJSONL(path string) (*JSONL, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSONL{path: path, f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Record appends a game to the file.
+func (s *JSONL) Record(r cowbull.GameRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(r)
+}
+
+// Game looks up the game with id, reading the whole file. Should the game
+// have been recorded more than once, the last record wins.
+func (s *JSONL) Game(id string) (cowbull.GameRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Open(s.path)
+	if err != nil {
+		return cowbull.GameRecord{}, err
+	}
+	defer f.Close()
+
+	var found *cowbull.GameRecord
+	dec := json.NewDecoder(f)
+	for {
+		var r cowbull.GameRecord
+		err := dec.Decode(&r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return cowbull.GameRecord{}, err
+		}
+		if r.ID == id {
+			found = &r
+		}
+	}
+	if found == nil {
+		return cowbull.GameRecord{}, cowbull.ErrGameNotFound
+	}
+	return *found, nil
+}
+
+// Games reads all games from the file.
+func (s *JSONL) Games() ([]cowbull.GameRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []cowbull.GameRecord
+	dec := json.NewDecoder(f)
+	for {
+		var r cowbull.GameRecord
+		err := dec.Decode(&r)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+}
+
+// Ping checks that the file is still open.
+func (s *JSONL) Ping() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.f.Stat()
+	return err
+}
+
+// Close flushes the file to disk and closes it.
+func (s *JSONL) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.f.Sync(); err != nil {
+		s.f.Close()
+		return err
+	}
+	return s.f.Close()
+}
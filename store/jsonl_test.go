@@ -0,0 +1,123 @@
+package store_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/game"
+	. "github.com/Bo0mer/cowbull/store"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// newRecord returns a record of a game won by alice in two moves.
+func newRecord(id string) cowbull.GameRecord {
+	started := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	return cowbull.GameRecord{
+		ID:       id,
+		Settings: cowbull.GameSettings{Role: cowbull.RoleGuesser, AI: true, Digits: 4},
+		Thinker:  cowbull.PlayerEntry{ID: cowbull.AIPlayer, Name: "computer"},
+		Guessers: []cowbull.PlayerEntry{{ID: "alice-id", Name: "alice"}},
+		Result: game.Result{
+			ID:      id,
+			Outcome: game.OutcomeWin,
+			Secret:  "1234",
+			Digits:  4,
+			Moves: []game.Move{
+				{Guess: "4321", Cows: 4},
+				{Guess: "1234", Bulls: 4},
+			},
+		},
+		Started:  started,
+		Finished: started.Add(time.Minute),
+	}
+}
+
+var _ = Describe("JSONL", func() {
+	var dir, path string
+	var s *JSONL
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "cowbull-store")
+		Ω(err).ShouldNot(HaveOccurred())
+		path = filepath.Join(dir, "games.jsonl")
+		s, err = OpenJSONL(path)
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	readRecords := func() []cowbull.GameRecord {
+		f, err := os.Open(path)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer f.Close()
+		var records []cowbull.GameRecord
+		lines := bufio.NewScanner(f)
+		for lines.Scan() {
+			var r cowbull.GameRecord
+			Ω(json.Unmarshal(lines.Bytes(), &r)).Should(Succeed())
+			records = append(records, r)
+		}
+		return records
+	}
+
+	It("should append a line per game", func() {
+		Ω(s.Record(newRecord("a"))).Should(Succeed())
+		Ω(s.Record(newRecord("b"))).Should(Succeed())
+		Ω(s.Close()).Should(Succeed())
+		Ω(readRecords()).Should(Equal([]cowbull.GameRecord{newRecord("a"), newRecord("b")}))
+	})
+
+	It("should keep the games recorded before it was opened", func() {
+		Ω(s.Record(newRecord("a"))).Should(Succeed())
+		Ω(s.Close()).Should(Succeed())
+
+		var err error
+		s, err = OpenJSONL(path)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(s.Record(newRecord("b"))).Should(Succeed())
+		Ω(s.Close()).Should(Succeed())
+		Ω(readRecords()).Should(HaveLen(2))
+	})
+
+	It("should look up recorded games", func() {
+		Ω(s.Record(newRecord("a"))).Should(Succeed())
+		Ω(s.Record(newRecord("b"))).Should(Succeed())
+
+		r, err := s.Game("b")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(r).Should(Equal(newRecord("b")))
+
+		_, err = s.Game("c")
+		Ω(err).Should(Equal(cowbull.ErrGameNotFound))
+	})
+
+	It("should list all games in order", func() {
+		Ω(s.Record(newRecord("b"))).Should(Succeed())
+		Ω(s.Record(newRecord("a"))).Should(Succeed())
+
+		records, err := s.Games()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(records).Should(Equal([]cowbull.GameRecord{newRecord("b"), newRecord("a")}))
+	})
+
+	It("should fail to record once closed", func() {
+		Ω(s.Close()).Should(Succeed())
+		Ω(s.Record(newRecord("a"))).ShouldNot(Succeed())
+	})
+
+	It("should answer pings until closed", func() {
+		Ω(s.Ping()).Should(Succeed())
+		Ω(s.Close()).Should(Succeed())
+		Ω(s.Ping()).ShouldNot(Succeed())
+	})
+})
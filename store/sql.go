@@ -0,0 +1,271 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/game"
+)
+
+// schema creates the tables of SQL, unless they exist.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS games (
+		id       TEXT PRIMARY KEY,
+		settings TEXT NOT NULL,
+		digits   INTEGER NOT NULL,
+		outcome  TEXT NOT NULL,
+		reason   TEXT NOT NULL,
+		secret   TEXT NOT NULL,
+		started  TEXT NOT NULL,
+		finished TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS participants (
+		game_id   TEXT NOT NULL REFERENCES games (id),
+		player_id TEXT NOT NULL,
+		name      TEXT NOT NULL,
+		role      TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS moves (
+		game_id TEXT NOT NULL REFERENCES games (id),
+		turn    INTEGER NOT NULL,
+		guess   TEXT NOT NULL,
+		cows    INTEGER NOT NULL,
+		bulls   INTEGER NOT NULL,
+		PRIMARY KEY (game_id, turn)
+	)`,
+	`CREATE TABLE IF NOT EXISTS accounts (
+		id            TEXT PRIMARY KEY,
+		username      TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL,
+		token_hash    TEXT NOT NULL UNIQUE,
+		created       TEXT NOT NULL
+	)`,
+}
+
+// SQL records games and keeps accounts in an SQL database. Statements are
+// written for SQLite.
+type SQL struct {
+	db *sql.DB
+}
+
+// NewSQL records games and keeps accounts in db, creating the tables needed.
+func NewSQL(db *sql.DB) (*SQL, error) {
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			return nil, err
+		}
+	}
+	return &SQL{db: db}, nil
+}
+
+// Record inserts a game, its participants and moves.
+func (s *SQL) Record(r cowbull.GameRecord) error {
+	settings, err := json.Marshal(r.Settings)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO games (id, settings, digits, outcome, reason, secret, started, finished)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.ID, string(settings), r.Result.Digits, r.Result.Outcome, r.Result.Reason, r.Result.Secret,
+		r.Started.UTC().Format(time.RFC3339Nano), r.Finished.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return err
+	}
+
+	if err := insertParticipant(tx, r.ID, r.Thinker, cowbull.RoleThinker); err != nil {
+		return err
+	}
+	for _, g := range r.Guessers {
+		if err := insertParticipant(tx, r.ID, g, cowbull.RoleGuesser); err != nil {
+			return err
+		}
+	}
+
+	for i, m := range r.Result.Moves {
+		_, err = tx.Exec(`INSERT INTO moves (game_id, turn, guess, cows, bulls) VALUES (?, ?, ?, ?, ?)`,
+			r.ID, i+1, m.Guess, m.Cows, m.Bulls)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func insertParticipant(tx *sql.Tx, gameID string, p cowbull.PlayerEntry, role string) error {
+	_, err := tx.Exec(`INSERT INTO participants (game_id, player_id, name, role) VALUES (?, ?, ?, ?)`,
+		gameID, p.ID, p.Name, role)
+	return err
+}
+
+// Game looks up the game with id.
+func (s *SQL) Game(id string) (cowbull.GameRecord, error) {
+	r := cowbull.GameRecord{ID: id}
+	var settings, started, finished string
+	err := s.db.QueryRow(`SELECT settings, digits, outcome, reason, secret, started, finished
+		FROM games WHERE id = ?`, id).
+		Scan(&settings, &r.Result.Digits, &r.Result.Outcome, &r.Result.Reason, &r.Result.Secret, &started, &finished)
+	if err == sql.ErrNoRows {
+		return r, cowbull.ErrGameNotFound
+	}
+	if err != nil {
+		return r, err
+	}
+	r.Result.ID = id
+	if err := json.Unmarshal([]byte(settings), &r.Settings); err != nil {
+		return r, err
+	}
+	if r.Started, err = time.Parse(time.RFC3339Nano, started); err != nil {
+		return r, err
+	}
+	if r.Finished, err = time.Parse(time.RFC3339Nano, finished); err != nil {
+		return r, err
+	}
+
+	if err := s.participants(&r); err != nil {
+		return r, err
+	}
+	return r, s.moves(&r)
+}
+
+// Games looks up all games.
+func (s *SQL) Games() ([]cowbull.GameRecord, error) {
+	ids, err := s.gameIDs()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]cowbull.GameRecord, 0, len(ids))
+	for _, id := range ids {
+		r, err := s.Game(id)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// gameIDs returns the IDs of all games, in the order they were recorded.
+func (s *SQL) gameIDs() ([]string, error) {
+	rows, err := s.db.Query(`SELECT id FROM games ORDER BY rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// participants reads the participants of the game recorded as r.
+func (s *SQL) participants(r *cowbull.GameRecord) error {
+	rows, err := s.db.Query(`SELECT player_id, name, role FROM participants WHERE game_id = ? ORDER BY rowid`, r.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p cowbull.PlayerEntry
+		var role string
+		if err := rows.Scan(&p.ID, &p.Name, &role); err != nil {
+			return err
+		}
+		if role == cowbull.RoleThinker {
+			r.Thinker = p
+		} else {
+			r.Guessers = append(r.Guessers, p)
+		}
+	}
+	return rows.Err()
+}
+
+// moves reads the moves of the game recorded as r.
+func (s *SQL) moves(r *cowbull.GameRecord) error {
+	rows, err := s.db.Query(`SELECT guess, cows, bulls FROM moves WHERE game_id = ? ORDER BY turn`, r.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	r.Result.Moves = []game.Move{}
+	for rows.Next() {
+		var m game.Move
+		if err := rows.Scan(&m.Guess, &m.Cows, &m.Bulls); err != nil {
+			return err
+		}
+		r.Result.Moves = append(r.Result.Moves, m)
+	}
+	return rows.Err()
+}
+
+// CreateAccount inserts an account, unless its username is taken.
+func (s *SQL) CreateAccount(a cowbull.Account) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM accounts WHERE username = ?`, a.Username).Scan(&n); err != nil {
+		return err
+	}
+	if n > 0 {
+		return cowbull.ErrUsernameTaken
+	}
+	_, err = tx.Exec(`INSERT INTO accounts (id, username, password_hash, token_hash, created) VALUES (?, ?, ?, ?, ?)`,
+		a.ID, a.Username, a.PasswordHash, a.TokenHash, a.Created.UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Account looks up the account with username.
+func (s *SQL) Account(username string) (cowbull.Account, error) {
+	return s.account(`username = ?`, username)
+}
+
+// AccountByToken looks up the account with tokenHash.
+func (s *SQL) AccountByToken(tokenHash string) (cowbull.Account, error) {
+	return s.account(`token_hash = ?`, tokenHash)
+}
+
+// account looks up the account matching where.
+func (s *SQL) account(where string, arg string) (cowbull.Account, error) {
+	var a cowbull.Account
+	var created string
+	err := s.db.QueryRow(`SELECT id, username, password_hash, token_hash, created FROM accounts WHERE `+where, arg).
+		Scan(&a.ID, &a.Username, &a.PasswordHash, &a.TokenHash, &created)
+	if err == sql.ErrNoRows {
+		return a, cowbull.ErrAccountNotFound
+	}
+	if err != nil {
+		return a, err
+	}
+	a.Created, err = time.Parse(time.RFC3339Nano, created)
+	return a, err
+}
+
+// Ping checks that the database is reachable.
+func (s *SQL) Ping() error {
+	return s.db.Ping()
+}
+
+// Close closes the database.
+func (s *SQL) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,107 @@
+package store_test
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Bo0mer/cowbull"
+	. "github.com/Bo0mer/cowbull/store"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SQL", func() {
+	var dir, path string
+	var s *SQL
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "cowbull-store")
+		Ω(err).ShouldNot(HaveOccurred())
+		path = filepath.Join(dir, "games.db")
+		s, err = OpenSQLite(path)
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		s.Close()
+		os.RemoveAll(dir)
+	})
+
+	query := func(q string, args ...interface{}) []string {
+		db, err := sql.Open("sqlite", path)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer db.Close()
+		rows, err := db.Query(q, args...)
+		Ω(err).ShouldNot(HaveOccurred())
+		defer rows.Close()
+		var values []string
+		for rows.Next() {
+			var v string
+			Ω(rows.Scan(&v)).Should(Succeed())
+			values = append(values, v)
+		}
+		Ω(rows.Err()).ShouldNot(HaveOccurred())
+		return values
+	}
+
+	It("should record games, their participants and moves", func() {
+		Ω(s.Record(newRecord("a"))).Should(Succeed())
+		Ω(s.Record(newRecord("b"))).Should(Succeed())
+
+		Ω(query(`SELECT id || ':' || outcome || ':' || secret FROM games ORDER BY id`)).
+			Should(Equal([]string{"a:win:1234", "b:win:1234"}))
+		Ω(query(`SELECT name || ':' || role FROM participants WHERE game_id = ? ORDER BY role`, "a")).
+			Should(Equal([]string{"alice:guesser", "computer:thinker"}))
+		Ω(query(`SELECT turn || ':' || guess FROM moves WHERE game_id = ? ORDER BY turn`, "a")).
+			Should(Equal([]string{"1:4321", "2:1234"}))
+		Ω(query(`SELECT started FROM games WHERE id = ?`, "a")).
+			Should(Equal([]string{"2026-10-19T12:00:00Z"}))
+	})
+
+	It("should look up recorded games", func() {
+		Ω(s.Record(newRecord("a"))).Should(Succeed())
+		Ω(s.Record(newRecord("b"))).Should(Succeed())
+
+		r, err := s.Game("b")
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(r).Should(Equal(newRecord("b")))
+
+		_, err = s.Game("c")
+		Ω(err).Should(Equal(cowbull.ErrGameNotFound))
+	})
+
+	It("should list all games in order", func() {
+		Ω(s.Record(newRecord("b"))).Should(Succeed())
+		Ω(s.Record(newRecord("a"))).Should(Succeed())
+
+		records, err := s.Games()
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(records).Should(Equal([]cowbull.GameRecord{newRecord("b"), newRecord("a")}))
+	})
+
+	It("should reject a game recorded twice", func() {
+		Ω(s.Record(newRecord("a"))).Should(Succeed())
+		Ω(s.Record(newRecord("a"))).ShouldNot(Succeed())
+		Ω(query(`SELECT turn FROM moves`)).Should(HaveLen(2))
+	})
+
+	It("should answer pings until closed", func() {
+		Ω(s.Ping()).Should(Succeed())
+		Ω(s.Close()).Should(Succeed())
+		Ω(s.Ping()).ShouldNot(Succeed())
+	})
+
+	It("should keep the games once reopened", func() {
+		Ω(s.Record(newRecord("a"))).Should(Succeed())
+		Ω(s.Close()).Should(Succeed())
+
+		var err error
+		s, err = OpenSQLite(path)
+		Ω(err).ShouldNot(HaveOccurred())
+		Ω(query(`SELECT id FROM games`)).Should(Equal([]string{"a"}))
+	})
+})
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"database/sql"
+
+	// Pure Go SQLite driver, registered as "sqlite".
+	_ "github.com/glebarez/go-sqlite"
+)
+
+// OpenSQLite records games and keeps accounts in the SQLite database file at
+// path, creating it if needed.
+func OpenSQLite(path string) (*SQL, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer at a time anyway.
+	db.SetMaxOpenConns(1)
+	s, err := NewSQL(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
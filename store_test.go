@@ -0,0 +1,58 @@
+package cowbull_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("NewGameRecord", func() {
+	var thinker *cowbullfakes.FakePlayer
+	var started time.Time
+
+	BeforeEach(func() {
+		thinker = new(cowbullfakes.FakePlayer)
+		thinker.IDReturns("alice-id")
+		thinker.NameReturns("alice")
+		thinker.ThinkReturns(0, errors.New("not in the mood"))
+		started = time.Now()
+	})
+
+	It("should describe a game against the computer", func() {
+		g := game.New(thinker, LocalGuesser(4))
+		Ω(g.Play()).ShouldNot(Succeed())
+		settings := GameSettings{Role: RoleThinker, AI: true, Digits: 4}
+
+		r := NewGameRecord(g, settings, started)
+		Ω(r.ID).ShouldNot(BeEmpty())
+		Ω(r.Settings).Should(Equal(settings))
+		Ω(r.Thinker).Should(Equal(PlayerEntry{ID: "alice-id", Name: "alice"}))
+		Ω(r.Guessers).Should(Equal([]PlayerEntry{{ID: AIPlayer, Name: "computer"}}))
+		Ω(r.Result.Outcome).Should(Equal(game.OutcomeAbort))
+		Ω(r.Started).Should(Equal(started))
+		Ω(r.Finished).ShouldNot(BeTemporally("<", started))
+	})
+
+	It("should list every guesser of a multi-guesser game", func() {
+		bob, carol := new(cowbullfakes.FakePlayer), new(cowbullfakes.FakePlayer)
+		bob.IDReturns("bob-id")
+		carol.IDReturns("carol-id")
+		g := game.New(thinker, &MultiGuesser{Players: []Player{bob, carol}})
+		g.Play()
+
+		r := NewGameRecord(g, GameSettings{}, started)
+		Ω(r.Guessers).Should(Equal([]PlayerEntry{{ID: "bob-id"}, {ID: "carol-id"}}))
+	})
+
+	It("should give every game a new ID", func() {
+		g := game.New(thinker, LocalGuesser(4))
+		g.Play()
+		Ω(NewGameRecord(g, GameSettings{}, started).ID).ShouldNot(Equal(NewGameRecord(g, GameSettings{}, started).ID))
+	})
+})
@@ -0,0 +1,104 @@
+package cowbull
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// Strategy picks the next guess out of the numbers that are still possible.
+// The candidates are never empty and are in ascending order.
+type Strategy func(candidates []string) string
+
+// Strategies are the known guessing strategies by name.
+var Strategies = map[string]Strategy{
+	"random": RandomStrategy,
+	"first":  FirstStrategy,
+	"minmax": MinMaxStrategy,
+}
+
+// RandomStrategy guesses any of the possible numbers.
+func RandomStrategy(candidates []string) string {
+	return candidates[rand.Intn(len(candidates))]
+}
+
+// FirstStrategy guesses the smallest possible number.
+func FirstStrategy(candidates []string) string {
+	return candidates[0]
+}
+
+// MinMaxStrategy guesses the possible number that leaves the fewest numbers
+// possible in the worst case, as Knuth did for Mastermind. Ties are broken
+// in favour of the smaller number.
+func MinMaxStrategy(candidates []string) string {
+	n := len(candidates[0])
+	if len(candidates) == countNumbers(n) {
+		// Every number is possible, and every number splits them alike.
+		return candidates[0]
+	}
+
+	best, bestWorst := candidates[0], len(candidates)+1
+	var counts [scoreCount]int
+	for _, guess := range candidates {
+		counts = [scoreCount]int{}
+		worst := 0
+		for _, c := range candidates {
+			k := scoreKey(computeCowsBulls(c, guess))
+			counts[k]++
+			if counts[k] > worst {
+				worst = counts[k]
+			}
+			if worst >= bestWorst {
+				break
+			}
+		}
+		if worst < bestWorst {
+			best, bestWorst = guess, worst
+		}
+	}
+	return best
+}
+
+// numbers caches the numbers by digit count, as listing them is costly.
+var numbers struct {
+	sync.Mutex
+	byDigits [11][]string
+}
+
+// Numbers returns all n-digit numbers with distinct digits, including the
+// ones starting with zero, in ascending order.
+func Numbers(n int) []string {
+	if n < 1 || n > 10 {
+		return nil
+	}
+	numbers.Lock()
+	defer numbers.Unlock()
+	if numbers.byDigits[n] == nil {
+		set := make(map[string]struct{}, countNumbers(n))
+		variations([]byte("0123456789"), n, 0, set)
+		all := make([]string, 0, len(set))
+		for number := range set {
+			all = append(all, number)
+		}
+		sort.Strings(all)
+		numbers.byDigits[n] = all
+	}
+	return append([]string(nil), numbers.byDigits[n]...)
+}
+
+// countNumbers returns the count of n-digit numbers with distinct digits.
+func countNumbers(n int) int {
+	count := 1
+	for i := 0; i < n; i++ {
+		count *= 10 - i
+	}
+	return count
+}
+
+// scoreCount is an upper bound of the distinct scores of a guess.
+const scoreCount = 11 * 11
+
+// scoreKey maps a score to an index below scoreCount.
+func scoreKey(cows, bulls int) int {
+	return cows*11 + bulls
+}
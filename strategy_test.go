@@ -0,0 +1,75 @@
+package cowbull_test
+
+import (
+	. "github.com/Bo0mer/cowbull"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Numbers", func() {
+	It("should list the numbers with distinct digits in order", func() {
+		numbers := Numbers(2)
+		Ω(numbers).Should(HaveLen(90))
+		Ω(numbers[0]).Should(Equal("01"))
+		Ω(numbers[89]).Should(Equal("98"))
+		Ω(numbers).ShouldNot(ContainElement("11"))
+	})
+
+	It("should list nothing for invalid digit counts", func() {
+		Ω(Numbers(0)).Should(BeEmpty())
+		Ω(Numbers(11)).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("Strategies", func() {
+	candidates := []string{"1234", "1243", "2134", "4321"}
+
+	DescribeTable("should pick one of the candidates",
+		func(name string) {
+			strategy, ok := Strategies[name]
+			Ω(ok).Should(BeTrue())
+			Ω(candidates).Should(ContainElement(strategy(candidates)))
+		},
+		Entry("random", "random"),
+		Entry("first", "first"),
+		Entry("minmax", "minmax"),
+	)
+
+	It("should pick the first candidate with FirstStrategy", func() {
+		Ω(FirstStrategy(candidates)).Should(Equal("1234"))
+	})
+
+	It("should pick the guess that splits the candidates best with MinMaxStrategy", func() {
+		// 0534 tells all of them apart, unlike 0281 which scores 0594 and
+		// 0613 alike.
+		Ω(MinMaxStrategy([]string{"0281", "0534", "0594", "0613"})).Should(Equal("0534"))
+	})
+
+	DescribeTable("AIGuesser should guess every number",
+		func(name string, digits, maxTries int) {
+			numbers := Numbers(digits)
+			// Every 7th number is plenty to catch a broken strategy.
+			for i := 0; i < len(numbers); i += 7 {
+				secret := numbers[i]
+				g := NewAIGuesser(digits, Strategies[name])
+				tries := 0
+				for {
+					tries++
+					guess, err := g.Guess(digits)
+					Ω(err).ShouldNot(HaveOccurred())
+					if guess == secret {
+						break
+					}
+					cows, bulls := Score(secret, guess)
+					Ω(g.Tell(guess, cows, bulls)).Should(Succeed())
+				}
+				Ω(tries).Should(BeNumerically("<=", maxTries), secret)
+			}
+		},
+		Entry("first, two digits", "first", 2, 90),
+		Entry("minmax, two digits", "minmax", 2, 10),
+		Entry("minmax, three digits", "minmax", 3, 10),
+	)
+})
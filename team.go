@@ -0,0 +1,289 @@
+package cowbull
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+// Ways a team decides on its guess.
+const (
+	DecideByVote    = protocol.DecideByVote
+	DecideByCaptain = protocol.DecideByCaptain
+)
+
+// maxTeamChat is how many characters a team chat message may have.
+const maxTeamChat = 500
+
+// teamObserver may be implemented by a Player that wants to take part in
+// the decisions of its team.
+type teamObserver interface {
+	AnnounceProposals(protocol.Proposals) error
+	TeamChat(protocol.TeamChat) error
+}
+
+// TeamGuesser makes a team of guessers guess as one.
+// Every turn, each member is asked for a guess, its proposal. The members
+// are shown the proposals and asked to guess again: with DecideByVote, the
+// guess with the most votes is the team guess, while with DecideByCaptain
+// the captain, the first of the players, alone picks it. All members are
+// told the score of the team guess.
+type TeamGuesser struct {
+	Players []Player
+	// Decision is how the team decides. Empty means DecideByVote.
+	Decision string
+	// Log is where failures to tell the members are logged. Nil means the
+	// default logger.
+	Log *slog.Logger
+
+	mu   sync.Mutex
+	over bool
+}
+
+// Guess asks the team for its guess. Members that fail are left out of
+// the turn. If all of them fail, the last failure is returned.
+func (t *TeamGuesser) Guess(n int) (string, error) {
+	proposals, err := t.ask(t.Players, n)
+	if err != nil {
+		return "", err
+	}
+	if len(t.Players) == 1 {
+		return proposals[0].Number, nil
+	}
+	t.announce(proposals)
+
+	voters := t.Players
+	if t.Decision == DecideByCaptain {
+		voters = voters[:1]
+	}
+	votes, err := t.ask(voters, n)
+	if err != nil {
+		// Nobody decided, so the proposals decide.
+		votes = proposals
+	}
+	return tally(proposals, votes), nil
+}
+
+// ask asks players to guess at once and returns their guesses, in the order
+// of the players. If all of them fail, the last failure is returned.
+func (t *TeamGuesser) ask(players []Player, n int) ([]protocol.Proposal, error) {
+	guesses := make([]string, len(players))
+	errs := make([]error, len(players))
+	var wg sync.WaitGroup
+	for i, p := range players {
+		wg.Add(1)
+		go func(i int, p Player) {
+			defer wg.Done()
+			guesses[i], errs[i] = p.Guess(n)
+		}(i, p)
+	}
+	wg.Wait()
+
+	var proposals []protocol.Proposal
+	var err error
+	for i, p := range players {
+		if errs[i] != nil {
+			err = errs[i]
+			continue
+		}
+		proposals = append(proposals, protocol.Proposal{
+			Player: PlayerEntry{ID: p.ID(), Name: p.Name()},
+			Number: guesses[i],
+		})
+	}
+	if len(proposals) == 0 {
+		return nil, err
+	}
+	return proposals, nil
+}
+
+// announce shows the members the proposals of the turn.
+func (t *TeamGuesser) announce(proposals []protocol.Proposal) {
+	decision := t.Decision
+	if decision == "" {
+		decision = DecideByVote
+	}
+	captain := t.Players[0]
+	msg := protocol.Proposals{
+		Decision:  decision,
+		Captain:   PlayerEntry{ID: captain.ID(), Name: captain.Name()},
+		Proposals: proposals,
+	}
+	for _, p := range t.Players {
+		if o, ok := p.(teamObserver); ok {
+			if err := o.AnnounceProposals(msg); err != nil {
+				logger(t.Log).Warn("error announcing proposals", "player_id", p.ID(), "err", err)
+			}
+		}
+	}
+}
+
+// tally returns the number with the most votes. Ties go to the number
+// proposed first, or else voted for first.
+func tally(proposals, votes []protocol.Proposal) string {
+	count := make(map[string]int)
+	for _, v := range votes {
+		count[v.Number]++
+	}
+	var best string
+	for _, p := range append(append([]protocol.Proposal(nil), proposals...), votes...) {
+		if count[p.Number] > count[best] {
+			best = p.Number
+		}
+	}
+	return best
+}
+
+// Tell tells all members the score of the team guess. It fails only if
+// all of them fail.
+func (t *TeamGuesser) Tell(number string, cows, bulls int) error {
+	var err error
+	told := 0
+	for _, p := range t.Players {
+		if e := p.Tell(number, cows, bulls); e != nil {
+			err = e
+			continue
+		}
+		told++
+	}
+	if told == 0 {
+		return err
+	}
+	return nil
+}
+
+// GameOver tells all members the result of the game.
+func (t *TeamGuesser) GameOver(r game.Result) {
+	t.mu.Lock()
+	t.over = true
+	t.mu.Unlock()
+
+	for _, p := range t.Players {
+		if o, ok := p.(game.Observer); ok {
+			o.GameOver(r)
+		}
+	}
+}
+
+// Chat relays a message from a member to all members, including the
+// sender. Empty and overlong messages are rejected with a
+// *protocol.Error.
+func (t *TeamGuesser) Chat(from Player, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return protocol.Errorf(protocol.CodeBadRequest, "empty message")
+	}
+	if utf8.RuneCountInString(text) > maxTeamChat {
+		return protocol.Errorf(protocol.CodeBadRequest, "message longer than %d characters", maxTeamChat)
+	}
+	msg := protocol.TeamChat{From: PlayerEntry{ID: from.ID(), Name: from.Name()}, Text: text}
+	for _, p := range t.Players {
+		if o, ok := p.(teamObserver); ok {
+			if err := o.TeamChat(msg); err != nil {
+				logger(t.Log).Warn("error relaying team chat", "player_id", p.ID(), "err", err)
+			}
+		}
+	}
+	return nil
+}
+
+// member reports whether the player with id is a member of the team.
+func (t *TeamGuesser) member(id string) bool {
+	for _, p := range t.Players {
+		if p.ID() == id {
+			return true
+		}
+	}
+	return false
+}
+
+// finished reports whether the game of the team is over.
+func (t *TeamGuesser) finished() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.over
+}
+
+// NewTeamGame creates a game in which a team guesses, requested by a player
+// with settings. A thinker plays against the opponents of the settings as a
+// team, while a guesser teams up with them against the computer, as the
+// captain.
+// If the settings do not make up a team game, the returned error is a
+// *protocol.Error describing why.
+func (h *Hub) NewTeamGame(from Player, settings GameSettings) (*game.Game, error) {
+	switch settings.Decision {
+	case "", DecideByVote, DecideByCaptain:
+	default:
+		return nil, protocol.Errorf(protocol.CodeInvalidSettings, "unknown decision %q", settings.Decision)
+	}
+
+	opponents, err := h.opponents(settings.Opponents)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range opponents {
+		if p.ID() == from.ID() {
+			return nil, protocol.Errorf(protocol.CodeInvalidSettings, "a player cannot team up with or against itself")
+		}
+	}
+
+	var thinker game.Thinker
+	team := &TeamGuesser{Decision: settings.Decision, Log: h.log}
+	switch settings.Role {
+	case RoleThinker:
+		if len(opponents) == 0 {
+			return nil, protocol.Errorf(protocol.CodeInvalidSettings, "no guesser specified")
+		}
+		thinker = from
+		team.Players = opponents
+	case RoleGuesser:
+		if !settings.AI {
+			return nil, protocol.Errorf(protocol.CodeInvalidSettings, "teams guess the number of the computer")
+		}
+		if settings.Digits < 1 || settings.Digits > 10 {
+			return nil, protocol.Errorf(protocol.CodeInvalidSettings, "invalid digit count %d", settings.Digits)
+		}
+		thinker = LocalThinker(settings.Digits)
+		team.Players = append([]Player{from}, opponents...)
+	default:
+		return nil, protocol.Errorf(protocol.CodeInvalidRole, "invalid role: %s", settings.Role)
+	}
+
+	g, err := h.gamer.Game(thinker, team)
+	if err != nil {
+		return nil, err
+	}
+	h.do(func(map[string]Player) {
+		h.teams[team] = true
+	})
+	return g, nil
+}
+
+// TeamChat relays a message from a player to its team. It fails with a
+// *protocol.Error if the player is in no team whose game is on, or if the
+// message is rejected.
+func (h *Hub) TeamChat(from Player, text string) error {
+	teamChan := make(chan *TeamGuesser, 1)
+	h.do(func(map[string]Player) {
+		var found *TeamGuesser
+		for t := range h.teams {
+			if t.finished() {
+				delete(h.teams, t)
+				continue
+			}
+			if t.member(from.ID()) {
+				found = t
+			}
+		}
+		teamChan <- found
+	})
+	team := <-teamChan
+	if team == nil {
+		return protocol.Errorf(protocol.CodeBadRequest, "not in a team game")
+	}
+	return team.Chat(from, text)
+}
@@ -0,0 +1,246 @@
+package cowbull_test
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TeamGuesser", func() {
+	var team *TeamGuesser
+	var captain, mate1, mate2 *cowbullfakes.FakePlayer
+	var member *teamPlayer
+
+	// guesses makes p propose and then vote for the given numbers. An empty
+	// one makes it fail instead.
+	guesses := func(p *cowbullfakes.FakePlayer, proposal, vote string) {
+		calls := 0
+		p.GuessStub = func(int) (string, error) {
+			number := proposal
+			if calls++; calls > 1 {
+				number = vote
+			}
+			if number == "" {
+				return "", errors.New("gone")
+			}
+			return number, nil
+		}
+	}
+
+	BeforeEach(func() {
+		captain = new(cowbullfakes.FakePlayer)
+		captain.IDReturns("c")
+		mate1 = new(cowbullfakes.FakePlayer)
+		mate1.IDReturns("m1")
+		mate2 = new(cowbullfakes.FakePlayer)
+		mate2.IDReturns("m2")
+		member = &teamPlayer{FakePlayer: mate2}
+		team = &TeamGuesser{Players: []Player{captain, mate1, member}}
+	})
+
+	Describe("Guess", func() {
+		It("should show the members the proposals", func() {
+			guesses(captain, "12", "12")
+			guesses(mate1, "34", "34")
+			guesses(mate2, "56", "56")
+			_, err := team.Guess(2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(member.proposals).To(Equal([]protocol.Proposals{{
+				Decision: DecideByVote,
+				Captain:  PlayerEntry{ID: "c"},
+				Proposals: []protocol.Proposal{
+					{Player: PlayerEntry{ID: "c"}, Number: "12"},
+					{Player: PlayerEntry{ID: "m1"}, Number: "34"},
+					{Player: PlayerEntry{ID: "m2"}, Number: "56"},
+				},
+			}}))
+		})
+
+		It("should guess what most members vote for", func() {
+			guesses(captain, "12", "12")
+			guesses(mate1, "34", "56")
+			guesses(mate2, "56", "56")
+			Expect(team.Guess(2)).To(Equal("56"))
+		})
+
+		It("should break ties by the order of the proposals", func() {
+			guesses(captain, "12", "34")
+			guesses(mate1, "34", "12")
+			guesses(mate2, "56", "56")
+			Expect(team.Guess(2)).To(Equal("12"))
+		})
+
+		It("should leave failing members out of the turn", func() {
+			guesses(captain, "12", "12")
+			guesses(mate1, "", "")
+			guesses(mate2, "56", "56")
+			Expect(team.Guess(2)).To(Equal("12"))
+			Expect(member.proposals[0].Proposals).To(HaveLen(2))
+		})
+
+		It("should fail once every member failed", func() {
+			captain.GuessReturns("", errors.New("gone"))
+			mate1.GuessReturns("", errors.New("gone"))
+			mate2.GuessReturns("", game.ErrForfeit)
+			_, err := team.Guess(2)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should go with the proposals if nobody votes", func() {
+			guesses(captain, "12", "")
+			guesses(mate1, "34", "")
+			guesses(mate2, "34", "")
+			Expect(team.Guess(2)).To(Equal("34"))
+		})
+
+		It("should not hold a vote in a team of one", func() {
+			team.Players = []Player{captain}
+			captain.GuessReturns("12", nil)
+			Expect(team.Guess(2)).To(Equal("12"))
+			Expect(captain.GuessCallCount()).To(Equal(1))
+		})
+
+		Context("when the captain decides", func() {
+			BeforeEach(func() {
+				team.Decision = DecideByCaptain
+			})
+
+			It("should guess what the captain picks", func() {
+				guesses(captain, "12", "56")
+				guesses(mate1, "34", "34")
+				guesses(mate2, "34", "34")
+				Expect(team.Guess(2)).To(Equal("56"))
+				Expect(mate1.GuessCallCount()).To(Equal(1))
+				Expect(member.proposals[0].Decision).To(Equal(DecideByCaptain))
+			})
+		})
+	})
+
+	Describe("Tell", func() {
+		It("should tell all members the score", func() {
+			Expect(team.Tell("12", 1, 0)).To(Succeed())
+			Expect(captain.TellCallCount()).To(Equal(1))
+			Expect(mate1.TellCallCount()).To(Equal(1))
+			Expect(mate2.TellCallCount()).To(Equal(1))
+		})
+
+		It("should fail only once every member failed", func() {
+			captain.TellReturns(errors.New("gone"))
+			mate1.TellReturns(errors.New("gone"))
+			Expect(team.Tell("12", 1, 0)).To(Succeed())
+			mate2.TellReturns(errors.New("gone"))
+			Expect(team.Tell("12", 1, 0)).To(MatchError("gone"))
+		})
+	})
+
+	Describe("Chat", func() {
+		It("should relay messages to the members", func() {
+			Expect(team.Chat(captain, " try 56 ")).To(Succeed())
+			Expect(member.chat).To(Equal([]protocol.TeamChat{{From: PlayerEntry{ID: "c"}, Text: "try 56"}}))
+		})
+
+		It("should reject empty and overlong messages", func() {
+			Expect(team.Chat(captain, "  ")).NotTo(Succeed())
+			Expect(team.Chat(captain, strings.Repeat("x", 501))).NotTo(Succeed())
+			Expect(member.chat).To(BeEmpty())
+		})
+	})
+
+	It("should record the members as the guessers", func() {
+		g := game.New(new(cowbullfakes.FakePlayer), team)
+		Expect(NewGameRecord(g, GameSettings{}, time.Now()).Guessers).To(Equal([]PlayerEntry{{ID: "c"}, {ID: "m1"}, {ID: "m2"}}))
+	})
+})
+
+var _ = Describe("NewTeamGame", func() {
+	var hub *Hub
+	var logger *slog.Logger
+	var gamer *cowbullfakes.FakeGamer
+	var alice, bob *cowbullfakes.FakePlayer
+
+	BeforeEach(func() {
+		gamer = new(cowbullfakes.FakeGamer)
+		logger = slog.New(slog.NewTextHandler(GinkgoWriter, nil))
+		hub = NewHub(gamer, logger)
+		alice = computerPlayer("alice", 3)
+		bob = computerPlayer("bob", 3)
+		hub.Add(alice)
+		hub.Add(bob)
+	})
+
+	It("should team a guesser up with the opponents against the computer", func() {
+		_, err := hub.NewTeamGame(alice, GameSettings{Mode: protocol.ModeTeam, Role: RoleGuesser, AI: true, Digits: 3, Decision: DecideByCaptain, Opponents: []string{"bob"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gamer.GameCallCount()).To(Equal(1))
+		thinker, guesser := gamer.GameArgsForCall(0)
+		Expect(thinker).To(BeAssignableToTypeOf(&AIThinker{}))
+		Expect(guesser).To(Equal(&TeamGuesser{Players: []Player{alice, bob}, Decision: DecideByCaptain, Log: logger}))
+	})
+
+	It("should let a thinker play against the opponents as a team", func() {
+		carol := computerPlayer("carol", 3)
+		hub.Add(carol)
+		_, err := hub.NewTeamGame(carol, GameSettings{Mode: protocol.ModeTeam, Role: RoleThinker, Opponents: []string{"alice", "bob"}})
+		Expect(err).NotTo(HaveOccurred())
+		thinker, guesser := gamer.GameArgsForCall(0)
+		Expect(thinker).To(Equal(carol))
+		Expect(guesser.(*TeamGuesser).Players).To(Equal([]Player{alice, bob}))
+	})
+
+	It("should relay chat to the team while its game is on", func() {
+		_, err := hub.NewTeamGame(alice, GameSettings{Mode: protocol.ModeTeam, Role: RoleGuesser, AI: true, Digits: 3, Opponents: []string{"bob"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hub.TeamChat(bob, "hi")).To(Succeed())
+
+		_, guesser := gamer.GameArgsForCall(0)
+		guesser.(*TeamGuesser).GameOver(game.Result{})
+		Expect(code(hub.TeamChat(bob, "hi"))).To(Equal(protocol.CodeBadRequest))
+	})
+
+	It("should reject chat from players in no team", func() {
+		Expect(code(hub.TeamChat(alice, "hi"))).To(Equal(protocol.CodeBadRequest))
+	})
+
+	It("should reject invalid settings", func() {
+		_, err := hub.NewTeamGame(alice, GameSettings{Role: RoleThinker})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewTeamGame(alice, GameSettings{Role: RoleThinker, Opponents: []string{"alice"}})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewTeamGame(alice, GameSettings{Role: RoleGuesser, Opponents: []string{"bob"}})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewTeamGame(alice, GameSettings{Role: RoleGuesser, AI: true, Digits: 11})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewTeamGame(alice, GameSettings{Role: RoleThinker, Decision: "dice", Opponents: []string{"bob"}})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = hub.NewTeamGame(alice, GameSettings{Role: "referee", Opponents: []string{"bob"}})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidRole))
+		_, err = hub.NewTeamGame(alice, GameSettings{Role: RoleThinker, Opponents: []string{"carol"}})
+		Expect(code(err)).To(Equal(protocol.CodeUnknownOpponent))
+	})
+})
+
+// teamPlayer records what it is told about the decisions of its team.
+type teamPlayer struct {
+	*cowbullfakes.FakePlayer
+	proposals []protocol.Proposals
+	chat      []protocol.TeamChat
+}
+
+func (p *teamPlayer) AnnounceProposals(ps protocol.Proposals) error {
+	p.proposals = append(p.proposals, ps)
+	return nil
+}
+
+func (p *teamPlayer) TeamChat(m protocol.TeamChat) error {
+	p.chat = append(p.chat, m)
+	return nil
+}
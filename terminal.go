@@ -0,0 +1,134 @@
+package cowbull
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Bo0mer/cowbull/game"
+)
+
+// TerminalPlayer is a player sitting in front of a terminal.
+// It prompts on out and reads the answers from in, one line at a time.
+// Invalid answers are reported and the player is prompted again.
+// Answering forfeit to any prompt gives up the game.
+type TerminalPlayer struct {
+	in     *bufio.Scanner
+	out    io.Writer
+	digits int
+
+	moves int
+}
+
+// NewTerminalPlayer creates a player that plays on in and out.
+// When thinking, it thinks of n-digit numbers.
+func NewTerminalPlayer(in io.Reader, out io.Writer, n int) *TerminalPlayer {
+	return &TerminalPlayer{
+		in:     bufio.NewScanner(in),
+		out:    out,
+		digits: n,
+	}
+}
+
+// Think asks the player to think of a number.
+func (p *TerminalPlayer) Think() (int, error) {
+	p.moves = 0
+	fmt.Fprintf(p.out, "Think of a number with %d distinct digits and keep it to yourself.\n", p.digits)
+	if _, err := p.prompt("Press enter when ready: "); err != nil {
+		return 0, err
+	}
+	return p.digits, nil
+}
+
+// Try asks the player to score a guess.
+func (p *TerminalPlayer) Try(guess string) (int, int, error) {
+	p.moves++
+	for {
+		line, err := p.prompt(fmt.Sprintf("%3d. %s  cows and bulls: ", p.moves, guess))
+		if err != nil {
+			return 0, 0, err
+		}
+		cows, bulls, err := parseScore(line, len(guess))
+		if err != nil {
+			fmt.Fprintf(p.out, "%v. Enter them separated by space, e.g. 1 2.\n", err)
+			continue
+		}
+		return cows, bulls, nil
+	}
+}
+
+// Guess asks the player for a guess.
+func (p *TerminalPlayer) Guess(n int) (string, error) {
+	for {
+		line, err := p.prompt(fmt.Sprintf("%3d. Your guess: ", p.moves+1))
+		if err != nil {
+			return "", err
+		}
+		if !validNumber(line, n) {
+			fmt.Fprintf(p.out, "The guess should be a number with %d distinct digits.\n", n)
+			continue
+		}
+		return line, nil
+	}
+}
+
+// Tell shows the player the score of its guess.
+func (p *TerminalPlayer) Tell(number string, cows, bulls int) error {
+	p.moves++
+	fmt.Fprintf(p.out, "     %s  %d cows %d bulls\n", number, cows, bulls)
+	return nil
+}
+
+// GameOver shows the player how the game ended.
+func (p *TerminalPlayer) GameOver(r game.Result) {
+	p.moves = 0
+	switch r.Outcome {
+	case game.OutcomeWin:
+		fmt.Fprintf(p.out, "The number %s was guessed in %d moves.\n", r.Secret, len(r.Moves))
+	case game.OutcomeForfeit:
+		fmt.Fprintf(p.out, "Gave up after %d moves.\n", len(r.Moves))
+	default:
+		fmt.Fprintf(p.out, "Game over (%s): %s\n", r.Outcome, r.Reason)
+	}
+	if r.Outcome != game.OutcomeWin && r.Secret != "" {
+		fmt.Fprintf(p.out, "The number was %s.\n", r.Secret)
+	}
+}
+
+// prompt prints a prompt and returns the line typed by the player.
+func (p *TerminalPlayer) prompt(prompt string) (string, error) {
+	fmt.Fprint(p.out, prompt)
+	if !p.in.Scan() {
+		if err := p.in.Err(); err != nil {
+			return "", err
+		}
+		return "", io.ErrUnexpectedEOF
+	}
+	line := strings.TrimSpace(p.in.Text())
+	if line == "forfeit" {
+		return "", game.ErrForfeit
+	}
+	return line, nil
+}
+
+// parseScore parses cows and bulls of an n-digit guess.
+func parseScore(s string, n int) (int, int, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("expected cows and bulls, got %q", s)
+	}
+	cows, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid cows %q", fields[0])
+	}
+	bulls, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid bulls %q", fields[1])
+	}
+	if cows < 0 || bulls < 0 || cows+bulls > n {
+		return 0, 0, fmt.Errorf("%d cows and %d bulls are impossible", cows, bulls)
+	}
+	return cows, bulls, nil
+}
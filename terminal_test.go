@@ -0,0 +1,87 @@
+package cowbull_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/game"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TerminalPlayer", func() {
+	var out *bytes.Buffer
+	var player *TerminalPlayer
+
+	newPlayer := func(input string) {
+		out = new(bytes.Buffer)
+		player = NewTerminalPlayer(strings.NewReader(input), out, 4)
+	}
+
+	Describe("Think", func() {
+		It("should return the configured digit count", func() {
+			newPlayer("\n")
+			digits, err := player.Think()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(digits).Should(Equal(4))
+		})
+	})
+
+	Describe("Try", func() {
+		It("should return the score typed", func() {
+			newPlayer("1 2\n")
+			cows, bulls, err := player.Try("1234")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cows).Should(Equal(1))
+			Ω(bulls).Should(Equal(2))
+		})
+
+		It("should prompt again on impossible scores", func() {
+			newPlayer("three\n3 2\n-1 0\n0 4\n")
+			cows, bulls, err := player.Try("1234")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(cows).Should(Equal(0))
+			Ω(bulls).Should(Equal(4))
+			Ω(strings.Count(out.String(), "cows and bulls: ")).Should(Equal(4))
+		})
+	})
+
+	Describe("Guess", func() {
+		It("should prompt again until the guess is valid", func() {
+			newPlayer("123\n1123\n12a4\n1234\n")
+			guess, err := player.Guess(4)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(guess).Should(Equal("1234"))
+			Ω(strings.Count(out.String(), "distinct digits")).Should(Equal(3))
+		})
+
+		It("should forfeit when asked to", func() {
+			newPlayer("forfeit\n")
+			_, err := player.Guess(4)
+			Ω(err).Should(Equal(game.ErrForfeit))
+		})
+
+		It("should fail when the input ends", func() {
+			newPlayer("")
+			_, err := player.Guess(4)
+			Ω(err).Should(Equal(io.ErrUnexpectedEOF))
+		})
+	})
+
+	Describe("a game against the computer", func() {
+		It("should be played till the end", func() {
+			thinker := NewLocalThinker(4, func(n int) []int {
+				return []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 0}
+			})
+			newPlayer("5678\n4321\n1234\n")
+			g := game.New(thinker, player)
+			Ω(g.Play()).Should(Succeed())
+			Ω(out.String()).Should(ContainSubstring("5678  0 cows 0 bulls"))
+			Ω(out.String()).Should(ContainSubstring("4321  4 cows 0 bulls"))
+			Ω(out.String()).Should(ContainSubstring("The number 1234 was guessed in 3 moves."))
+		})
+	})
+})
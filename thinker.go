@@ -45,6 +45,11 @@ func (p *AIThinker) Think() (int, error) {
 	return len(p.number), nil
 }
 
+// Secret returns the number the thinker thought of.
+func (p *AIThinker) Secret() string {
+	return p.number
+}
+
 // Try returns the cows and bulls for number.
 func (p *AIThinker) Try(number string) (int, int, error) {
 	if len(number) != len(p.number) {
@@ -69,7 +74,12 @@ func (p *AIThinker) generateNumber() (string, error) {
 	}
 	perm := p.perm(10)
 	if perm[0] == 0 {
-		randIdx := rand.Intn(p.digits-1) + 1
+		// Keep the zero in the number, unless it is the only digit.
+		n := p.digits
+		if n == 1 {
+			n = len(perm)
+		}
+		randIdx := rand.Intn(n-1) + 1
 		perm[0], perm[randIdx] = perm[randIdx], perm[0]
 	}
 	res := ""
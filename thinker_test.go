@@ -71,6 +71,15 @@ var _ = Describe("LocalThinker", func() {
 		})
 	})
 
+	Describe("Secret", func() {
+		It("should reveal the number thought of", func() {
+			thinker := LocalThinker(4)
+			_, err := thinker.Think()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(thinker.Secret()).Should(Equal(thinker.number))
+		})
+	})
+
 	Describe("Try", func() {
 		var number string
 		var try string
@@ -0,0 +1,381 @@
+// Package tournament pairs the players of cowbull tournaments and ranks
+// them by the results of their matches.
+//
+// A match is a pair of games in which each player guesses the number of the
+// other. The player who guesses in fewer turns wins the match. A player who
+// fails, gives up or does not answer in time in either game loses it.
+package tournament
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"sort"
+
+	"github.com/Bo0mer/cowbull/protocol"
+)
+
+// Formats of a tournament.
+const (
+	// RoundRobin pairs every player with every other one once.
+	RoundRobin = protocol.FormatRoundRobin
+	// Swiss pairs players with equal points for a fixed count of rounds,
+	// never pairing the same players twice if it can be helped.
+	Swiss = protocol.FormatSwiss
+	// SingleElimination pairs the players left in a bracket, knocking out
+	// the loser of every match. The higher seed goes through a draw.
+	SingleElimination = protocol.FormatSingleElimination
+)
+
+// Match is a pair of games between two players, with swapped roles.
+type Match = protocol.Match
+
+// Standing is the position of a player in a tournament.
+type Standing = protocol.Standing
+
+// ErrTooFewPlayers is returned when a tournament is created with fewer than
+// two players.
+var ErrTooFewPlayers = errors.New("tournament: at least two players are needed")
+
+// Tournament pairs players round by round. It is not safe for concurrent
+// use.
+type Tournament struct {
+	format string
+	seeds  []string
+	rounds int
+	played [][]*Match
+}
+
+// New creates a tournament of a format between players, given by ID in
+// order of seed. Rounds applies to swiss tournaments only; with zero they
+// last long enough for a single player to win every match.
+func New(format string, players []string, rounds int) (*Tournament, error) {
+	if len(players) < 2 {
+		return nil, ErrTooFewPlayers
+	}
+	t := &Tournament{format: format, seeds: players}
+	n := len(players)
+	switch format {
+	case RoundRobin:
+		t.rounds = n - 1
+		if n%2 == 1 {
+			t.rounds = n
+		}
+	case Swiss:
+		t.rounds = rounds
+		if t.rounds <= 0 {
+			t.rounds = log2(n)
+		}
+	case SingleElimination:
+		t.rounds = log2(n)
+	default:
+		return nil, fmt.Errorf("tournament: unknown format %q", format)
+	}
+	return t, nil
+}
+
+// log2 returns the binary logarithm of n, rounded up.
+func log2(n int) int {
+	return bits.Len(uint(n - 1))
+}
+
+// Rounds returns the count of rounds to be played.
+func (t *Tournament) Rounds() int {
+	return t.rounds
+}
+
+// Round returns the current round, zero before the first one is paired.
+func (t *Tournament) Round() int {
+	return len(t.played)
+}
+
+// Matches returns all matches paired so far, by round.
+func (t *Tournament) Matches() []*Match {
+	var all []*Match
+	for _, round := range t.played {
+		all = append(all, round...)
+	}
+	return all
+}
+
+// Over reports whether all rounds are over.
+func (t *Tournament) Over() bool {
+	return len(t.played) == t.rounds && t.roundOver()
+}
+
+// roundOver reports whether every match of the current round is over.
+func (t *Tournament) roundOver() bool {
+	if len(t.played) == 0 {
+		return true
+	}
+	for _, m := range t.played[len(t.played)-1] {
+		if !m.Done {
+			return false
+		}
+	}
+	return true
+}
+
+// NextRound pairs the players of the next round. It returns nil while
+// matches of the current round are not over, and once the tournament is.
+// Byes are over from the start.
+func (t *Tournament) NextRound() []*Match {
+	if t.Over() || !t.roundOver() {
+		return nil
+	}
+	var pairs [][2]string
+	switch t.format {
+	case RoundRobin:
+		pairs = t.roundRobin()
+	case Swiss:
+		pairs = t.swiss()
+	case SingleElimination:
+		pairs = t.elimination()
+	}
+
+	round := len(t.played) + 1
+	matches := make([]*Match, len(pairs))
+	for i, p := range pairs {
+		matches[i] = &Match{Round: round, A: p[0], B: p[1]}
+		if p[1] == "" {
+			matches[i].Winner, matches[i].Done = p[0], true
+		}
+	}
+	t.played = append(t.played, matches)
+	return matches
+}
+
+// roundRobin pairs the players by the circle method: the first seed stays
+// put while the others rotate around it.
+func (t *Tournament) roundRobin() [][2]string {
+	circle := append([]string(nil), t.seeds...)
+	if len(circle)%2 == 1 {
+		circle = append(circle, "")
+	}
+	n := len(circle)
+	r := len(t.played)
+	rotated := make([]string, n)
+	rotated[0] = circle[0]
+	for i := 1; i < n; i++ {
+		rotated[i] = circle[1+(i-1+r)%(n-1)]
+	}
+
+	var pairs [][2]string
+	for i := 0; i < n/2; i++ {
+		a, b := rotated[i], rotated[n-1-i]
+		// Alternate who thinks first, and give byes to the player.
+		if a == "" || (r%2 == 1 && b != "") {
+			a, b = b, a
+		}
+		pairs = append(pairs, [2]string{a, b})
+	}
+	return pairs
+}
+
+// swiss pairs each player, in order of standing, with the next one it has
+// not met yet. The lowest ranked player without a bye gets one if the
+// count of players is odd.
+func (t *Tournament) swiss() [][2]string {
+	var ranked []string
+	for _, s := range t.Standings() {
+		ranked = append(ranked, s.ID)
+	}
+
+	var pairs [][2]string
+	if len(ranked)%2 == 1 {
+		bye := len(ranked) - 1
+		for i := len(ranked) - 1; i >= 0; i-- {
+			if !t.hadBye(ranked[i]) {
+				bye = i
+				break
+			}
+		}
+		pairs = append(pairs, [2]string{ranked[bye], ""})
+		ranked = append(ranked[:bye:bye], ranked[bye+1:]...)
+	}
+
+	paired := make([]bool, len(ranked))
+	for i := range ranked {
+		if paired[i] {
+			continue
+		}
+		opponent := -1
+		for j := i + 1; j < len(ranked); j++ {
+			if paired[j] {
+				continue
+			}
+			if opponent < 0 {
+				opponent = j
+			}
+			if !t.met(ranked[i], ranked[j]) {
+				opponent = j
+				break
+			}
+		}
+		paired[i], paired[opponent] = true, true
+		pairs = append(pairs, [2]string{ranked[i], ranked[opponent]})
+	}
+	return pairs
+}
+
+// elimination pairs the players left. The first round follows a seeded
+// bracket, which gives byes to the top seeds and keeps them apart until the
+// last rounds. Later rounds pair the winners of neighbouring matches.
+func (t *Tournament) elimination() [][2]string {
+	var pairs [][2]string
+	if len(t.played) == 0 {
+		order := bracket(1 << uint(log2(len(t.seeds))))
+		for i := 0; i < len(order); i += 2 {
+			a, b := t.seeds[order[i]], ""
+			if order[i+1] < len(t.seeds) {
+				b = t.seeds[order[i+1]]
+			}
+			pairs = append(pairs, [2]string{a, b})
+		}
+		return pairs
+	}
+
+	last := t.played[len(t.played)-1]
+	for i := 0; i+1 < len(last); i += 2 {
+		pairs = append(pairs, [2]string{last[i].Winner, last[i+1].Winner})
+	}
+	return pairs
+}
+
+// bracket returns the seeds, counted from zero, of the positions of a
+// bracket of size players, a power of two. Neighbouring positions meet in
+// the first round, and the sum of their seeds is always size-1.
+func bracket(size int) []int {
+	order := []int{0}
+	for n := 2; n <= size; n *= 2 {
+		next := make([]int, 0, n)
+		for _, seed := range order {
+			next = append(next, seed, n-1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+func (t *Tournament) hadBye(id string) bool {
+	for _, m := range t.Matches() {
+		if m.A == id && m.B == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Tournament) met(a, b string) bool {
+	for _, m := range t.Matches() {
+		if (m.A == a && m.B == b) || (m.A == b && m.B == a) {
+			return true
+		}
+	}
+	return false
+}
+
+// Finish records the result of a match: the turns each player took to guess
+// the number of the other, zero if it did not, and whether each player
+// failed in either game.
+func (t *Tournament) Finish(m *Match, turnsA, turnsB int, failedA, failedB bool) {
+	m.TurnsA, m.TurnsB = turnsA, turnsB
+	m.Done = true
+	switch {
+	case failedA && failedB:
+	case failedA:
+		m.Winner = m.B
+	case failedB:
+		m.Winner = m.A
+	case turnsA > 0 && (turnsB == 0 || turnsA < turnsB):
+		m.Winner = m.A
+	case turnsB > 0 && (turnsA == 0 || turnsB < turnsA):
+		m.Winner = m.B
+	}
+	if m.Winner == "" && t.format == SingleElimination {
+		m.Winner = t.higherSeed(m.A, m.B)
+	}
+}
+
+func (t *Tournament) higherSeed(a, b string) string {
+	if t.seed(a) < t.seed(b) {
+		return a
+	}
+	return b
+}
+
+func (t *Tournament) seed(id string) int {
+	for i, s := range t.seeds {
+		if s == id {
+			return i
+		}
+	}
+	return len(t.seeds)
+}
+
+// Standings ranks the players by points, then by the turns they took per
+// number they guessed, then by seed. Players who guessed no number come after
+// those who did on equal points. Names are left empty.
+func (t *Tournament) Standings() []Standing {
+	byID := make(map[string]*Standing, len(t.seeds))
+	standings := make([]Standing, len(t.seeds))
+	for i, id := range t.seeds {
+		standings[i].ID = id
+		byID[id] = &standings[i]
+	}
+
+	for _, m := range t.Matches() {
+		if !m.Done {
+			continue
+		}
+		a, b := byID[m.A], byID[m.B]
+		if b == nil {
+			a.Wins++
+			a.Points++
+			continue
+		}
+		solve(a, m.TurnsA)
+		solve(b, m.TurnsB)
+		switch m.Winner {
+		case m.A:
+			a.Wins++
+			a.Points++
+			b.Losses++
+			b.Eliminated = t.format == SingleElimination
+		case m.B:
+			b.Wins++
+			b.Points++
+			a.Losses++
+			a.Eliminated = t.format == SingleElimination
+		default:
+			a.Draws++
+			b.Draws++
+			a.Points += 0.5
+			b.Points += 0.5
+		}
+	}
+
+	sort.SliceStable(standings, func(i, j int) bool {
+		si, sj := standings[i], standings[j]
+		if si.Points != sj.Points {
+			return si.Points > sj.Points
+		}
+		if si.Solved == 0 || sj.Solved == 0 {
+			return si.Solved > sj.Solved
+		}
+		// Compares the turns per number without dividing.
+		return si.Turns*sj.Solved < sj.Turns*si.Solved
+	})
+	for i := range standings {
+		standings[i].Rank = i + 1
+	}
+	return standings
+}
+
+// solve accounts for a number guessed in turns, zero if it was not guessed.
+func solve(s *Standing, turns int) {
+	if turns > 0 {
+		s.Solved++
+		s.Turns += turns
+	}
+}
@@ -0,0 +1,13 @@
+package tournament_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTournament(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Tournament Suite")
+}
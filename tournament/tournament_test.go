@@ -0,0 +1,207 @@
+package tournament_test
+
+import (
+	. "github.com/Bo0mer/cowbull/tournament"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tournament", func() {
+
+	// play finishes every match of a round, letting the lower seed win
+	// when seeds are given by single letters.
+	play := func(t *Tournament, matches []*Match) {
+		for _, m := range matches {
+			if m.Done {
+				continue
+			}
+			if m.A < m.B {
+				t.Finish(m, 4, 6, false, false)
+			} else {
+				t.Finish(m, 6, 4, false, false)
+			}
+		}
+	}
+
+	// pairs returns the pairs of matches as strings.
+	pairs := func(matches []*Match) []string {
+		var ps []string
+		for _, m := range matches {
+			ps = append(ps, m.A+m.B)
+		}
+		return ps
+	}
+
+	It("should need two players", func() {
+		_, err := New(RoundRobin, []string{"a"}, 0)
+		Ω(err).Should(Equal(ErrTooFewPlayers))
+	})
+
+	It("should reject unknown formats", func() {
+		_, err := New("ladder", []string{"a", "b"}, 0)
+		Ω(err).Should(HaveOccurred())
+	})
+
+	Describe("round-robin", func() {
+		It("should pair every player with every other one once", func() {
+			t, err := New(RoundRobin, []string{"a", "b", "c", "d", "e"}, 0)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(t.Rounds()).Should(Equal(5))
+
+			met := make(map[string]int)
+			byes := make(map[string]int)
+			for matches := t.NextRound(); matches != nil; matches = t.NextRound() {
+				for _, m := range matches {
+					if m.B == "" {
+						byes[m.A]++
+						continue
+					}
+					a, b := m.A, m.B
+					if a > b {
+						a, b = b, a
+					}
+					met[a+b]++
+				}
+				play(t, matches)
+			}
+			Ω(t.Over()).Should(BeTrue())
+			Ω(t.Round()).Should(Equal(5))
+			Ω(met).Should(HaveLen(10))
+			for _, n := range met {
+				Ω(n).Should(Equal(1))
+			}
+			Ω(byes).Should(Equal(map[string]int{"a": 1, "b": 1, "c": 1, "d": 1, "e": 1}))
+
+			standings := t.Standings()
+			Ω(standings[0].ID).Should(Equal("a"))
+			Ω(standings[0].Rank).Should(Equal(1))
+			Ω(standings[0].Wins).Should(Equal(5))
+			Ω(standings[4].ID).Should(Equal("e"))
+			Ω(standings[4].Losses).Should(Equal(4))
+		})
+
+		It("should not pair a round before the last one is over", func() {
+			t, _ := New(RoundRobin, []string{"a", "b"}, 0)
+			Ω(t.NextRound()).Should(HaveLen(1))
+			Ω(t.NextRound()).Should(BeNil())
+		})
+	})
+
+	Describe("swiss", func() {
+		It("should pair players with equal points who have not met", func() {
+			t, err := New(Swiss, []string{"a", "b", "c", "d"}, 0)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(t.Rounds()).Should(Equal(2))
+
+			first := t.NextRound()
+			Ω(pairs(first)).Should(Equal([]string{"ab", "cd"}))
+			play(t, first)
+			second := t.NextRound()
+			Ω(pairs(second)).Should(Equal([]string{"ac", "bd"}))
+			play(t, second)
+			Ω(t.NextRound()).Should(BeNil())
+			Ω(t.Over()).Should(BeTrue())
+		})
+
+		It("should give a bye to the lowest ranked player without one", func() {
+			t, _ := New(Swiss, []string{"a", "b", "c"}, 3)
+			first := t.NextRound()
+			Ω(pairs(first)).Should(Equal([]string{"c", "ab"}))
+			play(t, first)
+			second := t.NextRound()
+			Ω(second[0].A).Should(Equal("b"))
+			Ω(second[0].B).Should(BeEmpty())
+		})
+	})
+
+	Describe("single elimination", func() {
+		It("should give byes to the top seeds and knock out losers", func() {
+			t, err := New(SingleElimination, []string{"a", "b", "c", "d", "e"}, 0)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(t.Rounds()).Should(Equal(3))
+
+			first := t.NextRound()
+			Ω(pairs(first)).Should(Equal([]string{"a", "de", "b", "c"}))
+			play(t, first)
+			second := t.NextRound()
+			Ω(pairs(second)).Should(Equal([]string{"ad", "bc"}))
+			play(t, second)
+			Ω(pairs(t.NextRound())).Should(Equal([]string{"ab"}))
+
+			standings := t.Standings()
+			Ω(standings[4].ID).Should(Equal("e"))
+			Ω(standings[4].Eliminated).Should(BeTrue())
+			Ω(standings[0].Eliminated).Should(BeFalse())
+		})
+
+		It("should let the higher seed through a draw", func() {
+			t, _ := New(SingleElimination, []string{"a", "b"}, 0)
+			m := t.NextRound()[0]
+			t.Finish(m, 5, 5, false, false)
+			Ω(m.Winner).Should(Equal("a"))
+			Ω(t.Over()).Should(BeTrue())
+		})
+	})
+
+	Describe("Finish", func() {
+		var t *Tournament
+		var m *Match
+
+		BeforeEach(func() {
+			t, _ = New(RoundRobin, []string{"a", "b"}, 0)
+			m = t.NextRound()[0]
+		})
+
+		It("should let the player guessing in fewer turns win", func() {
+			t.Finish(m, 7, 5, false, false)
+			Ω(m.Winner).Should(Equal("b"))
+			Ω(m.Done).Should(BeTrue())
+		})
+
+		It("should let a player guessing beat one who did not", func() {
+			t.Finish(m, 9, 0, false, false)
+			Ω(m.Winner).Should(Equal("a"))
+		})
+
+		It("should let a player who failed lose", func() {
+			t.Finish(m, 3, 5, true, false)
+			Ω(m.Winner).Should(Equal("b"))
+		})
+
+		It("should break ties by the turns per number guessed, ranking players who guessed none last", func() {
+			t, _ = New(RoundRobin, []string{"a", "b", "c", "d"}, 0)
+			turns := map[string][]int{
+				"a": {5, 5, 0},
+				"b": {6, 0, 0},
+				"c": {0, 0, 0},
+				"d": {4, 4, 4},
+			}
+			next := func(id string) int {
+				n := turns[id][0]
+				turns[id] = turns[id][1:]
+				return n
+			}
+			for matches := t.NextRound(); matches != nil; matches = t.NextRound() {
+				for _, m := range matches {
+					// Both fail, so that every match is drawn.
+					t.Finish(m, next(m.A), next(m.B), true, true)
+				}
+			}
+
+			standings := t.Standings()
+			Ω([]string{standings[0].ID, standings[1].ID, standings[2].ID, standings[3].ID}).Should(Equal([]string{"d", "a", "b", "c"}))
+			Ω(standings[1].Solved).Should(Equal(2))
+			Ω(standings[1].Turns).Should(Equal(10))
+			Ω(standings[3].Solved).Should(BeZero())
+		})
+
+		It("should call equal turns a draw", func() {
+			t.Finish(m, 5, 5, false, false)
+			Ω(m.Winner).Should(BeEmpty())
+			standings := t.Standings()
+			Ω(standings[0].Points).Should(Equal(0.5))
+			Ω(standings[1].Draws).Should(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,403 @@
+package cowbull
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+	"github.com/Bo0mer/cowbull/tournament"
+)
+
+// TournamentSettings describes a tournament to be created.
+type TournamentSettings = protocol.TournamentSettings
+
+// Tournament describes a tournament and how it stands.
+type Tournament = protocol.Tournament
+
+// tournamentObserver may be implemented by a Player that wants to follow
+// the tournaments it takes part in.
+type tournamentObserver interface {
+	AnnounceTournament(Tournament) error
+}
+
+// Tournaments runs tournaments between the players of a hub. Every game
+// of a match is started by Tournaments on its own, and handed to a function
+// once finished. It is safe for concurrent use.
+type Tournaments struct {
+	hub      *Hub
+	log      *slog.Logger
+	finished func(g *game.Game, settings GameSettings, started time.Time)
+
+	max  int
+	keep time.Duration
+
+	mu          sync.Mutex
+	tournaments map[string]*runningTournament
+}
+
+// defaultTournamentRetention is how long tournaments are kept once over by
+// default.
+const defaultTournamentRetention = time.Hour
+
+// runningTournament is a tournament and its registered players.
+type runningTournament struct {
+	id        string
+	organiser string
+	settings  TournamentSettings
+	state     string
+	players   []PlayerEntry
+	t         *tournament.Tournament
+}
+
+// NewTournaments creates Tournaments between the players of hub. Finished
+// is called with every game played, once it is over.
+func NewTournaments(hub *Hub, log *slog.Logger, finished func(g *game.Game, settings GameSettings, started time.Time)) *Tournaments {
+	return &Tournaments{
+		hub:         hub,
+		log:         log,
+		finished:    finished,
+		keep:        defaultTournamentRetention,
+		tournaments: make(map[string]*runningTournament),
+	}
+}
+
+// Limit caps the tournaments registering or running at once to max, zero
+// meaning no limit, and forgets tournaments keep after they are over. It
+// should be called before the tournaments are in use.
+func (ts *Tournaments) Limit(max int, keep time.Duration) {
+	ts.max = max
+	ts.keep = keep
+}
+
+// Create creates a tournament organised by a player, open for registration.
+func (ts *Tournaments) Create(organiser Player, settings TournamentSettings) (Tournament, error) {
+	switch settings.Format {
+	case tournament.RoundRobin, tournament.Swiss, tournament.SingleElimination:
+	default:
+		return Tournament{}, protocol.Errorf(protocol.CodeInvalidSettings, "unknown tournament format %q", settings.Format)
+	}
+	if settings.Digits < 1 || settings.Digits > 10 {
+		return Tournament{}, protocol.Errorf(protocol.CodeInvalidSettings, "invalid digit count %d", settings.Digits)
+	}
+	if err := ts.hub.accepting(); err != nil {
+		return Tournament{}, err
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.max > 0 && ts.open() >= ts.max {
+		return Tournament{}, protocol.Errorf(protocol.CodeTooManyTournaments, "the server holds %d tournaments already", ts.max)
+	}
+	rt := &runningTournament{
+		id:        newGameID(),
+		organiser: organiser.ID(),
+		settings:  settings,
+		state:     protocol.StateRegistering,
+	}
+	ts.tournaments[rt.id] = rt
+	ts.log.Info("tournament created", "tournament_id", rt.id, "player_id", rt.organiser)
+	return rt.describe(), nil
+}
+
+// Join registers a player to play in a tournament.
+func (ts *Tournaments) Join(id string, p Player) (Tournament, error) {
+	ts.mu.Lock()
+	rt, err := ts.lookup(id)
+	if err == nil && rt.state != protocol.StateRegistering {
+		err = protocol.Errorf(protocol.CodeNotAllowed, "tournament %s has started", id)
+	}
+	if err != nil {
+		ts.mu.Unlock()
+		return Tournament{}, err
+	}
+	if !rt.registered(p.ID()) {
+		rt.players = append(rt.players, PlayerEntry{ID: p.ID(), Name: p.Name()})
+	}
+	desc := rt.describe()
+	ts.mu.Unlock()
+
+	ts.announce(desc, p.ID())
+	return desc, nil
+}
+
+// Start closes the registration of a tournament and starts playing it. Only
+// its organiser may start it.
+func (ts *Tournaments) Start(id string, p Player) (Tournament, error) {
+	if err := ts.hub.accepting(); err != nil {
+		return Tournament{}, err
+	}
+	ts.mu.Lock()
+	rt, err := ts.lookup(id)
+	if err == nil {
+		err = rt.start(p.ID())
+	}
+	if err != nil {
+		ts.mu.Unlock()
+		return Tournament{}, err
+	}
+	desc := rt.describe()
+	ts.mu.Unlock()
+
+	ts.log.Info("tournament started", "tournament_id", id, "players", len(desc.Players))
+	ts.announce(desc, p.ID())
+	go ts.run(rt)
+	return desc, nil
+}
+
+// Tournament returns the tournament with an ID.
+func (ts *Tournaments) Tournament(id string) (Tournament, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	rt, ok := ts.tournaments[id]
+	if !ok {
+		return Tournament{}, false
+	}
+	return rt.describe(), true
+}
+
+// List returns all tournaments, ordered by ID.
+func (ts *Tournaments) List() []Tournament {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	list := make([]Tournament, 0, len(ts.tournaments))
+	for _, rt := range ts.tournaments {
+		list = append(list, rt.describe())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// lookup returns the tournament with an ID. It must be called with ts.mu
+// held.
+func (ts *Tournaments) lookup(id string) (*runningTournament, error) {
+	rt, ok := ts.tournaments[id]
+	if !ok {
+		return nil, protocol.Errorf(protocol.CodeUnknownTournament, "unknown tournament %q", id)
+	}
+	return rt, nil
+}
+
+// open returns the count of tournaments registering or running. It must be
+// called with ts.mu held.
+func (ts *Tournaments) open() int {
+	n := 0
+	for _, rt := range ts.tournaments {
+		if rt.state == protocol.StateRegistering || rt.state == protocol.StateRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// forget removes a tournament that is over once it has been kept long
+// enough.
+func (ts *Tournaments) forget(rt *runningTournament) {
+	time.AfterFunc(ts.keep, func() {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+		delete(ts.tournaments, rt.id)
+	})
+}
+
+// run plays a started tournament round by round, until it is over or the
+// hub is draining.
+func (ts *Tournaments) run(rt *runningTournament) {
+	for {
+		draining := ts.hub.accepting() != nil
+		ts.mu.Lock()
+		var matches []*tournament.Match
+		if draining {
+			rt.state = protocol.StateAborted
+		} else if matches = rt.t.NextRound(); matches == nil {
+			rt.state = protocol.StateFinished
+		}
+		desc := rt.describe()
+		ts.mu.Unlock()
+
+		ts.announce(desc, "")
+		if draining {
+			ts.log.Info("tournament aborted", "tournament_id", rt.id, "round", desc.Round)
+			ts.forget(rt)
+			return
+		}
+		if matches == nil {
+			ts.log.Info("tournament finished", "tournament_id", rt.id)
+			ts.forget(rt)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, m := range matches {
+			if m.Done {
+				continue
+			}
+			wg.Add(1)
+			go func(m *tournament.Match) {
+				defer wg.Done()
+				ts.play(rt, m)
+				ts.mu.Lock()
+				desc := rt.describe()
+				ts.mu.Unlock()
+				ts.announce(desc, "")
+			}(m)
+		}
+		wg.Wait()
+	}
+}
+
+// play plays the games of a match and records its result. The match is left
+// unfinished if the hub drains before its games are played.
+func (ts *Tournaments) play(rt *runningTournament, m *tournament.Match) {
+	players := make(map[string]Player)
+	for _, p := range ts.hub.playersWithIDs([]string{m.A, m.B}) {
+		players[p.ID()] = p
+	}
+	a := &matchPlayer{Player: players[m.A], digits: rt.settings.Digits}
+	b := &matchPlayer{Player: players[m.B], digits: rt.settings.Digits}
+	a.failed, b.failed = a.Player == nil, b.Player == nil
+
+	var games []string
+	var turnsA, turnsB int
+	if !a.failed && !b.failed {
+		id, turns, ok := ts.playGame(rt, a, b)
+		if !ok {
+			return
+		}
+		games, turnsB = append(games, id), turns
+		if id, turns, ok = ts.playGame(rt, b, a); !ok {
+			return
+		}
+		games, turnsA = append(games, id), turns
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	m.Games = games
+	rt.t.Finish(m, turnsA, turnsB, a.failed, b.failed)
+}
+
+// playGame plays a game of a match of rt, once there is a game slot for
+// its players. It returns the ID of the game and the turns the guesser took
+// to guess, zero if it did not, or false if the hub drained before the game
+// started.
+func (ts *Tournaments) playGame(rt *runningTournament, thinker, guesser *matchPlayer) (string, int, bool) {
+	// The game waits for its players to finish their other games, if they
+	// play as many as they may.
+	ids := []string{thinker.ID(), guesser.ID()}
+	if ts.hub.accepting() != nil || !ts.hub.games.wait(ts.hub.drained, ids...) {
+		return "", 0, false
+	}
+	defer ts.hub.games.release(ids...)
+
+	g, err := ts.hub.gamer.Game(thinker, guesser)
+	if err != nil {
+		ts.log.Error("error creating tournament game", "tournament_id", rt.id, "err", err)
+		thinker.failed, guesser.failed = true, true
+		return "", 0, true
+	}
+	g.SetID(newGameID())
+	settings := GameSettings{
+		Role:      RoleThinker,
+		Digits:    thinker.digits,
+		Opponents: []string{guesser.ID()},
+	}
+	log, over := ts.hub.gameOn(settings.Mode, []game.Thinker{thinker}, []game.Guesser{guesser})
+	defer over()
+	log = log.With("tournament_id", rt.id)
+	ts.hub.metrics.timeMoves(g, thinker, guesser)
+	traceMoves(log, g)
+	defer ts.hub.metrics.playing(settings.Mode)()
+	started := time.Now()
+	err = g.Play()
+	traceResult(log, g.Result())
+	if ts.finished != nil {
+		ts.finished(g, settings, started)
+	}
+	if err != nil {
+		return g.ID(), 0, true
+	}
+	return g.ID(), len(g.Result().Moves), true
+}
+
+// announce sends a tournament to its organiser and players, but the one
+// with the except ID.
+func (ts *Tournaments) announce(t Tournament, except string) {
+	ids := []string{t.Organiser}
+	for _, p := range t.Players {
+		if p.ID != t.Organiser {
+			ids = append(ids, p.ID)
+		}
+	}
+	for _, p := range ts.hub.playersWithIDs(ids) {
+		o, ok := p.(tournamentObserver)
+		if !ok || p.ID() == except {
+			continue
+		}
+		if err := o.AnnounceTournament(t); err != nil {
+			ts.log.Warn("error announcing tournament", "tournament_id", t.ID, "player_id", p.ID(), "err", err)
+		}
+	}
+}
+
+func (rt *runningTournament) registered(id string) bool {
+	for _, p := range rt.players {
+		if p.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// start closes the registration, if the player with id may do so.
+func (rt *runningTournament) start(id string) error {
+	if id != rt.organiser {
+		return protocol.Errorf(protocol.CodeNotAllowed, "only the organiser may start tournament %s", rt.id)
+	}
+	if rt.state != protocol.StateRegistering {
+		return protocol.Errorf(protocol.CodeNotAllowed, "tournament %s has started", rt.id)
+	}
+	ids := make([]string, len(rt.players))
+	for i, p := range rt.players {
+		ids[i] = p.ID
+	}
+	t, err := tournament.New(rt.settings.Format, ids, rt.settings.Rounds)
+	if err != nil {
+		return protocol.Errorf(protocol.CodeNotAllowed, "%v", err)
+	}
+	rt.t = t
+	rt.state = protocol.StateRunning
+	return nil
+}
+
+// describe returns the current state of the tournament.
+func (rt *runningTournament) describe() Tournament {
+	t := Tournament{
+		ID:        rt.id,
+		Format:    rt.settings.Format,
+		Digits:    rt.settings.Digits,
+		Organiser: rt.organiser,
+		State:     rt.state,
+		Players:   append([]PlayerEntry{}, rt.players...),
+		Matches:   []protocol.Match{},
+		Standings: []protocol.Standing{},
+	}
+	if rt.t == nil {
+		return t
+	}
+	t.Round, t.Rounds = rt.t.Round(), rt.t.Rounds()
+	for _, m := range rt.t.Matches() {
+		t.Matches = append(t.Matches, *m)
+	}
+	names := make(map[string]string, len(rt.players))
+	for _, p := range rt.players {
+		names[p.ID] = p.Name
+	}
+	for _, s := range rt.t.Standings() {
+		s.Name = names[s.ID]
+		t.Standings = append(t.Standings, s)
+	}
+	return t
+}
@@ -0,0 +1,274 @@
+package cowbull_test
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/cowbullfakes"
+	"github.com/Bo0mer/cowbull/game"
+	"github.com/Bo0mer/cowbull/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// computerPlayer returns a player thinking of n-digit numbers and guessing
+// as the computer does.
+func computerPlayer(id string, n int) *cowbullfakes.FakePlayer {
+	p := new(cowbullfakes.FakePlayer)
+	p.IDReturns(id)
+	p.NameReturns(id)
+	var thinker *AIThinker
+	var guesser *AIGuesser
+	p.ThinkStub = func() (int, error) {
+		thinker = LocalThinker(n)
+		return thinker.Think()
+	}
+	p.TryStub = func(number string) (int, int, error) {
+		return thinker.Try(number)
+	}
+	p.GuessStub = func(digits int) (string, error) {
+		if guesser == nil {
+			guesser = LocalGuesser(digits)
+		}
+		return guesser.Guess(digits)
+	}
+	p.TellStub = func(number string, cows, bulls int) error {
+		if bulls == len(number) {
+			guesser = nil
+			return nil
+		}
+		return guesser.Tell(number, cows, bulls)
+	}
+	return p
+}
+
+var _ = Describe("Tournaments", func() {
+	var hub *Hub
+	var tournaments *Tournaments
+	var mu sync.Mutex
+	var finished []*game.Game
+
+	finishedGames := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(finished)
+	}
+
+	BeforeEach(func() {
+		gamer := new(cowbullfakes.FakeGamer)
+		gamer.GameStub = func(t game.Thinker, g game.Guesser) (*game.Game, error) {
+			return game.New(t, g), nil
+		}
+		logger := slog.New(slog.NewTextHandler(GinkgoWriter, nil))
+		hub = NewHub(gamer, logger)
+		finished = nil
+		tournaments = NewTournaments(hub, logger, func(g *game.Game, _ GameSettings, _ time.Time) {
+			mu.Lock()
+			defer mu.Unlock()
+			finished = append(finished, g)
+		})
+	})
+
+	It("should play a round-robin tournament to the end", func() {
+		organiser := computerPlayer("organiser", 3)
+		t, err := tournaments.Create(organiser, TournamentSettings{Format: "round-robin", Digits: 3})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.State).To(Equal(protocol.StateRegistering))
+
+		for _, id := range []string{"alice", "bob", "carol"} {
+			p := computerPlayer(id, 3)
+			hub.Add(p)
+			_, err := tournaments.Join(t.ID, p)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		t, err = tournaments.Start(t.ID, organiser)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(t.Rounds).To(Equal(3))
+
+		Eventually(func() string {
+			t, _ = tournaments.Tournament(t.ID)
+			return t.State
+		}, 5*time.Second).Should(Equal(protocol.StateFinished))
+		Expect(t.Matches).To(HaveLen(6))
+		Expect(t.Standings).To(HaveLen(3))
+		Expect(t.Standings[0].Name).NotTo(BeEmpty())
+		Expect(finishedGames()).To(Equal(6))
+		for _, m := range t.Matches {
+			Expect(m.Done).To(BeTrue())
+			if m.B != "" {
+				Expect(m.Games).To(HaveLen(2))
+				Expect(m.TurnsA).To(BeNumerically(">", 0))
+				Expect(m.TurnsB).To(BeNumerically(">", 0))
+			}
+		}
+	})
+
+	It("should let players who left the hub lose", func() {
+		organiser := computerPlayer("organiser", 3)
+		t, _ := tournaments.Create(organiser, TournamentSettings{Format: "single-elimination", Digits: 3})
+		alice, bob := computerPlayer("alice", 3), computerPlayer("bob", 3)
+		hub.Add(alice)
+		tournaments.Join(t.ID, alice)
+		tournaments.Join(t.ID, bob)
+		tournaments.Start(t.ID, organiser)
+
+		Eventually(func() string {
+			t, _ = tournaments.Tournament(t.ID)
+			return t.State
+		}).Should(Equal(protocol.StateFinished))
+		Expect(t.Matches[0].Winner).To(Equal("alice"))
+		Expect(finishedGames()).To(BeZero())
+	})
+
+	It("should hold thinkers to the digit count of the tournament", func() {
+		organiser := computerPlayer("organiser", 3)
+		t, _ := tournaments.Create(organiser, TournamentSettings{Format: "swiss", Digits: 3})
+		alice, bob := computerPlayer("alice", 3), computerPlayer("bob", 4)
+		hub.Add(alice)
+		hub.Add(bob)
+		tournaments.Join(t.ID, alice)
+		tournaments.Join(t.ID, bob)
+		tournaments.Start(t.ID, organiser)
+
+		Eventually(func() string {
+			t, _ = tournaments.Tournament(t.ID)
+			return t.State
+		}).Should(Equal(protocol.StateFinished))
+		Expect(t.Standings[0].ID).To(Equal("alice"))
+		Expect(t.Standings[0].Wins).To(Equal(1))
+	})
+
+	It("should wait for the players to finish their other games", func() {
+		hub.LimitGames(0, 1)
+		release := make(chan struct{})
+		thinker, guesser := computerPlayer("alice", 3), computerPlayer("dave", 3)
+		think := thinker.ThinkStub
+		thinker.ThinkStub = func() (int, error) {
+			<-release
+			return think()
+		}
+		Expect(hub.Enqueue(thinker, Queue{Role: RoleThinker, Digits: 3})).To(Succeed())
+		Expect(hub.Enqueue(guesser, Queue{Role: RoleGuesser, Digits: 3})).To(Succeed())
+		Eventually(thinker.ThinkCallCount).Should(Equal(1))
+
+		organiser := computerPlayer("organiser", 3)
+		t, _ := tournaments.Create(organiser, TournamentSettings{Format: "single-elimination", Digits: 3})
+		alice, bob := computerPlayer("alice", 3), computerPlayer("bob", 3)
+		hub.Add(alice)
+		hub.Add(bob)
+		tournaments.Join(t.ID, alice)
+		tournaments.Join(t.ID, bob)
+		tournaments.Start(t.ID, organiser)
+		Consistently(func() int {
+			return alice.ThinkCallCount() + alice.GuessCallCount()
+		}).Should(BeZero())
+
+		close(release)
+		Eventually(func() string {
+			t, _ = tournaments.Tournament(t.ID)
+			return t.State
+		}, 5*time.Second).Should(Equal(protocol.StateFinished))
+		Expect(t.Matches[0].Games).To(HaveLen(2))
+	})
+
+	It("should stop playing once the server is draining", func() {
+		organiser := computerPlayer("organiser", 3)
+		t, _ := tournaments.Create(organiser, TournamentSettings{Format: "round-robin", Digits: 3})
+		release := make(chan struct{})
+		thinking := make(chan struct{}, 2)
+		for _, id := range []string{"alice", "bob"} {
+			p := computerPlayer(id, 3)
+			think := p.ThinkStub
+			p.ThinkStub = func() (int, error) {
+				thinking <- struct{}{}
+				<-release
+				return think()
+			}
+			hub.Add(p)
+			tournaments.Join(t.ID, p)
+		}
+		tournaments.Start(t.ID, organiser)
+		Eventually(thinking).Should(Receive())
+
+		hub.Drain(time.Now().Add(time.Minute))
+		close(release)
+		Eventually(func() string {
+			t, _ = tournaments.Tournament(t.ID)
+			return t.State
+		}, 5*time.Second).Should(Equal(protocol.StateAborted))
+		Expect(t.Matches[0].Done).To(BeFalse())
+		Expect(thinking).To(BeEmpty())
+		Expect(finishedGames()).To(Equal(1))
+	})
+
+	It("should reject invalid settings", func() {
+		organiser := computerPlayer("organiser", 3)
+		_, err := tournaments.Create(organiser, TournamentSettings{Format: "ladder", Digits: 3})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+		_, err = tournaments.Create(organiser, TournamentSettings{Format: "swiss", Digits: 11})
+		Expect(code(err)).To(Equal(protocol.CodeInvalidSettings))
+	})
+
+	It("should reject unknown tournaments", func() {
+		_, err := tournaments.Join("t1", computerPlayer("alice", 3))
+		Expect(code(err)).To(Equal(protocol.CodeUnknownTournament))
+		_, err = tournaments.Start("t1", computerPlayer("alice", 3))
+		Expect(code(err)).To(Equal(protocol.CodeUnknownTournament))
+	})
+
+	It("should let only the organiser start a tournament once", func() {
+		organiser, alice := computerPlayer("organiser", 3), computerPlayer("alice", 3)
+		t, _ := tournaments.Create(organiser, TournamentSettings{Format: "swiss", Digits: 3})
+		tournaments.Join(t.ID, alice)
+
+		_, err := tournaments.Start(t.ID, organiser)
+		Expect(code(err)).To(Equal(protocol.CodeNotAllowed))
+		tournaments.Join(t.ID, computerPlayer("bob", 3))
+		_, err = tournaments.Start(t.ID, alice)
+		Expect(code(err)).To(Equal(protocol.CodeNotAllowed))
+
+		_, err = tournaments.Start(t.ID, organiser)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = tournaments.Join(t.ID, computerPlayer("carol", 3))
+		Expect(code(err)).To(Equal(protocol.CodeNotAllowed))
+		_, err = tournaments.Start(t.ID, organiser)
+		Expect(code(err)).To(Equal(protocol.CodeNotAllowed))
+	})
+
+	It("should cap the tournaments registering or running at once", func() {
+		tournaments.Limit(1, time.Hour)
+		organiser := computerPlayer("organiser", 3)
+		_, err := tournaments.Create(organiser, TournamentSettings{Format: "swiss", Digits: 3})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = tournaments.Create(organiser, TournamentSettings{Format: "swiss", Digits: 3})
+		Expect(code(err)).To(Equal(protocol.CodeTooManyTournaments))
+	})
+
+	It("should forget tournaments a while after they are over", func() {
+		tournaments.Limit(1, 10*time.Millisecond)
+		organiser := computerPlayer("organiser", 3)
+		t, _ := tournaments.Create(organiser, TournamentSettings{Format: "single-elimination", Digits: 3})
+		for _, id := range []string{"alice", "bob"} {
+			p := computerPlayer(id, 3)
+			hub.Add(p)
+			tournaments.Join(t.ID, p)
+		}
+		tournaments.Start(t.ID, organiser)
+
+		Eventually(tournaments.List, 5*time.Second).Should(BeEmpty())
+		_, ok := tournaments.Tournament(t.ID)
+		Expect(ok).To(BeFalse())
+		_, err := tournaments.Create(organiser, TournamentSettings{Format: "swiss", Digits: 3})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should list the tournaments", func() {
+		organiser := computerPlayer("organiser", 3)
+		t1, _ := tournaments.Create(organiser, TournamentSettings{Format: "swiss", Digits: 3})
+		t2, _ := tournaments.Create(organiser, TournamentSettings{Format: "round-robin", Digits: 4})
+		Expect(tournaments.List()).To(ConsistOf(t1, t2))
+	})
+})
@@ -0,0 +1,139 @@
+package cowbull
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Formats a Tree may be encoded in.
+const (
+	TreeFormatJSON = "json"
+	TreeFormatGob  = "gob"
+)
+
+// Tree is the decision tree of a strategy: what to guess first and, for
+// every possible score, what to guess next, till every number is guessed.
+type Tree struct {
+	// Digits is the digit count of the numbers.
+	Digits int `json:"digits"`
+	// Strategy is the name of the strategy the tree was built for.
+	Strategy string `json:"strategy"`
+	Root     *Node  `json:"root"`
+}
+
+// Node is a guess in a Tree.
+type Node struct {
+	Guess string `json:"guess"`
+	// Branches lead to the next guess for each score the guess may get,
+	// except for the winning one.
+	Branches []Branch `json:"branches,omitempty"`
+}
+
+// Branch is the way to go in a Tree once a guess gets a score.
+type Branch struct {
+	Cows  int   `json:"cows"`
+	Bulls int   `json:"bulls"`
+	Next  *Node `json:"next"`
+}
+
+// BuildTree builds the decision tree of the named strategy for n-digit
+// numbers. Unless the strategy is deterministic, so is the tree.
+func BuildTree(n int, strategy string) (*Tree, error) {
+	s, ok := Strategies[strategy]
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q", strategy)
+	}
+	numbers := Numbers(n)
+	if len(numbers) == 0 {
+		return nil, fmt.Errorf("invalid digit count %d", n)
+	}
+	return &Tree{
+		Digits:   n,
+		Strategy: strategy,
+		Root:     buildNode(numbers, s),
+	}, nil
+}
+
+func buildNode(candidates []string, s Strategy) *Node {
+	node := &Node{Guess: s(candidates)}
+	var parts [scoreCount][]string
+	for _, c := range candidates {
+		if c == node.Guess {
+			continue
+		}
+		k := scoreKey(computeCowsBulls(c, node.Guess))
+		parts[k] = append(parts[k], c)
+	}
+	for k, part := range parts {
+		if len(part) == 0 {
+			continue
+		}
+		node.Branches = append(node.Branches, Branch{
+			Cows:  k / 11,
+			Bulls: k % 11,
+			Next:  buildNode(part, s),
+		})
+	}
+	return node
+}
+
+// Next returns the node to continue with once the guess of n gets a score,
+// or nil if there is none.
+func (n *Node) Next(cows, bulls int) *Node {
+	for _, b := range n.Branches {
+		if b.Cows == cows && b.Bulls == bulls {
+			return b.Next
+		}
+	}
+	return nil
+}
+
+// Turns returns how many numbers the tree guesses in how many turns.
+func (t *Tree) Turns() map[int]int {
+	turns := make(map[int]int)
+	var walk func(n *Node, depth int)
+	walk = func(n *Node, depth int) {
+		turns[depth]++
+		for _, b := range n.Branches {
+			walk(b.Next, depth+1)
+		}
+	}
+	if t.Root != nil {
+		walk(t.Root, 1)
+	}
+	return turns
+}
+
+// Encode writes the tree to w in format.
+func (t *Tree) Encode(w io.Writer, format string) error {
+	switch format {
+	case TreeFormatJSON:
+		return json.NewEncoder(w).Encode(t)
+	case TreeFormatGob:
+		return gob.NewEncoder(w).Encode(t)
+	}
+	return fmt.Errorf("unknown tree format %q", format)
+}
+
+// DecodeTree reads a tree in format from r.
+func DecodeTree(r io.Reader, format string) (*Tree, error) {
+	t := new(Tree)
+	var err error
+	switch format {
+	case TreeFormatJSON:
+		err = json.NewDecoder(r).Decode(t)
+	case TreeFormatGob:
+		err = gob.NewDecoder(r).Decode(t)
+	default:
+		return nil, fmt.Errorf("unknown tree format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Root == nil {
+		return nil, fmt.Errorf("tree has no root")
+	}
+	return t, nil
+}
@@ -0,0 +1,98 @@
+package cowbull_test
+
+import (
+	"bytes"
+
+	. "github.com/Bo0mer/cowbull"
+	"github.com/Bo0mer/cowbull/game"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tree", func() {
+	var tree *Tree
+
+	BeforeEach(func() {
+		var err error
+		tree, err = BuildTree(3, "minmax")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	It("should guess every number exactly once", func() {
+		seen := make(map[string]bool)
+		var walk func(n *Node)
+		walk = func(n *Node) {
+			Ω(seen).ShouldNot(HaveKey(n.Guess))
+			seen[n.Guess] = true
+			for _, b := range n.Branches {
+				walk(b.Next)
+			}
+		}
+		walk(tree.Root)
+		Ω(seen).Should(HaveLen(720))
+	})
+
+	It("should count the numbers by turns", func() {
+		var numbers int
+		for _, n := range tree.Turns() {
+			numbers += n
+		}
+		Ω(numbers).Should(Equal(720))
+		Ω(tree.Turns()[1]).Should(Equal(1))
+	})
+
+	It("should reject unknown strategies and digit counts", func() {
+		_, err := BuildTree(3, "psychic")
+		Ω(err).Should(HaveOccurred())
+		_, err = BuildTree(0, "first")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	DescribeTable("should survive encoding",
+		func(format string) {
+			var buf bytes.Buffer
+			Ω(tree.Encode(&buf, format)).Should(Succeed())
+			decoded, err := DecodeTree(&buf, format)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(decoded).Should(Equal(tree))
+		},
+		Entry("json", TreeFormatJSON),
+		Entry("gob", TreeFormatGob),
+	)
+
+	It("should reject unknown formats", func() {
+		Ω(tree.Encode(new(bytes.Buffer), "xml")).ShouldNot(Succeed())
+		_, err := DecodeTree(new(bytes.Buffer), "xml")
+		Ω(err).Should(HaveOccurred())
+	})
+
+	Describe("played by AIGuesser", func() {
+		It("should guess as the tree says", func() {
+			thinker := NewLocalThinker(3, func(n int) []int {
+				return []int{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}
+			})
+			g := game.New(thinker, NewTreeGuesser(tree))
+			Ω(g.Play()).Should(Succeed())
+
+			node := tree.Root
+			for _, m := range g.Result().Moves {
+				Ω(node).ShouldNot(BeNil())
+				Ω(m.Guess).Should(Equal(node.Guess))
+				node = node.Next(m.Cows, m.Bulls)
+			}
+			Ω(g.Result().Secret).Should(Equal("987"))
+		})
+
+		It("should keep playing once off the tree", func() {
+			g := NewTreeGuesser(tree)
+			// Someone else guessed 987, which the tree does not foresee.
+			Ω(g.Tell("987", 0, 0)).Should(Succeed())
+			guess, err := g.Guess(3)
+			Ω(err).ShouldNot(HaveOccurred())
+			cows, bulls := Score("987", guess)
+			Ω(cows + bulls).Should(BeZero())
+		})
+	})
+})